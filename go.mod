@@ -3,12 +3,20 @@ module epub
 go 1.24.2
 
 require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/andybalholm/cascadia v1.3.3
+	github.com/gen2brain/avif v0.4.4
 	github.com/go-shiori/go-epub v1.2.1
+	golang.org/x/image v0.27.0
 	golang.org/x/net v0.39.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/ebitengine/purego v0.8.3 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/gofrs/uuid/v5 v5.0.0 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
 	github.com/vincent-petithory/dataurl v1.0.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
 )
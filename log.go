@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// logLevel returns the lowest level logged given -quiet, -verbose and
+// -debug: errors only, everything, or by default what the build does, e.g.
+// each page fetched and the EPUB written. The most verbose flag given wins;
+// -debug also records where each record was logged from.
+func logLevel(quiet, verbose, debug bool) slog.Level {
+	switch {
+	case debug, verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// newLogHandler returns a handler writing records of level and up to w in
+// format, text or json, with the source line of each if addSource is set.
+func newLogHandler(w io.Writer, format string, level slog.Level, addSource bool) (slog.Handler, error) {
+	hopts := &slog.HandlerOptions{Level: level, AddSource: addSource}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, hopts), nil
+	case "json":
+		return slog.NewJSONHandler(w, hopts), nil
+	}
+	return nil, fmt.Errorf("invalid log format '%s' (want text or json)", format)
+}
@@ -1,694 +1,626 @@
 package main
 
 import (
-	"bytes"
-	"errors"
+	"context"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
-	"path"
+	"os/signal"
 	"strings"
+	"time"
 
-	"github.com/go-shiori/go-epub"
-	"golang.org/x/net/html"
+	"epub/pkg/converter"
 )
 
-const fetchURL = "https://www.gutenberg.org/cache/epub/1184/pg1184-images.html"
-const outputEPUB = "output.epub"
-const tempImageDir = "temp_images"
-const outputHTML = "output.html"
+// Defaults of the command line flags.
+const (
+	defaultURL       = "https://www.gutenberg.org/cache/epub/1184/pg1184-images.html"
+	defaultOutput    = "output.epub"
+	defaultImageDir  = "temp_images"
+	defaultHTMLCache = "output.html"
+	defaultTitle     = "Count of Monte Cristo"
+	defaultAuthor    = "ritikprajapat21"
+)
 
 func main() {
-	// Fetch or load the HTML content
-	body, baseURL, err := fetchOrLoadHTML(fetchURL, outputHTML)
-	if err != nil {
-		log.Fatalf("Error fetching or loading HTML: %v", err)
+	if err := run(); err != nil {
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
+}
 
-	// Parse the HTML
-	doc, err := html.Parse(bytes.NewReader(body))
-	if err != nil {
-		log.Fatalf("Error parsing HTML: %v", err)
+// run parses the command line and does what it asks.
+func run() error {
+	sourceURL := flag.String("url", defaultURL, "page to convert")
+	outputPath := flag.String("out", defaultOutput, "where to write the EPUB")
+	title := flag.String("title", "", "book title (default from -meta or the page's metadata)")
+	author := flag.String("author", "", "book author (default from -meta or the page's metadata)")
+	lang := flag.String("lang", "", "book language, e.g. en or pt-BR (default from -meta, or the page's <html lang>)")
+	description := flag.String("description", "", "book description (default from -meta, or the page's <meta name=\"description\">)")
+	publisher := flag.String("publisher", "", "book publisher")
+	identifier := flag.String("identifier", "", "unique book identifier: an ISBN, a UUID or any URN or URL (default a random UUID)")
+	pubDate := flag.String("pubdate", "", "publication date, as YYYY, YYYY-MM or YYYY-MM-DD")
+	imageDir := flag.String("image-dir", defaultImageDir, "directory to keep downloaded images in")
+	htmlCacheFlag := flag.String("html-cache", defaultHTMLCache, "local copy of the page, used instead of fetching when present; empty to always fetch (default no cache with -url)")
+	cacheDir := flag.String("cache-dir", "", "directory caching fetched pages and stylesheets by URL, revalidated with the server on each run; replaces -html-cache")
+	statePath := flag.String("state", "", "file recording the pages fetched, so an interrupted build run again with it resumes instead of fetching them again; removed once the EPUB is written")
+	debugHTMLDir := flag.String("debug-html", "", "directory to dump each section's generated XHTML into")
+	metaFile := flag.String("meta", "", "sidecar JSON file with book metadata (title, author, language, identifiers, series, description, subjects, creators, publisher, date)")
+	titleCaseFlag := flag.String("title-case", string(converter.TitleCaseNone), "re-case extracted section titles: none, title or sentence")
+	timeout := flag.Duration("timeout", 0, "abort the build if it takes longer than this (e.g. 5m); 0 means no limit")
+	requestTimeout := flag.Duration("request-timeout", converter.DefaultRequestTimeout, "give up on an HTTP request, or one attempt at it, after this long")
+	retries := flag.Int("retries", converter.DefaultRetries, "times to retry an HTTP request that fails, times out or gets a server error")
+	retryBackoff := flag.Duration("retry-backoff", converter.DefaultRetryBackoff, "pause before the first retry of a request, doubled before each later one")
+	userAgent := flag.String("user-agent", converter.DefaultUserAgent(), "User-Agent header sent with every request")
+	requestDelay := flag.Duration("delay", 0, "least time between requests to the same host, e.g. 500ms")
+	rate := flag.Float64("rate", 0, "most requests per second to the same host (same as -delay 1s/rate)")
+	cookieFile := flag.String("cookies", "", "Netscape cookies.txt file or browser JSON cookie export whose cookies are sent with requests")
+	var headers headerList
+	flag.Var(&headers, "header", "add a header, as \"Name: value\", to requests for the pages converted; repeatable")
+	basicAuth := flag.String("user", "", "user:password for HTTP basic authentication to the pages converted")
+	bearerToken := flag.String("token", "", "bearer token for authentication to the pages converted (default $EPUB_TOKEN)")
+	proxyFlag := flag.String("proxy", "", "HTTP, HTTPS or SOCKS5 proxy for every request, e.g. socks5://127.0.0.1:9050 (default $HTTPS_PROXY/$HTTP_PROXY)")
+	ignoreRobots := flag.Bool("ignore-robots", false, "follow crawled links even where the site's robots.txt disallows them")
+	screen := flag.String("screen", "", "downscale images to fit a reader screen: kindle, tablet or phone")
+	maxImage := flag.Int("max-image", 0, "downscale images to fit within this many pixels square; with -screen, the tighter limit applies")
+	convertImagesFlag := flag.String("convert-images", string(converter.ImageFormatKeep), "what images readers may not display (WebP, AVIF) become: keep, jpeg or png")
+	imageQuality := flag.Int("image-quality", 0, "re-encode JPEG images at this quality (1-100) where that makes them smaller")
+	cover := flag.String("cover", "", "cover image (local path or URL)")
+	thumbnailSize := flag.Int("cover-thumbnail", 0, "make a cover thumbnail fitting in this many pixels square and report it in the summary")
+	embedThumbnail := flag.Bool("embed-cover-thumbnail", false, "also embed the cover thumbnail in the EPUB")
+	generateCover := flag.Bool("generate-cover", false, "if there's no -cover, generate an SVG cover showing the title and author")
+	coverBackground := flag.String("cover-background", "#2b4c7e", "background color of a generated cover")
+	coverForeground := flag.String("cover-foreground", "#ffffff", "text color of a generated cover")
+	coverFont := flag.String("cover-font", "serif", "font family of a generated cover")
+	altTextFile := flag.String("alt-text", "", "JSON file mapping image URLs to replacement alt text")
+	followRefresh := flag.Bool("follow-refresh", false, "if the page is a meta refresh redirect, convert the page it points to")
+	commentsFlag := flag.String("comments", string(converter.CommentsDrop), "HTML comments: drop, keep (as XHTML comments) or aside (as visible notes)")
+	archive := flag.String("archive", "", "convert a .zip or .tar.gz of HTML chapters (sorted by path) instead of the URL")
+	crawl := flag.Int("crawl", 0, "follow \"next\" links from the URL to up to this many further pages, each starting a chapter")
+	nextLinkFlag := flag.String("next-link", "", "with -crawl, the \"next\" link or an element containing it, as tag, .class or tag.class (default rel=next or \"Next\" text)")
+	urlsFile := flag.String("urls-file", "", "convert the pages listed in this file, one URL per line, in order and each starting a chapter, instead of the URL")
+	separator := flag.String("separator", "", "insert a divider section between merged sources with this label template, e.g. 'Part {{.Index}}: {{.Title}}'")
+	batchFile := flag.String("batch", "", "build every book listed in this JSON file; re-runs skip books already built")
+	navTitle := flag.String("nav-title", "", "heading of the table of contents page (default \"Table of Contents\")")
+	contentsPage := flag.Bool("toc-page", false, "insert a table of contents page at the front of the book")
+	prune := flag.Bool("prune", false, "drop embedded images and stylesheets that nothing in the book refers to")
+	orderFile := flag.String("reading-order", "", "file listing section titles or source names, one per line, in reading order")
+	keepEmpty := flag.Bool("keep-empty-blocks", false, "keep paragraphs with nothing visible, e.g. only zero-width spaces, at the start and end of sections")
+	headingsFlag := flag.String("headings", "", "elements that start a section, outermost first, as a comma-separated list of tag, .class or tag.class; later ones nest in the table of contents (default h3)")
+	sectionMarkerFlag := flag.String("section-marker", "", "element that also starts a new section, as tag, .class or tag.class (e.g. div.chapter)")
+	selectFlag := flag.String("select", "", "CSS selector of the content to keep, e.g. article.main; everything else on the page is dropped")
+	removeFlag := flag.String("remove", "", "CSS selector of elements to drop with their contents, e.g. \".ads, .share-buttons, nav\"")
+	recipeDir := flag.String("recipes", "", "directory of JSON site recipes (content, title, next_page, strip and chapter_heading rules by domain), used before the built-in ones")
+	noRecipes := flag.Bool("no-builtin-recipes", false, "don't apply the built-in recipes for Project Gutenberg, Wikipedia, WordPress.com, Blogger and Substack")
+	skipFlag := flag.String("skip", strings.Join(converter.DefaultSkipElements, ","), "comma-separated elements to leave out with their contents; empty to keep everything")
+	inMemory := flag.Bool("memory", false, "build in memory, without the HTML cache or downloaded image files; only the EPUB is written")
+	indexPath := flag.String("index", "", "also write a JSON index of the sections, with image thumbnails, to this file")
+	workers := flag.Int("workers", 0, "sections to prepare at once (default the number of CPUs)")
+	imageWorkers := flag.Int("image-workers", converter.DefaultImageWorkers, "images to download at once")
+	pageWorkers := flag.Int("page-workers", converter.DefaultPageWorkers, "pages of -urls-file or a project to fetch at once")
+	attribution := flag.Bool("attribution", false, "append a section crediting the source, with the -license text")
+	license := flag.String("license", "", "license text for the attribution section")
+	leadingTitle := flag.String("leading-title", "", "title of the content before the first heading (default the page's <title>, or \"Introduction\")")
+	leadingFlag := flag.String("leading", "keep", "content before the first heading: keep, frontmatter (keep, marked as front matter), discard or merge (into the first section)")
+	frontMatter := flag.Bool("front-matter", false, "mark the content before the first heading as front matter (same as -leading frontmatter)")
+	colophon := flag.Bool("colophon", false, "append a colophon with build information")
+	imageTextFlag := flag.String("image-text", "none", "also write image alt or caption text next to images: none, visible or hidden")
+	minText := flag.Int("min-text", 0, "fail if fewer characters of text than this are extracted (e.g. from a JavaScript-rendered page)")
+	subtitlesFlag := flag.String("subtitles", "none", "treat a lower heading right after a section heading as its subtitle: none, label (add it to the title) or styled")
+	presentationalFlag := flag.String("presentational", "strip", "deprecated align and bgcolor attributes: strip, or css to keep them as inline styles")
+	dedupImages := flag.Bool("dedup-images", false, "replace resized copies of an image, e.g. thumbnails, with the largest one")
+	dedupThreshold := flag.Int("dedup-threshold", converter.DefaultDedupThreshold, "how many of the 64 perceptual hash bits may differ between copies of an image")
+	chapterDir := flag.String("chapters", "", "also write each section as its own EPUB into this directory")
+	partLabel := flag.String("part-label", "Part", "label numbering the chapter EPUBs' titles, e.g. \"Part 3: The Harbour\"")
+	a11yReport := flag.String("a11y-report", "", "write a report of accessibility problems in the source to this file (JSON if it ends in .json)")
+	estimate := flag.Bool("estimate", false, "print an estimate of the EPUB's size without building it")
+	footnotesFlag := flag.String("footnotes", "keep", "footnotes: keep (in place), popup (popup notes at the end of the section that references them) or endnotes (a Notes section at the end, with links back)")
+	endnotes := flag.Bool("endnotes", false, "move footnotes into a Notes section at the end (same as -footnotes endnotes)")
+	compressionFlag := flag.String("compression", "default", "zip compression: default, auto (store images, deflate text), store or deflate")
+	compressionLevel := flag.Int("compression-level", 0, "deflate level, 1 (fastest) to 9 (smallest); 0 for the default")
+	metricsFile := flag.String("metrics", "", "write per-phase build timings as JSON to this file, or - for stderr")
+	tableImages := flag.Bool("table-images", false, "embed tables that are too wide or have nested tables as images, with their text as alt text")
+	tableImageColumns := flag.Int("table-image-columns", converter.DefaultTableImageColumns, "how many columns a table may have before -table-images embeds it as an image")
+	spreadFlag := flag.String("spread", "", "rendition:spread of the book: none, landscape, both or auto (default unset)")
+	flowFlag := flag.String("flow", "", "rendition:flow of the book: paginated, scrolled-continuous, scrolled-doc or auto (default unset)")
+	gutenberg := flag.Bool("gutenberg", false, "strip Project Gutenberg's header and license, take the title and author from the header and split chapters at its chapter anchors")
+	normalizeHeadings := flag.Bool("normalize-headings", false, "renumber each page's heading levels to close gaps, e.g. h1, h3, h5 become h1, h2, h3, before -headings picks the levels that start sections")
+	var creators creatorList
+	flag.Var(&creators, "creator", "add a creator besides the author, as Name or Name:role with a MARC relator code or author, translator, illustrator or editor; repeatable")
+	singleFile := flag.Bool("single-file", false, "put the whole book in one file, with a table of contents pointing into it")
+	embedCSS := flag.Bool("embed-css", false, "embed the page's stylesheets and the images they reference")
+	addr := flag.String("addr", ":8080", "address the serve command listens on")
+	serveToken := flag.String("serve-token", "", "bearer token the serve command requires of requests to /convert; none if empty (default $EPUB_SERVE_TOKEN)")
+	maxBuilds := flag.Int("max-builds", defaultMaxBuilds, "conversions the serve command runs at once; requests beyond them are turned away with 503")
+	allowPrivate := flag.Bool("allow-private", false, "let the serve command fetch from loopback, private and link-local addresses, e.g. to convert pages on the local network")
+	showProgress := flag.Bool("progress", true, "show a progress bar on stderr while building, if it is a terminal")
+	quiet := flag.Bool("quiet", false, "only log errors, and show no progress bar")
+	verbose := flag.Bool("verbose", false, "also log each request made and image loaded")
+	debug := flag.Bool("debug", false, "log everything, as -verbose does, with the source line of each record")
+	logFormat := flag.String("log-format", "text", "log record format on stderr: text or json")
+	validate := flag.Bool("validate", false, "check the finished EPUB the way EPUBCheck does, failing if it finds problems")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %[1]s [flags] [HTML file, directory or glob pattern ...]\n       %[1]s build [flags] book.yaml|book.toml|book.json\n       %[1]s serve [flags]\n\nLocal files are converted instead of the URL, each as a chapter; a directory's\nHTML files are taken in the order its manifest.txt lists them, or else by path.\nbuild builds the book a YAML, TOML or JSON book manifest describes; flags\noverride it.\nserve converts the URLs POSTed as JSON to /convert on -addr; flags set the\ndefaults of each conversion.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	args, command := os.Args[1:], ""
+	if len(args) > 0 && (args[0] == "build" || args[0] == "serve") {
+		args, command = args[1:], args[0]
+	}
+	flag.CommandLine.Parse(args) // Exits on error
+	var paths []string
+	if command == "" {
+		paths = flag.Args()
 	}
 
-	// Create EPUB
-	e, err := epub.NewEpub("Count of Monte Cristo")
+	var bar *progressBar
+	if *showProgress && !*quiet && *logFormat == "text" && isTerminal(os.Stderr) {
+		bar = &progressBar{w: os.Stderr}
+		defer bar.clear()
+	}
+	var logOut io.Writer = os.Stderr
+	if bar != nil {
+		logOut = bar
+	}
+	handler, err := newLogHandler(logOut, *logFormat, logLevel(*quiet, *verbose, *debug), *debug)
 	if err != nil {
-		log.Fatalf("Error creating EPUB: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-	e.SetAuthor("ritikprajapat21") // You can change this
+	slog.SetDefault(slog.New(handler))
 
-	// Create temporary directory for images
-	if err := os.MkdirAll(tempImageDir, 0755); err != nil {
-		log.Fatalf("Error creating temp image directory: %v", err)
+	titleCase, err := converter.ParseTitleCaseMode(*titleCaseFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-	// defer os.RemoveAll(tempImageDir) // Clean up temp directory
-
-	// Extract content and images
-	var currentSection strings.Builder
-	var sectionTitle string = "Chapter 1" // Default title
-
-	var extractText func(*html.Node)
-	extractText = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			// Basic section handling (can be improved based on actual HTML structure)
-			if n.Data == "h3" {
-				if currentSection.Len() > 0 {
-					// Add previous section to EPUB
-					_, err := e.AddSection(currentSection.String(), sectionTitle, "", "")
-					if err != nil {
-						log.Printf("Warning: Could not add section '%s': %v", sectionTitle, err)
-					}
-					currentSection.Reset() // Start new section
-				}
-				sectionTitle = getText(n) // Get title from heading
-				if sectionTitle == "" {
-					sectionTitle = "Unnamed Section"
-				}
-			}
-
-			// Handle images
-			if n.Data == "img" {
-				for _, attr := range n.Attr {
-					if attr.Key == "src" {
-						imgURL := attr.Val
-						// Resolve relative URLs
-						absoluteImgURL, err := baseURL.Parse(imgURL)
-						if err != nil {
-							log.Printf("Warning: Could not parse image URL '%s': %v", imgURL, err)
-							continue
-						}
-
-						// Download or load image
-						imgPath, err := fetchOrLoadImage(absoluteImgURL.String(), tempImageDir)
-						if err != nil {
-							log.Printf("Warning: Could not download or load image '%s': %v", absoluteImgURL.String(), err)
-							continue
-						}
-
-						// Add image to EPUB and get internal path
-						epubImgPath, err := e.AddImage(imgPath, "")
-						if err != nil {
-							log.Printf("Warning: Could not add image '%s' to EPUB: %v", imgPath, err)
-							// Don't remove the local file yet if adding failed
-							continue
-						}
-
-						// Append img tag to current section content
-						currentSection.WriteString(fmt.Sprintf(`<p><img src="%s" alt="Image"/></p>`, epubImgPath))
-						// No need to remove imgPath here, defer os.RemoveAll(tempImageDir) handles cleanup
-						break // Found src, move to next node
-					}
-				}
-			}
-		} else if n.Type == html.TextNode {
-			// Append text content, trimming whitespace
-			trimmedData := strings.TrimSpace(n.Data)
-			if trimmedData != "" {
-				// Basic paragraph wrapping
-				if !strings.HasSuffix(currentSection.String(), "</p>") && currentSection.Len() > 0 {
-					// If the last thing wasn't a closing p tag, start a new one.
-					// This is a simplification; real HTML structure might need more complex handling.
-					currentSection.WriteString("<p>")
-				} else if currentSection.Len() == 0 {
-					// currentSection.WriteString("<p>")
-				}
-				currentSection.WriteString("<p>" + html.EscapeString(trimmedData) + " ") // Add space between text nodes
-				// Add closing tag tentatively; might be overwritten by next element or text
-				if !strings.HasSuffix(currentSection.String(), "</p>") {
-					currentSection.WriteString("</p>")
-				}
-			}
+	comments, err := converter.ParseCommentMode(*commentsFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	imageText, err := converter.ParseImageTextMode(*imageTextFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	subtitles, err := converter.ParseSubtitleMode(*subtitlesFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	presentational, err := converter.ParsePresentationMode(*presentationalFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	leading, err := converter.ParseLeadingMode(*leadingFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	if *frontMatter {
+		leading = converter.LeadingFrontMatter
+	}
+	footnotes, err := converter.ParseFootnoteMode(*footnotesFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	if *endnotes {
+		footnotes = converter.FootnotesEndnotes
+	}
+	compression, err := converter.ParseCompressionMode(*compressionFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+	var sectionMarker converter.SectionMarker
+	if *sectionMarkerFlag != "" {
+		sectionMarker, err = converter.ParseSectionMarker(*sectionMarkerFlag)
+		if err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
-
-		// Recursively process child nodes
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			extractText(c)
+	}
+	var selectContent, removeContent converter.Selector
+	if *selectFlag != "" {
+		selectContent, err = converter.ParseSelector(*selectFlag)
+		if err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
 	}
-
-	// Find the body node to start extraction
-	var bodyNode *html.Node
-	var findBody func(*html.Node)
-	findBody = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "body" {
-			bodyNode = n
-			return
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			findBody(c)
-			if bodyNode != nil {
-				return
-			}
+	if *removeFlag != "" {
+		removeContent, err = converter.ParseSelector(*removeFlag)
+		if err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
 	}
-	findBody(doc)
-
-	if bodyNode != nil {
-		extractText(bodyNode)
-	} else {
-		log.Println("Warning: Could not find body node in HTML, extracting from root.")
-		extractText(doc) // Fallback to extracting from root if body not found
+	if *rate < 0 {
+		return fmt.Errorf("error parsing flags: -rate must not be negative")
 	}
-
-	// Add the last section if it has content
-	if currentSection.Len() > 0 {
-		_, err := e.AddSection(currentSection.String(), sectionTitle, "", "")
-		if err != nil {
-			log.Printf("Warning: Could not add final section '%s': %v", sectionTitle, err)
+	if *rate > 0 {
+		*requestDelay = max(*requestDelay, time.Duration(float64(time.Second) / *rate))
+	}
+	var proxy *url.URL
+	if *proxyFlag != "" {
+		if proxy, err = converter.ParseProxy(*proxyFlag); err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
 	}
-
-	// Write EPUB file
-	err = e.Write(outputEPUB)
-	if err != nil {
-		log.Fatalf("Error writing EPUB file: %v", err)
+	var cookies []*http.Cookie
+	if *cookieFile != "" {
+		if cookies, err = converter.LoadCookies(*cookieFile); err != nil {
+			return fmt.Errorf("error loading cookies: %w", err)
+		}
 	}
-
-	fmt.Printf("Successfully created EPUB: %s\n", outputEPUB)
-}
-
-// fetchOrLoadHTML fetches the HTML content from a given URL if the local file doesn't exist
-// or loads it from the local file. It returns the body content as bytes and the base URL.
-func fetchOrLoadHTML(urlStr, filePath string) ([]byte, *url.URL, error) {
-	content, err := os.ReadFile(filePath)
-	if err == nil {
-		baseURL, err := url.Parse(urlStr)
+	var authorization string
+	if *bearerToken == "" {
+		*bearerToken = os.Getenv("EPUB_TOKEN")
+	}
+	switch {
+	case *basicAuth != "" && *bearerToken != "":
+		return fmt.Errorf("error parsing flags: -user and -token can't be used together")
+	case *basicAuth != "":
+		user, password, ok := strings.Cut(*basicAuth, ":")
+		if !ok {
+			return fmt.Errorf("error parsing flags: -user must be user:password")
+		}
+		authorization = converter.BasicAuth(user, password)
+	case *bearerToken != "":
+		authorization = converter.BearerAuth(*bearerToken)
+	}
+	var recipes []converter.Recipe
+	if *recipeDir != "" {
+		if recipes, err = converter.LoadRecipes(*recipeDir); err != nil {
+			return fmt.Errorf("error loading recipes: %w", err)
+		}
+	}
+	if !*noRecipes {
+		recipes = append(recipes, converter.BuiltinRecipes...)
+	}
+	var sectionHeadings []converter.SectionMarker
+	if *headingsFlag != "" {
+		sectionHeadings, err = converter.ParseSectionHeadings(*headingsFlag)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse base URL: %w", err)
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
-		return content, baseURL, nil
 	}
-	if !errors.Is(err, os.ErrNotExist) {
-		return nil, nil, fmt.Errorf("failed to read local HTML file '%s': %w", filePath, err)
+	var nextLink converter.SectionMarker
+	if *nextLinkFlag != "" {
+		nextLink, err = converter.ParseNextLink(*nextLinkFlag)
+		if err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
+		}
 	}
-
-	// File doesn't exist, fetch from URL
-	resp, err := http.Get(urlStr)
+	spread, err := converter.ParseRenditionSpread(*spreadFlag)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get URL '%s': %w", urlStr, err)
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("bad status for URL '%s': %s", urlStr, resp.Status)
+	flow, err := converter.ParseRenditionFlow(*flowFlag)
+	if err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-
-	body, err := io.ReadAll(resp.Body)
+	skipElements, err := converter.ParseSkipElements(*skipFlag)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read response body from '%s': %w", urlStr, err)
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-
-	// Save the fetched content to the local file
-	err = os.WriteFile(filePath, body, 0644)
+	maxImageSize, err := converter.ParseScreenPreset(*screen)
 	if err != nil {
-		log.Printf("Warning: Failed to save HTML to '%s': %v", filePath, err)
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
-
-	baseURL, err := url.Parse(urlStr)
+	if *maxImage > 0 {
+		if maxImageSize.X == 0 || *maxImage < maxImageSize.X {
+			maxImageSize.X = *maxImage
+		}
+		if maxImageSize.Y == 0 || *maxImage < maxImageSize.Y {
+			maxImageSize.Y = *maxImage
+		}
+	}
+	convertImages, err := converter.ParseImageFormat(*convertImagesFlag)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse base URL '%s': %w", urlStr, err)
+		return fmt.Errorf("error parsing flags: %w", err)
 	}
 
-	return body, baseURL, nil
-}
-
-// fetchOrLoadImage downloads an image from a URL and saves it to a temporary directory if it doesn't exist locally.
-// It returns the path to the (newly downloaded or existing) image file.
-func fetchOrLoadImage(imgURL string, dir string) (string, error) {
-	parsedURL, err := url.Parse(imgURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse image URL '%s': %w", imgURL, err)
+	var meta converter.Metadata
+	if !flagSet("url") && *archive == "" && *urlsFile == "" && *batchFile == "" && !*gutenberg && command == "" && flag.NArg() == 0 {
+		meta = converter.Metadata{Title: defaultTitle, Author: defaultAuthor}
 	}
-	filename := path.Base(parsedURL.Path)
-	if filename == "." || filename == "/" { // Handle cases where path is minimal
-		filename = "image_" + strings.ReplaceAll(parsedURL.Host, ".", "_") + ".tmp" // Create a fallback name
+	if *metaFile != "" {
+		sidecar, err := converter.LoadMetadata(*metaFile)
+		if err != nil {
+			return fmt.Errorf("error loading metadata: %w", err)
+		}
+		meta = meta.Merge(sidecar)
 	}
-	// Ensure filename is safe (basic sanitization)
-	safeFilename := strings.Map(func(r rune) rune {
-		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' {
-			return '_'
+	flagMeta := converter.Metadata{Title: *title, Author: *author, Creators: creators, Language: *lang, Description: *description, Publisher: *publisher}
+	if *identifier != "" {
+		id, err := converter.ParseIdentifier(*identifier)
+		if err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
+		}
+		flagMeta.Identifiers = append([]string{id}, meta.Identifiers...) // The sidecar's become extra ones
+	}
+	if *pubDate != "" {
+		if flagMeta.Date, err = converter.ParseDate(*pubDate); err != nil {
+			return fmt.Errorf("error parsing flags: %w", err)
 		}
-		return r
-	}, filename)
+	}
+	meta = meta.Merge(flagMeta)
 
-	filepath := path.Join(dir, safeFilename)
+	htmlCache := *htmlCacheFlag
+	if flagSet("url") && !flagSet("html-cache") {
+		htmlCache = "" // The default cache holds the default page
+	}
 
-	// Check if the image already exists
-	if _, err := os.Stat(filepath); err == nil {
-		return filepath, nil // Image exists, return the path
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return "", fmt.Errorf("failed to check if image exists at '%s': %w", filepath, err)
+	var altText map[string]string
+	if *altTextFile != "" {
+		altText, err = converter.LoadAltText(*altTextFile)
+		if err != nil {
+			return fmt.Errorf("error loading alt text: %w", err)
+		}
 	}
 
-	// Image doesn't exist, download it
-	resp, err := http.Get(imgURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to get image URL '%s': %w", imgURL, err)
+	var readingOrder []string
+	if *orderFile != "" {
+		readingOrder, err = converter.LoadReadingOrder(*orderFile)
+		if err != nil {
+			return fmt.Errorf("error loading reading order: %w", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status for image '%s': %s", imgURL, resp.Status)
+	if *retries == 0 {
+		*retries = -1 // Zero retries in Options means the default
 	}
 
-	// Create the directory if it doesn't exist (should already be created in main, but just in case)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	var sourceURLs []string
+	if *urlsFile != "" {
+		sourceURLs, err = converter.LoadURLs(*urlsFile)
+		if err != nil {
+			return fmt.Errorf("error loading URL list: %w", err)
+		}
 	}
 
-	// Create the file
-	out, err := os.Create(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create image file '%s': %w", filepath, err)
+	opts := converter.Options{
+		SourceURL:     *sourceURL,
+		SourceURLs:    sourceURLs,
+		Archive:       *archive,
+		Paths:         paths,
+		HTMLCache:     htmlCache,
+		CacheDir:      *cacheDir,
+		StatePath:     *statePath,
+		OutputPath:    *outputPath,
+		ImageDir:      *imageDir,
+		DebugHTMLDir:  *debugHTMLDir,
+		TitleCase:     titleCase,
+		SectionMarker: sectionMarker,
+		SkipElements:  skipElements,
+		Select:        selectContent,
+		Remove:        removeContent,
+		Recipes:       recipes,
+		Subtitles:     subtitles,
+		MaxImageSize:  maxImageSize,
+		Metadata:      meta,
+		Timeout:       *timeout,
+
+		CoverImage:     *cover,
+		ThumbnailSize:  *thumbnailSize,
+		EmbedThumbnail: *embedThumbnail,
+
+		AltText:       altText,
+		ImageText:     imageText,
+		FollowRefresh: *followRefresh,
+		Comments:      comments,
+
+		SourceSeparator:  *separator,
+		EmbedCSS:         *embedCSS,
+		PruneResources:   *prune,
+		ReadingOrder:     readingOrder,
+		KeepEmptyBlocks:  *keepEmpty,
+		InMemory:         *inMemory,
+		IndexPath:        *indexPath,
+		Workers:          *workers,
+		Attribution:      *attribution,
+		License:          *license,
+		LeadingTitle:     *leadingTitle,
+		Leading:          leading,
+		Colophon:         *colophon,
+		MinText:          *minText,
+		Presentational:   presentational,
+		DedupImages:      *dedupImages,
+		DedupThreshold:   *dedupThreshold,
+		Footnotes:        footnotes,
+		ChapterDir:       *chapterDir,
+		PartLabel:        *partLabel,
+		Compression:      compression,
+		CompressionLevel: *compressionLevel,
+		NavTitle:         *navTitle,
+
+		AccessibilityReport: *a11yReport,
+		SingleFile:          *singleFile,
+		TableImages:         *tableImages,
+		TableImageColumns:   *tableImageColumns,
+		Spread:              spread,
+		Flow:                flow,
+		NormalizeHeadings:   *normalizeHeadings,
+		Crawl:               *crawl,
+		NextLink:            nextLink,
+		SectionHeadings:     sectionHeadings,
+		ImageWorkers:        *imageWorkers,
+		PageWorkers:         *pageWorkers,
+		RequestTimeout:      *requestTimeout,
+		Retries:             *retries,
+		RetryBackoff:        *retryBackoff,
+		UserAgent:           *userAgent,
+		RequestDelay:        *requestDelay,
+		IgnoreRobots:        *ignoreRobots,
+		Cookies:             cookies,
+		Headers:             headers.header,
+		Authorization:       authorization,
+		Proxy:               proxy,
+		ConvertImages:       convertImages,
+		ImageQuality:        *imageQuality,
+		ContentsPage:        *contentsPage,
+		Gutenberg:           *gutenberg,
+		Validate:            *validate,
 	}
-	defer out.Close()
+	if *generateCover {
+		opts.CoverStyle = &converter.CoverStyle{Background: *coverBackground, Foreground: *coverForeground, Font: *coverFont}
+	}
+	if command == "build" {
+		if flag.NArg() != 1 {
+			return fmt.Errorf("error parsing flags: build wants one book manifest, got %d arguments", flag.NArg())
+		}
+		project, err := converter.LoadProject(flag.Arg(0))
+		if err != nil {
+			return fmt.Errorf("error loading book manifest: %w", err)
+		}
+		if !flagSet("out") {
+			opts.OutputPath = "" // The manifest's, if it names one
+		}
+		opts = project.Options(opts)
+		if opts.OutputPath == "" {
+			opts.OutputPath = defaultOutput
+		}
+	}
+	// Ctrl-C cancels the build, or stops the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
+	if command == "serve" {
+		if flag.NArg() != 0 {
+			return fmt.Errorf("error parsing flags: serve takes no arguments, got %d", flag.NArg())
+		}
+		if *serveToken == "" {
+			*serveToken = os.Getenv("EPUB_SERVE_TOKEN")
+		}
+		if *maxBuilds < 1 {
+			return fmt.Errorf("error parsing flags: -max-builds must be at least 1")
+		}
+		opts.PublicOnly = !*allowPrivate
+		cfg := serveConfig{addr: *addr, token: *serveToken, maxBuilds: *maxBuilds}
+		if err := serve(ctx, cfg, opts); err != nil {
+			return fmt.Errorf("error serving: %w", err)
+		}
+		return nil
+	}
+	if bar != nil {
+		opts.Progress = bar.update
+	}
+	if *estimate {
+		est, err := converter.EstimateSize(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("error estimating EPUB size: %w", err)
+		}
+		fmt.Printf("Estimated EPUB size: %d bytes (%d bytes of text, %d images totalling %d bytes", est.Bytes, est.TextBytes, est.Images, est.ImageBytes)
+		if est.UnknownImages > 0 {
+			fmt.Printf(", %d of unknown size", est.UnknownImages)
+		}
+		fmt.Println(")")
+		return nil
+	}
+	if *batchFile != "" {
+		items, err := converter.LoadBatch(*batchFile)
+		if err != nil {
+			return fmt.Errorf("error loading batch: %w", err)
+		}
+		state, err := converter.LoadBatchState(*batchFile + ".state")
+		if err != nil {
+			return fmt.Errorf("error loading batch: %w", err)
+		}
+		built, err := converter.RunBatch(ctx, items, opts, state)
+		bar.clear()
+		slog.Info("Built batch", "epubs", len(built))
+		if err != nil {
+			return fmt.Errorf("error running batch: %w", err)
+		}
+		return nil
+	}
+
+	result, err := converter.New(opts).Build(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to save image to '%s': %w", filepath, err)
+		return fmt.Errorf("error building EPUB: %w", err)
+	}
+	bar.clear()
+	if *metricsFile != "" {
+		// Written whatever is made of the EPUB, as a failed validation is
+		// still worth profiling
+		defer func() {
+			if err := converter.WriteMetrics(*metricsFile, result.Summary().Metrics); err != nil {
+				slog.Warn("Could not write metrics", "err", err)
+			}
+		}()
 	}
 
-	return filepath, nil
+	if problems := result.Summary().Validation; len(problems) > 0 {
+		for _, p := range problems {
+			slog.Error("Validation problem", "problem", p)
+		}
+		return fmt.Errorf("EPUB written to %s failed validation with %d problem(s)", opts.OutputPath, len(problems))
+	}
+	slog.Info("Created EPUB", "output", opts.OutputPath)
+	for _, s := range result.Summary().Skipped {
+		slog.Warn("Skipped malformed section", "section", s.Title)
+	}
+	if *a11yReport != "" {
+		slog.Info("Wrote accessibility report", "findings", len(result.Summary().Accessibility), "report", *a11yReport)
+	}
+	if chapters := result.Summary().Chapters; len(chapters) > 0 {
+		slog.Info("Wrote chapter EPUBs", "epubs", len(chapters), "dir", *chapterDir)
+	}
+	for _, r := range result.Summary().Pruned {
+		slog.Info("Pruned unused resource", "kind", r.Kind, "path", r.Path)
+	}
+	if c := result.Summary().Cover; c != nil && c.Thumbnail != nil && c.Thumbnail.File != "" {
+		slog.Info("Wrote cover thumbnail", "file", c.Thumbnail.File, "width", c.Thumbnail.Width, "height", c.Thumbnail.Height)
+	}
+	return nil
 }
 
-// getText extracts and concatenates all text nodes within a given node.
-func getText(n *html.Node) string {
-	var b strings.Builder
-	var extract func(*html.Node)
-	extract = func(node *html.Node) {
-		if node.Type == html.TextNode {
-			b.WriteString(strings.TrimSpace(node.Data))
+// flagSet reports whether the command line flag name was given.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			extract(c)
-		}
-	}
-	extract(n)
-	return b.String()
+	})
+	return set
 }
 
-// Helper function to read file content (replaces os.ReadFile for clarity in example)
-// Note: This function is not used in the final version but kept for reference
-// if you were reading from a local file initially.
-func readFileContent(filename string) ([]byte, error) {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
-	}
-	return content, nil
+// headerList is a repeatable -header flag.
+type headerList struct {
+	header http.Header
+	values []string
 }
 
-// getAndSave fetches HTML and saves it to a local file.
-// Note: This function is replaced by fetchOrLoadHTML in the final version.
-// Kept for reference from the original code.
-func getAndSave() (*os.File, error) {
-	resp, err := http.Get("https://www.gutenberg.org/cache/epub/1184/pg1184-images.html#linkC2HCH0002") // Original URL had fragment
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (l *headerList) String() string {
+	return strings.Join(l.values, ", ")
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (l *headerList) Set(s string) error {
+	name, value, err := converter.ParseHeader(s)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	// Using os.Create simplifies file opening for writing, truncating if exists.
-	f, err := os.Create("example.html")
-	if err != nil {
-		// Return nil for file pointer on error
-		return nil, err
+	if l.header == nil {
+		l.header = make(http.Header)
 	}
-	// No need to return f if we close it here. Let's close it immediately after write.
-	// defer f.Close() // Defer is useful if more operations follow, but here we write and close.
+	l.header.Add(name, value)
+	l.values = append(l.values, s)
+	return nil
+}
 
-	_, err = f.Write(body)
-	if err != nil {
-		f.Close() // Close file before returning error
-		return nil, err
-	}
+// creatorList is a repeatable -creator flag.
+type creatorList []converter.Creator
 
-	err = f.Close() // Close the file explicitly after successful write
-	if err != nil {
-		// Log or return this error as well if closing fails
-		return nil, err
+func (l *creatorList) String() string {
+	var names []string
+	for _, c := range *l {
+		names = append(names, c.Name+":"+c.Role)
 	}
-
-	// Since the file is closed, we can't return the *os.File handle in a usable state.
-	// The function signature might need adjustment based on how it's used.
-	// Returning nil, nil might be appropriate if the goal is just to save the file.
-	return nil, nil // Adjusted return based on closing the file
+	return strings.Join(names, ", ")
 }
 
-// Helper function needed for html.Parse
-type bytesReader struct {
-	*bytes.Reader
+func (l *creatorList) Set(s string) error {
+	c, err := converter.ParseCreator(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, c)
+	return nil
 }
-
-// package main
-//
-// import (
-// 	"bytes"
-// 	"errors"
-// 	"fmt"
-// 	"io"
-// 	"log"
-// 	"net/http"
-// 	"net/url"
-// 	"os"
-// 	"path"
-// 	"strings"
-//
-// 	"github.com/go-shiori/go-epub"
-// 	"golang.org/x/net/html"
-// )
-//
-// const fetchURL = "https://www.gutenberg.org/cache/epub/1184/pg1184-images.html"
-// const outputEPUB = "output.epub"
-// const tempImageDir = "temp_images"
-// const outputHTML = "output.html"
-//
-// func main() {
-// 	// Fetch the HTML content
-// 	body, baseURL, err := fetchHTMLAndSave(fetchURL)
-// 	if err != nil {
-// 		log.Fatalf("Error fetching HTML: %v", err)
-// 		os.Exit(1)
-// 	}
-//
-// 	// Parse the HTML
-// 	doc, err := html.Parse(bytes.NewReader(body))
-// 	if err != nil {
-// 		log.Fatalf("Error parsing HTML: %v", err)
-// 	}
-//
-// 	// Create EPUB
-// 	e, err := epub.NewEpub("Fetched EPUB")
-// 	if err != nil {
-// 		log.Fatalf("Error creating EPUB: %v", err)
-// 		os.Exit(1)
-// 	}
-// 	e.SetAuthor("Cline") // You can change this
-//
-// 	// Create temporary directory for images
-// 	if err := os.MkdirAll(tempImageDir, 0755); err != nil {
-// 		log.Fatalf("Error creating temp image directory: %v", err)
-// 	}
-// 	defer os.RemoveAll(tempImageDir) // Clean up temp directory
-//
-// 	// Extract content and images
-// 	var currentSection strings.Builder
-// 	var sectionTitle string = "Chapter 1" // Default title
-//
-// 	var extractText func(*html.Node)
-// 	extractText = func(n *html.Node) {
-// 		if n.Type == html.ElementNode {
-// 			// Basic section handling (can be improved based on actual HTML structure)
-// 			if n.Data == "h1" || n.Data == "h2" || n.Data == "h3" {
-// 				if currentSection.Len() > 0 {
-// 					// Add previous section to EPUB
-// 					_, err := e.AddSection(currentSection.String(), sectionTitle, "", "")
-// 					if err != nil {
-// 						log.Printf("Warning: Could not add section '%s': %v", sectionTitle, err)
-// 					}
-// 					currentSection.Reset() // Start new section
-// 				}
-// 				sectionTitle = getText(n) // Get title from heading
-// 				if sectionTitle == "" {
-// 					sectionTitle = "Unnamed Section"
-// 				}
-// 			}
-//
-// 			// Handle images
-// 			if n.Data == "img" {
-// 				for _, attr := range n.Attr {
-// 					if attr.Key == "src" {
-// 						imgURL := attr.Val
-// 						// Resolve relative URLs
-// 						absoluteImgURL, err := baseURL.Parse(imgURL)
-// 						if err != nil {
-// 							log.Printf("Warning: Could not parse image URL '%s': %v", imgURL, err)
-// 							continue
-// 						}
-//
-// 						// Download image
-// 						imgPath, err := downloadImage(absoluteImgURL.String(), tempImageDir)
-// 						if err != nil {
-// 							log.Printf("Warning: Could not download image '%s': %v", absoluteImgURL.String(), err)
-// 							continue
-// 						}
-//
-// 						// Add image to EPUB and get internal path
-// 						epubImgPath, err := e.AddImage(imgPath, "")
-// 						if err != nil {
-// 							log.Printf("Warning: Could not add image '%s' to EPUB: %v", imgPath, err)
-// 							// Don't remove the local file yet if adding failed
-// 							continue
-// 						}
-//
-// 						// Append img tag to current section content
-// 						currentSection.WriteString(fmt.Sprintf(`<p><img src="%s" alt="Image"/></p>`, epubImgPath))
-// 						// No need to remove imgPath here, defer os.RemoveAll(tempImageDir) handles cleanup
-// 						break // Found src, move to next node
-// 					}
-// 				}
-// 			}
-// 		} else if n.Type == html.TextNode {
-// 			// Append text content, trimming whitespace
-// 			trimmedData := strings.TrimSpace(n.Data)
-// 			if trimmedData != "" {
-// 				// Basic paragraph wrapping
-// 				if !strings.HasSuffix(currentSection.String(), "</p>") && currentSection.Len() > 0 {
-// 					// If the last thing wasn't a closing p tag, start a new one.
-// 					// This is a simplification; real HTML structure might need more complex handling.
-// 					currentSection.WriteString("<p>")
-// 				} else if currentSection.Len() == 0 {
-// 					currentSection.WriteString("<p>")
-// 				}
-// 				currentSection.WriteString(html.EscapeString(trimmedData) + " ") // Add space between text nodes
-// 				// Add closing tag tentatively; might be overwritten by next element or text
-// 				if !strings.HasSuffix(currentSection.String(), "</p>") {
-// 					currentSection.WriteString("</p>")
-// 				}
-// 			}
-// 		}
-//
-// 		// Recursively process child nodes
-// 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-// 			extractText(c)
-// 		}
-// 	}
-//
-// 	// Find the body node to start extraction
-// 	var bodyNode *html.Node
-// 	var findBody func(*html.Node)
-// 	findBody = func(n *html.Node) {
-// 		if n.Type == html.ElementNode && n.Data == "body" {
-// 			bodyNode = n
-// 			return
-// 		}
-// 		for c := n.FirstChild; c != nil; c = c.NextSibling {
-// 			findBody(c)
-// 			if bodyNode != nil {
-// 				return
-// 			}
-// 		}
-// 	}
-// 	findBody(doc)
-//
-// 	if bodyNode != nil {
-// 		extractText(bodyNode)
-// 	} else {
-// 		log.Println("Warning: Could not find body node in HTML, extracting from root.")
-// 		extractText(doc) // Fallback to extracting from root if body not found
-// 	}
-//
-// 	// Add the last section if it has content
-// 	if currentSection.Len() > 0 {
-// 		_, err := e.AddSection(currentSection.String(), sectionTitle, "", "")
-// 		if err != nil {
-// 			log.Printf("Warning: Could not add final section '%s': %v", sectionTitle, err)
-// 		}
-// 	}
-//
-// 	// Write EPUB file
-// 	err = e.Write(outputEPUB)
-// 	if err != nil {
-// 		log.Fatalf("Error writing EPUB file: %v", err)
-// 	}
-//
-// 	fmt.Printf("Successfully created EPUB: %s\n", outputEPUB)
-// }
-//
-// // fetchHTML fetches the HTML content from a given URL.
-// // It returns the body content as bytes and the base URL for resolving relative links.
-// func fetchHTMLAndSave(urlStr string) ([]byte, *url.URL, error) {
-// 	r, err := os.Open(outputHTML)
-// 	if err != nil {
-// 		return nil, nil, fmt.Errorf("failed to open file: %w", err)
-// 	}
-// 	body, err := io.ReadAll(r)
-// 	if err != nil {
-// 		return nil, nil, fmt.Errorf("failed to read file: %w", err)
-// 	}
-//
-// 	if errors.Is(err, os.ErrNotExist) {
-// 		resp, err := http.Get(urlStr)
-// 		if err != nil {
-// 			return nil, nil, fmt.Errorf("failed to get URL: %w", err)
-// 		}
-// 		defer resp.Body.Close()
-//
-// 		if resp.StatusCode != http.StatusOK {
-// 			return nil, nil, fmt.Errorf("bad status: %s", resp.Status)
-// 		}
-//
-// 		body, err := io.ReadAll(resp.Body)
-// 		if err != nil {
-// 			return nil, nil, fmt.Errorf("failed to read response body: %w", err)
-// 		}
-//
-// 		f, err := os.OpenFile(outputHTML, os.O_CREATE|os.O_RDONLY, os.ModeAppend)
-// 		if err != nil {
-// 			return nil, nil, fmt.Errorf("failed to open file: %w", err)
-// 		}
-// 		f.Write(body)
-// 	}
-//
-// 	baseURL, err := url.Parse(urlStr)
-// 	if err != nil {
-// 		return nil, nil, fmt.Errorf("failed to parse base URL: %w", err)
-// 	}
-//
-//
-// 	return body, baseURL, nil
-// }
-//
-// // downloadImage downloads an image from a URL and saves it to a temporary directory.
-// // It returns the path to the downloaded image file.
-// func downloadImage(imgURL string, dir string) (string, error) {
-// 	resp, err := http.Get(imgURL)
-// 	if err != nil {
-// 		return "", fmt.Errorf("failed to get image URL %s: %w", imgURL, err)
-// 	}
-// 	defer resp.Body.Close()
-//
-// 	if resp.StatusCode != http.StatusOK {
-// 		return "", fmt.Errorf("bad status for image %s: %s", imgURL, resp.Status)
-// 	}
-//
-// 	// Create a unique filename based on the URL path
-// 	parsedURL, err := url.Parse(imgURL)
-// 	if err != nil {
-// 		return "", fmt.Errorf("failed to parse image URL %s: %w", imgURL, err)
-// 	}
-// 	filename := path.Base(parsedURL.Path)
-// 	if filename == "." || filename == "/" { // Handle cases where path is minimal
-// 		filename = "image_" + strings.ReplaceAll(parsedURL.Host, ".", "_") + ".tmp" // Create a fallback name
-// 	}
-// 	// Ensure filename is safe (basic sanitization)
-// 	filename = strings.Map(func(r rune) rune {
-// 		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' {
-// 			return '_'
-// 		}
-// 		return r
-// 	}, filename)
-//
-// 	filepath := path.Join(dir, filename)
-//
-// 	// Create the file
-// 	out, err := os.Create(filepath)
-// 	if err != nil {
-// 		return "", fmt.Errorf("failed to create image file %s: %w", filepath, err)
-// 	}
-// 	defer out.Close()
-//
-// 	// Write the body to file
-// 	_, err = io.Copy(out, resp.Body)
-// 	if err != nil {
-// 		return "", fmt.Errorf("failed to save image %s: %w", filepath, err)
-// 	}
-//
-// 	return filepath, nil
-// }
-//
-// // getText extracts and concatenates all text nodes within a given node.
-// func getText(n *html.Node) string {
-// 	var b strings.Builder
-// 	var extract func(*html.Node)
-// 	extract = func(node *html.Node) {
-// 		if node.Type == html.TextNode {
-// 			b.WriteString(strings.TrimSpace(node.Data))
-// 		}
-// 		for c := node.FirstChild; c != nil; c = c.NextSibling {
-// 			extract(c)
-// 		}
-// 	}
-// 	extract(n)
-// 	return b.String()
-// }
-//
-// // Helper function to read file content (replaces os.ReadFile for clarity in example)
-// // Note: This function is not used in the final version but kept for reference
-// // if you were reading from a local file initially.
-// func readFileContent(filename string) ([]byte, error) {
-// 	content, err := os.ReadFile(filename)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
-// 	}
-// 	return content, nil
-// }
-//
-// // getAndSave fetches HTML and saves it to a local file.
-// // Note: This function is replaced by fetchHTML in the final version.
-// // Kept for reference from the original code.
-// func getAndSave() (*os.File, error) {
-// 	resp, err := http.Get("https://www.gutenberg.org/cache/epub/1184/pg1184-images.html#linkC2HCH0002") // Original URL had fragment
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	defer resp.Body.Close()
-//
-// 	body, err := io.ReadAll(resp.Body)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-//
-// 	// Using os.Create simplifies file opening for writing, truncating if exists.
-// 	f, err := os.Create("example.html")
-// 	if err != nil {
-// 		// Return nil for file pointer on error
-// 		return nil, err
-// 	}
-// 	// No need to return f if we close it here. Let's close it immediately after write.
-// 	// defer f.Close() // Defer is useful if more operations follow, but here we write and close.
-//
-// 	_, err = f.Write(body)
-// 	if err != nil {
-// 		f.Close() // Close file before returning error
-// 		return nil, err
-// 	}
-//
-// 	err = f.Close() // Close the file explicitly after successful write
-// 	if err != nil {
-// 		// Log or return this error as well if closing fails
-// 		return nil, err
-// 	}
-//
-// 	// Since the file is closed, we can't return the *os.File handle in a usable state.
-// 	// The function signature might need adjustment based on how it's used.
-// 	// Returning nil, nil might be appropriate if the goal is just to save the file.
-// 	return nil, nil // Adjusted return based on closing the file
-// }
-//
-// // Helper function needed for html.Parse
-// type bytesReader struct {
-// 	*bytes.Reader
-// }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMain runs the program with args, as if from the command line, and
+// returns what it logged to stderr.
+func runMain(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	oldArgs, oldFlags, oldStderr, oldLogger := os.Args, flag.CommandLine, os.Stderr, slog.Default()
+	defer func() {
+		os.Args, flag.CommandLine, os.Stderr = oldArgs, oldFlags, oldStderr
+		slog.SetDefault(oldLogger)
+	}()
+	os.Args = append([]string{"epub"}, args...)
+	flag.CommandLine = flag.NewFlagSet("epub", flag.ExitOnError)
+	os.Stderr = stderr
+
+	runErr := run()
+	logged, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(logged), runErr
+}
+
+func TestRunReportsOutput(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "book.html")
+	if err := os.WriteFile(page, []byte("<h3>One</h3><p>Text.</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "book.epub")
+	tests := []struct {
+		name string
+		flag string
+		want bool
+	}{
+		{"default", "-progress=false", true},
+		{"quiet", "-quiet", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged, err := runMain(t, tt.flag, "-out", out, "-image-dir", filepath.Join(dir, "images"), page)
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got := strings.Contains(logged, "Created EPUB") && strings.Contains(logged, out); got != tt.want {
+				t.Errorf("reported the EPUB written = %v, want %v; logged:\n%s", got, tt.want, logged)
+			}
+		})
+	}
+}
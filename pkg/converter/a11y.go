@@ -0,0 +1,232 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// minContrast is the lowest contrast ratio between text and its background
+// that WCAG 2 (level AA) allows for normal-sized text.
+const minContrast = 4.5
+
+// A11yFinding is one problem in an accessibility report.
+type A11yFinding struct {
+	Source string `json:"source"` // Archive entry name or URL of the document
+	Kind   string `json:"kind"`   // missing-alt, skipped-heading, table-without-headers or low-contrast
+	Detail string `json:"detail"`
+}
+
+// checkAccessibility looks through the source documents for problems that
+// carry over into the EPUB: images without alt text (unless opts supplies
+// it), headings that skip levels, tables without header cells, and inline
+// styles with too little contrast.
+func checkAccessibility(sources []*source, opts Options) []A11yFinding {
+	findings := []A11yFinding{}
+	for _, src := range sources {
+		add := func(kind, format string, args ...any) {
+			findings = append(findings, A11yFinding{Source: src.name, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+		}
+		lastLevel := 0
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.ElementNode {
+				switch {
+				case n.Data == "img":
+					imgSrc := getAttr(n, "src")
+					_, hasAlt := attr(n, "alt")
+					if !hasAlt {
+						if u, err := src.baseURL.Parse(imgSrc); err == nil {
+							_, hasAlt = lookupAltText(opts.AltText, u.String(), imgSrc)
+						}
+					}
+					if !hasAlt {
+						add("missing-alt", "image '%s' has no alt text", imgSrc)
+					}
+				case isHeading(n):
+					level := int(n.Data[1] - '0')
+					if lastLevel > 0 && level > lastLevel+1 {
+						add("skipped-heading", "<%s> '%s' follows an <h%d>", n.Data, getTextContent(n), lastLevel)
+					}
+					lastLevel = level
+				case n.Data == "table":
+					if findElement(n, "th") == nil {
+						add("table-without-headers", "table starting '%s' has no header cells", truncateText(getTextContent(n), 40))
+					}
+				}
+				if ratio, ok := inlineContrast(n); ok && ratio < minContrast {
+					add("low-contrast", "<%s> '%s' has a contrast ratio of %.1f:1 (want at least %.1f:1)",
+						n.Data, truncateText(getTextContent(n), 40), ratio, minContrast)
+				}
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(src.doc)
+	}
+	return findings
+}
+
+// attr returns the value of n's attribute key and whether it has one.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// truncateText shortens s to at most n runes, marking a cut with "...".
+func truncateText(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
+
+// styleColorPattern matches the color and background color declarations of
+// a style attribute.
+var styleColorPattern = regexp.MustCompile(`(?i)(?:^|;)\s*(color|background-color|background)\s*:\s*([^;]+)`)
+
+// inlineContrast returns the contrast ratio between the text color an
+// element's style attribute sets and the background color it or its nearest
+// ancestor sets. ok is false if the element sets no color or either color
+// isn't one parseColor understands.
+func inlineContrast(n *html.Node) (ratio float64, ok bool) {
+	fg, _ := styleColors(n)
+	if fg == "" {
+		return 0, false
+	}
+	var bg string
+	for p := n; p != nil && p.Type == html.ElementNode && bg == ""; p = p.Parent {
+		_, bg = styleColors(p)
+		if bg == "" {
+			bg = getAttr(p, "bgcolor")
+		}
+	}
+	if bg == "" {
+		bg = "white" // What readers show by default
+	}
+	fgRGB, ok1 := parseColor(fg)
+	bgRGB, ok2 := parseColor(bg)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	l1, l2 := relativeLuminance(fgRGB), relativeLuminance(bgRGB)
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05), true
+}
+
+// styleColors returns the text and background colors n's style attribute
+// sets, if any.
+func styleColors(n *html.Node) (fg, bg string) {
+	for _, m := range styleColorPattern.FindAllStringSubmatch(getAttr(n, "style"), -1) {
+		value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(m[2]), "!important"))
+		if strings.EqualFold(m[1], "color") {
+			fg = value
+		} else {
+			bg = value
+		}
+	}
+	return fg, bg
+}
+
+// namedColors are the CSS color names parseColor knows.
+var namedColors = map[string][3]int{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "gray": {128, 128, 128}, "grey": {128, 128, 128},
+	"silver": {192, 192, 192}, "lightgray": {211, 211, 211}, "lightgrey": {211, 211, 211},
+	"darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169}, "red": {255, 0, 0}, "maroon": {128, 0, 0},
+	"yellow": {255, 255, 0}, "olive": {128, 128, 0}, "lime": {0, 255, 0}, "green": {0, 128, 0},
+	"aqua": {0, 255, 255}, "cyan": {0, 255, 255}, "teal": {0, 128, 128}, "blue": {0, 0, 255},
+	"navy": {0, 0, 128}, "fuchsia": {255, 0, 255}, "magenta": {255, 0, 255}, "purple": {128, 0, 128},
+	"orange": {255, 165, 0},
+}
+
+// rgbPattern matches an rgb() or rgba() color.
+var rgbPattern = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+// parseColor parses a CSS color given as a name, #rgb, #rrggbb or rgb().
+func parseColor(s string) ([3]int, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if m := rgbPattern.FindStringSubmatch(s); m != nil {
+		var c [3]int
+		for i := range c {
+			c[i], _ = strconv.Atoi(m[i+1])
+			c[i] = min(c[i], 255)
+		}
+		return c, true
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok {
+		return [3]int{}, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return [3]int{}, false
+	}
+	return [3]int{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}, true
+}
+
+// relativeLuminance returns the WCAG relative luminance of an sRGB color.
+func relativeLuminance(c [3]int) float64 {
+	var l [3]float64
+	for i, v := range c {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			l[i] = f / 12.92
+		} else {
+			l[i] = math.Pow((f+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+}
+
+// writeA11yReport writes findings to filePath: as JSON if it ends in
+// .json, otherwise as text, one finding per line.
+func writeA11yReport(filePath string, findings []A11yFinding) error {
+	var data []byte
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false) // Details quote tag names
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			Findings []A11yFinding `json:"findings"`
+		}{findings})
+		if err != nil {
+			return fmt.Errorf("failed to encode accessibility report: %w", err)
+		}
+		data = b.Bytes()
+	} else {
+		var b strings.Builder
+		for _, f := range findings {
+			fmt.Fprintf(&b, "%s: %s: %s\n", f.Source, f.Kind, f.Detail)
+		}
+		if len(findings) == 0 {
+			b.WriteString("No accessibility problems found.\n")
+		}
+		data = []byte(b.String())
+	}
+	if err := writeFileAtomic(filePath, data); err != nil {
+		return fmt.Errorf("failed to write accessibility report '%s': %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccessibilityReport(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/nameless.png":  {"image/png", testPNG(t, 2, 2, color.Black)},
+		"/described.png": {"image/png", testPNG(t, 3, 3, color.White)},
+	})
+	page := `<html><head><title>Page</title></head><body>` +
+		`<h2>Chapter</h2><p>Text.</p><img src="` + srv.URL + `/nameless.png"><img src="` + srv.URL + `/described.png" alt="Described">` +
+		`<h4>Too deep</h4><p style="color: #777; background-color: #888">Faint.</p>` +
+		`<h3>Fine</h3><table><tr><td>No headers</td></tr></table></body></html>`
+	want := []A11yFinding{
+		{Kind: "missing-alt", Detail: "image '" + srv.URL + "/nameless.png' has no alt text"},
+		{Kind: "skipped-heading", Detail: "<h4> 'Too deep' follows an <h2>"},
+		{Kind: "low-contrast"},
+		{Kind: "table-without-headers", Detail: "table starting 'No headers' has no header cells"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "a11y.json")
+		testBuild(t, page, Options{AccessibilityReport: report})
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct{ Findings []A11yFinding }
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("report isn't JSON: %v\n%s", err, data)
+		}
+		if len(got.Findings) != len(want) {
+			t.Fatalf("findings = %+v, want %d", got.Findings, len(want))
+		}
+		for i, f := range got.Findings {
+			if f.Kind != want[i].Kind || (want[i].Detail != "" && f.Detail != want[i].Detail) {
+				t.Errorf("finding %d = %s: %s, want %s: %s", i, f.Kind, f.Detail, want[i].Kind, want[i].Detail)
+			}
+		}
+	})
+	t.Run("text", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "a11y.txt")
+		testBuild(t, page, Options{AccessibilityReport: report})
+		data, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("report has %d lines, want %d:\n%s", len(lines), len(want), data)
+		}
+		for i, line := range lines {
+			if !strings.Contains(line, ": "+want[i].Kind+": "+want[i].Detail) {
+				t.Errorf("line %d = %q, want %s: %s", i+1, line, want[i].Kind, want[i].Detail)
+			}
+		}
+	})
+}
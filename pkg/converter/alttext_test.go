@@ -0,0 +1,83 @@
+package converter
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAltTextOverride(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/a.png": {"image/png", testPNG(t, 2, 2, color.White)},
+		"/b.png": {"image/png", testPNG(t, 3, 3, color.Black)},
+		"/c.png": {"image/png", testPNG(t, 4, 4, color.White)},
+	})
+	altFile := filepath.Join(t.TempDir(), "alt.json")
+	overrides := `{"` + srv.URL + `/a.png": "Corrected by absolute URL", "c.png": "Corrected as written"}`
+	if err := os.WriteFile(altFile, []byte(overrides), 0644); err != nil {
+		t.Fatal(err)
+	}
+	alt, err := LoadAltText(altFile)
+	if err != nil {
+		t.Fatalf("LoadAltText: %v", err)
+	}
+
+	page := `<html><head><base href="` + srv.URL + `/"></head><body><h3>One</h3><p>Text.</p>` +
+		`<img src="a.png" alt="wrong"><img src="b.png" alt="Original"><img src="c.png"></body></html>`
+	result, files := testBuild(t, page, Options{AltText: alt})
+	body := sectionFile(t, result, files, "One")
+
+	var alts []string
+	for _, m := range regexp.MustCompile(`<img [^>]*alt="([^"]*)"`).FindAllStringSubmatch(body, -1) {
+		alts = append(alts, m[1])
+	}
+	want := []string{"Corrected by absolute URL", "Original", "Corrected as written"}
+	if len(alts) != len(want) {
+		t.Fatalf("alt texts = %q, want %q", alts, want)
+	}
+	for i := range want {
+		if alts[i] != want[i] {
+			t.Errorf("image %d alt = %q, want %q", i+1, alts[i], want[i])
+		}
+	}
+}
+
+func TestImageText(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/barn.png": {"image/png", testPNG(t, 2, 2, color.Black)},
+		"/logo.png": {"image/png", testPNG(t, 3, 3, color.White)},
+	})
+	page := `<html><head><base href="` + srv.URL + `/"></head><body><h3>One</h3><p>Text.</p>` +
+		`<figure><img src="barn.png" alt="Barn"><figcaption>A red barn at dusk</figcaption></figure>` +
+		`<img src="logo.png" alt="Logo"></body></html>`
+	tests := []struct {
+		mode     ImageTextMode
+		want     []string
+		captions int // Times the figure caption appears
+	}{
+		{ImageTextNone, []string{`alt="Barn"/></p>`, `<p>A red barn at dusk </p>`, `alt="Logo"/></p>`}, 1},
+		{ImageTextVisible, []string{`alt="Barn"/><figcaption>A red barn at dusk</figcaption></figure>`, `alt="Logo"/><figcaption>Logo</figcaption></figure>`}, 1},
+		{ImageTextHidden, []string{
+			`alt="Barn" aria-describedby="image-description-1"/><span id="image-description-1" hidden="hidden">A red barn at dusk</span>`,
+			`<p>A red barn at dusk </p>`,
+			`alt="Logo" aria-describedby="image-description-2"/><span id="image-description-2" hidden="hidden">Logo</span>`,
+		}, 2}, // Described for screen readers and still shown
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			result, files := testBuild(t, page, Options{ImageText: tt.mode})
+			body := sectionFile(t, result, files, "One")
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("section lacks %s:\n%s", want, body)
+				}
+			}
+			if n := strings.Count(body, "A red barn at dusk"); n != tt.captions {
+				t.Errorf("caption appears %d times, want %d:\n%s", n, tt.captions, body)
+			}
+		})
+	}
+}
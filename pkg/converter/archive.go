@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// archiveScheme is the URL scheme of documents and images read from an
+// archive; the URL path is the entry's path inside the archive.
+const archiveScheme = "archive"
+
+// loadArchive reads a .zip or .tar.gz archive of HTML chapters. Every HTML
+// entry becomes a source, in sorted path order, and images are resolved
+// against the archive's own entries rather than the network.
+func loadArchive(archivePath string, store MediaStore) ([]*source, error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive '%s': %w", archivePath, err)
+	}
+	entries, err := readArchive(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive '%s': %w", archivePath, err)
+	}
+	return archiveSources(entries, store)
+}
+
+// archiveSources turns archive entries, keyed by path, into sources.
+// Images are put in store.
+func archiveSources(entries map[string][]byte, store MediaStore) ([]*source, error) {
+	var names []string
+	for name := range entries {
+		if isHTMLFile(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("archive contains no HTML files")
+	}
+	sort.Strings(names)
+
+	fetch := func(ctx context.Context, u *url.URL) ([]byte, error) {
+		if u.Scheme != archiveScheme {
+			return fetchHTML(ctx, u.String(), "") // Absolute links still go to the network
+		}
+		name := strings.TrimPrefix(u.Path, "/")
+		data, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("'%s' not found in archive", name)
+		}
+		return data, nil
+	}
+	loadImage := func(ctx context.Context, u *url.URL) (string, error) {
+		if u.Scheme != archiveScheme {
+			return fetchImage(ctx, u, store)
+		}
+		data, err := fetch(ctx, u)
+		if err != nil {
+			return "", err
+		}
+		return store.Put(localImageFilename(u), data)
+	}
+
+	var sources []*source
+	for _, name := range names {
+		src, err := fileSource(name, entries[name], &url.URL{Scheme: archiveScheme, Path: "/" + name})
+		if err != nil {
+			return nil, err
+		}
+		src.loadImage, src.fetch = loadImage, fetch
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// fileSource parses the HTML file data, named name, into a source found at
+// baseURL, titled by its <title> or else its file name.
+func fileSource(name string, data []byte, baseURL *url.URL) (*source, error) {
+	parseStart := time.Now()
+	doc, err := html.Parse(bytes.NewReader(unnestLinks(toUTF8(data, ""))))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML from '%s': %w", name, err)
+	}
+	parseTime := time.Since(parseStart)
+	var title string
+	if t := findElement(doc, "title"); t != nil {
+		title = strings.TrimSpace(getText(t))
+	}
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+	return &source{
+		doc:       doc,
+		baseURL:   baseURL,
+		name:      name,
+		title:     title,
+		parseTime: parseTime,
+	}, nil
+}
+
+// readArchive returns the regular file entries of a zip or gzipped tar
+// archive keyed by their cleaned, slash-separated paths.
+func readArchive(data []byte) (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open '%s': %w", f.Name, err)
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read '%s': %w", f.Name, err)
+			}
+			entries[cleanEntryName(f.Name)] = b
+		}
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read '%s': %w", hdr.Name, err)
+			}
+			entries[cleanEntryName(hdr.Name)] = b
+		}
+	default:
+		return nil, errors.New("unsupported archive format (want .zip or .tar.gz)")
+	}
+	return entries, nil
+}
+
+// cleanEntryName normalizes an archive entry path, e.g. "./ch/01.html" to
+// "ch/01.html".
+func cleanEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+}
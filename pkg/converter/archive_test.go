@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testArchive returns a zip, or a gzipped tar if gz is set, of files by
+// path.
+func testArchive(t *testing.T, files map[string][]byte, gz bool) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if gz {
+		zw := gzip.NewWriter(&b)
+		tw := tar.NewWriter(zw)
+		for name, data := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write(data)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	zw := zip.NewWriter(&b)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestBuildFromArchive(t *testing.T) {
+	pic := testPNG(t, 3, 3, color.Black)
+	files := map[string][]byte{
+		"book/02-second.html": []byte(`<html><head><title>Second</title></head><body><h3>Two</h3><p>Second chapter.</p></body></html>`),
+		"book/01-first.html":  []byte(`<html><head><title>First</title></head><body><h3>One</h3><p>First chapter.</p><img src="img/pic.png" alt="Picture"></body></html>`),
+		"book/img/pic.png":    pic,
+		"book/notes.txt":      []byte("Not a chapter."),
+	}
+	for _, tt := range []struct {
+		name string
+		gz   bool
+	}{{"zip", false}, {"tar.gz", true}} {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "book."+tt.name)
+			if err := os.WriteFile(archive, testArchive(t, files, tt.gz), 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, epub := testBuild(t, "", Options{Archive: archive})
+
+			var titles []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != "One|Two" {
+				t.Errorf("sections = %q, want One and Two in path order", titles)
+			}
+			one := sectionFile(t, result, epub, "One")
+			if !strings.Contains(one, "First chapter.") || !strings.Contains(one, `<img src="../images/`) {
+				t.Errorf("first chapter lacks its text or image:\n%s", one)
+			}
+			var embedded bool
+			for name, data := range epub {
+				if strings.HasPrefix(name, "EPUB/images/") && data == string(pic) {
+					embedded = true
+				}
+			}
+			if !embedded {
+				t.Error("image from the archive not embedded")
+			}
+		})
+	}
+}
+
+func TestArchiveWithoutHTML(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "book.zip")
+	if err := os.WriteFile(archive, testArchive(t, map[string][]byte{"notes.txt": []byte("Text.")}, false), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadArchive(archive, dirStore(t.TempDir())); err == nil || !strings.Contains(err.Error(), "no HTML") {
+		t.Errorf("loadArchive error = %v, want no HTML files", err)
+	}
+}
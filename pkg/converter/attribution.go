@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// defaultAttributionTemplate is the body of the attribution section unless
+// Options.AttributionTemplate replaces it.
+const defaultAttributionTemplate = `<h2>Attribution</h2>
+<p><em>{{.Title}}</em>{{if .Author}} by {{.Author}}{{end}}{{if .SourceURL}} was converted from <a href="{{.SourceURL}}">{{.SourceURL}}</a>{{else if .Archive}} was converted from {{.Archive}}{{end}}.</p>
+{{if .License}}<p>{{.License}}</p>
+{{end}}`
+
+// attributionData is what the attribution template is executed with.
+type attributionData struct {
+	Title     string
+	Author    string
+	SourceURL string // Page the book was made from, if it was made from web pages
+	Archive   string // Name of the archive it was made from, if any
+	License   string
+}
+
+// attributionSection renders the attribution section for a book built with
+// opts, described by meta, its metadata as detected and configured.
+func attributionSection(opts Options, meta Metadata) (Section, error) {
+	text := opts.AttributionTemplate
+	if text == "" {
+		text = defaultAttributionTemplate
+	}
+	t, err := template.New("attribution").Parse(text)
+	if err != nil {
+		return Section{}, fmt.Errorf("invalid attribution template: %w", err)
+	}
+	data := attributionData{
+		Title:     meta.Title,
+		Author:    meta.Author,
+		SourceURL: opts.sourceURL(),
+		License:   opts.License,
+	}
+	if opts.Archive != "" {
+		data.SourceURL, data.Archive = "", filepath.Base(opts.Archive)
+	}
+	var body strings.Builder
+	if err := t.Execute(&body, data); err != nil {
+		return Section{}, fmt.Errorf("failed to render attribution: %w", err)
+	}
+	return Section{Title: "Attribution", Body: body.String()}, nil
+}
@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttribution(t *testing.T) {
+	const page = `<html><head><title>The Detected Title</title>
+<meta name="author" content="Page Author"></head>
+<body><h3>Chapter One</h3><p>Text.</p></body></html>`
+	tests := []struct {
+		name string
+		meta Metadata
+		want []string
+	}{
+		{
+			name: "detected metadata",
+			want: []string{
+				"<em>The Detected Title</em> by Page Author",
+				`<a href="https://example.com/book/page.html">https://example.com/book/page.html</a>`,
+			},
+		},
+		{
+			name: "configured title wins",
+			meta: Metadata{Title: "Configured"},
+			want: []string{"<em>Configured</em> by Page Author"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{Attribution: true, Metadata: tt.meta})
+			body := sectionFile(t, result, files, "Attribution")
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("attribution lacks %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
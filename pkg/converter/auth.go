@@ -0,0 +1,197 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BasicAuth returns the Authorization header value for HTTP basic
+// authentication as user with password.
+func BasicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// BearerAuth returns the Authorization header value for a bearer token.
+func BearerAuth(token string) string {
+	return "Bearer " + token
+}
+
+// ParseHeader parses a -header value of the form "Name: value".
+func ParseHeader(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header '%s' (want Name: value)", s)
+	}
+	return http.CanonicalHeaderKey(name), value, nil
+}
+
+// browserCookie is a cookie as browser extensions such as Cookie-Editor
+// export them to JSON.
+type browserCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HTTPOnly       bool     `json:"httpOnly"`
+	HostOnly       bool     `json:"hostOnly"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"` // As Chrome's DevTools export it
+}
+
+// LoadCookies reads the cookies in a Netscape cookies.txt file, as curl,
+// wget and browser extensions write them, or in a browser's JSON export.
+// A cookie's Domain starts with a dot if it's also sent to subdomains.
+// Expired cookies are left out.
+func LoadCookies(path string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie file '%s': %w", path, err)
+	}
+	var cookies []*http.Cookie
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		cookies, err = parseJSONCookies(trimmed)
+	} else {
+		cookies, err = parseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cookie file '%s': %w", path, err)
+	}
+	now := time.Now()
+	kept := cookies[:0]
+	for _, c := range cookies {
+		if c.Expires.IsZero() || c.Expires.After(now) {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// parseNetscapeCookies parses a cookies.txt file: a line per cookie of
+// domain, whether subdomains get it, path, whether it's secure, expiry in
+// Unix seconds (0 for a session cookie), name and value, separated by tabs.
+func parseNetscapeCookies(data []byte) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab-separated fields, got %d", n, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry '%s'", n, fields[4])
+		}
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			Domain:   cookieDomain(fields[0], strings.EqualFold(fields[1], "TRUE")),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, sc.Err()
+}
+
+// parseJSONCookies parses a browser's JSON cookie export: an array of
+// cookies, or an object with them under "cookies".
+func parseJSONCookies(data []byte) ([]*http.Cookie, error) {
+	var list []browserCookie
+	if data[0] == '{' {
+		var wrapped struct {
+			Cookies []browserCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, err
+		}
+		list = wrapped.Cookies
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	var cookies []*http.Cookie
+	for _, b := range list {
+		if b.Name == "" || b.Domain == "" {
+			return nil, fmt.Errorf("cookie without a name or domain")
+		}
+		c := &http.Cookie{
+			Name:     b.Name,
+			Value:    b.Value,
+			Domain:   cookieDomain(b.Domain, !b.HostOnly),
+			Path:     b.Path,
+			Secure:   b.Secure,
+			HttpOnly: b.HTTPOnly,
+		}
+		expiry := b.ExpirationDate
+		if expiry == nil {
+			expiry = b.Expires
+		}
+		if expiry != nil && *expiry > 0 {
+			c.Expires = time.Unix(int64(*expiry), 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, nil
+}
+
+// cookieDomain returns domain with a leading dot if the cookie is also sent
+// to its subdomains, and without one if it isn't.
+func cookieDomain(domain string, subdomains bool) string {
+	domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+	if subdomains {
+		return "." + domain
+	}
+	return domain
+}
+
+// cookieURL returns a URL that cookie c, as LoadCookies returns it, belongs
+// to, for adding it to a cookie jar.
+func cookieURL(c *http.Cookie) *url.URL {
+	u := &url.URL{Scheme: "http", Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+	if c.Secure {
+		u.Scheme = "https"
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u
+}
+
+// sourceHosts returns the hosts of the pages opts names, which are sent
+// Options.Headers and Options.Authorization.
+func (opts Options) sourceHosts() map[string]bool {
+	hosts := make(map[string]bool)
+	add := func(rawURL string) {
+		if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+			hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+	add(opts.SourceURL)
+	for _, u := range opts.SourceURLs {
+		add(u)
+	}
+	for _, in := range opts.Inputs {
+		add(in.URL)
+	}
+	return hosts
+}
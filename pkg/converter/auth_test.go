@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCredentialsStayWithSourceHost(t *testing.T) {
+	type seen struct{ auth, apiKey, cookie string }
+	var mu sync.Mutex
+	requests := make(map[string]seen) // By path
+	record := func(r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		cookie := ""
+		if c, err := r.Cookie("session"); err == nil {
+			cookie = c.Value
+		}
+		requests[r.URL.Path] = seen{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key"), cookie}
+	}
+
+	pic := testPNG(t, 2, 2, color.Black)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pic)
+	}))
+	defer images.Close()
+	// Cookies don't tell ports apart, so the images are on another host name
+	imageURL := strings.Replace(images.URL, "127.0.0.1", "localhost", 1) + "/cdn/pic.png"
+
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != BearerAuth("t0ken") {
+			http.Error(w, "log in first", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Members</title></head><body><h3>One</h3><p>Members only.</p>` +
+			`<img src="own.png" alt="Own"><img src="` + imageURL + `" alt="Elsewhere"></body></html>`))
+	}))
+	defer pages.Close()
+
+	result, files := testBuild(t, "", Options{
+		SourceURL:     pages.URL + "/members/post.html",
+		Cookies:       []*http.Cookie{{Name: "session", Value: "s3ssion", Domain: "127.0.0.1", Path: "/"}},
+		Headers:       http.Header{"X-Api-Key": {"k3y"}},
+		Authorization: BearerAuth("t0ken"),
+	})
+	if body := sectionFile(t, result, files, "One"); !strings.Contains(body, "Members only.") {
+		t.Fatalf("page not fetched with its credentials:\n%s", body)
+	}
+	if _, ok := files["EPUB/images/pic.png"]; !ok {
+		t.Error("image from the other host not embedded")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := seen{BearerAuth("t0ken"), "k3y", "s3ssion"}
+	if got := requests["/members/post.html"]; got != want {
+		t.Errorf("page request had %+v, want %+v", got, want)
+	}
+	if got, ok := requests["/members/own.png"]; ok && got != want {
+		t.Errorf("image on the source host had %+v, want %+v", got, want)
+	}
+	if got, ok := requests["/cdn/pic.png"]; !ok || got != (seen{}) {
+		t.Errorf("image on another host requested = %v with %+v, want no credentials", ok, got)
+	}
+}
+
+func TestCredentialsDroppedOnRedirect(t *testing.T) {
+	type seen struct{ auth, apiKey string }
+	var mu sync.Mutex
+	requests := make(map[string]seen) // By host and path
+	pic := testPNG(t, 2, 2, color.Black)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Host+r.URL.Path] = seen{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pic)
+	}))
+	defer other.Close()
+	otherHost := strings.TrimPrefix(other.URL, "http://")
+	// Another port of the same address, and another host name for it
+	targets := map[string]string{
+		"/same-address.png": "http://" + otherHost + "/a.png",
+		"/other-name.png":   "http://" + strings.Replace(otherHost, "127.0.0.1", "localhost", 1) + "/b.png",
+	}
+
+	var sourceSeen seen
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if to, ok := targets[r.URL.Path]; ok {
+			http.Redirect(w, r, to, http.StatusFound)
+			return
+		}
+		mu.Lock()
+		sourceSeen = seen{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Members</title></head><body><h3>One</h3><p>Members only.</p>` +
+			`<img src="same-address.png" alt="A"><img src="other-name.png" alt="B"></body></html>`))
+	}))
+	defer pages.Close()
+
+	testBuild(t, "", Options{
+		SourceURL:     pages.URL + "/post.html",
+		Headers:       http.Header{"X-Api-Key": {"k3y"}},
+		Authorization: BasicAuth("reader", "pa55"),
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := (seen{BasicAuth("reader", "pa55"), "k3y"}); sourceSeen != want {
+		t.Errorf("page request had %+v, want %+v", sourceSeen, want)
+	}
+	for _, to := range targets {
+		key := strings.TrimPrefix(to, "http://")
+		if got, ok := requests[key]; !ok || got != (seen{}) {
+			t.Errorf("redirect to %s requested = %v with %+v, want no credentials", to, ok, got)
+		}
+	}
+}
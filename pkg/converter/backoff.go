@@ -0,0 +1,150 @@
+package converter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits on backing off from hosts that answer 429 Too Many Requests.
+const (
+	defaultRetryAfter = 5 * time.Second // Pause when the response doesn't say
+	maxRetryAfter     = 2 * time.Minute // Longest pause honoured
+)
+
+// hostBackoff tracks hosts that have asked us to slow down, and spaces out
+// requests to each host. It is shared by every request, so once one
+// download is told to wait, the others to that host wait too instead of
+// piling on.
+type hostBackoff struct {
+	mu    sync.Mutex
+	until map[string]time.Time // Host -> when requests may resume
+	last  map[string]time.Time // Host -> when the last request started, or is due to
+}
+
+var rateLimits = &hostBackoff{
+	until: make(map[string]time.Time),
+	last:  make(map[string]time.Time),
+}
+
+// wait blocks until requests to host may resume or ctx is done.
+func (b *hostBackoff) wait(ctx context.Context, host string) error {
+	b.mu.Lock()
+	until := b.until[host]
+	b.mu.Unlock()
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pause holds off requests to host for d.
+func (b *hostBackoff) pause(host string, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := time.Now().Add(d); until.After(b.until[host]) {
+		b.until[host] = until
+	}
+}
+
+// throttle blocks until a request to host may start, at least interval
+// after the start of the last one, or until ctx is done.
+func (b *hostBackoff) throttle(ctx context.Context, host string, interval time.Duration) error {
+	b.mu.Lock()
+	start := time.Now()
+	if next := b.last[host].Add(max(interval, 0)); next.After(start) {
+		start = next
+	}
+	b.last[host] = start // Reserve the slot so concurrent requests queue up
+	b.mu.Unlock()
+	d := time.Until(start)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// doRequest sends req with the client, user agent and credentials of the
+// retry policy in its context, first waiting out any pause on its host and
+// spacing it from the last request there as the policy asks. A 429 response
+// pauses the host for its Retry-After time; a failed connection, a timeout
+// or a 5xx response waits out the policy's backoff. Either way the request
+// is retried, up to the policy's retries; after that the last response or
+// error is returned.
+func doRequest(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	policy := retryPolicyFrom(ctx)
+	host := req.URL.Host
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", policy.userAgent)
+	}
+	policy.authorize(req)
+	for attempt := 0; ; attempt++ {
+		if err := rateLimits.wait(ctx, host); err != nil {
+			return nil, err
+		}
+		if err := rateLimits.throttle(ctx, host, policy.interval); err != nil {
+			return nil, err
+		}
+		fetcherLog.Debug("Requesting", "url", req.URL.String(), "attempt", attempt+1)
+		resp, err := policy.client.Do(req)
+		if attempt == policy.retries {
+			return resp, err
+		}
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			d := retryAfter(resp.Header.Get("Retry-After"))
+			fetcherLog.Warn("Host is rate limiting requests; pausing", "host", host, "pause", d)
+			rateLimits.pause(host, d)
+			continue
+		}
+		if !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		var problem string
+		if err != nil {
+			problem = err.Error()
+		} else {
+			resp.Body.Close()
+			problem = resp.Status
+		}
+		d := policy.delay(attempt)
+		fetcherLog.Warn("Retrying request", "url", req.URL.String(), "in", d.Round(time.Millisecond), "problem", problem)
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date.
+func retryAfter(value string) time.Duration {
+	d := defaultRetryAfter
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = time.Until(t)
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
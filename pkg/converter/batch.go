@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// BatchItem is one book in a batch file.
+type BatchItem struct {
+	URL     string `json:"url"`     // Page to convert
+	Archive string `json:"archive"` // Or a .zip/.tar.gz of chapters
+	Output  string `json:"output"`  // Where the EPUB is written
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+}
+
+// LoadBatch reads a batch file: a JSON array of items.
+func LoadBatch(filePath string) ([]BatchItem, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file '%s': %w", filePath, err)
+	}
+	var items []BatchItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file '%s': %w", filePath, err)
+	}
+	for i, item := range items {
+		if item.Output == "" {
+			return nil, fmt.Errorf("batch item %d has no output path", i+1)
+		}
+		if item.URL == "" && item.Archive == "" {
+			return nil, fmt.Errorf("batch item %d (%s) has no url or archive", i+1, item.Output)
+		}
+	}
+	return items, nil
+}
+
+// BatchState records which outputs of a batch run were built successfully,
+// so a resumed run skips them.
+type BatchState struct {
+	path      string
+	Completed map[string]bool `json:"completed"` // Keyed by output path
+}
+
+// LoadBatchState reads the state file at statePath; a missing file is an
+// empty state.
+func LoadBatchState(statePath string) (*BatchState, error) {
+	s := &BatchState{path: statePath, Completed: make(map[string]bool)}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch state '%s': %w", statePath, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state '%s': %w", statePath, err)
+	}
+	if s.Completed == nil {
+		s.Completed = make(map[string]bool)
+	}
+	return s, nil
+}
+
+// done reports whether an earlier run recorded output as built and it is
+// still there. An output the state doesn't record may be left over from
+// anything, so it's built again.
+func (s *BatchState) done(output string) bool {
+	if !s.Completed[output] {
+		return false
+	}
+	_, err := os.Stat(output)
+	return err == nil
+}
+
+// markDone records output as built and saves the state.
+func (s *BatchState) markDone(output string) error {
+	s.Completed[output] = true
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// RunBatch builds every item, using base for all settings an item doesn't
+// override. Items state records as built by an earlier, e.g. interrupted,
+// run are skipped if their output is still there; a failed item is logged
+// and the rest still run. It returns the outputs it built.
+func RunBatch(ctx context.Context, items []BatchItem, base Options, state *BatchState) ([]string, error) {
+	var built, failed []string
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return built, ctx.Err()
+		}
+		if state.done(item.Output) {
+			writerLog.Info("Skipping item already built", "output", item.Output)
+			continue
+		}
+
+		opts := base
+		opts.SourceURL = item.URL
+		opts.Archive = item.Archive
+		opts.OutputPath = item.Output
+		opts.HTMLCache = "" // Items must not share the single-page cache
+		opts.Metadata = base.Metadata.Merge(Metadata{Title: item.Title, Author: item.Author})
+
+		if _, err := build(ctx, opts); err != nil {
+			writerLog.Error("Could not build item", "output", item.Output, "err", err)
+			failed = append(failed, item.Output)
+			continue
+		}
+		built = append(built, item.Output)
+		if err := state.markDone(item.Output); err != nil {
+			writerLog.Warn("Could not save batch state", "err", err)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return built, fmt.Errorf("%d of %d batch items failed: %v", len(failed), len(items), failed)
+	}
+	return built, nil
+}
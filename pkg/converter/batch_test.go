@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunBatchResumes(t *testing.T) {
+	var fetched atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<h3>Book</h3><p>The book at %s.</p>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		exists    bool // one.epub is there, e.g. half written by a killed run
+		recorded  bool // The state file records one.epub as built
+		wantBuilt []string
+	}{
+		{name: "recorded and there", exists: true, recorded: true, wantBuilt: []string{"two.epub"}},
+		{name: "partial output not recorded", exists: true, wantBuilt: []string{"one.epub", "two.epub"}},
+		{name: "recorded but gone", recorded: true, wantBuilt: []string{"one.epub", "two.epub"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched.Store(0)
+			dir := t.TempDir()
+			one, two := filepath.Join(dir, "one.epub"), filepath.Join(dir, "two.epub")
+			if tt.exists {
+				if err := os.WriteFile(one, []byte("PK\x03\x04 cut short"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			state, err := LoadBatchState(filepath.Join(dir, "batch.state"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.recorded {
+				if err := state.markDone(one); err != nil {
+					t.Fatal(err)
+				}
+			}
+			items := []BatchItem{
+				{URL: srv.URL + "/one.html", Output: one},
+				{URL: srv.URL + "/two.html", Output: two},
+			}
+
+			built, err := RunBatch(context.Background(), items, Options{ImageDir: filepath.Join(dir, "images")}, state)
+			if err != nil {
+				t.Fatalf("RunBatch: %v", err)
+			}
+			var names []string
+			for _, b := range built {
+				names = append(names, filepath.Base(b))
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantBuilt, ",") {
+				t.Errorf("built %v, want %v", names, tt.wantBuilt)
+			}
+			if n := fetched.Load(); int(n) != len(tt.wantBuilt) {
+				t.Errorf("server got %d requests, want %d", n, len(tt.wantBuilt))
+			}
+			for _, output := range []string{one, two} {
+				if !state.Completed[output] {
+					t.Errorf("state doesn't record %s as built", filepath.Base(output))
+				}
+			}
+			if len(tt.wantBuilt) == 2 {
+				if files := epubFiles(t, one); files["mimetype"] != "application/epub+zip" {
+					t.Errorf("rebuilt %s isn't an EPUB", filepath.Base(one))
+				}
+			} else if data, err := os.ReadFile(one); err != nil || !strings.HasSuffix(string(data), "cut short") {
+				t.Errorf("recorded output was rewritten: %q, %v", data, err)
+			}
+			saved, err := LoadBatchState(filepath.Join(dir, "batch.state"))
+			if err != nil || !saved.Completed[two] {
+				t.Errorf("saved state doesn't record %s: %v", filepath.Base(two), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,659 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-shiori/go-epub"
+)
+
+// Options configures a build.
+type Options struct {
+	SourceURL     string        // Page to convert
+	SourceURLs    []string      // If set, pages converted in order instead of SourceURL, each starting a chapter
+	Archive       string        // If set, a .zip or .tar.gz of HTML chapters converted instead of SourceURL
+	Paths         []string      // If set, local HTML files, directories and glob patterns converted instead of SourceURL, each file a chapter
+	Inputs        []Input       // If set, the sources converted in order instead of SourceURL, e.g. from a Project
+	HTMLCache     string        // Local copy of the page, used instead of fetching when present
+	CacheDir      string        // If set, pages and stylesheets are cached here by URL and revalidated, instead of using HTMLCache
+	StatePath     string        // If set, pages fetched are recorded here so an interrupted build resumes without fetching them again
+	OutputPath    string        // Where the EPUB is written
+	ImageDir      string        // Where downloaded images are kept; removed again if the build created it and fails
+	DebugHTMLDir  string        // If set, each section's generated XHTML is dumped here
+	TitleCase     TitleCaseMode // How extracted section titles are re-cased
+	SectionMarker SectionMarker // Elements that also start a section, e.g. div.chapter; none if zero
+	SkipElements  []string      // Elements left out with their contents; nav, footer, aside and header if nil
+	Select        Selector      // If set, each page is narrowed to the elements matching it, e.g. article.main; pages it matches nothing in are kept whole
+	Remove        Selector      // Elements dropped with their contents before Select applies, e.g. ".ads, .share-buttons"
+	Recipes       []Recipe      // Site rules matched by page host, the first match winning; see BuiltinRecipes
+	Subtitles     SubtitleMode  // What a lower heading right after a section heading is; none by default
+	MaxImageSize  image.Point   // Images larger than this are downscaled; zero means no limit
+	ConvertImages ImageFormat   // What WebP, AVIF and other images readers may not display become; kept by default
+	ImageQuality  int           // If set, JPEGs are re-encoded at this quality where it shrinks them; zero leaves them, using DefaultImageQuality for other encoding
+	Metadata      Metadata      // Book-level metadata
+	Timeout       time.Duration // Deadline for the whole build; zero means no limit
+
+	CoverImage     string      // Local path or URL of the cover image
+	CoverStyle     *CoverStyle // If set and there's no CoverImage, an SVG cover with the title and author is generated
+	ThumbnailSize  int         // If set, a cover thumbnail fitting in this many pixels square is made
+	EmbedThumbnail bool        // Also embed the cover thumbnail as its own manifest item
+
+	AltText   map[string]string // Alt text overrides keyed by image URL (absolute or as written in src)
+	ImageText ImageTextMode     // Whether alt or caption text is also written next to images; none by default
+
+	FollowRefresh bool        // Follow <meta http-equiv="refresh"> redirects to the real page
+	Comments      CommentMode // What to do with HTML comments; drop by default
+
+	// Crawl, if set, follows "next" links from SourceURL to up to this many
+	// further pages, each converted like a page of SourceURLs. NextLink
+	// selects the link, or an element containing it; if zero, the link is
+	// the rel="next" one, or else one reading e.g. "Next chapter".
+	Crawl    int
+	NextLink SectionMarker
+
+	// SectionHeadings are the elements that start a section, outermost first;
+	// each one's sections nest in the table of contents under the sections
+	// of those before it. Only h3 starts sections if nil.
+	SectionHeadings []SectionMarker
+
+	// SourceSeparator, if set, is a text/template label for a divider section
+	// inserted between merged sources, e.g. "Part {{.Index}}: {{.Title}}".
+	SourceSeparator string
+
+	NavTitle string // Heading of the navigation document, e.g. "Contents"; go-epub's default if empty
+
+	// ContentsPage inserts a table of contents page, headed like the
+	// navigation document, at the front of the book, listing its sections nested as
+	// in the navigation document.
+	ContentsPage bool
+
+	// EmbedCSS embeds each source's linked and inline stylesheets, along
+	// with the images they reference through url(...).
+	EmbedCSS bool
+
+	// Stylesheets are local CSS files added, as they are, to every section,
+	// after any embedded page CSS so their rules win.
+	Stylesheets []string
+
+	PruneResources bool // Drop embedded resources no section or stylesheet refers to
+
+	// ReadingOrder, if set, lists section titles or source names in the order
+	// their sections should be read; unlisted sections follow at the end.
+	ReadingOrder []string
+
+	KeepEmptyBlocks bool // Keep paragraphs with nothing visible, e.g. only zero-width spaces, at the start and end of sections instead of dropping them
+
+	// ImageName, if set, names each embedded image given the URL it came
+	// from and its 1-based position in the book, e.g. "img-001.png". Names
+	// can't contain directories; slashes are replaced with dashes.
+	ImageName func(sourceURL string, index int) (filename string)
+
+	// InMemory keeps the build's files off the disk: the HTML caches aren't
+	// used and images and stylesheets are held in memory. Only go-epub's
+	// scratch directory, removed once the book is written, is still used.
+	// Combine with SourceHTML and Output for a build that reads and writes no
+	// files of its own.
+	InMemory   bool
+	SourceHTML []byte    // If set, the page to convert, with SourceURL only used to resolve links
+	Output     io.Writer // If set, the EPUB is written here instead of to OutputPath
+
+	// MediaStore, if set, keeps fetched images, stylesheets and covers until
+	// they are embedded, in place of ImageDir or memory.
+	MediaStore MediaStore
+
+	// AccessibilityReport, if set, is where a report of accessibility
+	// problems in the source is written: JSON if it ends in .json,
+	// otherwise text.
+	AccessibilityReport string
+
+	// IndexPath, if set, is where a JSON index of the sections is written for
+	// reader apps, with thumbnails of the cover and each section's first image.
+	IndexPath string
+
+	Workers      int // Sections sanitized and thumbnailed at once; GOMAXPROCS if zero
+	ImageWorkers int // Images downloaded at once, ahead of extracting each source; DefaultImageWorkers if zero
+	PageWorkers  int // Pages of SourceURLs or Inputs fetched and parsed at once; DefaultPageWorkers if zero
+
+	// RequestTimeout bounds each HTTP request, body included. A request that
+	// fails, times out or gets a 5xx answer is tried again up to Retries
+	// times (none if negative), after RetryBackoff doubled for each earlier
+	// retry, with random jitter. Each is the package default if zero.
+	RequestTimeout time.Duration
+	Retries        int
+	RetryBackoff   time.Duration
+
+	// UserAgent is sent with every request; DefaultUserAgent() if empty.
+	// RequestDelay spaces out the requests to each host, so there's at
+	// least this long between their starts. Crawling skips pages robots.txt
+	// disallows for the user agent, and spaces out the pages it follows as
+	// far as robots.txt asks, unless IgnoreRobots is set; pages named
+	// directly are always fetched.
+	UserAgent    string
+	RequestDelay time.Duration
+	IgnoreRobots bool
+
+	// Cookies, e.g. from LoadCookies, are sent with requests to the sites
+	// they belong to, as are cookies the sites set during the build. Headers
+	// and Authorization (e.g. from BasicAuth or BearerAuth) are sent only
+	// with requests to the hosts of the pages the build names, so that
+	// credentials don't leak to image and stylesheet hosts.
+	Cookies       []*http.Cookie
+	Headers       http.Header
+	Authorization string
+
+	// Proxy, if set, is the HTTP, HTTPS or SOCKS5 proxy every request goes
+	// through; otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY are obeyed.
+	Proxy *url.URL
+
+	// PublicOnly refuses to connect to addresses that aren't public:
+	// loopback, private, link-local (as the 169.254.169.254 of cloud
+	// metadata services) and unspecified ones, as a server fetching the URLs
+	// its clients name must. The address is checked as it's dialed, after
+	// DNS and on every redirect. The proxy environment variables are then
+	// ignored, as a proxy would reach what the check keeps out. With Proxy
+	// set, it's the host of each request that's resolved and checked
+	// instead, since only the proxy is dialed.
+	PublicOnly bool
+
+	// Attribution appends a section crediting the source, filled in from
+	// AttributionTemplate (an html/template; a standard one if empty) with
+	// the title, author, source URL and License.
+	Attribution         bool
+	AttributionTemplate string
+	License             string // License text for the attribution section, e.g. the Project Gutenberg License notice
+
+	// LeadingTitle names the section holding a page's content before its
+	// first heading; the page's <title>, or "Introduction", if empty.
+	// Leading says what becomes of that content of the first page; it's
+	// kept as a section of its own by default.
+	LeadingTitle string
+	Leading      LeadingMode
+
+	Colophon bool // Append a colophon recording the tool version, build time, source and counts
+
+	// MinText, if set, fails the build when fewer characters of text than
+	// this are extracted, as from a page that renders with JavaScript.
+	MinText int
+
+	Presentational PresentationMode // Whether align and bgcolor become inline CSS; stripped by default
+
+	// DedupImages finds images that are resized copies of each other, such
+	// as a thumbnail and its full version, by their perceptual hashes, and
+	// points every reference at the largest copy. Hashes may differ in
+	// DedupThreshold of their 64 bits. Built EPUBs also drop the smaller
+	// copies; Convert and Sections leave them in.
+	DedupImages    bool
+	DedupThreshold int
+
+	// Footnotes says what becomes of footnotes, the notes that links marked
+	// up as note references, or that look like them ("[1]" or a number in
+	// superscript), point at: kept in place by default.
+	Footnotes FootnoteMode
+
+	// Endnotes moves footnotes into a section of their own at the end of
+	// the book and links their references to it as popup notes.
+	//
+	// Deprecated: Set Footnotes to FootnotesEndnotes instead.
+	Endnotes bool
+
+	// ChapterDir, if set, is where each section is also written as an EPUB
+	// of its own, numbered as a part of the book: its title is the book's
+	// title, PartLabel ("Part" by default) and number, and the section's
+	// title, and the book's title becomes its series if there isn't one.
+	ChapterDir string
+	PartLabel  string
+
+	// Compression controls how files are compressed in the EPUB zip;
+	// CompressionLevel is the compress/flate level for deflated files, from
+	// 1 (fastest) to 9 (smallest), or flate's default if zero.
+	Compression      CompressionMode
+	CompressionLevel int
+
+	// SingleFile puts the whole book in one section, for readers that
+	// scroll through one file better, with a table of contents pointing at
+	// each extracted section's place in it. The colophon, written once the
+	// book is assembled, still comes after it as a file of its own.
+	SingleFile bool
+
+	// TableImages embeds tables with more than TableImageColumns columns
+	// (8 if zero), or with tables nested in them, as SVG images with their
+	// text as the alt text, since readers lay them out poorly.
+	TableImages       bool
+	TableImageColumns int
+
+	// Spread and Flow set the rendition:spread and rendition:flow
+	// properties of the package document, e.g. for fixed-layout books;
+	// unset if empty.
+	Spread RenditionSpread
+	Flow   RenditionFlow
+
+	// NormalizeHeadings renumbers each page's headings so the levels it
+	// uses are consecutive from h1, as h1, h3 and h5 become h1, h2 and h3,
+	// before sections are extracted. SectionHeadings, and the default h3,
+	// then name the renumbered levels, so "h1,h2,h3" splits and nests such a
+	// page at each of its three levels.
+	NormalizeHeadings bool
+
+	// Gutenberg strips Project Gutenberg's header and license from each
+	// page, keeping the book between its START and END markers, takes the
+	// title and author from the header, and starts sections at the heading
+	// its chapter anchors mark unless SectionHeadings says otherwise.
+	Gutenberg bool
+
+	// Validate checks the finished EPUB the way EPUBCheck does before it is
+	// written, recording any problems in the Summary's Validation.
+	Validate bool
+
+	// Progress, if set, is called as the build fetches each page, downloads
+	// each image and starts writing the EPUB, one call at a time.
+	Progress func(ProgressEvent)
+}
+
+// Convert parses the HTML page read from r, resolving its links and images
+// against base, and returns it as an EPUB ready for WriteTo, without writing
+// anything itself. Options describing other inputs (SourceURL, SourceURLs, Paths, Inputs,
+// Archive, HTMLCache) and outputs (OutputPath, Output, IndexPath, AccessibilityReport,
+// ChapterDir) are ignored, as are those that rewrite the finished EPUB file:
+// extra identifiers, series and subjects in Metadata, Spread, Flow, NavTitle,
+// PruneResources and the in-file table of contents of SingleFile. If ctx is
+// cancelled or opts.Timeout passes first, the context's error is returned.
+func Convert(ctx context.Context, r io.Reader, base *url.URL, opts Options) (_ *epub.Epub, err error) {
+	page, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTML: %w", err)
+	}
+	opts.SourceHTML, opts.SourceURL, opts.Archive, opts.IndexPath, opts.AccessibilityReport = page, "", "", "", ""
+	opts.SourceURLs, opts.Paths, opts.Inputs = nil, nil, nil
+	if base != nil {
+		opts.SourceURL = base.String()
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	removeImageDir, err := opts.makeImageDir()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			removeImageDir()
+		}
+	}()
+	e, _, err := assemble(ctx, opts)
+	return e, err
+}
+
+// build converts the documents described by opts into an EPUB written to
+// opts.OutputPath. If ctx is cancelled or opts.Timeout passes first, the
+// build stops, no EPUB is written, and the context's error is returned.
+func build(ctx context.Context, opts Options) (_ *Result, err error) {
+	start := time.Now()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	removeImageDir, err := opts.makeImageDir()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			removeImageDir() // Nothing will use what a failed build downloaded
+		}
+	}()
+	ctx = opts.withProgress(ctx)
+	e, result, err := assemble(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	meta := opts.Metadata
+	writeStart := time.Now()
+
+	// Write EPUB file
+	if ctx.Err() != nil {
+		return nil, buildAborted(ctx, opts)
+	}
+	progressFrom(ctx).start(ProgressWriting)
+	data, err := finishEPUB(e, meta, result.nav, result.contents, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.duplicates) > 0 {
+		if data, err = removeResources(data, result.duplicates); err != nil {
+			return nil, fmt.Errorf("error removing duplicate images: %w", err)
+		}
+		result.prune(result.duplicates)
+	}
+	if opts.PruneResources {
+		keep := make(map[string]bool)
+		if c := result.summary.Cover; c != nil && c.Thumbnail != nil && c.Thumbnail.Path != "" {
+			keep[internalArchivePath(c.Thumbnail.Path)] = true // Embedded for readers to find, not referenced
+		}
+		var pruned []string
+		data, pruned, err = pruneEPUB(data, keep)
+		if err != nil {
+			return nil, fmt.Errorf("error pruning EPUB resources: %w", err)
+		}
+		result.prune(pruned)
+	}
+	if opts.Compression != "" && opts.Compression != CompressionDefault {
+		data, err = recompressEPUB(data, opts.Compression, opts.CompressionLevel)
+		if err != nil {
+			return nil, fmt.Errorf("error compressing EPUB: %w", err)
+		}
+	}
+	if opts.Validate {
+		if result.summary.Validation, err = ValidateEPUB(data); err != nil {
+			return nil, fmt.Errorf("error validating EPUB: %w", err)
+		}
+	}
+	if opts.Output != nil {
+		if _, err := opts.Output.Write(data); err != nil {
+			return nil, fmt.Errorf("error writing EPUB: %w", err)
+		}
+		writerLog.Info("Wrote EPUB to writer", "bytes", len(data), "sections", len(result.summary.Sections))
+	} else {
+		if err := writeOutput(opts.OutputPath, data); err != nil {
+			return nil, err
+		}
+		writerLog.Info("Wrote EPUB", "output", opts.OutputPath, "bytes", len(data), "sections", len(result.summary.Sections))
+	}
+	removeJobState(opts.StatePath)
+
+	if opts.ChapterDir != "" {
+		if result.summary.Chapters, err = writeChapters(opts, result); err != nil {
+			return nil, fmt.Errorf("error writing chapter EPUBs: %w", err)
+		}
+	}
+
+	if opts.AccessibilityReport != "" {
+		if err := writeA11yReport(opts.AccessibilityReport, result.summary.Accessibility); err != nil {
+			return nil, err
+		}
+	}
+
+	if index := result.index; index != nil {
+		if c := result.summary.Cover; c != nil {
+			if index.Cover, err = thumbnailDataURL(opts.mediaStore(), c.source); err != nil {
+				imagesLog.Warn("Could not make index thumbnail for the cover", "err", err)
+			}
+		}
+		if err := writeIndex(opts.IndexPath, *index); err != nil {
+			return nil, err
+		}
+	}
+
+	result.summary.Metrics.Write = time.Since(writeStart)
+	result.summary.Metrics.Total = time.Since(start)
+	return result, nil
+}
+
+// writeEPUB writes e out. go-epub assembles the book in a scratch
+// directory of its own, under os.TempDir, and removes it once the book is
+// written. Its storage could be switched to memory, but that switch is
+// process-wide, so it would catch books written at the same time elsewhere,
+// e.g. those Convert returns, and its in-memory storage isn't safe for
+// concurrent use.
+func writeEPUB(e *epub.Epub) ([]byte, error) {
+	var out bytes.Buffer
+	if _, err := e.WriteTo(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// finishEPUB writes e out and makes the changes go-epub has no API for:
+// the metadata in meta it can't set, the rendition properties, the
+// navigation title and, if set, a table of contents of nav entries instead
+// of one entry per section and the contents entries of the table of
+// contents page.
+func finishEPUB(e *epub.Epub, meta Metadata, nav, contents []navEntry, opts Options) ([]byte, error) {
+	written, err := writeEPUB(e)
+	if err != nil {
+		return nil, fmt.Errorf("error writing EPUB file: %w", err)
+	}
+	edits := epubEdits{}
+	if extra := append(meta.opfElements(), opts.renditionElements()...); len(extra) > 0 {
+		edits.add(packageDocumentPath, func(b []byte) []byte { return insertOPFMetadata(b, extra) })
+	}
+	if opts.NavTitle != "" {
+		edits.add(navDocumentPath, func(b []byte) []byte { return setNavTitle(b, opts.NavTitle) })
+	}
+	if len(nav) > 0 {
+		edits.add(navDocumentPath, func(b []byte) []byte { return setNavEntries(b, nav) })
+		edits.add(ncxPath, func(b []byte) []byte { return setNCXEntries(b, nav) })
+	}
+	if contents != nil {
+		edits.add(contentsPagePath, func(b []byte) []byte { return setContentsEntries(b, contents) })
+	}
+	data, err := edits.apply(written)
+	if err != nil {
+		return nil, fmt.Errorf("error finishing EPUB file: %w", err)
+	}
+	return data, nil
+}
+
+// assemble fetches and extracts the documents described by opts into a new
+// EPUB, ready to be written.
+func assemble(ctx context.Context, opts Options) (*epub.Epub, *Result, error) {
+	ctx = opts.withRetryPolicy(ctx)
+	if progressFrom(ctx) == nil {
+		ctx = opts.withProgress(ctx)
+	}
+	result := &Result{summary: Summary{Output: opts.OutputPath}}
+	metrics := &result.summary.Metrics
+
+	// Fetch, read and parse the input documents
+	start := time.Now()
+	sources, err := loadSources(ctx, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, buildAborted(ctx, opts)
+		}
+		return nil, nil, err
+	}
+	for _, src := range sources {
+		metrics.Parse += src.parseTime
+	}
+	metrics.Fetch = time.Since(start) - metrics.Parse
+
+	// Create EPUB
+	meta := opts.Metadata
+	if len(sources) > 0 {
+		meta = detectMetadata(sources[0].doc).Merge(sources[0].meta).Merge(meta) // Configured values win
+	}
+	e, err := epub.NewEpub(meta.Title)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating EPUB: %w", err)
+	}
+	meta.apply(e)
+
+	if opts.AccessibilityReport != "" {
+		result.summary.Accessibility = checkAccessibility(sources, opts)
+	}
+
+	// Add the cover
+	if opts.CoverImage != "" || opts.CoverStyle != nil {
+		start := time.Now()
+		cover, err := addCover(ctx, e, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error adding cover: %w", err)
+		}
+		result.addCover(cover, opts.mediaStore())
+		metrics.Images += time.Since(start)
+	}
+
+	// Extract content and images
+	start, coverTime := time.Now(), metrics.Images
+	x, err := newExtractor(ctx, opts, e, result)
+	if err != nil {
+		return nil, nil, err
+	}
+	x.imageTotal = x.countImages(sources)
+	x.collectLinks(sources)
+	for _, src := range sources {
+		x.extract(src)
+	}
+	if ctx.Err() != nil {
+		return nil, nil, buildAborted(ctx, opts)
+	}
+	sections := reorderSections(x.finish(), opts.ReadingOrder)
+	parserLog.Info("Split pages into sections", "pages", len(sources), "sections", len(sections))
+	if opts.DedupImages {
+		replace := similarImages(x.embedded, opts.DedupThreshold)
+		replaceImages(sections, replace)
+		for from := range replace {
+			result.duplicates = append(result.duplicates, internalArchivePath(from))
+		}
+		sort.Strings(result.duplicates)
+	}
+	if err := checkExtractedText(sections, sources, opts); err != nil {
+		return nil, nil, err
+	}
+	if opts.Attribution {
+		s, err := attributionSection(opts, meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		sections = append(sections, s)
+	}
+	var anchors []anchoredSection
+	if opts.SingleFile && len(sections) > 0 {
+		title := meta.Title
+		if title == "" {
+			title = sections[0].Title
+		}
+		var merged Section
+		merged, anchors = mergeSections(sections, title)
+		sections = []Section{merged}
+	}
+	nameSections(sections)
+	x.resolveLinks(sections)
+
+	// Add the sections to the EPUB, after the table of contents page
+	var toc tocBuilder
+	contents := contentsSection(opts)
+	if opts.ContentsPage {
+		filename, err := e.AddSection(contents.Body, contents.Title, contents.filename, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error adding table of contents page: %w", err)
+		}
+		result.summary.Sections = append(result.summary.Sections, SectionInfo{Title: contents.Title, Filename: filename, Size: len(contents.Body)})
+	}
+	index := bookIndex{Title: meta.Title, Sections: []indexEntry{}}
+	var parents []Section // Added sections the next one may nest under, outermost first
+	for _, p := range prepareSections(sections, opts) {
+		s := p.section
+		if p.err != nil {
+			writerLog.Warn("Skipping malformed section", "section", s.Title, "err", p.err)
+			result.summary.Skipped = append(result.summary.Skipped, SkippedSection{Title: s.Title, Body: s.Body, Reason: p.err.Error()})
+			continue
+		}
+		for len(parents) > 0 && parents[len(parents)-1].Level >= s.Level {
+			parents = parents[:len(parents)-1]
+		}
+		var filename string
+		if len(parents) > 0 {
+			filename, err = e.AddSubSection(parents[len(parents)-1].filename, s.Body, s.Title, s.filename, s.CSS)
+		} else {
+			filename, err = e.AddSection(s.Body, s.Title, s.filename, s.CSS)
+		}
+		if err != nil {
+			writerLog.Warn("Could not add section", "section", s.Title, "err", err)
+			continue
+		}
+		parents = append(parents, Section{Level: s.Level, filename: filename})
+		result.summary.Sections = append(result.summary.Sections, SectionInfo{Title: s.Title, Filename: filename, Size: len(s.Body)})
+		toc.add(s, filename, anchors)
+		if opts.ChapterDir != "" {
+			s.filename = filename
+			result.chapters = append(result.chapters, s)
+		}
+		if opts.IndexPath != "" {
+			index.Sections = append(index.Sections, newIndexEntry(s, filename, p.thumbnail))
+		}
+		if opts.DebugHTMLDir != "" {
+			if err := dumpSection(opts.DebugHTMLDir, filename, s.Body); err != nil {
+				writerLog.Warn("Could not dump section", "section", s.Title, "err", err)
+			}
+		}
+	}
+
+	// The colophon describes everything before it, so it's added last
+	if opts.Colophon {
+		s, err := colophonSection(opts, meta, result.summary)
+		if err != nil {
+			return nil, nil, err
+		}
+		filename, err := e.AddSection(s.Body, s.Title, "", "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error adding colophon: %w", err)
+		}
+		toc.add(s, filename, nil)
+		result.summary.Sections = append(result.summary.Sections, SectionInfo{Title: s.Title, Filename: filename, Size: len(s.Body)})
+	}
+
+	if ctx.Err() != nil {
+		return nil, nil, buildAborted(ctx, opts)
+	}
+	if opts.ContentsPage {
+		result.contents = toc.entries
+	}
+	if anchors != nil {
+		result.nav = toc.entries
+		if opts.ContentsPage {
+			result.nav = append([]navEntry{{Title: contents.Title, Href: "xhtml/" + contents.filename}}, toc.entries...)
+		}
+	}
+	metrics.Extract = time.Since(start) - (metrics.Images - coverTime)
+	if opts.IndexPath != "" {
+		result.index = &index
+	}
+	return e, result, nil
+}
+
+// makeImageDir creates ImageDir, if images are kept there, for a build to
+// download them to. The returned func removes it again, for a build that
+// fails or is stopped, if it was created here and no StatePath keeps the
+// downloads for a later run to resume with.
+func (opts Options) makeImageDir() (remove func(), err error) {
+	remove = func() {}
+	if _, ok := opts.mediaStore().(dirStore); !ok {
+		return remove, nil
+	}
+	if _, err := os.Stat(opts.ImageDir); err == nil {
+		return remove, nil
+	}
+	if err := os.MkdirAll(opts.ImageDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating temp image directory: %w", err)
+	}
+	if opts.StatePath != "" {
+		return remove, nil
+	}
+	return func() {
+		if err := os.RemoveAll(opts.ImageDir); err != nil {
+			imagesLog.Warn("Could not remove image directory", "dir", opts.ImageDir, "err", err)
+		}
+	}, nil
+}
+
+// buildAborted returns the error for a build stopped by ctx.
+func buildAborted(ctx context.Context, opts Options) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && opts.Timeout > 0 {
+		return fmt.Errorf("build exceeded its %s deadline: %w", opts.Timeout, ctx.Err())
+	}
+	return fmt.Errorf("build aborted: %w", ctx.Err())
+}
@@ -0,0 +1,333 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-shiori/go-epub"
+)
+
+// slowServer serves nothing until the request is given up on.
+func slowServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBuildDeadline(t *testing.T) {
+	srv := slowServer(t)
+	tests := []struct {
+		name        string
+		existingDir bool
+		statePath   bool
+		wantDir     bool
+	}{
+		{name: "created directory removed"},
+		{name: "existing directory kept", existingDir: true, wantDir: true},
+		{name: "kept for resuming", statePath: true, wantDir: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := Options{
+				SourceURL:  srv.URL + "/book.html",
+				OutputPath: filepath.Join(dir, "book.epub"),
+				ImageDir:   filepath.Join(dir, "images"),
+				Timeout:    100 * time.Millisecond,
+			}
+			if tt.existingDir {
+				if err := os.Mkdir(opts.ImageDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.statePath {
+				opts.StatePath = filepath.Join(dir, "state.json")
+			}
+
+			start := time.Now()
+			_, err := build(context.Background(), opts)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("build error = %v, want the deadline exceeded", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("build took %s to stop after a 100ms deadline", elapsed)
+			}
+			if _, err := os.Stat(opts.OutputPath); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("EPUB written by a build past its deadline")
+			}
+			if _, err := os.Stat(opts.ImageDir); (err == nil) != tt.wantDir {
+				t.Errorf("image directory exists = %v, want %v", err == nil, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestConvertCancelledRemovesImageDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir := filepath.Join(t.TempDir(), "images")
+	page := `<h1>One</h1><p>Text.</p><img src="pic.png">`
+	_, err := Convert(ctx, strings.NewReader(page), nil, Options{ImageDir: dir})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Convert error = %v, want cancelled", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("image directory left behind by a cancelled build")
+	}
+}
+
+func TestInMemoryAndDiskBuildsSideBySide(t *testing.T) {
+	page := []byte(`<h1>Book</h1><h2>One</h2><p>First.</p><h2>Two</h2><p>Second.</p>`)
+	dir := t.TempDir()
+	errs := make(chan error, 12)
+	for i := range 12 {
+		go func() {
+			opts := Options{SourceHTML: page, ImageDir: filepath.Join(dir, "images")}
+			out := filepath.Join(dir, fmt.Sprintf("book%d.epub", i))
+			var err error
+			switch i % 3 {
+			case 0:
+				var buf strings.Builder
+				_, err = New(opts).BuildTo(context.Background(), &buf)
+				if err == nil && buf.Len() == 0 {
+					err = errors.New("empty EPUB")
+				}
+			case 1:
+				opts.OutputPath = out
+				_, err = New(opts).Build(context.Background())
+			case 2:
+				// Books Convert returns are written by the caller, outside
+				// any build, while in-memory builds are writing theirs
+				var e *epub.Epub
+				if e, err = Convert(context.Background(), bytes.NewReader(page), nil, opts); err == nil {
+					err = e.Write(out)
+				}
+			}
+			if err == nil && i%3 != 0 {
+				var r *zip.ReadCloser
+				if r, err = zip.OpenReader(out); err != nil {
+					err = fmt.Errorf("%s isn't a zip: %w", filepath.Base(out), err)
+				} else {
+					r.Close()
+				}
+			}
+			errs <- err
+		}()
+	}
+	for range 12 {
+		if err := <-errs; err != nil {
+			t.Errorf("build: %v", err)
+		}
+	}
+}
+
+func TestInMemoryBuild(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/style.css": {"text/css", []byte(`p { background: url(dot.png) }`)},
+		"/dot.png":   {"image/png", testPNG(t, 2, 2, color.Black)},
+		"/pic.png":   {"image/png", testPNG(t, 4, 4, color.White)},
+	})
+	page := `<html><head><title>Book</title><link rel="stylesheet" href="style.css"></head>` +
+		`<body><h3>One</h3><p>First.</p><img src="pic.png" alt="Picture"><h3>Two</h3><p>Second.</p></body></html>`
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadDir(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	opts := Options{
+		SourceURL:  srv.URL + "/book.html",
+		SourceHTML: []byte(page),
+		InMemory:   true,
+		EmbedCSS:   true,
+		Output:     &out,
+		ImageDir:   filepath.Join(dir, "images"),
+		HTMLCache:  filepath.Join(dir, "page.html"),
+		OutputPath: filepath.Join(dir, "book.epub"),
+	}
+	result, err := New(opts).Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("in-memory build wrote files: %v, %v", entries, err)
+	}
+	if after, err := os.ReadDir(wd); err != nil || len(after) != len(before) {
+		t.Errorf("in-memory build wrote to the working directory: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("output isn't a zip: %v", err)
+	}
+	files := make(map[string]bool)
+	for _, f := range r.File {
+		files[f.Name] = true
+	}
+	if r.File[0].Name != "mimetype" {
+		t.Errorf("first entry is %s, want mimetype", r.File[0].Name)
+	}
+	for _, s := range result.Summary().Sections {
+		if !files["EPUB/xhtml/"+s.Filename] {
+			t.Errorf("EPUB lacks section %q (%s)", s.Title, s.Filename)
+		}
+	}
+	var images int
+	for name := range files {
+		if strings.HasPrefix(name, "EPUB/images/") {
+			images++
+		}
+	}
+	if images != 2 {
+		t.Errorf("EPUB has %d images, want the picture and the stylesheet's: %v", images, files)
+	}
+	if problems, err := ValidateEPUB(out.Bytes()); err != nil || len(problems) > 0 {
+		t.Errorf("in-memory EPUB isn't valid: %v, %v", problems, err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/book/img/a.png": {"image/png", testPNG(t, 3, 3, color.Black)},
+		"/img/b.png":      {"image/png", testPNG(t, 4, 4, color.White)},
+	})
+	base, _ := url.Parse(srv.URL + "/book/chapter.html")
+	navLink := regexp.MustCompile(`<a href="xhtml/[^"]+">([^<]+)</a>`)
+
+	tests := []struct {
+		name       string
+		page       string
+		base       *url.URL
+		opts       Options
+		wantTitles []string // In the navigation document
+		wantImages []string // Under EPUB/images/
+		wantOPF    []string // Fragments of the package document
+	}{
+		{
+			name:       "relative images against base",
+			page:       `<h3>One</h3><p>Text.</p><img src="img/a.png" alt="A">`,
+			base:       base,
+			wantTitles: []string{"One"},
+			wantImages: []string{"a.png"},
+		},
+		{
+			name:       "headings",
+			page:       `<h2>Part</h2><p>Opening.</p><h3>One</h3><p>First.</p><h3>Two</h3><p>Second.</p>`,
+			base:       base,
+			opts:       Options{SectionHeadings: []SectionMarker{{Tag: "h2"}, {Tag: "h3"}}},
+			wantTitles: []string{"Part", "One", "Two"},
+		},
+		{
+			name: "metadata from the page and options",
+			page: `<html><head><title>The Page</title><meta name="author" content="Page Author"></head>` +
+				`<body><h3>One</h3><p>Text.</p></body></html>`,
+			base:       base,
+			opts:       Options{Metadata: Metadata{Title: "The Book", Language: "fr"}},
+			wantTitles: []string{"One"},
+			wantOPF:    []string{">The Book</dc:title>", ">Page Author</dc:creator>", ">fr</dc:language>"},
+		},
+		{
+			name:       "nil base",
+			page:       `<h3>One</h3><p>Text.</p><img src="img/a.png" alt="A"><img src="` + srv.URL + `/img/b.png" alt="B">`,
+			opts:       Options{Retries: -1}, // The relative image can't be fetched
+			wantTitles: []string{"One"},
+			wantImages: []string{"b.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.ImageDir = filepath.Join(t.TempDir(), "images")
+			e, err := Convert(context.Background(), strings.NewReader(tt.page), tt.base, tt.opts)
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			out := filepath.Join(t.TempDir(), "book.epub")
+			if err := e.Write(out); err != nil {
+				t.Fatal(err)
+			}
+			files := epubFiles(t, out)
+
+			var titles []string
+			for _, m := range navLink.FindAllStringSubmatch(files["EPUB/nav.xhtml"], -1) {
+				titles = append(titles, m[1])
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.wantTitles, "|") {
+				t.Errorf("sections = %q, want %q", titles, tt.wantTitles)
+			}
+			var images []string
+			for name := range files {
+				if img, ok := strings.CutPrefix(name, "EPUB/images/"); ok {
+					images = append(images, img)
+				}
+			}
+			slices.Sort(images)
+			if strings.Join(images, "|") != strings.Join(tt.wantImages, "|") {
+				t.Errorf("images = %q, want %q", images, tt.wantImages)
+			}
+			for _, want := range tt.wantOPF {
+				if !strings.Contains(files[packageDocumentPath], want) {
+					t.Errorf("package document lacks %s:\n%s", want, files[packageDocumentPath])
+				}
+			}
+		})
+	}
+}
+
+func TestWroteEPUBLog(t *testing.T) {
+	page := []byte(`<html><head><title>Book</title></head><body><h3>One</h3><p>Text.</p></body></html>`)
+	stale := filepath.Join(t.TempDir(), "stale.epub")
+	tests := []struct {
+		name    string
+		output  bool
+		want    string
+		wantNot string
+	}{
+		{"to a file", false, `msg="Wrote EPUB" component=writer output=` + stale + ` bytes=`, "to writer"},
+		{"to a writer", true, `msg="Wrote EPUB to writer" component=writer bytes=`, stale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+
+			opts := Options{SourceHTML: page, ImageDir: filepath.Join(t.TempDir(), "images"), OutputPath: stale}
+			var out bytes.Buffer
+			if tt.output {
+				opts.Output = &out
+			}
+			if _, err := build(context.Background(), opts); err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if !strings.Contains(logged.String(), tt.want) || strings.Contains(logged.String(), tt.wantNot) {
+				t.Errorf("log lacks %q or has %q:\n%s", tt.want, tt.wantNot, logged.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-shiori/go-epub"
+)
+
+// writeChapters writes each section of the book result describes as an
+// EPUB of its own into opts.ChapterDir, and returns their paths. The
+// endnotes aren't a chapter; they go into every chapter that links to them.
+func writeChapters(opts Options, result *Result) ([]string, error) {
+	if err := os.MkdirAll(opts.ChapterDir, 0755); err != nil {
+		return nil, writeError(opts.ChapterDir, err)
+	}
+	var chapters []Section
+	var notes *Section
+	for _, s := range result.chapters {
+		if s.filename == endnotesFilename {
+			notes = &s
+			continue
+		}
+		chapters = append(chapters, s)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(opts.OutputPath), filepath.Ext(opts.OutputPath))
+	if base == "" || base == "." {
+		base = "chapter"
+	}
+	width := len(strconv.Itoa(len(chapters)))
+	var paths []string
+	for i, s := range chapters {
+		data, err := chapterEPUB(s, i+1, notes, opts, result)
+		if err != nil {
+			return paths, fmt.Errorf("failed to build chapter '%s': %w", s.Title, err)
+		}
+		filePath := filepath.Join(opts.ChapterDir, fmt.Sprintf("%s-%0*d.epub", base, width, i+1))
+		if err := writeOutput(filePath, data); err != nil {
+			return paths, err
+		}
+		paths = append(paths, filePath)
+	}
+	return paths, nil
+}
+
+// chapterEPUB builds s, the n'th chapter, as an EPUB with the book's
+// metadata and cover and the images and stylesheets s uses.
+func chapterEPUB(s Section, n int, notes *Section, opts Options, result *Result) ([]byte, error) {
+	label := opts.PartLabel
+	if label == "" {
+		label = "Part"
+	}
+	meta := opts.Metadata
+	book := meta.Title
+	meta.Title = fmt.Sprintf("%s %d: %s", label, n, s.Title)
+	if book != "" {
+		meta.Title = book + ", " + meta.Title
+		if meta.Series == "" {
+			meta.Series = book
+		}
+	}
+	meta.SeriesIndex = n
+	meta.Identifiers = nil // Each chapter is a publication of its own; go-epub generates an identifier
+
+	e, err := epub.NewEpub(meta.Title)
+	if err != nil {
+		return nil, err
+	}
+	meta.apply(e)
+
+	store := opts.mediaStore()
+	added := make(map[string]bool)
+	var addMedia func(internalPath string) error
+	addMedia = func(internalPath string) error {
+		loc, ok := result.media[internalPath]
+		if !ok || added[internalPath] {
+			return nil // External, or already added
+		}
+		added[internalPath] = true
+		source, err := epubSource(store, loc)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(internalPath, "../fonts/") {
+			_, err = e.AddFont(source, path.Base(internalPath))
+			return err
+		}
+		if !strings.HasPrefix(internalPath, "../css/") {
+			_, err = e.AddImage(source, path.Base(internalPath))
+			return err
+		}
+		if _, err := e.AddCSS(source, path.Base(internalPath)); err != nil {
+			return err
+		}
+		// Stylesheets refer to images and imported stylesheets by the same
+		// internal paths as sections do
+		css, err := store.Get(loc)
+		if err != nil {
+			return err
+		}
+		for _, m := range cssURLPattern.FindAllStringSubmatch(string(css), -1) {
+			if err := addMedia(strings.TrimSpace(m[2])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if c := result.summary.Cover; c != nil {
+		source, err := epubSource(store, c.source)
+		if err != nil {
+			return nil, err
+		}
+		internalPath, err := e.AddImage(source, path.Base(c.Path))
+		if err != nil {
+			return nil, err
+		}
+		if err := e.SetCover(internalPath, ""); err != nil {
+			return nil, err
+		}
+		added[c.Path] = true
+	}
+	sections := []Section{s}
+	if notes != nil && strings.Contains(s.Body, endnotesFilename+"#") {
+		sections = append(sections, *notes)
+	}
+	for i := range sections {
+		// Other chapters aren't in this EPUB
+		sections[i].Body = sectionLinkPattern.ReplaceAllStringFunc(sections[i].Body, func(m string) string {
+			sub := sectionLinkPattern.FindStringSubmatch(m)
+			if sub[1] == s.filename {
+				return m
+			}
+			return unlinked(sub[2], sub[3])
+		})
+	}
+	for _, s := range sections {
+		if s.CSS != "" {
+			if err := addMedia(s.CSS); err != nil {
+				return nil, err
+			}
+		}
+		for _, m := range refAttrPattern.FindAllStringSubmatch(s.Body, -1) {
+			if err := addMedia(m[1]); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := e.AddSection(s.Body, s.Title, s.filename, s.CSS); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := finishEPUB(e, meta, nil, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Compression != "" && opts.Compression != CompressionDefault {
+		return recompressEPUB(data, opts.Compression, opts.CompressionLevel)
+	}
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChapterEPUBs(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{"/map.png": {"image/png", testPNG(t, 2, 2, color.Black)}})
+	page := `<html><head><title>Page</title></head><body>` +
+		`<h3>One</h3><p>First text.</p>` +
+		`<h3>Two</h3><p>Second text.</p><img src="` + srv.URL + `/map.png" alt="Map">` +
+		`<h3>Three</h3><p>Third text.</p></body></html>`
+	dir := t.TempDir()
+	out, chapterDir := filepath.Join(dir, "book.epub"), filepath.Join(dir, "chapters")
+	result, combined := testBuild(t, page, Options{
+		OutputPath: out,
+		ChapterDir: chapterDir,
+		PartLabel:  "Episode",
+		Metadata:   Metadata{Title: "Saga", Author: "Ann Writer"},
+	})
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		sectionFile(t, result, combined, title)
+	}
+	chapters := result.Summary().Chapters
+	var want []string
+	for i := 1; i <= 3; i++ {
+		want = append(want, filepath.Join(chapterDir, fmt.Sprintf("book-%d.epub", i)))
+	}
+	if fmt.Sprint(chapters) != fmt.Sprint(want) {
+		t.Fatalf("chapters = %q, want %q", chapters, want)
+	}
+	texts := []string{"First text.", "Second text.", "Third text."}
+	for i, path := range chapters {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems, err := ValidateEPUB(data); err != nil || len(problems) > 0 {
+			t.Errorf("%s: ValidateEPUB = %v, %v", path, problems, err)
+		}
+		files := epubFiles(t, path)
+		opf := files[packageDocumentPath]
+		for _, want := range []string{
+			fmt.Sprintf("Saga, Episode %d: %s", i+1, []string{"One", "Two", "Three"}[i]),
+			"Ann Writer",
+		} {
+			if !strings.Contains(opf, want) {
+				t.Errorf("%s metadata lacks %q:\n%s", path, want, opf)
+			}
+		}
+		var sectionTexts []string
+		for name, data := range files {
+			for _, text := range texts {
+				if strings.HasSuffix(name, ".xhtml") && strings.Contains(data, text) {
+					sectionTexts = append(sectionTexts, text)
+				}
+			}
+		}
+		if len(sectionTexts) != 1 || sectionTexts[0] != texts[i] {
+			t.Errorf("%s holds %q, want only %q", path, sectionTexts, texts[i])
+		}
+		if _, hasMap := files["EPUB/images/map.png"]; hasMap != (i == 1) {
+			t.Errorf("%s holds the map = %v, want %v", path, hasMap, i == 1)
+		}
+	}
+}
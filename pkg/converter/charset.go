@@ -0,0 +1,40 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// metaCharsetPattern finds the charset declared by <meta charset> or
+// <meta http-equiv="Content-Type" content="...; charset=...">.
+var metaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+
+// toUTF8 returns body converted to UTF-8. The charset is found as a browser
+// finds it: from a byte order mark, from contentType if it names one, from a
+// <meta> declaration near the start of the document, or else guessed from
+// the bytes. A converted document's declaration is changed to utf-8, so
+// converting it again is a no-op and a cached copy parses the same as a
+// fresh download. A document that can't be decoded is left as it is.
+func toUTF8(body []byte, contentType string) []byte {
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name != "utf-8" {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err != nil {
+			parserLog.Warn("Could not decode page; treating it as UTF-8", "charset", name, "err", err)
+			return body
+		}
+		body = decoded
+	}
+
+	head := body[:min(len(body), 1024)]
+	meta := metaCharsetPattern.FindSubmatchIndex(head)
+	if meta == nil || strings.EqualFold(string(head[meta[2]:meta[3]]), "utf-8") {
+		return body
+	}
+	out := make([]byte, 0, len(body))
+	out = append(out, body[:meta[2]]...)
+	out = append(out, "utf-8"...)
+	return append(out, body[meta[3]:]...)
+}
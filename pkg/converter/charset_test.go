@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// latin1 encodes s, which must only hold Latin-1 runes, as ISO-8859-1.
+func latin1(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"utf-8 untouched", []byte("<p>café</p>"), "text/html; charset=utf-8", "<p>café</p>"},
+		{"latin-1 by header", latin1("<p>café</p>"), "text/html; charset=iso-8859-1", "<p>café</p>"},
+		{"windows-1252 quotes", []byte("<p>\x93quoted\x94</p>"), "text/html; charset=windows-1252", "<p>“quoted”</p>"},
+		{"meta declaration rewritten", latin1(`<meta charset="ISO-8859-1"><p>café</p>`), "text/html", `<meta charset="utf-8"><p>café</p>`},
+		{"http-equiv declaration", latin1(`<meta http-equiv="Content-Type" content="text/html; charset=latin1"><p>é</p>`), "", `<meta http-equiv="Content-Type" content="text/html; charset=utf-8"><p>é</p>`},
+		{"shift_jis", []byte("<meta charset=\"shift_jis\"><p>\x93\xfa\x96\x7b</p>"), "", "<meta charset=\"utf-8\"><p>日本</p>"},
+		{"koi8-r", []byte("<p>\xf0\xd2\xc9\xd7\xc5\xd4</p>"), "text/html; charset=koi8-r", "<p>Привет</p>"},
+		{"utf-8 declared twice", []byte(`<meta charset="utf-8"><p>café</p>`), "text/html; charset=UTF-8", `<meta charset="utf-8"><p>café</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toUTF8(tt.body, tt.contentType)
+			if string(got) != tt.want {
+				t.Errorf("toUTF8 = %q, want %q", got, tt.want)
+			}
+			if again := toUTF8(got, ""); !bytes.Equal(again, got) {
+				t.Errorf("converting again gives %q", again)
+			}
+		})
+	}
+}
+
+func TestLatin1PageCachedAsFetched(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		page        []byte
+	}{
+		{"charset in header", "text/html; charset=ISO-8859-1", latin1("<html><body><p>Déjà vu, señor.</p></body></html>")},
+		{"charset in meta", "text/html", latin1(`<html><head><meta charset="iso-8859-1"></head><body><p>Déjà vu, señor.</p></body></html>`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(tt.page)
+			}))
+			defer srv.Close()
+			ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+			dir := t.TempDir()
+
+			// The -html-cache copy of the page
+			file := filepath.Join(dir, "page.html")
+			fresh, _, err := fetchOrLoadHTML(ctx, srv.URL+"/page", file, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(fresh, []byte("Déjà vu, señor.")) {
+				t.Errorf("fresh page not decoded: %q", fresh)
+			}
+			saved, _, err := fetchOrLoadHTML(ctx, srv.URL+"/page", file, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("server got %d requests, want the saved page used", hits.Load())
+			}
+			if !bytes.Equal(saved, fresh) {
+				t.Errorf("saved page = %q\nfetched = %q", saved, fresh)
+			}
+
+			// The -cache-dir copy, revalidated
+			cache := httpCache(filepath.Join(dir, "cache"))
+			first, err := fetchPage(ctx, srv.URL+"/page", cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cached, err := fetchPage(ctx, srv.URL+"/page", cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(first, fresh) || !bytes.Equal(cached, fresh) {
+				t.Errorf("cache-dir pages = %q, %q\nfetched = %q", first, cached, fresh)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// colophonTemplate is the body of the colophon section.
+var colophonTemplate = template.Must(template.New("colophon").Parse(`<h2>Colophon</h2>
+{{if .Title}}<p><em>{{.Title}}</em></p>
+{{end}}<p>Made with epub-creator-go {{.Version}} on {{.Built.Format "2006-01-02 15:04:05 MST"}}.</p>
+{{if .SourceURL}}<p>Source: <a href="{{.SourceURL}}">{{.SourceURL}}</a></p>
+{{else if .Archive}}<p>Source: {{.Archive}}</p>
+{{end}}<p>{{.Sections}} sections, {{.Images}} images.</p>
+`))
+
+// colophonData is what the colophon template is executed with.
+type colophonData struct {
+	Title     string
+	Version   string
+	Built     time.Time
+	SourceURL string
+	Archive   string
+	Sections  int // Sections before the colophon
+	Images    int
+}
+
+// toolVersion returns the version of this program, as recorded by the Go
+// toolchain.
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// colophonSection renders the colophon for a book built with opts and
+// described by meta, from the summary of what went into it so far.
+func colophonSection(opts Options, meta Metadata, summary Summary) (Section, error) {
+	data := colophonData{
+		Title:     meta.Title,
+		Version:   toolVersion(),
+		Built:     time.Now().UTC(),
+		SourceURL: opts.sourceURL(),
+		Sections:  len(summary.Sections),
+	}
+	if opts.Archive != "" {
+		data.SourceURL, data.Archive = "", filepath.Base(opts.Archive)
+	}
+	for _, r := range summary.Resources {
+		if r.Kind == "image" {
+			data.Images++
+		}
+	}
+	var body strings.Builder
+	if err := colophonTemplate.Execute(&body, data); err != nil {
+		return Section{}, fmt.Errorf("failed to render colophon: %w", err)
+	}
+	return Section{Title: "Colophon", Body: body.String()}, nil
+}
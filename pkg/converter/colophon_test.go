@@ -0,0 +1,28 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestColophon(t *testing.T) {
+	const page = `<html><head><title>The Detected Title</title></head>
+<body><h3>Chapter One</h3><p>Text.</p><h3>Chapter Two</h3><p>More.</p></body></html>`
+	result, files := testBuild(t, page, Options{Colophon: true})
+	body := sectionFile(t, result, files, "Colophon")
+
+	for _, want := range []string{
+		"<p><em>The Detected Title</em></p>",
+		`<p>Source: <a href="https://example.com/book/page.html">https://example.com/book/page.html</a></p>`,
+		"<p>2 sections, 0 images.</p>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("colophon lacks %q:\n%s", want, body)
+		}
+	}
+	buildInfo := regexp.MustCompile(`<p>Made with epub-creator-go \S+ on \d{4}-\d\d-\d\d \d\d:\d\d:\d\d UTC\.</p>`)
+	if !buildInfo.MatchString(body) {
+		t.Errorf("colophon lacks the build info:\n%s", body)
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// CommentMode selects what happens to HTML comments in the source.
+type CommentMode string
+
+const (
+	CommentsDrop  CommentMode = "drop"  // Leave comments out
+	CommentsKeep  CommentMode = "keep"  // Carry them over as XHTML comments
+	CommentsAside CommentMode = "aside" // Show them as editorial note blocks
+)
+
+// ParseCommentMode validates a -comments flag value.
+func ParseCommentMode(s string) (CommentMode, error) {
+	switch m := CommentMode(strings.ToLower(s)); m {
+	case CommentsDrop, CommentsKeep, CommentsAside:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid comment mode '%s' (want drop, keep or aside)", s)
+}
+
+// renderComment returns the section markup for a source comment under mode,
+// or "" if it should be dropped.
+func renderComment(text string, mode CommentMode) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	switch mode {
+	case CommentsKeep:
+		// "--" may not appear inside an XML comment
+		for strings.Contains(text, "--") {
+			text = strings.ReplaceAll(text, "--", "- -")
+		}
+		if strings.HasSuffix(text, "-") {
+			text += " "
+		}
+		return "<!-- " + text + " -->"
+	case CommentsAside:
+		return `<aside class="editorial-note"><p>` + html.EscapeString(text) + `</p></aside>`
+	}
+	return ""
+}
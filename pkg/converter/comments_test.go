@@ -0,0 +1,54 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>Before. </p>` +
+		`<!-- Check this -- and <b>that</b> --><p>After. </p><!--   --></body></html>`
+	tests := []struct {
+		mode    CommentMode
+		want    string // Between the paragraphs
+		wantNot []string
+	}{
+		{mode: "", want: "<p>Before. </p><p>After. </p>", wantNot: []string{"<!--", "<aside"}},
+		{mode: CommentsDrop, want: "<p>Before. </p><p>After. </p>", wantNot: []string{"<!--", "<aside"}},
+		{mode: CommentsKeep, want: "<p>Before. </p><!-- Check this - - and <b>that</b> --><p>After. </p>", wantNot: []string{"<aside"}},
+		{
+			mode:    CommentsAside,
+			want:    `<p>Before. </p><aside class="editorial-note"><p>Check this -- and &lt;b&gt;that&lt;/b&gt;</p></aside><p>After. </p>`,
+			wantNot: []string{"<!--"},
+		},
+	}
+	for _, tt := range tests {
+		name := string(tt.mode)
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{Comments: tt.mode})
+			body := sectionFile(t, result, files, "One")
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("section lacks %s:\n%s", tt.want, body)
+			}
+			for _, not := range tt.wantNot {
+				if strings.Contains(body, not) {
+					t.Errorf("section has %s:\n%s", not, body)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCommentMode(t *testing.T) {
+	for s, want := range map[string]CommentMode{"drop": CommentsDrop, "Keep": CommentsKeep, "ASIDE": CommentsAside} {
+		if got, err := ParseCommentMode(s); err != nil || got != want {
+			t.Errorf("ParseCommentMode(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	if _, err := ParseCommentMode("show"); err == nil {
+		t.Error("ParseCommentMode accepted an unknown mode")
+	}
+}
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CompressionMode selects how files are compressed in the EPUB zip.
+type CompressionMode string
+
+const (
+	CompressionDefault CompressionMode = "default" // As go-epub writes it
+	CompressionAuto    CompressionMode = "auto"    // Store already-compressed media, deflate the rest
+	CompressionStore   CompressionMode = "store"   // Store everything
+	CompressionDeflate CompressionMode = "deflate" // Deflate everything
+)
+
+// storedExtensions are formats that are already compressed, so deflating
+// them again only costs time and can make them bigger.
+var storedExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+	".woff": true, ".woff2": true, ".mp3": true, ".mp4": true, ".m4a": true, ".ogg": true,
+}
+
+// ParseCompressionMode validates a -compression flag value.
+func ParseCompressionMode(s string) (CompressionMode, error) {
+	switch m := CompressionMode(strings.ToLower(s)); m {
+	case CompressionDefault, CompressionAuto, CompressionStore, CompressionDeflate:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid compression mode '%s' (want default, auto, store or deflate)", s)
+}
+
+// method returns the zip compression method for the file name under m.
+func (m CompressionMode) method(name string) uint16 {
+	switch m {
+	case CompressionStore:
+		return zip.Store
+	case CompressionAuto:
+		if storedExtensions[strings.ToLower(path.Ext(name))] {
+			return zip.Store
+		}
+	}
+	return zip.Deflate
+}
+
+// recompressEPUB repacks the EPUB in data with files compressed as mode
+// says, deflating at level (a compress/flate level), or at
+// flate.DefaultCompression if level is zero.
+func recompressEPUB(data []byte, mode CompressionMode, level int) ([]byte, error) {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return nil, fmt.Errorf("invalid compression level %d (want %d to %d)", level, flate.HuffmanOnly, flate.BestCompression)
+	}
+	keep := func(name string, b []byte) ([]byte, error) { return b, nil }
+	return repackEPUB(data, keep, mode.method, level)
+}
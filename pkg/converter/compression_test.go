@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := fileServer(t, map[string]servedFile{"/photo.jpg": {"image/jpeg", photo.Bytes()}})
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>` +
+		strings.Repeat("The same words, over and over. ", 200) + `</p><img src="` + srv.URL + `/photo.jpg" alt="Photo"></body></html>`
+
+	tests := []struct {
+		name      string
+		mode      CompressionMode
+		level     int
+		wantImage uint16
+		wantText  uint16
+	}{
+		{"auto", CompressionAuto, 0, zip.Store, zip.Deflate},
+		{"store", CompressionStore, 0, zip.Store, zip.Store},
+		{"deflate at the default level", CompressionDeflate, 0, zip.Deflate, zip.Deflate},
+		{"deflate at a set level", CompressionDeflate, 9, zip.Deflate, zip.Deflate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "book.epub")
+			result, _ := testBuild(t, page, Options{OutputPath: out, Compression: tt.mode, CompressionLevel: tt.level})
+			text := "EPUB/xhtml/" + result.Summary().Sections[0].Filename
+
+			r, err := zip.OpenReader(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			var sawImage, sawText bool
+			for _, f := range r.File {
+				switch {
+				case f.Name == "mimetype":
+					if f.Method != zip.Store {
+						t.Errorf("mimetype compressed with method %d", f.Method)
+					}
+				case strings.HasSuffix(f.Name, ".jpg"):
+					sawImage = true
+					if f.Method != tt.wantImage {
+						t.Errorf("%s method = %d, want %d", f.Name, f.Method, tt.wantImage)
+					}
+				case f.Name == text:
+					sawText = true
+					if f.Method != tt.wantText {
+						t.Errorf("%s method = %d, want %d", f.Name, f.Method, tt.wantText)
+					}
+					if f.Method == zip.Deflate && f.CompressedSize64*4 > f.UncompressedSize64 {
+						t.Errorf("%s deflated to %d of %d bytes; repeated text should shrink", f.Name, f.CompressedSize64, f.UncompressedSize64)
+					}
+				}
+			}
+			if !sawImage || !sawText {
+				t.Errorf("EPUB lacks the image (%v) or the section (%v)", sawImage, sawText)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestContentsListsEveryFile(t *testing.T) {
+	page := `<html><head><title>Listed</title><link rel="stylesheet" href="style.css"></head><body>
+<h3>One</h3><p>Text<sup><a href="#fn1">1</a></sup>.</p><img src="pic.png" alt="Picture">
+<h3>Two</h3><p>More.</p><ol><li id="fn1">The note.</li></ol></body></html>`
+	css := `@font-face { font-family: Body; src: url(body.woff2) format("woff2"); }
+body { font-family: Body; background: url(paper.png); }`
+	srv := fileServer(t, map[string]servedFile{
+		"/book.html":  {"text/html", []byte(page)},
+		"/style.css":  {"text/css", []byte(css)},
+		"/pic.png":    {"image/png", testPNG(t, 4, 3, color.White)},
+		"/paper.png":  {"image/png", testPNG(t, 2, 2, color.Black)},
+		"/body.woff2": {"font/woff2", []byte("wOF2 not really a font")},
+	})
+	cover := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(cover, testPNG(t, 6, 9, color.Gray{128}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, files := testBuild(t, "", Options{
+		SourceURL:      srv.URL + "/book.html",
+		EmbedCSS:       true,
+		CoverImage:     cover,
+		ThumbnailSize:  3,
+		EmbedThumbnail: true,
+		ContentsPage:   true,
+		Footnotes:      FootnotesEndnotes,
+		Attribution:    true,
+		Colophon:       true,
+	})
+	sections, resources := result.Contents()
+
+	var titles []string
+	listed := make(map[string]int64)
+	for _, s := range sections {
+		titles = append(titles, s.Title)
+		listed["EPUB/xhtml/"+s.Filename] = int64(s.Size)
+	}
+	wantTitles := []string{contentsTitle, "One", "Two", "Notes", "Attribution", "Colophon"}
+	if strings.Join(titles, "|") != strings.Join(wantTitles, "|") {
+		t.Errorf("sections = %v, want %v", titles, wantTitles)
+	}
+	kinds := make(map[string]int)
+	for _, r := range resources {
+		kinds[r.Kind]++
+		name := internalArchivePath(r.Path)
+		listed[name] = r.Size
+		if data, ok := files[name]; !ok {
+			t.Errorf("listed %s %s isn't in the EPUB", r.Kind, r.Path)
+		} else if int64(len(data)) != r.Size {
+			t.Errorf("%s listed as %d bytes, is %d", r.Path, r.Size, len(data))
+		}
+	}
+	if kinds["image"] != 4 || kinds["css"] != 1 || kinds["font"] != 1 {
+		t.Errorf("resources by kind = %v, want 4 images (picture, background, cover, thumbnail), 1 css, 1 font", kinds)
+	}
+
+	// Every content file and resource in the EPUB is listed, bar the ones
+	// go-epub generates itself
+	var unlisted []string
+	for name := range files {
+		generated := !strings.HasPrefix(name, "EPUB/") || name == packageDocumentPath || name == navDocumentPath ||
+			name == ncxPath || name == "EPUB/xhtml/cover.xhtml" || name == "EPUB/css/cover.css"
+		if _, ok := listed[name]; !ok && !generated {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+	if len(unlisted) > 0 {
+		t.Errorf("files not listed: %v", unlisted)
+	}
+}
@@ -0,0 +1,70 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// htmlSniffLen is how much of a body is searched for signs of HTML.
+const htmlSniffLen = 1024
+
+// fetchPage downloads the HTML page at urlStr through cache like fetchHTML,
+// but first makes sure it is HTML. Mirrors often serve pages as text/plain or
+// application/octet-stream, so the content type alone doesn't decide: a body
+// that looks like HTML is accepted whatever it's served as.
+func fetchPage(ctx context.Context, urlStr string, cache httpCache) ([]byte, error) {
+	body, contentType, err := cache.get(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if !isHTMLType(contentType) && !looksLikeHTML(body) {
+		return nil, fmt.Errorf("'%s' is served as %s and doesn't look like HTML", urlStr, contentType)
+	}
+	return toUTF8(body, contentType), nil
+}
+
+// isHTMLType reports whether contentType is an HTML media type, or missing.
+func isHTMLType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// looksLikeHTML reports whether body starts like an HTML document: it has an
+// <html> tag or doctype near the start, or begins with an HTML element.
+func looksLikeHTML(body []byte) bool {
+	head := body
+	if len(head) > htmlSniffLen {
+		head = head[:htmlSniffLen]
+	}
+	lower := bytes.ToLower(head)
+	if bytes.Contains(lower, []byte("<html")) || bytes.Contains(lower, []byte("<!doctype html")) {
+		return true
+	}
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// checkImageType returns an error if an image response declares a content
+// type that isn't an image, such as the HTML of an error or login page served
+// with a 200. Unlike pages, images aren't sniffed: only a missing or generic
+// binary type is let through, or a font type, as stylesheets load fonts the
+// way they load images.
+func checkImageType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type '%s': %w", contentType, err)
+	}
+	if strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "font/") || mediaType == "application/octet-stream" {
+		return nil
+	}
+	return fmt.Errorf("served as %s, not an image", mediaType)
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchPageContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"html", "text/html; charset=utf-8", "<p>Text</p>", false},
+		{"xhtml", "application/xhtml+xml", "<p>Text</p>", false},
+		{"html served as text", "text/plain", "<!DOCTYPE html><html><body>Text</body></html>", false},
+		{"html served as binary", "application/octet-stream", "<html><p>Text</p></html>", false},
+		{"plain text", "text/plain", "Just some text.", true},
+		{"pdf", "application/pdf", "%PDF-1.4 binary", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			_, err := fetchPage(context.Background(), srv.URL, "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("fetchPage error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckImageType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{"", false},
+		{"image/png", false},
+		{"image/svg+xml", false},
+		{"application/octet-stream", false},
+		{"text/html; charset=utf-8", true},
+		{"application/json", true},
+		{"not a type;;", true},
+	}
+	for _, tt := range tests {
+		if err := checkImageType(tt.contentType); (err != nil) != tt.wantErr {
+			t.Errorf("checkImageType(%q) = %v, want error %v", tt.contentType, err, tt.wantErr)
+		}
+	}
+}
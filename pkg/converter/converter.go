@@ -0,0 +1,97 @@
+// Package converter turns web pages, local HTML files, or archives of HTML
+// chapters into EPUB books: it fetches and parses the HTML, splits it into
+// sections at its headings, and downloads and embeds its images.
+//
+// Builds log through the default log/slog logger, naming the part of the
+// pipeline each record comes from in its component attribute: fetcher,
+// parser, images or writer.
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Converter builds an EPUB from one input with a fixed set of options.
+type Converter struct {
+	opts Options
+}
+
+// New returns a Converter building with opts. Its input is the SourceURL,
+// SourceURLs, Paths, Inputs, Archive or SourceHTML in opts until FromURL,
+// FromFiles, FromReader or AddURL sets another.
+func New(opts Options) *Converter {
+	return &Converter{opts: opts}
+}
+
+// FromURL makes the page at rawURL, an absolute http or https URL, the
+// input.
+func (c *Converter) FromURL(rawURL string) error {
+	if _, err := parseBaseURL(rawURL); err != nil {
+		return err
+	}
+	c.opts.SourceURL, c.opts.SourceHTML, c.opts.Archive = rawURL, nil, ""
+	c.opts.SourceURLs, c.opts.Paths, c.opts.Inputs = nil, nil, nil
+	return nil
+}
+
+// AddURL appends the page at rawURL, an absolute http or https URL, to the
+// pages making up the input, each of which starts a chapter. Added pages
+// replace any other input.
+func (c *Converter) AddURL(rawURL string) error {
+	if _, err := parseBaseURL(rawURL); err != nil {
+		return err
+	}
+	c.opts.SourceURLs = append(c.opts.SourceURLs, rawURL)
+	c.opts.SourceHTML, c.opts.Archive, c.opts.Paths = nil, "", nil
+	c.opts.Inputs = nil
+	return nil
+}
+
+// FromFiles makes the local HTML files, directories and glob patterns paths
+// name the input, each file a chapter. A directory's files are taken as its
+// manifest.txt lists them, or else sorted by path.
+func (c *Converter) FromFiles(paths ...string) error {
+	if _, err := localFiles(paths); err != nil {
+		return err
+	}
+	c.opts.Paths = paths
+	c.opts.SourceHTML, c.opts.Archive = nil, ""
+	c.opts.SourceURLs, c.opts.Inputs = nil, nil
+	return nil
+}
+
+// FromReader makes the HTML page read from r the input, resolving its links
+// and images against baseURL.
+func (c *Converter) FromReader(r io.Reader, baseURL string) error {
+	page, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading HTML: %w", err)
+	}
+	c.opts.SourceHTML, c.opts.SourceURL, c.opts.Archive = page, baseURL, ""
+	c.opts.SourceURLs, c.opts.Paths, c.opts.Inputs = nil, nil, nil
+	return nil
+}
+
+// BuildTo converts the input into an EPUB written to w, holding images and
+// stylesheets in memory as with InMemory unless the options name a
+// MediaStore, so the build leaves no files behind, e.g. when serving EPUBs
+// over HTTP. Files the options ask for besides the EPUB itself are still
+// written.
+func (c *Converter) BuildTo(ctx context.Context, w io.Writer) (*Result, error) {
+	opts := c.opts
+	opts.Output = w
+	if opts.MediaStore == nil {
+		opts.InMemory = true
+	}
+	return build(ctx, opts)
+}
+
+// Build converts the input into an EPUB written to the OutputPath or
+// Output of the options, along with any other files they ask for. If ctx is
+// cancelled or the Timeout passes first, the build stops, no EPUB is
+// written, and the context's error is returned.
+func (c *Converter) Build(ctx context.Context) (*Result, error) {
+	return build(ctx, c.opts)
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddURL(t *testing.T) {
+	pages := make(map[string]servedFile)
+	for i := 1; i <= 3; i++ {
+		pages[fmt.Sprintf("/novel/chapter-%d.html", i)] = servedFile{"text/html", []byte(fmt.Sprintf(
+			`<html><head><title>Chapter %d</title></head><body><p>Text of chapter %d.</p></body></html>`, i, i))}
+	}
+	srv := fileServer(t, pages)
+
+	dir := t.TempDir()
+	c := New(Options{OutputPath: filepath.Join(dir, "novel.epub"), ImageDir: filepath.Join(dir, "images")})
+	if err := c.AddURL("chapter-1.html"); err == nil {
+		t.Error("AddURL accepted a relative URL")
+	}
+	for _, i := range []int{2, 1, 3} { // Added out of order on purpose
+		if err := c.AddURL(fmt.Sprintf("%s/novel/chapter-%d.html", srv.URL, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := c.Build(context.Background())
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	files := epubFiles(t, filepath.Join(dir, "novel.epub"))
+	var titles []string
+	for _, s := range result.Summary().Sections {
+		titles = append(titles, s.Title)
+	}
+	if strings.Join(titles, "|") != "Chapter 2|Chapter 1|Chapter 3" {
+		t.Fatalf("sections = %q, want a chapter per page in the order added", titles)
+	}
+	for _, i := range []int{1, 2, 3} {
+		if body := sectionFile(t, result, files, fmt.Sprintf("Chapter %d", i)); !strings.Contains(body, fmt.Sprintf("Text of chapter %d.", i)) {
+			t.Errorf("chapter %d lacks its page's text:\n%s", i, body)
+		}
+	}
+}
+
+func TestLoadURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "list",
+			content: "# A web novel\nhttps://example.com/1.html\n\n  https://example.com/2.html  \n",
+			want:    []string{"https://example.com/1.html", "https://example.com/2.html"},
+		},
+		{name: "bad line", content: "https://example.com/1.html\nchapter-2.html\n", wantErr: "urls.txt:2"},
+		{name: "empty", content: "# Nothing yet\n", wantErr: "has no URLs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "urls.txt")
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadURLs(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadURLs error = %v, want one saying %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("LoadURLs = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-shiori/go-epub"
+	"golang.org/x/net/html"
+)
+
+// Size of generated SVG covers, in user units.
+const (
+	svgCoverWidth  = 600
+	svgCoverHeight = 800
+)
+
+// CoverStyle controls how a generated cover looks.
+type CoverStyle struct {
+	Background string // CSS color of the background
+	Foreground string // CSS color of the text
+	Font       string // CSS font family of the text
+}
+
+// addCover embeds opts.CoverImage as the book cover, or generates one from
+// the title and author if opts.CoverStyle is set instead, and, if requested,
+// makes a thumbnail of it for catalog displays.
+func addCover(ctx context.Context, e *epub.Epub, opts Options) (*CoverInfo, error) {
+	store := opts.mediaStore()
+	coverPath := opts.CoverImage
+	var size image.Point
+	var err error
+	switch {
+	case coverPath == "":
+		coverPath, err = writeSVGCover(store, opts.Metadata, *opts.CoverStyle)
+		if err != nil {
+			return nil, err
+		}
+		size = image.Pt(svgCoverWidth, svgCoverHeight)
+	case strings.HasPrefix(coverPath, "http://") || strings.HasPrefix(coverPath, "https://"):
+		var u *url.URL
+		if u, err = url.Parse(coverPath); err == nil {
+			coverPath, err = fetchImage(ctx, u, store)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case !isBuiltinStore(store):
+		// Local covers are read straight from disk by the built-in stores
+		data, err := os.ReadFile(coverPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cover image '%s': %w", coverPath, err)
+		}
+		if coverPath, err = store.Put(filepath.Base(coverPath), data); err != nil {
+			return nil, fmt.Errorf("failed to store cover image: %w", err)
+		}
+	}
+
+	if size == (image.Point{}) {
+		size, err = imageSize(store, coverPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	source, err := epubSource(store, coverPath)
+	if err != nil {
+		return nil, err
+	}
+	internalPath, err := e.AddImage(source, epubMediaName(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add cover image '%s': %w", mediaName(coverPath), err)
+	}
+	if err := e.SetCover(internalPath, ""); err != nil {
+		return nil, fmt.Errorf("failed to set cover: %w", err)
+	}
+	cover := &CoverInfo{Path: internalPath, Width: size.X, Height: size.Y, source: coverPath}
+
+	if opts.ThumbnailSize > 0 {
+		thumb, err := makeThumbnail(e, coverPath, opts)
+		if err != nil {
+			imagesLog.Warn("Could not make cover thumbnail", "err", err)
+		} else {
+			cover.Thumbnail = thumb
+		}
+	}
+	return cover, nil
+}
+
+// makeThumbnail scales the cover down to fit opts.ThumbnailSize pixels square
+// and embeds it when opts.EmbedThumbnail is set.
+func makeThumbnail(e *epub.Epub, coverPath string, opts Options) (*ThumbnailInfo, error) {
+	max := image.Pt(opts.ThumbnailSize, opts.ThumbnailSize)
+	store := opts.mediaStore()
+	thumbPath, err := fitImage(store, coverPath, max)
+	if err != nil {
+		return nil, err
+	}
+	size, err := imageSize(store, thumbPath)
+	if err != nil {
+		return nil, err
+	}
+	if size.X > max.X || size.Y > max.Y {
+		// fitImage leaves formats it can't re-encode alone
+		return nil, fmt.Errorf("cannot scale cover image '%s'", coverPath)
+	}
+
+	thumb := &ThumbnailInfo{Width: size.X, Height: size.Y, source: thumbPath}
+	if _, ok := store.(dirStore); ok {
+		thumb.File = thumbPath
+	}
+	if opts.EmbedThumbnail {
+		source, err := epubSource(store, thumbPath)
+		if err != nil {
+			return nil, err
+		}
+		internalPath, err := e.AddImage(source, "cover-thumbnail"+imageExt(thumbPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to add cover thumbnail: %w", err)
+		}
+		thumb.Path = internalPath
+	}
+	return thumb, nil
+}
+
+// writeSVGCover makes an SVG cover showing the book's title and author and
+// puts it in store. It returns its location.
+func writeSVGCover(store MediaStore, meta Metadata, style CoverStyle) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		svgCoverWidth, svgCoverHeight, svgCoverWidth, svgCoverHeight)
+	fmt.Fprintf(&b, `  <rect width="100%%" height="100%%" fill="%s"/>`+"\n", html.EscapeString(style.Background))
+	fmt.Fprintf(&b, `  <g fill="%s" font-family="%s" text-anchor="middle">`+"\n",
+		html.EscapeString(style.Foreground), html.EscapeString(style.Font))
+
+	lines := wrapWords(meta.Title, 18)
+	y := svgCoverHeight/3 - (len(lines)-1)*30
+	for _, line := range lines {
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" font-size="48" font-weight="bold">%s</text>`+"\n", svgCoverWidth/2, y, html.EscapeString(line))
+		y += 60
+	}
+	if meta.Author != "" {
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" font-size="32">%s</text>`+"\n", svgCoverWidth/2, svgCoverHeight*3/4, html.EscapeString(meta.Author))
+	}
+	b.WriteString("  </g>\n</svg>\n")
+
+	coverPath, err := store.Put("cover.svg", []byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to save generated cover: %w", err)
+	}
+	return coverPath, nil
+}
+
+// wrapWords splits s into lines of about width runes, breaking between words.
+func wrapWords(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratedSVGCover(t *testing.T) {
+	page := `<html><body><h3>One</h3><p>Text.</p></body></html>`
+	opts := Options{
+		Metadata:   Metadata{Title: "Tales & Trails", Author: "Ann Writer"},
+		CoverStyle: &CoverStyle{Background: "#203040", Foreground: "white", Font: "serif"},
+	}
+	result, files := testBuild(t, page, opts)
+	if c := result.Summary().Cover; c == nil || c.Width != svgCoverWidth || c.Height != svgCoverHeight {
+		t.Errorf("cover = %+v, want a %dx%d generated one", c, svgCoverWidth, svgCoverHeight)
+	}
+
+	var svgName, svg string
+	for name, data := range files {
+		if strings.HasSuffix(name, ".svg") {
+			svgName, svg = name, data
+		}
+	}
+	if svg == "" {
+		t.Fatal("no SVG cover in the EPUB")
+	}
+	for _, want := range []string{">Tales &amp; Trails<", ">Ann Writer<", `fill="#203040"`, `font-family="serif"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("cover lacks %s:\n%s", want, svg)
+		}
+	}
+
+	opf := files[packageDocumentPath]
+	href := strings.TrimPrefix(svgName, "EPUB/")
+	var item string
+	for _, line := range strings.Split(opf, "\n") {
+		if strings.Contains(line, `href="`+href+`"`) {
+			item = line
+		}
+	}
+	if !strings.Contains(item, `properties="cover-image"`) {
+		t.Errorf("%s not the cover image in the manifest:\n%s", href, opf)
+	}
+}
@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ParseNextLink parses a -next-link value, which selects the "next" link, or
+// an element containing it, with the same tag, .class or tag.class forms as a
+// section marker.
+func ParseNextLink(s string) (SectionMarker, error) {
+	m, err := ParseSectionMarker(s)
+	if err != nil {
+		return SectionMarker{}, fmt.Errorf("invalid next link selector '%s' (want tag, .class or tag.class)", s)
+	}
+	return m, nil
+}
+
+// nextLinkTexts are the texts, lowercased and without arrows, of links that
+// lead to the next page when there is no rel="next".
+var nextLinkTexts = map[string]bool{
+	"next":         true,
+	"next page":    true,
+	"next chapter": true,
+	"next part":    true,
+	"continue":     true,
+}
+
+// nextPageURL returns the page the "next" link of doc leads to, resolved
+// against baseURL, or nil if there is none. With a selector the link is the
+// first element it matches, or the first link inside that; otherwise it is
+// the first <link> or <a> with rel="next", or else the first <a> reading
+// e.g. "Next" or "Next chapter »".
+func nextPageURL(doc *html.Node, baseURL *url.URL, selector SectionMarker) *url.URL {
+	var link *html.Node
+	if selector != (SectionMarker{}) {
+		if n := findNode(doc, selector.matches); n != nil {
+			if isLink(n) {
+				link = n
+			} else {
+				link = findNode(n, isLink)
+			}
+		}
+	} else {
+		link = findNode(doc, func(n *html.Node) bool {
+			return (isLink(n) || n.Type == html.ElementNode && n.Data == "link") && hasToken(getAttr(n, "rel"), "next")
+		})
+		if link == nil {
+			link = findNode(doc, func(n *html.Node) bool {
+				return isLink(n) && nextLinkTexts[nextLinkText(n)]
+			})
+		}
+	}
+	if link == nil {
+		return nil
+	}
+
+	href := strings.TrimSpace(getAttr(link, "href"))
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	target, err := baseURL.Parse(href)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil
+	}
+	target.Fragment = ""
+	return target
+}
+
+// isLink reports whether n is an <a> with an href.
+func isLink(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Data == "a" && getAttr(n, "href") != ""
+}
+
+// nextLinkText returns the text of link n lowercased, with arrows and the
+// like trimmed off, so "Next Chapter »" reads "next chapter".
+func nextLinkText(n *html.Node) string {
+	text := strings.ToLower(getTextContent(n))
+	return strings.TrimSpace(strings.Trim(text, " »›→>-–—:"))
+}
+
+// findNode returns the first node under n, n included, that match reports,
+// or nil.
+func findNode(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findNode(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// crawlSources loads the page SourceURL names and then follows its "next"
+// links up to Crawl further pages, each a source of its own. The crawl stops
+// early at a page without a next link, at one already loaded, at one the
+// site's robots.txt disallows, or at one that can't be loaded. The pages
+// followed are fetched no faster than any Crawl-delay in robots.txt asks;
+// other requests to the site aren't held back by it. Without
+// Options.NextLink, the recipe for a page's site may say which its next
+// link is.
+func crawlSources(ctx context.Context, opts Options) ([]*source, error) {
+	first, err := loadPage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	progressFrom(ctx).step(ProgressPages, 0, opts.SourceURL) // Where the crawl ends isn't known
+	sources := []*source{first}
+	seen := map[string]bool{first.name: true}
+	delays := make(map[string]time.Duration) // Crawl-delay of each host, logged once
+	for src := first; ; {
+		nextLink := opts.NextLink
+		if r := opts.recipeFor(src.baseURL); r != nil && nextLink == (SectionMarker{}) {
+			nextLink = r.nextPage
+		}
+		next := nextPageURL(src.doc, src.baseURL, nextLink)
+		if next == nil || seen[next.String()] {
+			break
+		}
+		if len(sources) > opts.Crawl {
+			fetcherLog.Warn("Stopping crawl at the page limit; raise -crawl to follow more", "pages", len(sources))
+			break
+		}
+		seen[next.String()] = true
+		pageCtx := ctx
+		if !opts.IgnoreRobots {
+			allowed, delay := robotsAllowed(ctx, next)
+			if !allowed {
+				fetcherLog.Warn("Stopping crawl at a page robots.txt disallows; -ignore-robots fetches it anyway", "url", next.String())
+				break
+			}
+			if delay > 0 {
+				if delays[next.Host] != delay {
+					fetcherLog.Info("Honouring robots.txt crawl delay", "host", next.Host, "delay", delay)
+					delays[next.Host] = delay
+				}
+				pageCtx = withCrawlDelay(ctx, delay)
+			}
+		}
+
+		// A single cache file can't hold several pages
+		page := opts
+		page.SourceURL, page.SourceHTML, page.HTMLCache = next.String(), nil, ""
+		src, err = loadPage(pageCtx, page)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			fetcherLog.Warn("Stopping crawl at a page that can't be loaded", "url", next.String(), "err", err)
+			break
+		}
+		seen[src.name] = true // Where any meta refresh led
+		progressFrom(ctx).step(ProgressPages, 0, next.String())
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawl(t *testing.T) {
+	// Page 1 has a rel="next" link, page 2 a "Next Chapter »" link, and
+	// all of them a pager only a selector finds, which goes round from
+	// page 4 back to page 1
+	pages := make(map[string]servedFile)
+	for i := 1; i <= 4; i++ {
+		var head, next string
+		switch i {
+		case 1:
+			head = `<link rel="next" href="page-2.html">`
+		case 2:
+			next = `<p><a href="page-3.html#top">Next Chapter »</a></p>`
+		}
+		pager := fmt.Sprintf(`<div class="pager"><a href="page-%d.html">→</a></div>`, i%4+1)
+		pages[fmt.Sprintf("/story/page-%d.html", i)] = servedFile{"text/html", []byte(fmt.Sprintf(
+			`<html><head><title>Story</title>%s</head><body><h3>Page %d</h3><p>Text of page %d.</p>%s%s</body></html>`, head, i, i, next, pager))}
+	}
+	srv := fileServer(t, pages)
+
+	tests := []struct {
+		name     string
+		crawl    int
+		nextLink SectionMarker
+		want     []int
+	}{
+		{name: "rel and text heuristics", crawl: 10, want: []int{1, 2, 3}},
+		{name: "depth limit", crawl: 1, want: []int{1, 2}},
+		{name: "selector until the cycle", crawl: 10, nextLink: SectionMarker{Tag: "div", Class: "pager"}, want: []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, "", Options{SourceURL: srv.URL + "/story/page-1.html", Crawl: tt.crawl, NextLink: tt.nextLink})
+			var titles, want []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			for _, i := range tt.want {
+				want = append(want, fmt.Sprintf("Page %d", i))
+			}
+			if strings.Join(titles, "|") != strings.Join(want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, want)
+			}
+			for _, i := range tt.want {
+				if body := sectionFile(t, result, files, fmt.Sprintf("Page %d", i)); !strings.Contains(body, fmt.Sprintf("Text of page %d.", i)) {
+					t.Errorf("page %d section lacks its text:\n%s", i, body)
+				}
+			}
+		})
+	}
+}
+
+func TestParseNextLink(t *testing.T) {
+	if m, err := ParseNextLink("div.pager"); err != nil || m != (SectionMarker{Tag: "div", Class: "pager"}) {
+		t.Errorf("ParseNextLink(div.pager) = %+v, %v", m, err)
+	}
+	if _, err := ParseNextLink("div > a"); err == nil {
+		t.Error("ParseNextLink accepted a combinator")
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	var mu sync.Mutex
+	started := make(map[string][]time.Time) // By path
+	pic := testPNG(t, 2, 2, color.Black)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		started[r.URL.Path] = append(started[r.URL.Path], time.Now())
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nCrawl-delay: 0.3\n"))
+		case "/pic.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pic)
+		default:
+			var n int
+			fmt.Sscanf(r.URL.Path, "/page-%d.html", &n)
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><title>Story</title><link rel="next" href="page-%d.html"></head>`+
+				`<body><h3>Page %d</h3><p>Text of page %d.</p><img src="pic.png" alt="Picture"></body></html>`, n+1, n, n)
+		}
+	}))
+	defer srv.Close()
+	gap := func(from, to string) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(started[from]) == 0 || len(started[to]) == 0 {
+			t.Fatalf("%s or %s never requested", from, to)
+		}
+		return started[to][len(started[to])-1].Sub(started[from][len(started[from])-1])
+	}
+
+	testBuild(t, "", Options{SourceURL: srv.URL + "/page-1.html", Crawl: 2})
+	for _, pages := range [][2]string{{"/page-1.html", "/page-2.html"}, {"/page-2.html", "/page-3.html"}} {
+		if d := gap(pages[0], pages[1]); d < delay-20*time.Millisecond {
+			t.Errorf("%s requested %s after %s, want the crawl delay of %s", pages[1], d, pages[0], delay)
+		}
+	}
+	if d := gap("/page-3.html", "/pic.png"); d >= delay-20*time.Millisecond {
+		t.Errorf("image requested %s after the last page, want it not held back by the crawl delay", d)
+	}
+
+	// Neither a later build of a page nor a crawl ignoring robots.txt waits
+	start := time.Now()
+	testBuild(t, "", Options{SourceURL: srv.URL + "/page-4.html"})
+	if d := gap("/page-4.html", "/pic.png"); time.Since(start) >= delay || d >= delay-20*time.Millisecond {
+		t.Errorf("later build took %s, want it not held back by an earlier crawl's delay", time.Since(start))
+	}
+	testBuild(t, "", Options{SourceURL: srv.URL + "/page-5.html", Crawl: 1, IgnoreRobots: true})
+	if d := gap("/page-5.html", "/page-6.html"); d >= delay-20*time.Millisecond {
+		t.Errorf("page 6 requested %s after page 5 with robots.txt ignored, want no crawl delay", d)
+	}
+}
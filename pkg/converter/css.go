@@ -0,0 +1,255 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-epub"
+	"golang.org/x/net/html"
+)
+
+// cssURLPattern matches url(...) references in a stylesheet.
+var cssURLPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+
+// cssImportPattern matches @import rules, with the imported URL in group 2
+// or 4 and any media list in group 5.
+var cssImportPattern = regexp.MustCompile(`@import\s+(?:url\(\s*(['"]?)([^'")]*)['"]?\s*\)|(['"])([^'"]*)['"])([^;]*);`)
+
+// maxCSSImportDepth limits how deep @import chains are followed.
+const maxCSSImportDepth = 5
+
+// embedStylesheets gathers the linked and inline stylesheets of src into one
+// stylesheet, embeds the stylesheets it imports and the images it references,
+// and adds it to the EPUB, followed by the Stylesheets. It returns the
+// stylesheet's internal path, or "" if there is no CSS.
+func (x *extractor) embedStylesheets(src *source) string {
+	var imports, css strings.Builder
+	add := func(sheet string, sheetURL *url.URL) {
+		x.cssInProgress[sheetURL.String()] = true
+		defer delete(x.cssInProgress, sheetURL.String())
+		rules, body := x.rewriteCSS(src, sheet, sheetURL, 0)
+		imports.WriteString(rules)
+		css.WriteString(body + "\n")
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "link" && hasToken(getAttr(n, "rel"), "stylesheet"):
+				href := getAttr(n, "href")
+				sheetURL, err := src.baseURL.Parse(href)
+				if err != nil {
+					fetcherLog.Warn("Could not parse stylesheet URL", "url", href, "err", err)
+					break
+				}
+				data, err := src.fetch(x.ctx, sheetURL)
+				if err != nil {
+					fetcherLog.Warn("Could not load stylesheet", "url", sheetURL.String(), "err", err)
+					break
+				}
+				add(string(data), sheetURL)
+			case n.Data == "style":
+				add(getText(n), src.baseURL)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(src.doc)
+	if strings.TrimSpace(imports.String()+css.String()) == "" {
+		return x.bookStylesheet()
+	}
+	// @import rules only count at the start of a stylesheet
+	internalPath, err := x.addStylesheet(imports.String() + css.String() + x.bookCSS)
+	if err != nil {
+		writerLog.Warn("Could not add stylesheet to EPUB", "err", err)
+		return ""
+	}
+	return internalPath
+}
+
+// bookStylesheet returns the internal path of the Stylesheets on their own,
+// adding them the first time, or "" if there are none.
+func (x *extractor) bookStylesheet() string {
+	if x.bookCSS == "" || x.bookCSSPath != "" {
+		return x.bookCSSPath
+	}
+	internalPath, err := x.addStylesheet(x.bookCSS)
+	if err != nil {
+		writerLog.Warn("Could not add stylesheet to EPUB", "err", err)
+		return ""
+	}
+	x.bookCSSPath = internalPath
+	return internalPath
+}
+
+// addStylesheet adds css to the EPUB as a new stylesheet and returns its
+// internal path.
+func (x *extractor) addStylesheet(css string) (string, error) {
+	// go-epub embeds stylesheets from files, so stage it with the images
+	x.stylesheets++
+	name := fmt.Sprintf("style%04d.css", x.stylesheets)
+	cssPath, err := x.store.Put(name, []byte(css))
+	if err != nil {
+		return "", fmt.Errorf("failed to save stylesheet: %w", err)
+	}
+	source, err := epubSource(x.store, cssPath)
+	if err != nil {
+		return "", err
+	}
+	internalPath, err := x.e.AddCSS(source, name)
+	if err != nil {
+		return "", err
+	}
+	x.result.addResource("css", internalPath, cssPath, int64(len(css)))
+	return internalPath, nil
+}
+
+// rewriteCSS embeds what css, loaded from sheetURL, refers to: imported
+// stylesheets, up to maxCSSImportDepth deep, and images referenced by
+// url(...). It returns the @import rules, pointed at the embedded copies,
+// separately from the rest of the stylesheet, which has its url(...)
+// references rewritten. References that can't be embedded are left as they
+// are; imports that can't be are dropped.
+func (x *extractor) rewriteCSS(src *source, css string, sheetURL *url.URL, depth int) (imports, body string) {
+	var rules strings.Builder
+	css = cssImportPattern.ReplaceAllStringFunc(css, func(m string) string {
+		g := cssImportPattern.FindStringSubmatch(m)
+		ref := strings.TrimSpace(g[2] + g[4])
+		importURL, err := sheetURL.Parse(ref)
+		if err != nil {
+			fetcherLog.Warn("Could not parse imported stylesheet URL", "url", ref, "err", err)
+			return ""
+		}
+		internalPath, err := x.embedImportedCSS(src, importURL, depth+1)
+		if err != nil {
+			fetcherLog.Warn("Could not embed imported stylesheet", "url", importURL.String(), "err", err)
+			return ""
+		}
+		rules.WriteString(`@import url("` + internalPath + `")` + g[5] + ";\n")
+		return ""
+	})
+	return rules.String(), strings.TrimSpace(x.rewriteCSSURLs(src, css, sheetURL))
+}
+
+// embedImportedCSS embeds the stylesheet at u, imported depth levels below a
+// page's own stylesheets, and returns its internal path. Each URL is only
+// embedded once.
+func (x *extractor) embedImportedCSS(src *source, u *url.URL, depth int) (string, error) {
+	key := u.String()
+	if x.cssInProgress[key] {
+		return "", fmt.Errorf("import cycle")
+	}
+	if internalPath, ok := x.importedCSS[key]; ok {
+		return internalPath, nil
+	}
+	if depth > maxCSSImportDepth {
+		return "", fmt.Errorf("imports nested more than %d deep", maxCSSImportDepth)
+	}
+	data, err := src.fetch(x.ctx, u)
+	if err != nil {
+		return "", err
+	}
+
+	x.cssInProgress[key] = true
+	imports, body := x.rewriteCSS(src, string(data), u, depth)
+	delete(x.cssInProgress, key)
+	internalPath, err := x.addStylesheet(imports + body)
+	if err != nil {
+		return "", err
+	}
+	x.importedCSS[key] = internalPath
+	return internalPath, nil
+}
+
+// rewriteCSSURLs embeds the images and fonts referenced by url(...) in css,
+// resolved against sheetURL, and points the references at their internal
+// paths.
+// References that can't be embedded are left as they are.
+func (x *extractor) rewriteCSSURLs(src *source, css string, sheetURL *url.URL) string {
+	return cssURLPattern.ReplaceAllStringFunc(css, func(m string) string {
+		ref := strings.TrimSpace(cssURLPattern.FindStringSubmatch(m)[2])
+		if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		imgURL, err := sheetURL.Parse(ref)
+		if err != nil {
+			return m
+		}
+		internalPath, err := x.embedImageURL(src, imgURL)
+		if err != nil {
+			imagesLog.Warn("Could not embed CSS image", "url", imgURL, "err", err)
+			return m
+		}
+		return `url("` + internalPath + `")`
+	})
+}
+
+// embedImageURL loads the image or font at u and adds it to the EPUB,
+// returning its internal path. Each URL is only embedded once.
+func (x *extractor) embedImageURL(src *source, u *url.URL) (string, error) {
+	defer x.timeImages(time.Now())
+	if internalPath, ok := x.cssImages[u.String()]; ok {
+		return internalPath, nil
+	}
+	imgPath, err := src.loadImage(x.ctx, u)
+	if err != nil {
+		return "", err
+	}
+	embed := x.embedImage
+	if fontExtensions[strings.ToLower(path.Ext(u.Path))] {
+		embed = x.embedFont
+	}
+	internalPath, err := embed(imgPath, u.String())
+	if err != nil {
+		return "", err
+	}
+	x.cssImages[u.String()] = internalPath
+	return internalPath, nil
+}
+
+// fontExtensions are the extensions of the font files @font-face rules
+// load, which are embedded as fonts rather than images.
+var fontExtensions = map[string]bool{".otf": true, ".ttf": true, ".woff": true, ".woff2": true}
+
+// embedFont adds the font at fontPath in the media store, downloaded from
+// sourceURL, to the EPUB and returns its internal path.
+func (x *extractor) embedFont(fontPath, sourceURL string) (string, error) {
+	data, err := x.store.Get(fontPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s': %w", fontPath, err)
+	}
+	source, err := epubSource(x.store, fontPath)
+	if err != nil {
+		return "", err
+	}
+	name := mediaName(fontPath)
+	internalPath, err := x.e.AddFont(source, name)
+	var used *epub.FilenameAlreadyUsedError
+	for i := 2; errors.As(err, &used); i++ {
+		ext := path.Ext(name)
+		internalPath, err = x.e.AddFont(source, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to add font '%s': %w", sourceURL, err)
+	}
+	x.result.addResource("font", internalPath, fontPath, int64(len(data)))
+	return internalPath, nil
+}
+
+// hasToken reports whether the space-separated list s contains token,
+// ignoring case, as in rel="alternate stylesheet".
+func hasToken(s, token string) bool {
+	for _, f := range strings.Fields(s) {
+		if strings.EqualFold(f, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// stylesheets returns the contents of the EPUB's stylesheets, concatenated.
+func stylesheets(files map[string]string) string {
+	var css strings.Builder
+	for name, data := range files {
+		if strings.HasSuffix(name, ".css") {
+			css.WriteString(data + "\n")
+		}
+	}
+	return css.String()
+}
+
+func TestCSSBackgroundImages(t *testing.T) {
+	bg, dot := testPNG(t, 4, 4, color.Black), testPNG(t, 2, 2, color.White)
+	srv := fileServer(t, map[string]servedFile{
+		"/book/css/style.css": {"text/css", []byte(`.hero { background-image: url("../img/bg.png"); } .gone { background: url(missing.png) }`)},
+		"/book/img/bg.png":    {"image/png", bg},
+		"/shared/dot.png":     {"image/png", dot},
+	})
+	page := `<html><head><title>Page</title><link rel="stylesheet" href="css/style.css">` +
+		`<style>li { list-style-image: url('/shared/dot.png') } p { background: url(data:image/gif;base64,R0lGOD==) }</style></head>` +
+		`<body><h3>One</h3><div class="hero"><p>Text.</p></div></body></html>`
+	_, files := testBuild(t, page, Options{SourceURL: srv.URL + "/book/page.html", EmbedCSS: true})
+
+	images := make(map[string]string)
+	for name, data := range files {
+		if strings.HasPrefix(name, "EPUB/images/") {
+			images[data] = strings.TrimPrefix(name, "EPUB/")
+		}
+	}
+	css := stylesheets(files)
+	for _, img := range []struct {
+		name string
+		data []byte
+	}{{"bg.png", bg}, {"dot.png", dot}} {
+		internal, ok := images[string(img.data)]
+		if !ok {
+			t.Errorf("%s not embedded", img.name)
+			continue
+		}
+		if !strings.Contains(css, `url("../`+internal+`")`) {
+			t.Errorf("stylesheet doesn't point at the embedded %s (%s):\n%s", img.name, internal, css)
+		}
+	}
+	for _, kept := range []string{"url(missing.png)", "url(data:image/gif;base64,R0lGOD==)"} {
+		if !strings.Contains(css, kept) {
+			t.Errorf("stylesheet lost %s:\n%s", kept, css)
+		}
+	}
+	if strings.Contains(css, "/book/img/bg.png") || strings.Contains(css, "/shared/dot.png") {
+		t.Errorf("stylesheet still refers to the source:\n%s", css)
+	}
+}
+
+func TestCSSImports(t *testing.T) {
+	bg := testPNG(t, 4, 4, color.Black)
+	srv := fileServer(t, map[string]servedFile{
+		"/book/css/main.css":       {"text/css", []byte(`@import "base/base.css" screen; p { color: navy }`)},
+		"/book/css/base/base.css":  {"text/css", []byte(`@import url(../main.css); @import url('fonts.css'); h3 { background: url(../../img/bg.png) }`)},
+		"/book/css/base/fonts.css": {"text/css", []byte(`body { font-family: serif }`)},
+		"/book/img/bg.png":         {"image/png", bg},
+	})
+	page := `<html><head><title>Page</title><link rel="stylesheet" href="css/main.css"></head>` +
+		`<body><h3>One</h3><p>Text.</p></body></html>`
+	_, files := testBuild(t, page, Options{SourceURL: srv.URL + "/book/page.html", EmbedCSS: true})
+
+	sheets := make(map[string]string) // Internal path by a rule in the sheet
+	for name, data := range files {
+		if strings.HasSuffix(name, ".css") {
+			for _, rule := range []string{"color: navy", "h3 {", "font-family: serif"} {
+				if strings.Contains(data, rule) {
+					sheets[rule] = strings.TrimPrefix(name, "EPUB/")
+				}
+			}
+		}
+	}
+	if len(sheets) != 3 {
+		t.Fatalf("embedded stylesheets = %v, want all three", sheets)
+	}
+	main, base := files["EPUB/"+sheets["color: navy"]], files["EPUB/"+sheets["h3 {"]]
+	if !strings.HasPrefix(main, `@import url("../`+sheets["h3 {"]+`") screen;`) {
+		t.Errorf("main stylesheet doesn't import the embedded base one:\n%s", main)
+	}
+	if !strings.Contains(base, `@import url("../`+sheets["font-family: serif"]+`")`) {
+		t.Errorf("base stylesheet doesn't import the embedded fonts one:\n%s", base)
+	}
+	if strings.Contains(base, "main.css") {
+		t.Errorf("base stylesheet kept the import back to the main one:\n%s", base)
+	}
+	for name, data := range files {
+		if data == string(bg) && !strings.Contains(base, `url("../`+strings.TrimPrefix(name, "EPUB/")+`")`) {
+			t.Errorf("base stylesheet doesn't point at the embedded %s:\n%s", name, base)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugHTMLDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "debug")
+	page := `<html><body><h3>One</h3><p>First &amp; foremost.</p><h3>Two</h3><p>Second.</p></body></html>`
+	result, files := testBuild(t, page, Options{DebugHTMLDir: dir})
+
+	sections := result.Summary().Sections
+	if len(sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(sections))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(sections) {
+		t.Errorf("dumped %d files, want one per section", len(entries))
+	}
+	for _, s := range sections {
+		dumped, err := os.ReadFile(filepath.Join(dir, s.Filename))
+		if err != nil {
+			t.Errorf("section %q not dumped: %v", s.Title, err)
+			continue
+		}
+		if len(dumped) != s.Size {
+			t.Errorf("%s is %d bytes, section body is %d", s.Filename, len(dumped), s.Size)
+		}
+		if !strings.Contains(files["EPUB/xhtml/"+s.Filename], string(dumped)) {
+			t.Errorf("%s doesn't match the section in the EPUB:\n%s", s.Filename, dumped)
+		}
+	}
+}
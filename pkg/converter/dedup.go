@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// DefaultDedupThreshold is how many of the 64 bits of two images'
+// perceptual hashes may differ for them to count as the same picture.
+const DefaultDedupThreshold = 5
+
+// embeddedImage is a content image that may be a resized copy of another.
+type embeddedImage struct {
+	internalPath string
+	hash         uint64 // Perceptual hash; see imageHash
+	pixels       int    // Width times height
+}
+
+// imageHash returns a difference hash of img: each bit records whether a
+// pixel of an 9x8 grayscale thumbnail is brighter than its right neighbour.
+// Resized copies of an image get the same or a very close hash.
+func imageHash(img image.Image) uint64 {
+	small := downscale(img, image.Pt(9, 8))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			l, r := small.Pix[small.PixOffset(x, y):], small.Pix[small.PixOffset(x+1, y):]
+			if luminance(l) > luminance(r) {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return hash
+}
+
+// luminance returns the brightness of an NRGBA pixel.
+func luminance(p []uint8) int {
+	return (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+}
+
+// hashImage records the image at imgPath, embedded at internalPath, for
+// deduplication. Images that can't be decoded and animated GIFs are left out.
+func (x *extractor) hashImage(imgPath, internalPath string) {
+	for _, img := range x.embedded {
+		if img.internalPath == internalPath {
+			return // Already embedded from another page
+		}
+	}
+	data, err := x.store.Get(imgPath)
+	if err != nil || isAnimatedGIF(data) {
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	b := img.Bounds()
+	x.embedded = append(x.embedded, embeddedImage{internalPath: internalPath, hash: imageHash(img), pixels: b.Dx() * b.Dy()})
+}
+
+// similarImages maps the internal path of each image that looks like a
+// smaller (or equal) copy of another to that of the largest copy. Hashes
+// differing in at most threshold bits count as the same picture.
+func similarImages(images []embeddedImage, threshold int) map[string]string {
+	sorted := append([]embeddedImage(nil), images...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].pixels > sorted[j].pixels })
+	replace := make(map[string]string)
+	var kept []embeddedImage
+	for _, img := range sorted {
+		found := false
+		for _, k := range kept {
+			if bits.OnesCount64(img.hash^k.hash) <= threshold {
+				replace[img.internalPath] = k.internalPath
+				found = true
+				break
+			}
+		}
+		if !found {
+			kept = append(kept, img)
+		}
+	}
+	return replace
+}
+
+// replaceImages points the image references in sections at the images
+// replace maps them to.
+func replaceImages(sections []Section, replace map[string]string) {
+	if len(replace) == 0 {
+		return
+	}
+	pairs := make([]string, 0, len(replace)*2)
+	for from, to := range replace {
+		pairs = append(pairs, `src="`+from+`"`, `src="`+to+`"`)
+	}
+	r := strings.NewReplacer(pairs...)
+	for i := range sections {
+		sections[i].Body = r.Replace(sections[i].Body)
+	}
+}
@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// patternPNG returns a size by size PNG of a diagonal gradient, running the
+// other way if flip is set, which looks the same at any size.
+func patternPNG(t *testing.T, size int, flip bool) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			v := (x*3 + y) * 255 / (size * 4)
+			if flip {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{uint8(v)})
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDedupResizedImages(t *testing.T) {
+	full, thumb, other := patternPNG(t, 256, false), patternPNG(t, 48, false), patternPNG(t, 64, true)
+	srv := fileServer(t, map[string]servedFile{
+		"/full.png":  {"image/png", full},
+		"/thumb.png": {"image/png", thumb},
+		"/other.png": {"image/png", other},
+	})
+	page := `<html><head><title>Page</title></head><body>` +
+		`<h3>One</h3><p>Text.</p><img src="` + srv.URL + `/thumb.png" alt="Thumbnail"><img src="` + srv.URL + `/other.png" alt="Other">` +
+		`<h3>Two</h3><p>Text.</p><img src="` + srv.URL + `/full.png" alt="Full"></body></html>`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		wantDups []string
+	}{
+		{name: "off", opts: Options{}},
+		{name: "on", opts: Options{DedupImages: true, DedupThreshold: DefaultDedupThreshold}, wantDups: []string{"EPUB/images/thumb.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, tt.opts)
+			one := sectionFile(t, result, files, "One")
+			_, hasThumb := files["EPUB/images/thumb.png"]
+			if tt.opts.DedupImages {
+				if !strings.Contains(one, `src="../images/full.png" alt="Thumbnail"`) {
+					t.Errorf("thumbnail not replaced by the full image:\n%s", one)
+				}
+				if hasThumb {
+					t.Error("EPUB still holds the thumbnail")
+				}
+			} else if !strings.Contains(one, `src="../images/thumb.png"`) || !hasThumb {
+				t.Errorf("thumbnail replaced without deduplication:\n%s", one)
+			}
+			if !strings.Contains(one, `src="../images/other.png"`) || files["EPUB/images/other.png"] != string(other) {
+				t.Errorf("a different picture was deduplicated:\n%s", one)
+			}
+			if files["EPUB/images/full.png"] != string(full) {
+				t.Error("EPUB lacks the full image")
+			}
+			if got := result.duplicates; strings.Join(got, ",") != strings.Join(tt.wantDups, ",") {
+				t.Errorf("duplicates = %q, want %q", got, tt.wantDups)
+			}
+		})
+	}
+}
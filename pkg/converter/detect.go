@@ -0,0 +1,156 @@
+package converter
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// languagePattern matches a BCP 47 language tag such as en or pt-BR.
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,8}(?:-[A-Za-z0-9]{1,8})*$`)
+
+// Meta tags naming a page's title and author, by name or property, most
+// trusted first. Dublin Core tags are written both DC.title and dc.title.
+var (
+	titleMetaNames  = []string{"og:title", "dc.title", "dcterms.title"}
+	authorMetaNames = []string{"author", "article:author", "og:author", "book:author", "dc.creator", "dcterms.creator"}
+)
+
+// detectMetadata returns the metadata doc declares about itself: its title
+// and author from JSON-LD, OpenGraph or Dublin Core metadata, or else its
+// <title>; its language from <html lang>; and its description from
+// <meta name="description">. Layered under everything configured, it fills
+// in only what wasn't.
+func detectMetadata(doc *html.Node) Metadata {
+	var m Metadata
+	if root := findElement(doc, "html"); root != nil {
+		lang := strings.TrimSpace(getAttr(root, "lang"))
+		if lang == "" {
+			lang = strings.TrimSpace(getAttr(root, "xml:lang"))
+		}
+		if languagePattern.MatchString(lang) {
+			m.Language = lang
+		}
+	}
+	head := findElement(doc, "head")
+	if head == nil {
+		return m
+	}
+
+	metas := make(map[string]string)
+	var ld []any
+	for c := head.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type != html.ElementNode:
+		case c.Data == "meta":
+			key := getAttr(c, "name")
+			if key == "" {
+				key = getAttr(c, "property")
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			if content := strings.Join(strings.Fields(getAttr(c, "content")), " "); key != "" && content != "" && metas[key] == "" {
+				metas[key] = content
+			}
+		case c.Data == "script" && strings.EqualFold(strings.TrimSpace(getAttr(c, "type")), "application/ld+json"):
+			var v any
+			if err := json.Unmarshal([]byte(getTextContent(c)), &v); err == nil {
+				ld = append(ld, v)
+			}
+		}
+	}
+
+	m.Description = metas["description"]
+	m.Title, m.Author = linkedDataWork(ld)
+	for _, name := range titleMetaNames {
+		if m.Title == "" {
+			m.Title = metas[name]
+		}
+	}
+	if m.Title == "" {
+		if t := findElement(head, "title"); t != nil {
+			m.Title = getTextContent(t)
+		}
+	}
+	for _, name := range authorMetaNames {
+		// article:author is often a profile URL rather than a name
+		if a := metas[name]; m.Author == "" && !strings.Contains(a, "://") {
+			m.Author = a
+		}
+	}
+	return m
+}
+
+// linkedDataWork returns the title and author of the first creative work,
+// such as an Article or Book, described in the JSON-LD documents ld.
+func linkedDataWork(ld []any) (title, author string) {
+	var visit func(v any) bool
+	visit = func(v any) bool {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				if visit(item) {
+					return true
+				}
+			}
+		case map[string]any:
+			if graph, ok := v["@graph"]; ok {
+				return visit(graph)
+			}
+			title = linkedDataText(v["headline"])
+			if title == "" {
+				title = linkedDataText(v["name"])
+			}
+			author = linkedDataName(v["author"])
+			if title != "" && (author != "" || isCreativeWork(v["@type"])) {
+				return true
+			}
+			title, author = "", ""
+		}
+		return false
+	}
+	visit(ld)
+	return title, author
+}
+
+// isCreativeWork reports whether a JSON-LD @type names a kind of writing,
+// rather than e.g. the website or organisation a page also describes.
+func isCreativeWork(t any) bool {
+	if types, ok := t.([]any); ok {
+		for _, t := range types {
+			if isCreativeWork(t) {
+				return true
+			}
+		}
+		return false
+	}
+	s, _ := t.(string)
+	return strings.HasSuffix(s, "Article") || strings.HasSuffix(s, "Posting") ||
+		s == "Book" || s == "Chapter" || s == "CreativeWork" || s == "ScholarlyArticle"
+}
+
+// linkedDataName returns the name of a JSON-LD author: a string, a Person
+// or Organization object, or a list of them, joined with "and".
+func linkedDataName(v any) string {
+	switch v := v.(type) {
+	case []any:
+		var names []string
+		for _, item := range v {
+			if name := linkedDataName(item); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, " and ")
+	case map[string]any:
+		return linkedDataText(v["name"])
+	}
+	return linkedDataText(v)
+}
+
+// linkedDataText returns a JSON-LD value that is a string, with its
+// whitespace collapsed, or "".
+func linkedDataText(v any) string {
+	s, _ := v.(string)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,205 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Paths go-epub writes its generated files to.
+const (
+	packageDocumentPath = "EPUB/package.opf"
+	navDocumentPath     = "EPUB/nav.xhtml"
+	ncxPath             = "EPUB/toc.ncx"
+)
+
+// epubEdits collects changes to files inside a written EPUB, keyed by path.
+// Edits to the same file run in the order they were added.
+type epubEdits map[string][]func([]byte) []byte
+
+func (ed epubEdits) add(name string, edit func([]byte) []byte) {
+	ed[name] = append(ed[name], edit)
+}
+
+// apply returns the EPUB in data with all edits made.
+func (ed epubEdits) apply(data []byte) ([]byte, error) {
+	if len(ed) == 0 {
+		return data, nil
+	}
+	return rewriteEPUB(data, func(name string, b []byte) ([]byte, error) {
+		for _, edit := range ed[name] {
+			b = edit(b)
+		}
+		return b, nil
+	})
+}
+
+// errDropEntry is returned by a rewriteEPUB edit to leave the file out.
+var errDropEntry = errors.New("drop entry")
+
+// rewriteEPUB copies the EPUB archive in src into a new archive, passing each
+// file's contents through edit. It is used for the package document changes
+// go-epub has no API for. Entry order and compression methods are kept, so
+// the mimetype file stays first and uncompressed.
+func rewriteEPUB(src []byte, edit func(name string, data []byte) ([]byte, error)) ([]byte, error) {
+	return repackEPUB(src, edit, nil, flate.DefaultCompression)
+}
+
+// repackEPUB is rewriteEPUB with control over compression: method, if not
+// nil, picks each entry's compression method instead of keeping it, and
+// deflated entries are compressed at level.
+func repackEPUB(src []byte, edit func(name string, data []byte) ([]byte, error), method func(name string) uint16, level int) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(src), int64(len(src)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB archive: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open '%s' in EPUB: %w", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s' in EPUB: %w", f.Name, err)
+		}
+
+		data, err = edit(f.Name, data)
+		if err == errDropEntry {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite '%s' in EPUB: %w", f.Name, err)
+		}
+
+		m := f.Method
+		if method != nil && f.Name != "mimetype" { // The mimetype file must stay stored
+			m = method(f.Name)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: m, Modified: f.Modified})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add '%s' to EPUB: %w", f.Name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write '%s' to EPUB: %w", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish EPUB archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// insertOPFMetadata adds elements to the end of the package document's
+// <metadata> block.
+func insertOPFMetadata(opf []byte, elements []string) []byte {
+	if len(elements) == 0 {
+		return opf
+	}
+	s := string(opf)
+	i := strings.Index(s, "</metadata>")
+	if i < 0 {
+		return opf
+	}
+	var b strings.Builder
+	b.WriteString(s[:i])
+	for _, el := range elements {
+		b.WriteString("  " + el + "\n  ")
+	}
+	b.WriteString(s[i:])
+	return []byte(b.String())
+}
+
+// setNavTitle replaces the heading of the navigation document, which go-epub
+// always writes as "Table of Contents".
+func setNavTitle(nav []byte, title string) []byte {
+	return bytes.Replace(nav, []byte("<h1>Table of Contents</h1>"), []byte("<h1>"+html.EscapeString(title)+"</h1>"), 1)
+}
+
+// navEntry is a table of contents entry pointing into a section file.
+type navEntry struct {
+	Title string
+	Href  string // Relative to the navigation document, e.g. "xhtml/section0001.xhtml#part-2"
+	Level int    // Nesting depth; entries nest under the closest earlier one less deep
+}
+
+// setNavEntries replaces the entries of the navigation document's table of
+// contents.
+func setNavEntries(nav []byte, entries []navEntry) []byte {
+	var b strings.Builder
+	b.WriteString("<ol>")
+	writeNavItems(&b, entries, 0, "\n        ")
+	b.WriteString("\n      </ol>")
+	return replaceFirst(nav, regexp.MustCompile(`(?s)<ol>.*</ol>`), b.String())
+}
+
+// writeNavItems writes the leading entries at least level deep as list
+// items, the deeper ones among them in nested lists, and returns how many it
+// wrote.
+func writeNavItems(b *strings.Builder, entries []navEntry, level int, indent string) int {
+	i := 0
+	for i < len(entries) && entries[i].Level >= level {
+		entry := entries[i]
+		i++
+		b.WriteString(fmt.Sprintf("%s<li>%s  <a href=\"%s\">%s</a>", indent, indent, html.EscapeString(entry.Href), html.EscapeString(entry.Title)))
+		if i < len(entries) && entries[i].Level > entry.Level {
+			b.WriteString(indent + "  <ol>")
+			i += writeNavItems(b, entries[i:], entry.Level+1, indent+"    ")
+			b.WriteString(indent + "  </ol>")
+		}
+		b.WriteString(indent + "</li>")
+	}
+	return i
+}
+
+// setNCXEntries replaces the navigation points of the EPUB 2 toc.ncx, which
+// mirrors the navigation document.
+func setNCXEntries(ncx []byte, entries []navEntry) []byte {
+	var b strings.Builder
+	b.WriteString("<navMap>")
+	writeNavPoints(&b, entries, 0, 0, "\n    ")
+	b.WriteString("\n  </navMap>")
+	return replaceFirst(ncx, regexp.MustCompile(`(?s)<navMap>.*</navMap>`), b.String())
+}
+
+// writeNavPoints writes entries like writeNavItems as navigation points,
+// numbering them on from the offset-th entry.
+func writeNavPoints(b *strings.Builder, entries []navEntry, level, offset int, indent string) int {
+	i := 0
+	for i < len(entries) && entries[i].Level >= level {
+		entry := entries[i]
+		i++
+		b.WriteString(fmt.Sprintf("%s<navPoint id=\"navPoint-%d\">%s  <navLabel>%s    <text>%s</text>%s  </navLabel>%s  <content src=\"%s\"></content>",
+			indent, offset+i, indent, indent, html.EscapeString(entry.Title), indent, indent, html.EscapeString(entry.Href)))
+		if i < len(entries) && entries[i].Level > entry.Level {
+			i += writeNavPoints(b, entries[i:], entry.Level+1, offset+i, indent+"  ")
+		}
+		b.WriteString(indent + "</navPoint>")
+	}
+	return i
+}
+
+// replaceFirst replaces the first match of re in data with repl, taken
+// literally.
+func replaceFirst(data []byte, re *regexp.Regexp, repl string) []byte {
+	loc := re.FindIndex(data)
+	if loc == nil {
+		return data
+	}
+	out := append([]byte{}, data[:loc[0]]...)
+	out = append(out, repl...)
+	return append(out, data[loc[1]:]...)
+}
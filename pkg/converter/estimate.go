@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Rough figures estimateSize turns source sizes into an EPUB size with.
+const (
+	textCompressionRatio = 0.35    // Deflated size of extracted XHTML relative to its text
+	sectionMarkupBytes   = 300     // XHTML wrapper, manifest and navigation entries per section
+	fixedEPUBBytes       = 2 << 10 // mimetype, container, package document and navigation
+)
+
+// SizeEstimate is the estimated size of the EPUB a build would write.
+type SizeEstimate struct {
+	Bytes         int64 // Estimated size of the EPUB
+	TextBytes     int64 // Text extracted from the source documents, before compression
+	ImageBytes    int64 // Images, which are stored as they are
+	Images        int   // Images referenced
+	UnknownImages int   // Images whose size couldn't be found; not counted in ImageBytes
+}
+
+// EstimateSize estimates the size of the EPUB building opts would write,
+// without building it: it reads the source documents as a build would, but
+// only asks servers for the size of each image, with a HEAD request or,
+// where HEAD isn't supported, a one-byte range request.
+func EstimateSize(ctx context.Context, opts Options) (SizeEstimate, error) {
+	var est SizeEstimate
+	ctx = opts.withRetryPolicy(ctx)
+	sources, err := loadSources(ctx, opts)
+	if err != nil {
+		return est, err
+	}
+	seen := make(map[string]bool)
+	skip := opts.skippedElements()
+	sections := 0
+	for _, src := range sources {
+		headings := opts.sourceHeadings(src)
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			switch {
+			case n.Type == html.TextNode:
+				est.TextBytes += int64(len(strings.TrimSpace(n.Data)))
+			case headingLevel(n, headings) >= 0:
+				sections++
+			case n.Type == html.ElementNode && n.Data == "img":
+				u, err := src.baseURL.Parse(getAttr(n, "src"))
+				if err != nil || seen[u.String()] {
+					break
+				}
+				seen[u.String()] = true
+				est.Images++
+				size, err := imageURLSize(ctx, src, u)
+				if err != nil {
+					est.UnknownImages++
+					break
+				}
+				est.ImageBytes += size
+			case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style" || n.Data == "head" || skip[n.Data]):
+				return // Not extracted
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		if body := findElement(src.doc, "body"); body != nil {
+			walk(body)
+		} else {
+			walk(src.doc)
+		}
+		sections++ // Content before the first heading
+	}
+	if ctx.Err() != nil {
+		return est, ctx.Err()
+	}
+	if opts.CoverImage != "" {
+		if size, err := coverSize(ctx, opts.CoverImage); err == nil {
+			est.ImageBytes += size
+		}
+	}
+	est.Bytes = int64(float64(est.TextBytes)*textCompressionRatio) + est.ImageBytes + int64(sections*sectionMarkupBytes) + fixedEPUBBytes
+	return est, nil
+}
+
+// imageURLSize returns the size of the image at u, which src refers to.
+func imageURLSize(ctx context.Context, src *source, u *url.URL) (int64, error) {
+	if u.Scheme == "data" {
+		data, err := readMedia(u.String())
+		return int64(len(data)), err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		data, err := src.fetch(ctx, u) // Archive entries are already in memory
+		return int64(len(data)), err
+	}
+	return remoteSize(ctx, u.String())
+}
+
+// coverSize returns the size of the cover image at loc, a path or URL.
+func coverSize(ctx context.Context, loc string) (int64, error) {
+	if strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://") {
+		return remoteSize(ctx, loc)
+	}
+	fi, err := os.Stat(loc)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// errUnknownSize means a server didn't say how big a resource is.
+var errUnknownSize = errors.New("size unknown")
+
+// remoteSize asks the server for the size of the resource at urlStr without
+// downloading it: from the Content-Length of a HEAD response, or, if the
+// server doesn't answer HEAD, from the Content-Range of a request for the
+// first byte.
+func remoteSize(ctx context.Context, urlStr string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, urlStr, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request for '%s': %w", urlStr, err)
+	}
+	resp, err := doRequest(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+			return resp.ContentLength, nil
+		}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request for '%s': %w", urlStr, err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err = doRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get '%s': %w", urlStr, err)
+	}
+	defer resp.Body.Close() // Unread: a server ignoring the range sends everything
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if n, err := strconv.ParseInt(total, 10, 64); ok && err == nil {
+			return n, nil
+		}
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			return resp.ContentLength, nil
+		}
+	default:
+		return 0, fmt.Errorf("bad status for '%s': %s", urlStr, resp.Status)
+	}
+	return 0, fmt.Errorf("'%s': %w", urlStr, errUnknownSize)
+}
@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// noisePNG returns a size by size PNG of random pixels, which doesn't
+// compress.
+func noisePNG(t *testing.T, size int, seed int64) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	rand.New(rand.NewSource(seed)).Read(img.Pix)
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestEstimateSize(t *testing.T) {
+	photo, chart := noisePNG(t, 96, 1), noisePNG(t, 64, 2)
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/book.html":
+			var page strings.Builder
+			page.WriteString(`<html><head><title>Book</title></head><body>`)
+			for i := 1; i <= 5; i++ {
+				fmt.Fprintf(&page, "<h3>Chapter %d</h3><p>%s</p>", i, strings.Repeat("Words in a chapter of the book. ", 60))
+			}
+			page.WriteString(`<img src="photo.png" alt="Photo"><img src="chart.png" alt="Chart"></body></html>`)
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page.String()))
+		case "/photo.png":
+			http.ServeContent(w, r, "photo.png", time.Time{}, bytes.NewReader(photo))
+		case "/chart.png":
+			if r.Method == http.MethodHead {
+				http.Error(w, "HEAD not supported", http.StatusMethodNotAllowed)
+				return
+			}
+			http.ServeContent(w, r, "chart.png", time.Time{}, bytes.NewReader(chart))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	opts := Options{SourceURL: srv.URL + "/book.html", OutputPath: filepath.Join(dir, "book.epub"), ImageDir: filepath.Join(dir, "images")}
+	est, err := EstimateSize(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("EstimateSize: %v", err)
+	}
+	if est.Images != 2 || est.UnknownImages != 0 || est.ImageBytes != int64(len(photo)+len(chart)) {
+		t.Errorf("estimate = %+v, want 2 images of %d bytes", est, len(photo)+len(chart))
+	}
+	var fetched []string
+	for _, r := range requests {
+		if strings.Contains(r, ".png") {
+			fetched = append(fetched, r)
+		}
+	}
+	if want := []string{"HEAD /photo.png ", "HEAD /chart.png ", "GET /chart.png bytes=0-0"}; strings.Join(fetched, "|") != strings.Join(want, "|") {
+		t.Errorf("image requests = %q, want %q", fetched, want)
+	}
+
+	if _, err := build(context.Background(), opts); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	fi, err := os.Stat(opts.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio := float64(est.Bytes) / float64(fi.Size()); ratio < 0.75 || ratio > 1.25 {
+		t.Errorf("estimated %d bytes, built %d; want within 25%%", est.Bytes, fi.Size())
+	}
+}
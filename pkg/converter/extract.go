@@ -0,0 +1,639 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/go-shiori/go-epub"
+	"golang.org/x/net/html"
+)
+
+// Section is a chunk of extracted content that becomes one EPUB section.
+type Section struct {
+	Title  string
+	Body   string // XHTML body content
+	CSS    string // Internal path of the section's stylesheet, if any
+	Source string // Archive entry name or URL of the document it came from
+	Level  int    // Nesting depth in the table of contents; 0 for a top-level chapter
+
+	firstImage string // Media location of the section's first image, if any
+	filename   string // Internal filename to add the section as; generated if empty
+}
+
+// extractor walks parsed sources and splits their content into sections,
+// embedding images into the EPUB as it goes.
+type extractor struct {
+	ctx    context.Context
+	opts   Options
+	e      *epub.Epub
+	result *Result
+	store  MediaStore
+	src    *source         // Source currently being extracted
+	skip   map[string]bool // Elements not extracted at all
+
+	headings []SectionMarker // Elements that start a section in the current source, by level
+
+	sections         []Section
+	emit             func(Section) bool // If set, receives finished sections instead of keeping them; false stops extraction
+	stopped          bool
+	currentSection   strings.Builder
+	sectionTitle     string
+	sectionLevel     int
+	sectionTextNodes int
+	sectionImages    int
+	firstImageAlt    string
+	firstImage       string
+	leading          bool       // The current section is the content before the first source's first heading
+	heldLeading      *Section   // Leading content waiting to be merged into the next section
+	subtitle         *html.Node // Heading taken as the current section's subtitle, if any
+	sectionHeading   *html.Node // Heading the current section starts at, if any
+	headingEnd       int        // Length of the section once its heading's text is written
+	blankStart       int        // Where the section's last run of blank paragraphs starts
+	blankEnd         int        // And where it ends; trailing if nothing follows it
+	paragraphBlock   *html.Node // Block the section's last paragraph was written from
+	paragraphEnd     int        // Length of the section once that paragraph is written
+
+	sourceNames map[string]string          // Source names by URL, for links between sources
+	bookTargets map[string]map[string]bool // Ids that links point at, by source name
+	links       []internalLink             // Targets of the placeholder links written so far
+	anchors     map[string]string          // Ids written out by source name and original id
+	linkTargets map[string]bool            // Ids that links point at in the current source
+	docIDs      map[string]bool            // All ids in the current source
+	usedIDs     map[string]bool            // Ids written out for the current source so far
+	pendingIDs  []string                   // Link target ids waiting for the next paragraph
+
+	separator *template.Template // Label of the divider inserted between sources, if any
+	sources   int                // Number of sources extracted so far
+
+	css           string            // Internal path of the current source's stylesheet, if any
+	bookCSS       string            // The Stylesheets, concatenated
+	bookCSSPath   string            // Internal path of bookCSS on its own, once added
+	stylesheets   int               // Number of stylesheets embedded so far
+	cssImages     map[string]string // Internal paths of images embedded from CSS, by URL
+	importedCSS   map[string]string // Internal paths of stylesheets embedded through @import, by URL
+	cssInProgress map[string]bool   // URLs of stylesheets being embedded, to catch import cycles
+
+	images   int             // Number of images embedded so far
+	tables   int             // Number of tables embedded as images so far
+	embedded []embeddedImage // Content images, when looking for resized copies
+
+	embeddedFiles map[string]string // Internal paths of embedded images by SHA-256 of their contents
+
+	prefetched map[string]loadedImage // Images of the current source downloaded ahead of the walk, by URL
+	loaded     map[string]loadedImage // Images of all sources so far, by URL, so each is downloaded once
+	imageTotal int                    // Images to download in all, for progress
+
+	footnotes    map[string]*html.Node // Footnote definitions of the current source by id, when moving notes
+	noterefIDs   map[string]bool       // Ids of the current source's note references
+	sectionNotes map[string]string     // Ids of the current section's popup notes by footnote id
+	popupNotes   strings.Builder       // The current section's popup notes
+	noteIDs      map[string]string     // Endnote ids by source name and footnote id
+	notes        int                   // Number of endnotes so far
+	endnotes     strings.Builder
+}
+
+// separatorData is what a source separator label template is executed with.
+type separatorData struct {
+	Index int    // 1-based position of the source that follows the separator
+	Title string // Title of that source
+	Name  string // Its archive entry name or URL
+}
+
+func newExtractor(ctx context.Context, opts Options, e *epub.Epub, result *Result) (*extractor, error) {
+	x := &extractor{
+		ctx:           ctx,
+		opts:          opts,
+		e:             e,
+		result:        result,
+		store:         opts.mediaStore(),
+		skip:          opts.skippedElements(),
+		cssImages:     make(map[string]string),
+		importedCSS:   make(map[string]string),
+		cssInProgress: make(map[string]bool),
+		embeddedFiles: make(map[string]string),
+		loaded:        make(map[string]loadedImage),
+		noteIDs:       make(map[string]string),
+		anchors:       make(map[string]string),
+	}
+	for _, file := range opts.Stylesheets {
+		css, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stylesheet '%s': %w", file, err)
+		}
+		x.bookCSS += string(css) + "\n"
+	}
+	if opts.SourceSeparator != "" {
+		t, err := template.New("separator").Parse(opts.SourceSeparator)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source separator template: %w", err)
+		}
+		x.separator = t
+	}
+	return x, nil
+}
+
+// extract adds the content of src to the extracted sections. Each source
+// starts a new section titled src.title until its first heading.
+func (x *extractor) extract(src *source) {
+	x.flushSection()
+	if x.stopped {
+		return
+	}
+	if x.separator != nil && x.sources > 0 {
+		x.addSeparator(src)
+	}
+	x.sources++
+	x.src = src
+	x.sectionTitle, x.sectionLevel = src.title, 0
+	x.leading = x.sources == 1
+	x.linkTargets = x.bookTargets[src.name]
+	x.docIDs = collectIDs(src.doc)
+	if x.opts.NormalizeHeadings {
+		renamed := normalizeHeadings(src.doc)
+		if tag, ok := renamed[src.chapterHeading]; ok {
+			src.chapterHeading = tag // Found among the headings as they were
+		}
+	}
+	x.headings = x.opts.sourceHeadings(src)
+	if x.usedIDs == nil || !x.opts.SingleFile {
+		x.usedIDs = make(map[string]bool) // A single file holds every source's ids
+	}
+	x.footnotes, x.noterefIDs = nil, nil
+	if x.opts.footnoteMode() != FootnotesKeep {
+		x.footnotes, x.noterefIDs = collectFootnotes(src.doc, src.baseURL)
+	}
+	if x.opts.EmbedCSS {
+		x.css = x.embedStylesheets(src)
+	} else {
+		x.css = x.bookStylesheet()
+	}
+
+	x.prefetchImages(src)
+
+	// Find the body node to start extraction
+	bodyNode := findElement(src.doc, "body")
+	if bodyNode != nil {
+		x.walk(bodyNode)
+	} else {
+		parserLog.Warn("Could not find body node in HTML, extracting from root", "source", src.name)
+		x.walk(src.doc) // Fallback to extracting from root if body not found
+	}
+
+	// Targets after the last content still need to exist
+	for _, id := range x.pendingIDs {
+		x.currentSection.WriteString(fmt.Sprintf(`<div id="%s"></div>`, html.EscapeString(id)))
+	}
+	x.pendingIDs = nil
+}
+
+// addSeparator appends a divider section announcing src, so readers can
+// tell where one merged source ends and the next begins.
+func (x *extractor) addSeparator(src *source) {
+	var label strings.Builder
+	data := separatorData{Index: x.sources + 1, Title: src.title, Name: src.name}
+	if err := x.separator.Execute(&label, data); err != nil {
+		parserLog.Warn("Could not render source separator", "source", src.name, "err", err)
+		return
+	}
+	title := strings.TrimSpace(label.String())
+	if title == "" {
+		return
+	}
+	body := `<div class="source-separator"><p>` + html.EscapeString(title) + `</p></div>`
+	x.add(Section{Title: title, Body: body, Source: src.name})
+}
+
+// finish flushes the last section, adds the endnotes, and returns
+// everything extracted.
+func (x *extractor) finish() []Section {
+	x.flushSection()
+	if x.heldLeading != nil {
+		x.add(*x.heldLeading) // There was no later section to merge it into
+		x.heldLeading = nil
+	}
+	if s, ok := x.endnotesSection(); ok {
+		x.add(s)
+	}
+	return x.sections
+}
+
+// add hands a finished section to the emit callback, or keeps it.
+func (x *extractor) add(s Section) {
+	if x.stopped {
+		return
+	}
+	if x.emit != nil {
+		x.stopped = !x.emit(s)
+		return
+	}
+	x.sections = append(x.sections, s)
+}
+
+// flushSection keeps the current section if it has any text or images.
+// Image-only sections (e.g. a full-page illustration) count as content.
+func (x *extractor) flushSection() {
+	if x.sectionTextNodes > 0 || x.sectionImages > 0 {
+		title := x.sectionTitle
+		if title == "" {
+			if x.sectionTextNodes == 0 {
+				// Name illustration-only sections after their image
+				title = x.firstImageAlt
+				if title == "" {
+					title = "Illustration"
+				}
+			} else {
+				title = "Unnamed Section"
+			}
+		}
+		body := x.currentSection.String()
+		if x.blankEnd == len(body) && x.blankEnd > x.blankStart {
+			body = body[:x.blankStart] // Blank paragraphs the section ends with
+		}
+		body += x.popupNotes.String()
+		if x.leading && x.opts.Leading == LeadingFrontMatter {
+			body = `<section epub:type="frontmatter">` + body + `</section>`
+		}
+		s := Section{Title: title, Body: body, CSS: x.css, Source: x.src.name, Level: x.sectionLevel, firstImage: x.firstImage}
+		switch {
+		case x.leading && x.opts.Leading == LeadingDiscard:
+		case x.leading && x.opts.Leading == LeadingMerge:
+			x.heldLeading = &s
+		default:
+			if h := x.heldLeading; h != nil {
+				s.Body = h.Body + s.Body
+				if s.firstImage == "" {
+					s.firstImage = h.firstImage
+				}
+				x.heldLeading = nil
+			}
+			x.add(s)
+		}
+	}
+	x.currentSection.Reset() // Start new section
+	x.popupNotes.Reset()
+	x.sectionNotes = make(map[string]string)
+	x.sectionTextNodes, x.sectionImages = 0, 0
+	x.firstImageAlt, x.firstImage = "", ""
+	x.leading = false
+	x.sectionHeading, x.headingEnd, x.blankStart, x.blankEnd = nil, 0, 0, 0
+	x.paragraphBlock, x.paragraphEnd = nil, 0
+}
+
+// appendInline adds inline markup for n to the end of the last paragraph,
+// since it belongs with the text before it, or as a paragraph of its own if
+// the section doesn't end with a paragraph written from n's block, e.g. when
+// n opens a paragraph after a heading.
+func (x *extractor) appendInline(n *html.Node, markup string) {
+	block := enclosingBlock(n)
+	if body := x.currentSection.String(); block == x.paragraphBlock && len(body) == x.paragraphEnd && strings.HasSuffix(body, " </p>") {
+		x.currentSection.Reset()
+		x.currentSection.WriteString(strings.TrimSuffix(body, " </p>") + markup + " </p>")
+	} else {
+		x.currentSection.WriteString(x.openParagraph() + markup + " </p>")
+	}
+	x.paragraphBlock, x.paragraphEnd = block, x.currentSection.Len()
+}
+
+// enclosingBlock returns the nearest ancestor of n that isn't inline
+// markup, which the paragraphs written from n's text belong to.
+func enclosingBlock(n *html.Node) *html.Node {
+	p := n.Parent
+	for p != nil && (inlineElements[p.Data] || p.Data == "a" || p.Data == "ruby") {
+		p = p.Parent
+	}
+	return p
+}
+
+// openParagraph starts a paragraph carrying any pending link target ids.
+// A paragraph holds one id; further ones become empty spans inside it.
+func (x *extractor) openParagraph() string {
+	return x.openBlock("p")
+}
+
+// openBlock starts a tag element carrying pending ids like openParagraph.
+// tag may include attributes, as in `p class="subtitle"`.
+func (x *extractor) openBlock(tag string) string {
+	if len(x.pendingIDs) == 0 {
+		return "<" + tag + ">"
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(`<%s id="%s">`, tag, html.EscapeString(x.pendingIDs[0])))
+	for _, id := range x.pendingIDs[1:] {
+		b.WriteString(fmt.Sprintf(`<span id="%s"></span>`, html.EscapeString(id)))
+	}
+	x.pendingIDs = nil
+	return b.String()
+}
+
+func (x *extractor) walk(n *html.Node) {
+	if x.ctx.Err() != nil || x.stopped {
+		return // Build aborted or consumer done; unwind without doing more work
+	}
+	if n.Type == html.ElementNode {
+		// Navigation, headers and the like aren't content, nor are scripts,
+		// frames and form controls, which readers reject
+		if x.skip[n.Data] || droppedElements[n.Data] {
+			return
+		}
+
+		// Footnotes are moved as they're referenced
+		if x.isFootnote(n) {
+			return
+		}
+		if x.addNoteref(n) {
+			x.sectionTextNodes++
+			return
+		}
+
+		// Ruby annotations only make sense next to their base text
+		if n.Data == "ruby" {
+			x.appendInline(n, rubyMarkup(n))
+			x.sectionTextNodes++
+			return
+		}
+
+		// Configured section wrappers start a section; a heading inside them
+		// just names it, since the section is still empty when it's reached
+		if x.opts.SectionMarker.matches(n) {
+			x.flushSection()
+			x.sectionTitle = applyTitleCase(x.markerTitle(n), x.opts.TitleCase)
+			x.sectionLevel = 0
+		}
+
+		// Basic section handling (can be improved based on actual HTML structure)
+		if level := headingLevel(n, x.headings); level >= 0 {
+			x.flushSection()
+			x.sectionTitle = applyTitleCase(x.headingTitle(n), x.opts.TitleCase) // Get title from heading; empty titles are resolved on flush
+			x.sectionLevel = level
+			x.sectionHeading = n
+			if x.opts.Subtitles != "" && x.opts.Subtitles != SubtitleNone {
+				x.subtitle = subtitleHeading(n)
+				if x.subtitle != nil && headingLevel(x.subtitle, x.headings) >= 0 {
+					x.subtitle = nil // It starts a nested section instead
+				}
+			}
+			if x.subtitle != nil && x.opts.Subtitles == SubtitleLabel {
+				if sub := applyTitleCase(getTextContent(x.subtitle), x.opts.TitleCase); sub != "" && x.sectionTitle != "" {
+					x.sectionTitle += ": " + sub
+				}
+			}
+		}
+		if n == x.subtitle && x.opts.Subtitles == SubtitleStyled {
+			if text := getTextContent(n); text != "" {
+				x.sectionTextNodes++
+				x.currentSection.WriteString(x.openBlock(`p class="subtitle"`) + html.EscapeString(text) + "</p>")
+			}
+			return
+		}
+
+		// Keep ids that links point at so cross-references still resolve
+		if id := getAttr(n, "id"); id != "" && x.linkTargets[id] {
+			unique := x.uniqueID(id) // Duplicate ids would make the XHTML invalid
+			if _, ok := x.anchors[x.src.name+"#"+id]; !ok {
+				x.anchors[x.src.name+"#"+id] = unique // Links go to the first of duplicate ids, as in a browser
+			}
+			x.pendingIDs = append(x.pendingIDs, unique)
+		}
+
+		// Links to other parts of the book are pointed at their section files
+		if x.addInternalLink(n) {
+			x.sectionTextNodes++
+			return
+		}
+
+		// Tables too complex for readers to lay out become images
+		if n.Data == "table" && x.opts.TableImages && isComplexTable(n, x.opts.tableImageColumns()) && x.addTableImage(n) {
+			return
+		}
+
+		// Handle images
+		if n.Data == "img" {
+			x.addImage(n)
+		}
+
+		// A visible image caption has already been written with its image
+		if x.opts.ImageText == ImageTextVisible && isImageFigcaption(n) {
+			return
+		}
+	} else if n.Type == html.CommentNode {
+		if markup := renderComment(n.Data, x.opts.Comments); markup != "" {
+			x.currentSection.WriteString(markup)
+			if x.opts.Comments == CommentsAside {
+				x.sectionTextNodes++ // Visible notes are content in their own right
+			}
+		}
+	} else if n.Type == html.TextNode {
+		// Append text content, trimming whitespace
+		trimmedData := strings.TrimSpace(n.Data)
+		if trimmedData != "" && !x.opts.KeepEmptyBlocks && isBlankText(trimmedData) && len(x.pendingIDs) == 0 {
+			// Blank paragraphs, e.g. of zero-width spaces, are dropped at the
+			// start of the section and, on flush, at its end
+			if x.currentSection.Len() > x.headingEnd {
+				if x.blankEnd != x.currentSection.Len() {
+					x.blankStart = x.currentSection.Len()
+				}
+				x.currentSection.WriteString(x.openParagraph() + html.EscapeString(trimmedData) + " </p>")
+				x.blankEnd = x.currentSection.Len()
+			}
+		} else if trimmedData != "" {
+			x.sectionTextNodes++
+			// Basic paragraph wrapping: each text node becomes its own paragraph.
+			// This is a simplification; real HTML structure might need more complex handling.
+			open := x.openParagraph()
+			if x.opts.Presentational == PresentationCSS {
+				if style := presentationalStyle(n.Parent); style != "" {
+					open = x.openBlock(`p style="` + style + `"`)
+				}
+			}
+			x.currentSection.WriteString(open + html.EscapeString(trimmedData) + " </p>") // Add space between text nodes
+			x.paragraphBlock, x.paragraphEnd = enclosingBlock(n), x.currentSection.Len()
+			if x.sectionHeading != nil && isInside(n, x.sectionHeading) {
+				x.headingEnd = x.currentSection.Len()
+			}
+		}
+	}
+
+	// Recursively process child nodes
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		x.walk(c)
+	}
+}
+
+// headingTitle returns the section title for a heading: its text, or for a
+// heading that is just an image (e.g. a decorative "Part One" banner) the
+// image's alt text. The image itself is still embedded when the walk reaches it.
+func (x *extractor) headingTitle(n *html.Node) string {
+	if title := getText(n); title != "" {
+		return title
+	}
+	img := findElement(n, "img")
+	if img == nil {
+		return ""
+	}
+	src := getAttr(img, "src")
+	if u, err := x.src.baseURL.Parse(src); err == nil {
+		if alt, ok := lookupAltText(x.opts.AltText, u.String(), src); ok {
+			return alt
+		}
+	}
+	return strings.TrimSpace(getAttr(img, "alt"))
+}
+
+// addImage embeds the image an <img> node refers to and appends it to the
+// current section.
+func (x *extractor) addImage(n *html.Node) {
+	defer x.timeImages(time.Now())
+	alt := strings.TrimSpace(getAttr(n, "alt"))
+	imgURL := getAttr(n, "src")
+	if imgURL == "" {
+		return
+	}
+
+	// Resolve relative URLs
+	absoluteImgURL, err := x.src.baseURL.Parse(imgURL)
+	if err != nil {
+		imagesLog.Warn("Could not parse image URL", "url", imgURL, "err", err)
+		return
+	}
+	if override, ok := lookupAltText(x.opts.AltText, absoluteImgURL.String(), imgURL); ok {
+		alt = override
+	}
+
+	// Download or load image
+	imgPath, err := x.loadImage(x.ctx, absoluteImgURL)
+	if err != nil {
+		imagesLog.Warn("Could not download or load image", "url", absoluteImgURL.String(), "err", err)
+		return
+	}
+
+	// Convert, downscale and re-encode images as configured
+	if x.opts.processesImages() {
+		processed, err := processImage(x.store, imgPath, x.opts)
+		if err != nil {
+			imagesLog.Warn("Could not process image, embedding original", "image", imgPath, "err", err)
+		} else {
+			imgPath = processed
+		}
+	}
+
+	// Add image to EPUB and get internal path
+	epubImgPath, err := x.embedImage(imgPath, absoluteImgURL.String())
+	if err != nil {
+		imagesLog.Warn("Could not add image to EPUB", "image", imgPath, "err", err)
+		// Don't remove the local file yet if adding failed
+		return
+	}
+
+	if x.opts.DedupImages {
+		x.hashImage(imgPath, epubImgPath)
+	}
+
+	// Append img tag to current section content
+	imgAlt := alt
+	if imgAlt == "" {
+		imgAlt = "Image"
+	}
+	caption := imageCaption(n, alt)
+	switch {
+	case x.opts.ImageText == ImageTextVisible && caption != "":
+		x.currentSection.WriteString(fmt.Sprintf(`%s<img src="%s" alt="%s"/><figcaption>%s</figcaption></figure>`,
+			x.openBlock("figure"), epubImgPath, html.EscapeString(imgAlt), html.EscapeString(caption)))
+	case x.opts.ImageText == ImageTextHidden && caption != "":
+		id := x.uniqueID(fmt.Sprintf("image-description-%d", x.images)) // Numbered like the embedded images
+		x.currentSection.WriteString(fmt.Sprintf(`%s<img src="%s" alt="%s" aria-describedby="%s"/><span id="%s" hidden="hidden">%s</span></p>`,
+			x.openParagraph(), epubImgPath, html.EscapeString(imgAlt), id, id, html.EscapeString(caption)))
+	default:
+		x.currentSection.WriteString(fmt.Sprintf(`%s<img src="%s" alt="%s"/></p>`, x.openParagraph(), epubImgPath, html.EscapeString(imgAlt)))
+	}
+	if x.sectionImages == 0 {
+		x.firstImageAlt, x.firstImage = alt, imgPath
+	}
+	x.sectionImages++
+}
+
+// embedImage adds the image at imgPath in the media store, downloaded from
+// sourceURL, to the EPUB and returns its internal path. Images are named by
+// opts.ImageName if set; a name already in use gets a numeric suffix.
+func (x *extractor) embedImage(imgPath, sourceURL string) (string, error) {
+	// The same image, e.g. a logo on every page, is embedded once
+	data, err := x.store.Get(imgPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s': %w", imgPath, err)
+	}
+	sum := sha256.Sum256(data)
+	if internalPath, ok := x.embeddedFiles[string(sum[:])]; ok {
+		return internalPath, nil
+	}
+	source, err := epubSource(x.store, imgPath)
+	if err != nil {
+		return "", err
+	}
+	x.images++
+	var name string
+	if x.opts.ImageName != nil {
+		// go-epub keeps all images in one directory
+		name = strings.ReplaceAll(x.opts.ImageName(sourceURL, x.images), "/", "-")
+	} else if name = epubMediaName(source); name == "" {
+		// Named explicitly, so images with the same file name from different
+		// places become map.png and map-2.png rather than go-epub's image0002.png
+		name = mediaName(imgPath)
+	}
+	internalPath, err := x.e.AddImage(source, name)
+	var used *epub.FilenameAlreadyUsedError
+	for i := 2; errors.As(err, &used); i++ {
+		ext := path.Ext(name)
+		internalPath, err = x.e.AddImage(source, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	if err != nil {
+		return "", err
+	}
+	x.embeddedFiles[string(sum[:])] = internalPath
+	x.result.addResource("image", internalPath, imgPath, int64(len(data)))
+	return internalPath, nil
+}
+
+// findElement returns the first element named tag in n's subtree, or nil.
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// isBlankText reports whether text shows nothing: it has only spaces and
+// invisible characters such as zero-width spaces, byte order marks and soft
+// hyphens, which source pages use as spacers.
+func isBlankText(text string) bool {
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case r == '\u200b', r == '\u200c', r == '\u200d', r == '\u2060', r == '\ufeff', r == '\u00ad':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isInside reports whether n is ancestor or one of its descendants.
+func isInside(n, ancestor *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n == ancestor {
+			return true
+		}
+	}
+	return false
+}
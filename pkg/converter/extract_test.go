@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlankParagraphsTrimmed(t *testing.T) {
+	const zwsp, bom, shy = "\u200b", "\ufeff", "\u00ad"
+	tests := []struct {
+		name string
+		page string
+		keep bool
+		want string
+	}{
+		{
+			name: "leading and trailing blanks dropped",
+			page: `<h3>One</h3><p>` + zwsp + `</p><p>` + bom + `</p><p>Text.</p><p>` + shy + `</p><p>` + zwsp + `</p>`,
+			want: `<p>One </p><p>Text. </p>`,
+		},
+		{
+			name: "blanks between text kept",
+			page: `<h3>One</h3><p>A.</p><p>` + zwsp + `</p><p>B.</p>`,
+			want: `<p>One </p><p>A. </p><p>` + zwsp + ` </p><p>B. </p>`,
+		},
+		{
+			name: "trailing run of several blanks dropped",
+			page: `<h3>One</h3><p>` + zwsp + `</p><p>Text.</p><p>` + zwsp + `</p><p>More.</p><p>` + zwsp + ` ` + zwsp + `</p>`,
+			want: `<p>One </p><p>Text. </p><p>` + zwsp + ` </p><p>More. </p>`,
+		},
+		{
+			name: "kept when asked",
+			page: `<h3>One</h3><p>` + zwsp + `</p><p>Text.</p><p>` + zwsp + `</p>`,
+			keep: true,
+			want: `<p>One </p><p>` + zwsp + ` </p><p>Text. </p><p>` + zwsp + ` </p>`,
+		},
+		{
+			name: "link target kept",
+			page: `<h3>One</h3><p>Text <a href="#t">see</a>.</p><p id="t">` + zwsp + `</p>`,
+			want: `<p>One </p><p>Text </p><p><a href="#t">see</a> </p><p>. </p><p id="t">` + zwsp + ` </p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := testSections(t, tt.page, Options{KeepEmptyBlocks: tt.keep})
+			if len(sections) != 1 {
+				t.Fatalf("got %d sections %v, want 1", len(sections), sectionOutline(sections))
+			}
+			if sections[0].Body != tt.want {
+				t.Errorf("body\ngot  %q\nwant %q", sections[0].Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlankOnlySectionDropped(t *testing.T) {
+	page := `<p>Intro.</p><h3>Empty</h3><p>` + "\u200b" + `</p><h3>Full</h3><p>Text.</p>`
+	got := sectionOutline(testSections(t, page, Options{}))
+	want := []string{"0:Page", "0:Empty", "0:Full"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("sections = %v, want %v", got, want)
+	}
+}
+
+func TestIsBlankText(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"\u200b", true},
+		{"\ufeff \u00ad", true},
+		{" \u2060", true},
+		{"a\u200b", false},
+		{"\u00b7", false},
+	}
+	for _, tt := range tests {
+		if got := isBlankText(tt.text); got != tt.want {
+			t.Errorf("isBlankText(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNestedWrappersCollapsed(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body><h3>One</h3>` +
+		`<div><div><div><p>Wrapped <em>deeply</em>.</p></div></div></div>` +
+		`<div><div><p>Twice.</p><p>Over.</p></div></div></body></html>`
+	result, files := testBuild(t, page, Options{})
+	body := sectionFile(t, result, files, "One")
+	if strings.Contains(body, "<div") {
+		t.Errorf("wrapper divs kept:\n%s", body)
+	}
+	for _, want := range []string{"<p>Wrapped </p>", "<p>deeply </p>", "<p>. </p>", "<p>Twice. </p>", "<p>Over. </p>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %s:\n%s", want, body)
+		}
+	}
+}
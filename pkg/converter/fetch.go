@@ -0,0 +1,327 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// fetchOrLoadHTML fetches the HTML content from a given URL through cache if the local file doesn't exist
+// or loads it from the local file. An empty filePath disables the local file. It returns the body content as bytes and the base URL,
+// which is always urlStr: a cached copy is still resolved against the page it was fetched from.
+func fetchOrLoadHTML(ctx context.Context, urlStr, filePath string, cache httpCache) ([]byte, *url.URL, error) {
+	baseURL, err := parseBaseURL(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if filePath == "" {
+		// No local file: always fetch
+		body, err := fetchPage(ctx, urlStr, cache)
+		if err != nil {
+			return nil, nil, err
+		}
+		return body, baseURL, nil
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err == nil && len(content) > 0 {
+		fetcherLog.Debug("Loaded page from local file", "url", urlStr, "file", filePath)
+		return toUTF8(content, ""), baseURL, nil // Older or hand-made caches may not be UTF-8 yet
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to read local HTML file '%s': %w", filePath, err)
+	}
+
+	// File doesn't exist or is empty, fetch from URL
+	body, err := fetchPage(ctx, urlStr, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Save the fetched content to the local file; it's already UTF-8, so
+	// later runs parse exactly what this one does. fetchPage only returns
+	// complete pages, but an empty one isn't worth keeping
+	if len(body) == 0 {
+		fetcherLog.Warn("Not caching empty page", "url", urlStr)
+	} else if err := writeFileAtomic(filePath, body); err != nil {
+		fetcherLog.Warn("Failed to save HTML", "file", filePath, "err", err)
+	}
+
+	return body, baseURL, nil
+}
+
+// parseBaseURL parses the URL a page was fetched from, which its relative
+// links and images are resolved against. It must be an absolute http(s)
+// URL; a file name would leave every relative reference unresolvable.
+func parseBaseURL(urlStr string) (*url.URL, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base URL '%s': %w", urlStr, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL '%s': want an absolute http or https URL", urlStr)
+	}
+	return u, nil
+}
+
+// documentBase returns the URL relative references in doc resolve against:
+// the href of its <base> element, if any, resolved against baseURL, the
+// URL the document came from.
+func documentBase(doc *html.Node, baseURL *url.URL) *url.URL {
+	b := findElement(doc, "base")
+	if b == nil {
+		return baseURL
+	}
+	href := strings.TrimSpace(getAttr(b, "href"))
+	if href == "" {
+		return baseURL
+	}
+	u, err := baseURL.Parse(href)
+	if err != nil {
+		parserLog.Warn("Could not parse <base href>, resolving against the page URL", "href", href, "err", err)
+		return baseURL
+	}
+	return u
+}
+
+// fetchHTML downloads the text resource at urlStr, such as a stylesheet,
+// through cache and returns its body converted to UTF-8. Pages go through
+// fetchPage.
+func fetchHTML(ctx context.Context, urlStr string, cache httpCache) ([]byte, error) {
+	body, contentType, err := cache.get(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return toUTF8(body, contentType), nil
+}
+
+// fetchBytes downloads urlStr and returns its body and content type.
+func fetchBytes(ctx context.Context, urlStr string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request for URL '%s': %w", urlStr, err)
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get URL '%s': %w", urlStr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("bad status for URL '%s': %s", urlStr, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response body from '%s': %w", urlStr, err)
+	}
+	if err := checkContentLength(resp, int64(len(body))); err != nil {
+		return nil, "", fmt.Errorf("failed to read response body from '%s': %w", urlStr, err)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// errIncompleteDownload means a response body was shorter than its
+// Content-Length, e.g. because the connection dropped.
+var errIncompleteDownload = errors.New("incomplete download")
+
+// checkContentLength returns errIncompleteDownload if n bytes fall short of
+// the Content-Length resp advertised.
+func checkContentLength(resp *http.Response, n int64) error {
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return fmt.Errorf("%w: got %d of %d bytes", errIncompleteDownload, n, resp.ContentLength)
+	}
+	return nil
+}
+
+// SaveHTML fetches the page at urlStr and writes it to filePath. The file is
+// only replaced once the whole page has been received and written.
+func SaveHTML(ctx context.Context, urlStr, filePath string) error {
+	body, err := fetchPage(ctx, urlStr, "")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filePath, body); err != nil {
+		return fmt.Errorf("failed to save HTML to '%s': %w", filePath, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to filePath and renames
+// it into place, so readers never see a partially written file.
+func writeFileAtomic(filePath string, data []byte) error {
+	return writeFileAtomicFrom(filePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomicFrom is writeFileAtomic for contents written by write, such
+// as a download being copied. If write fails, filePath is left untouched.
+func writeFileAtomicFrom(filePath string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// localImageFilename derives a safe local filename for the image at u.
+func localImageFilename(u *url.URL) string {
+	// Take the last segment before decoding, so an encoded slash stays in it
+	filename := path.Base(u.EscapedPath())
+	if decoded, err := url.PathUnescape(filename); err == nil {
+		filename = decoded
+	}
+	if filename == "." || filename == "/" { // Handle cases where path is minimal
+		filename = "image_" + strings.ReplaceAll(u.Host, ".", "_") + ".tmp" // Create a fallback name
+	}
+	// Ensure filename is safe (basic sanitization); spaces and percent signs
+	// would also need escaping wherever the embedded image is referenced
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '*' || r == '?' || r == '"' || r == '<' || r == '>' || r == '|' || r == '%' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, filename)
+}
+
+// fetchOrLoadImage downloads an image from a URL and saves it to a temporary directory if it doesn't exist locally.
+// It returns the path to the (newly downloaded or existing) image file, which is kept in a subdirectory named after
+// a hash of the URL so images with the same file name from different places don't collide.
+func fetchOrLoadImage(ctx context.Context, imgURL string, dir string) (string, error) {
+	parsedURL, err := url.Parse(imgURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image URL '%s': %w", imgURL, err)
+	}
+	filepath := path.Join(dir, shortHash([]byte(parsedURL.String())), localImageFilename(parsedURL))
+
+	// Check if the image already exists
+	if _, err := os.Stat(filepath); err == nil {
+		return filepath, nil // Image exists, return the path
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check if image exists at '%s': %w", filepath, err)
+	}
+
+	// Image doesn't exist, download it, from the URL with any spaces or other
+	// unescaped characters in the original properly encoded
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for image URL '%s': %w", imgURL, err)
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image URL '%s': %w", imgURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status for image '%s': %s", imgURL, resp.Status)
+	}
+	if err := checkImageType(resp.Header.Get("Content-Type")); err != nil {
+		return "", fmt.Errorf("bad image '%s': %w", imgURL, err)
+	}
+
+	// Create the directory if it doesn't exist
+	if err := os.MkdirAll(path.Dir(filepath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory '%s': %w", path.Dir(filepath), err)
+	}
+
+	// Write the body to file, making sure all of it arrived; a partial image
+	// is never left behind to be picked up by the next run
+	err = writeFileAtomicFrom(filepath, func(w io.Writer) error {
+		written, err := io.Copy(w, resp.Body)
+		if err != nil {
+			return err
+		}
+		return checkContentLength(resp, written)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save image to '%s': %w", filepath, err)
+	}
+
+	return filepath, nil
+}
+
+// dumpSection writes a section's generated XHTML body to dir, using the same
+// filename the section has inside the EPUB so the two are easy to match up.
+func dumpSection(dir, filename, body string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create debug directory '%s': %w", dir, err)
+	}
+	dumpPath := filepath.Join(dir, filename)
+	if err := os.WriteFile(dumpPath, []byte(body), 0644); err != nil {
+		return fmt.Errorf("failed to write section dump '%s': %w", dumpPath, err)
+	}
+	return nil
+}
+
+// getAttr returns the value of the named attribute, or "" if it is missing.
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// getText extracts and concatenates all text nodes within a given node.
+func getText(n *html.Node) string {
+	var b strings.Builder
+	var extract func(*html.Node)
+	extract = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			b.WriteString(strings.TrimSpace(node.Data))
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+	extract(n)
+	return b.String()
+}
+
+// getTextContent returns the text inside n with runs of whitespace, including
+// those between elements, collapsed to single spaces.
+func getTextContent(n *html.Node) string {
+	var b strings.Builder
+	var extract func(*html.Node)
+	extract = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			b.WriteString(node.Data)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+	extract(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
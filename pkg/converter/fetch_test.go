@@ -0,0 +1,301 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchOrLoadHTMLCachesOnlyPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantErr   bool
+		wantSaved bool
+	}{
+		{"page saved", 200, "<p>Page</p>", false, true},
+		{"empty page not saved", 200, "", false, false},
+		{"server error not saved", 500, "<p>Oops</p>", true, false},
+		{"not found not saved", 404, "<p>Missing</p>", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			file := filepath.Join(t.TempDir(), "page.html")
+			ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+
+			_, _, err := fetchOrLoadHTML(ctx, srv.URL+"/page", file, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchOrLoadHTML error = %v, want error %v", err, tt.wantErr)
+			}
+			_, statErr := os.Stat(file)
+			if saved := statErr == nil; saved != tt.wantSaved {
+				t.Errorf("page saved = %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestFetchOrLoadHTMLUsesSavedPage(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+	file := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(file, []byte("<p>Saved</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body, base, err := fetchOrLoadHTML(context.Background(), srv.URL+"/page", file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "<p>Saved</p>" || base.String() != srv.URL+"/page" || hits.Load() != 0 {
+		t.Errorf("got %q from %s after %d requests, want the saved page resolved against its URL", body, base, hits.Load())
+	}
+}
+
+func TestCachedPageImagesResolveAgainstURL(t *testing.T) {
+	pic := testPNG(t, 3, 3, color.Black)
+	var mu sync.Mutex
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path != "/book/img/a.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pic)
+	}))
+	defer srv.Close()
+	// The cache file's own directory has an img/a.png that mustn't be used
+	dir := t.TempDir()
+	file := filepath.Join(dir, "page.html")
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>Cached.</p><img src="img/a.png" alt="A"></body></html>`
+	if err := os.WriteFile(file, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img", "a.png"), testPNG(t, 5, 5, color.White), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, files := testBuild(t, "", Options{SourceURL: srv.URL + "/book/page.html", HTMLCache: file})
+	if body := sectionFile(t, result, files, "One"); !strings.Contains(body, "Cached.") || !strings.Contains(body, `src="../images/a.png"`) {
+		t.Errorf("section lacks the cached text or the image:\n%s", body)
+	}
+	if files["EPUB/images/a.png"] != string(pic) {
+		t.Error("image not fetched from the page's URL and embedded")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(requested, " ") != "/book/img/a.png" {
+		t.Errorf("requested %q, want only the image, resolved against the page URL", requested)
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	var (
+		hits, revalidated atomic.Int32
+		failing           atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch {
+		case failing.Load():
+			w.WriteHeader(http.StatusBadGateway)
+		case r.Header.Get("If-None-Match") == `"v1"`:
+			revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>Page</p>"))
+		}
+	}))
+	defer srv.Close()
+	cache := httpCache(t.TempDir())
+	ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+	get := func() string {
+		t.Helper()
+		body, _, err := cache.get(ctx, srv.URL+"/page")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if got := get(); got != "<p>Page</p>" {
+		t.Fatalf("first get = %q", got)
+	}
+	if got := get(); got != "<p>Page</p>" || revalidated.Load() != 1 {
+		t.Errorf("second get = %q after %d revalidations, want the cached copy revalidated", got, revalidated.Load())
+	}
+
+	// A failed fetch neither replaces nor loses the cached copy
+	failing.Store(true)
+	if got := get(); got != "<p>Page</p>" {
+		t.Errorf("get while failing = %q, want the cached copy", got)
+	}
+	failing.Store(false)
+	if got := get(); got != "<p>Page</p>" || revalidated.Load() != 2 {
+		t.Errorf("get after failing = %q after %d revalidations, want the cached copy revalidated", got, revalidated.Load())
+	}
+}
+
+func TestHTTPCacheDoesNotCacheFailures(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<p>Page</p>"))
+	}))
+	defer srv.Close()
+	cache := httpCache(t.TempDir())
+	ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+
+	if _, _, err := cache.get(ctx, srv.URL); err == nil {
+		t.Fatal("get of a failing page succeeded")
+	}
+	if entries, _ := os.ReadDir(string(cache)); len(entries) != 0 {
+		t.Errorf("cache holds %d files after a failed fetch", len(entries))
+	}
+	failing.Store(false)
+	body, _, err := cache.get(ctx, srv.URL)
+	if err != nil || string(body) != "<p>Page</p>" {
+		t.Errorf("get once the page is back = %q, %v", body, err)
+	}
+}
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"https://example.com/book.html", false},
+		{"http://example.com", false},
+		{"book.html", true},
+		{"ftp://example.com/book.html", true},
+		{"https:///book.html", true},
+	}
+	for _, tt := range tests {
+		_, err := parseBaseURL(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBaseURL(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEncodedImageURLs(t *testing.T) {
+	pic, other := testPNG(t, 2, 2, color.Black), testPNG(t, 3, 3, color.White)
+	srv := fileServer(t, map[string]servedFile{
+		"/img/my photo.png":    {"image/png", pic},
+		"/img/other photo.png": {"image/png", other},
+	})
+	page := `<html><body><h3>One</h3><p>Text.</p>` +
+		`<img src="` + srv.URL + `/img/my%20photo.png" alt="Encoded">` +
+		`<img src="` + srv.URL + `/img/other photo.png" alt="Spaced"></body></html>`
+	result, files := testBuild(t, page, Options{})
+	one := sectionFile(t, result, files, "One")
+	for name, data := range map[string][]byte{"my_photo.png": pic, "other_photo.png": other} {
+		if files["EPUB/images/"+name] != string(data) {
+			t.Errorf("EPUB lacks images/%s with the served image", name)
+		}
+		if !strings.Contains(one, `src="../images/`+name+`"`) {
+			t.Errorf("section doesn't point at images/%s:\n%s", name, one)
+		}
+	}
+}
+
+func TestLocalImageFilename(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://example.com/img/photo.png", "photo.png"},
+		{"https://example.com/img/my%20photo.png", "my_photo.png"},
+		{"https://example.com/img/caf%C3%A9.png", "café.png"},
+		{"https://example.com/img/a%2Fb.png", "a_b.png"},
+		{"https://example.com/img/100%25.png", "100_.png"},
+		{"https://example.com/", "image_example_com.tmp"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := localImageFilename(u); got != tt.want {
+			t.Errorf("localImageFilename(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncatedImageRejected(t *testing.T) {
+	pic := testPNG(t, 2, 2, color.Black)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(len(pic)+100))
+		w.Write(pic) // The connection drops before the rest
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if _, err := fetchOrLoadImage(context.Background(), srv.URL+"/photo.png", dir); err == nil {
+		t.Error("fetchOrLoadImage of a truncated image succeeded")
+	}
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("truncated download left %s behind", p)
+		}
+		return nil
+	})
+
+	page := `<html><body><h3>One</h3><p>Text.</p><img src="` + srv.URL + `/photo.png" alt="Photo"></body></html>`
+	result, files := testBuild(t, page, Options{})
+	for name := range files {
+		if strings.HasPrefix(name, "EPUB/images/") {
+			t.Errorf("truncated image embedded as %s", name)
+		}
+	}
+	if one := sectionFile(t, result, files, "One"); strings.Contains(one, "<img") {
+		t.Errorf("section still shows the truncated image:\n%s", one)
+	}
+}
+
+func TestCheckContentLength(t *testing.T) {
+	tests := []struct {
+		advertised, got int64
+		wantErr         bool
+	}{
+		{100, 100, false},
+		{100, 40, true},
+		{-1, 40, false}, // No Content-Length
+	}
+	for _, tt := range tests {
+		err := checkContentLength(&http.Response{ContentLength: tt.advertised}, tt.got)
+		if tt.wantErr != errors.Is(err, errIncompleteDownload) || (!tt.wantErr && err != nil) {
+			t.Errorf("checkContentLength(%d of %d) = %v, want error %v", tt.got, tt.advertised, err, tt.wantErr)
+		}
+	}
+}
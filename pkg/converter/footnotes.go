@@ -0,0 +1,309 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// FootnoteMode selects what happens to footnotes: the notes that links
+// marked as note references (see isNoteref) point at.
+type FootnoteMode string
+
+const (
+	FootnotesKeep     FootnoteMode = "keep"     // Left where they are, their references linking to them
+	FootnotesPopup    FootnoteMode = "popup"    // Moved to the end of the section referencing them, as popup notes
+	FootnotesEndnotes FootnoteMode = "endnotes" // Moved to a Notes section at the end, linking back to their references
+)
+
+// ParseFootnoteMode validates a -footnotes flag value.
+func ParseFootnoteMode(s string) (FootnoteMode, error) {
+	switch m := FootnoteMode(strings.ToLower(s)); m {
+	case FootnotesKeep, FootnotesPopup, FootnotesEndnotes:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid footnote mode '%s' (want keep, popup or endnotes)", s)
+}
+
+// footnoteMode returns the configured footnote mode, taking Endnotes into
+// account.
+func (o Options) footnoteMode() FootnoteMode {
+	switch {
+	case o.Endnotes:
+		return FootnotesEndnotes
+	case o.Footnotes == "":
+		return FootnotesKeep
+	}
+	return o.Footnotes
+}
+
+// endnotesFilename is the internal filename of the endnotes section, which
+// note references link to.
+const endnotesFilename = "endnotes.xhtml"
+
+// maxNoteLabel is the longest link text, in characters, that can mark a
+// footnote reference without semantic markup, as in "1", "*" or "[12]".
+const maxNoteLabel = 5
+
+// bracketedLabel matches a note reference label written in brackets rather
+// than superscript, as Project Gutenberg and Wikipedia do: "[1]", "[a]", "[*]".
+var bracketedLabel = regexp.MustCompile(`^\[(?:[0-9]{1,3}|[a-zA-Z]|[*†‡§])\]$`)
+
+// leadingNoteMarker matches what a footnote's text may start with to mark
+// it: its number as "2.", "2)", "2:", "[2]" or "(2)", or an arrow or caret
+// back to the reference, as Wikipedia's "^".
+var leadingNoteMarker = regexp.MustCompile(`^(?:(?:\[[0-9a-zA-Z*†‡§]{1,3}\]|\([0-9a-zA-Z*†‡§]{1,3}\)|[0-9]{1,3}[.):]|[*†‡§])\s*|[\^↑↩]+\s*)+`)
+
+// Elements a footnote definition can be, when a note reference points at
+// an anchor or label inside one.
+var footnoteBlocks = map[string]bool{"aside": true, "dd": true, "div": true, "li": true, "p": true, "section": true}
+
+// collectFootnotes returns the footnote definitions in doc by id: elements
+// that note references (see isNoteref) point at. A reference to an anchor
+// or label, as in Project Gutenberg's footnotes, takes the block around it.
+// Headings are never notes, so short links in a table of contents don't
+// turn chapters into notes, and links back to something earlier aren't
+// references, so a note's "[1]" label doesn't make its reference's
+// paragraph a note too. It also returns the ids of the references, so
+// links from the notes back to them can be told apart from their text.
+func collectFootnotes(doc *html.Node, baseURL *url.URL) (notes map[string]*html.Node, refIDs map[string]bool) {
+	ids := make(map[string]*html.Node)
+	refs := make(map[string]bool)
+	refIDs = make(map[string]bool)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := getAttr(n, "id"); id != "" && ids[id] == nil {
+				ids[id] = n
+			}
+			if isNoteref(n) {
+				id, ok := localFragment(strings.TrimSpace(getAttr(n, "href")), baseURL)
+				if ok && ids[id] != nil {
+					return // Notes follow their references; this links a note back to one
+				}
+				if ok {
+					refs[id] = true
+				}
+				if id := getAttr(n, "id"); id != "" {
+					refIDs[id] = true
+				}
+				if p := n.Parent; p != nil && p.Type == html.ElementNode && p.Data == "sup" && getAttr(p, "id") != "" {
+					refIDs[getAttr(p, "id")] = true // Wikipedia puts the id on the <sup>
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	notes = make(map[string]*html.Node)
+	uses := make(map[*html.Node]int)
+	for id := range refs {
+		n := ids[id]
+		if n != nil && !footnoteBlocks[n.Data] && utf8.RuneCountInString(getTextContent(n)) <= maxNoteLabel+2 {
+			n = footnoteBlock(n)
+		}
+		if n != nil && !isHeading(n) && findHeading(n) == nil {
+			notes[id] = n
+			uses[n]++
+		}
+	}
+	for id, n := range notes {
+		if uses[n] > 1 {
+			delete(notes, id) // A block holding several notes stays where it is
+		}
+	}
+	return notes, refIDs
+}
+
+// footnoteBlock returns the nearest block around n a footnote can be, or
+// nil if there isn't one.
+func footnoteBlock(n *html.Node) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && footnoteBlocks[p.Data] {
+			return p
+		}
+	}
+	return nil
+}
+
+// isNoteref reports whether n is a link that looks like a footnote
+// reference: marked up as one, or a short label in superscript or brackets.
+func isNoteref(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Data != "a" || getAttr(n, "href") == "" {
+		return false
+	}
+	if hasToken(getAttr(n, "epub:type"), "noteref") || hasToken(getAttr(n, "role"), "doc-noteref") ||
+		hasToken(getAttr(n, "class"), "footnote-ref") || hasToken(getAttr(n, "class"), "noteref") ||
+		hasToken(getAttr(n, "class"), "fnanchor") || hasToken(getAttr(n, "class"), "footnote-reference") {
+		return true
+	}
+	label := getTextContent(n)
+	if label == "" || utf8.RuneCountInString(label) > maxNoteLabel {
+		return false
+	}
+	inSup := n.Parent != nil && n.Parent.Type == html.ElementNode && n.Parent.Data == "sup"
+	return inSup || findElement(n, "sup") != nil || bracketedLabel.MatchString(label)
+}
+
+// isHeading reports whether n is an h1 to h6 element.
+func isHeading(n *html.Node) bool {
+	return n.Type == html.ElementNode && isHeadingTag(n.Data)
+}
+
+// isHeadingTag reports whether tag is h1 to h6.
+func isHeadingTag(tag string) bool {
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6'
+}
+
+// findHeading returns the first heading inside n, or nil.
+func findHeading(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isHeading(c) {
+			return c
+		}
+		if h := findHeading(c); h != nil {
+			return h
+		}
+	}
+	return nil
+}
+
+// isFootnote reports whether n is a footnote definition of the current
+// source, which is moved to the section's popup notes or the endnotes
+// instead of being extracted in place.
+func (x *extractor) isFootnote(n *html.Node) bool {
+	if len(x.footnotes) == 0 || n.Type != html.ElementNode {
+		return false
+	}
+	for _, note := range x.footnotes {
+		if note == n {
+			return true
+		}
+	}
+	return false
+}
+
+// addNoteref appends a reference to the footnote a link points at, moving
+// the note to the current section's popup notes, or to the endnotes, the
+// first time it's referenced there. It reports whether n was a reference to
+// a known footnote.
+func (x *extractor) addNoteref(n *html.Node) bool {
+	if len(x.footnotes) == 0 || !isNoteref(n) {
+		return false
+	}
+	id, ok := localFragment(strings.TrimSpace(getAttr(n, "href")), x.src.baseURL)
+	if !ok || x.footnotes[id] == nil {
+		return false
+	}
+	label := strings.Trim(getTextContent(n), "[]() ")
+
+	if x.opts.footnoteMode() == FootnotesPopup {
+		// Popup notes must be in the same file as their references
+		noteID, ok := x.sectionNotes[id]
+		if !ok {
+			x.notes++
+			noteID = x.uniqueID(fmt.Sprintf("note%d", x.notes))
+			x.sectionNotes[id] = noteID
+			x.popupNotes.WriteString(fmt.Sprintf(`<aside epub:type="footnote" id="%s"><p>%s</p></aside>`,
+				noteID, html.EscapeString(x.noteText(x.footnotes[id], label))))
+		}
+		if label == "" {
+			label = fmt.Sprint(x.notes)
+		}
+		x.appendInline(n, fmt.Sprintf(`<a epub:type="noteref" href="#%s">%s</a>`, noteID, html.EscapeString(label)))
+		return true
+	}
+
+	key := x.src.name + "#" + id
+	noteID, ok := x.noteIDs[key]
+	refID := ""
+	if !ok {
+		x.notes++
+		noteID = fmt.Sprintf("note%d", x.notes)
+		if x.opts.SingleFile {
+			noteID = x.uniqueID(noteID) // The notes end up in the same file as the text
+		}
+		x.noteIDs[key] = noteID
+
+		// The note links back to its first reference, wherever that ends up
+		refID = x.uniqueID(fmt.Sprintf("noteref%d", x.notes))
+		x.anchors[x.src.name+"#"+refID] = refID
+		x.links = append(x.links, internalLink{Source: x.src.name, Fragment: refID})
+		x.endnotes.WriteString(fmt.Sprintf(`<aside epub:type="footnote" id="%s"><p>%d. %s <a href="epub-link:%d" role="doc-backlink">↩</a></p></aside>`,
+			noteID, x.notes, html.EscapeString(x.noteText(x.footnotes[id], label)), len(x.links)-1))
+	}
+	if label == "" {
+		label = fmt.Sprint(x.notes)
+	}
+	if refID != "" {
+		x.appendInline(n, fmt.Sprintf(`<a epub:type="noteref" id="%s" href="%s#%s">%s</a>`, refID, endnotesFilename, noteID, html.EscapeString(label)))
+	} else {
+		x.appendInline(n, fmt.Sprintf(`<a epub:type="noteref" href="%s#%s">%s</a>`, endnotesFilename, noteID, html.EscapeString(label)))
+	}
+	return true
+}
+
+// noteText returns the text of a footnote definition, without the links
+// back to its references that footnotes usually end with, or the number or
+// arrow it starts with, since the note is numbered afresh. label is the
+// text of the reference, which a note may also start with bare.
+func (x *extractor) noteText(n *html.Node, label string) string {
+	var b strings.Builder
+	var extract func(*html.Node)
+	extract = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			b.WriteString(node.Data)
+		}
+		if node.Type == html.ElementNode && node.Data == "a" && (isBacklink(node) || x.isRefLink(node)) {
+			return
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+	extract(n)
+	text := strings.Join(strings.Fields(b.String()), " ")
+	text = leadingNoteMarker.ReplaceAllString(text, "")
+	if label != "" {
+		if rest, ok := strings.CutPrefix(text, label+" "); ok {
+			text = rest
+		}
+	}
+	return text
+}
+
+// isRefLink reports whether n, a link in a footnote, points back at one of
+// the current source's note references, as a Project Gutenberg note's
+// "[1]" label does.
+func (x *extractor) isRefLink(n *html.Node) bool {
+	id, ok := localFragment(strings.TrimSpace(getAttr(n, "href")), x.src.baseURL)
+	return ok && x.noterefIDs[id]
+}
+
+// isBacklink reports whether n is a link from a footnote back to its
+// reference, such as "↩".
+func isBacklink(n *html.Node) bool {
+	if hasToken(getAttr(n, "class"), "footnote-backref") || hasToken(getAttr(n, "role"), "doc-backlink") {
+		return true
+	}
+	return !strings.ContainsFunc(getTextContent(n), func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	})
+}
+
+// endnotesSection returns the section holding the moved footnotes, if any.
+func (x *extractor) endnotesSection() (Section, bool) {
+	if x.endnotes.Len() == 0 {
+		return Section{}, false
+	}
+	body := `<section epub:type="endnotes"><h2>Notes</h2>` + x.endnotes.String() + `</section>`
+	return Section{Title: "Notes", Body: body, filename: endnotesFilename}, true
+}
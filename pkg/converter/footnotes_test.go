@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFootnoteMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FootnoteMode
+		wantErr bool
+	}{
+		{"", "", true},
+		{"keep", FootnotesKeep, false},
+		{"popup", FootnotesPopup, false},
+		{"ENDNOTES", FootnotesEndnotes, false},
+		{"inline", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFootnoteMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFootnoteMode(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFootnoteOptionsMode(t *testing.T) {
+	if got := (Options{Endnotes: true}).footnoteMode(); got != FootnotesEndnotes {
+		t.Errorf("deprecated Endnotes gives %q, want endnotes", got)
+	}
+	if got := (Options{}).footnoteMode(); got != FootnotesKeep {
+		t.Errorf("zero Options give %q, want keep", got)
+	}
+}
+
+func TestNoteMarkersStripped(t *testing.T) {
+	tests := []struct {
+		name string
+		note string
+		want string
+	}{
+		{"number and dot", `<p id="fn1">1. Note text.</p>`, `<p>1. Note text. <a`},
+		{"number and paren", `<p id="fn1">1) Note text.</p>`, `<p>1. Note text. <a`},
+		{"bracketed number", `<p id="fn1">[1] Note text.</p>`, `<p>1. Note text. <a`},
+		{"bare label", `<p id="fn1">1 Note text.</p>`, `<p>1. Note text. <a`},
+		{"caret", `<p id="fn1">^ Note text.</p>`, `<p>1. Note text. <a`},
+		{"back arrow", `<p id="fn1">↩ 1. Note text.</p>`, `<p>1. Note text. <a`},
+		{"backlink", `<p id="fn1"><a href="#ref1">↑</a> Note text.</p>`, `<p>1. Note text. <a`},
+		{"year is kept", `<p id="fn1">1984 was the year.</p>`, `<p>1. 1984 was the year. <a`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := `<h3>One</h3><p id="ref1">Text<sup><a href="#fn1">1</a></sup>.</p>` + tt.note
+			sections := testSections(t, page, Options{Footnotes: FootnotesEndnotes})
+			notes := sections[len(sections)-1]
+			if notes.Title != "Notes" {
+				t.Fatalf("last section is %q, want the notes", notes.Title)
+			}
+			if !strings.Contains(notes.Body, tt.want) {
+				t.Errorf("notes = %s, want them to contain %s", notes.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestFootnoteModes(t *testing.T) {
+	page := `<h3>One</h3><p>Text<sup><a href="#fn1">1</a></sup> and more<sup><a href="#fn2">2</a></sup>.</p>` +
+		`<ol><li id="fn1">First note.</li><li id="fn2">2. Second note.</li></ol>`
+	tests := []struct {
+		mode        FootnoteMode
+		wantTitles  []string
+		wantInBody  []string
+		notInBodies []string
+	}{
+		{
+			mode:       FootnotesKeep,
+			wantTitles: []string{"One"},
+			wantInBody: []string{"First note.", "2. Second note."},
+		},
+		{
+			mode:        FootnotesPopup,
+			wantTitles:  []string{"One"},
+			wantInBody:  []string{`<a epub:type="noteref" href="#note1">1</a>`, `<aside epub:type="footnote" id="note2"><p>Second note.</p></aside>`},
+			notInBodies: []string{"2. Second"},
+		},
+		{
+			mode:        FootnotesEndnotes,
+			wantTitles:  []string{"One", "Notes"},
+			wantInBody:  []string{`href="` + endnotesFilename + `#note1"`, `<p>2. Second note. <a`},
+			notInBodies: []string{"2. 2."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			sections := testSections(t, page, Options{Footnotes: tt.mode})
+			var titles []string
+			var all strings.Builder
+			for _, s := range sections {
+				titles = append(titles, s.Title)
+				all.WriteString(s.Body)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.wantTitles, "|") {
+				t.Errorf("sections = %v, want %v", titles, tt.wantTitles)
+			}
+			for _, want := range tt.wantInBody {
+				if !strings.Contains(all.String(), want) {
+					t.Errorf("bodies don't contain %s:\n%s", want, all.String())
+				}
+			}
+			for _, unwanted := range tt.notInBodies {
+				if strings.Contains(all.String(), unwanted) {
+					t.Errorf("bodies contain %s:\n%s", unwanted, all.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNoterefOpeningParagraph(t *testing.T) {
+	page := `<h3>CHAPTER II</h3><p><sup><a href="#fn1">1</a></sup> Text.</p><ol><li id="fn1">The note.</li></ol>`
+	tests := []struct {
+		mode FootnoteMode
+		want string
+	}{
+		{FootnotesKeep, `<p>CHAPTER II </p><p><a href="#fn1">1</a> </p><p>Text. </p>`},
+		{FootnotesPopup, `<p>CHAPTER II </p><p><a epub:type="noteref" href="#note1">1</a> </p><p>Text. </p>`},
+		{FootnotesEndnotes, `<p>CHAPTER II </p><p><a epub:type="noteref" id="noteref1" href="` + endnotesFilename + `#note1">1</a> </p><p>Text. </p>`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			sections := testSections(t, page, Options{Footnotes: tt.mode})
+			if len(sections) == 0 {
+				t.Fatal("no sections")
+			}
+			if got := sections[0].Body; !strings.HasPrefix(got, tt.want) {
+				t.Errorf("body = %s\nwant it to start %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+// animatedGIF returns an 8x8 GIF of two frames, one black and one white.
+func animatedGIF(t *testing.T) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := range 2 {
+		frame := image.NewPaletted(image.Rect(0, 0, 8, 8), palette)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(i)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 50)
+	}
+	var b bytes.Buffer
+	if err := gif.EncodeAll(&b, anim); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestIsAnimatedGIF(t *testing.T) {
+	var still bytes.Buffer
+	if err := gif.Encode(&still, image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"two frames", animatedGIF(t), true},
+		{"one frame", still.Bytes(), false},
+		{"not a GIF", testPNG(t, 2, 2, color.White), false},
+		{"truncated", animatedGIF(t)[:20], false},
+	}
+	for _, tt := range tests {
+		if got := isAnimatedGIF(tt.data); got != tt.want {
+			t.Errorf("%s: isAnimatedGIF = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnimatedGIFKeepsFrames(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/anim.gif": {"image/gif", animatedGIF(t)},
+	})
+	page := `<html><body><h3>One</h3><p>Text.</p><img src="` + srv.URL + `/anim.gif" alt="Animation"></body></html>`
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "embedded"},
+		{name: "downscaling", opts: Options{MaxImageSize: image.Pt(4, 4)}},
+		{name: "converting", opts: Options{ConvertImages: ImageFormatJPEG, ImageQuality: 50}},
+		{name: "deduplicating", opts: Options{DedupImages: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, files := testBuild(t, page, tt.opts)
+			var found bool
+			for name, data := range files {
+				if !strings.HasPrefix(name, "EPUB/images/") {
+					continue
+				}
+				found = true
+				g, err := gif.DecodeAll(strings.NewReader(data))
+				if err != nil {
+					t.Fatalf("%s isn't a GIF any more: %v", name, err)
+				}
+				if len(g.Image) != 2 {
+					t.Errorf("%s has %d frames, want 2", name, len(g.Image))
+				}
+				if b := g.Image[0].Bounds(); b.Dx() != 8 || b.Dy() != 8 {
+					t.Errorf("%s is %dx%d, want 8x8", name, b.Dx(), b.Dy())
+				}
+			}
+			if !found {
+				t.Fatal("no image embedded")
+			}
+		})
+	}
+}
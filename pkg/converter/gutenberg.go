@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// gutenbergMarker matches the lines Project Gutenberg puts between its
+	// header, the book and its license, e.g. "*** START OF THE PROJECT
+	// GUTENBERG EBOOK THE COUNT OF MONTE CRISTO ***".
+	gutenbergMarker = regexp.MustCompile(`(?i)\*{3}\s*(START|END) OF (THE|THIS) PROJECT GUTENBERG E-?BOOK`)
+
+	// gutenbergField matches a "Title: ..." or "Author: ..." line of the header.
+	gutenbergField = regexp.MustCompile(`(?im)^\s*(title|author)\s*:\s*(.+?)\s*$`)
+
+	// gutenbergAnchor matches the ids Gutenberg gives the anchors its
+	// contents links point at, e.g. link2HCH0001 or chap01.
+	gutenbergAnchor = regexp.MustCompile(`(?i)^(link\d*h|chap)`)
+
+	whitespaceRun = regexp.MustCompile(`\s+`)
+)
+
+// stripGutenberg removes the Project Gutenberg header before the START
+// marker of src and the license after its END marker, keeping the title
+// and author the header names as src's metadata, and finds the heading
+// element its chapters start with.
+func stripGutenberg(src *source) {
+	body := findElement(src.doc, "body")
+	if body == nil {
+		return
+	}
+	start, end := gutenbergMarkers(body)
+	if start == nil && end == nil {
+		parserLog.Warn("No Project Gutenberg markers; converting the page whole", "source", src.name)
+	}
+	if start != nil {
+		var header strings.Builder
+		for _, n := range removeSiblings(start, body, false) {
+			writeLines(&header, n)
+		}
+		for _, m := range gutenbergField.FindAllStringSubmatch(header.String(), -1) {
+			switch strings.ToLower(m[1]) {
+			case "title":
+				if src.meta.Title == "" {
+					src.meta.Title = m[2]
+				}
+			case "author":
+				if src.meta.Author == "" {
+					src.meta.Author = m[2]
+				}
+			}
+		}
+		start.Parent.RemoveChild(start)
+	}
+	if end != nil {
+		removeSiblings(end, body, true)
+		end.Parent.RemoveChild(end)
+	}
+	src.chapterHeading = gutenbergChapterHeading(body)
+}
+
+// gutenbergMarkers returns the outermost elements in body holding nothing
+// but the START and the END marker, or nil for those it lacks.
+func gutenbergMarkers(body *html.Node) (start, end *html.Node) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			if m := gutenbergMarker.FindStringSubmatch(n.Data); m != nil {
+				block := n
+				text := getTextContent(n)
+				for block.Parent != body && getTextContent(block.Parent) == text {
+					block = block.Parent
+				}
+				if strings.EqualFold(m[1], "start") && start == nil {
+					start = block
+				} else if strings.EqualFold(m[1], "end") && end == nil {
+					end = block
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+	return start, end
+}
+
+// removeSiblings removes everything inside body before n, or after it if
+// after is set, other than n's ancestors, and returns what it removed in
+// document order.
+func removeSiblings(n, body *html.Node, after bool) []*html.Node {
+	var removed []*html.Node
+	for cur := n; cur != body && cur.Parent != nil; cur = cur.Parent {
+		var nodes []*html.Node
+		if after {
+			for s := cur.NextSibling; s != nil; s = s.NextSibling {
+				nodes = append(nodes, s)
+			}
+		} else {
+			for s := cur.PrevSibling; s != nil; s = s.PrevSibling {
+				nodes = append([]*html.Node{s}, nodes...)
+			}
+		}
+		for _, s := range nodes {
+			cur.Parent.RemoveChild(s)
+		}
+		if after {
+			removed = append(removed, nodes...)
+		} else {
+			removed = append(nodes, removed...)
+		}
+	}
+	return removed
+}
+
+// writeLines writes the text of n to b, starting a new line at each block
+// element and <br>, so "Title: ..." fields end up on lines of their own.
+// Preformatted text keeps its line breaks.
+func writeLines(b *strings.Builder, n *html.Node) {
+	switch {
+	case n.Type == html.TextNode && n.Parent != nil && n.Parent.Data == "pre":
+		b.WriteString(n.Data)
+		return
+	case n.Type == html.TextNode:
+		b.WriteString(whitespaceRun.ReplaceAllString(n.Data, " "))
+		return
+	case n.Type == html.ElementNode && (n.Data == "br" || blockElements[n.Data]):
+		b.WriteString("\n")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeLines(b, c)
+	}
+	if n.Type == html.ElementNode && blockElements[n.Data] {
+		b.WriteString("\n")
+	}
+}
+
+// gutenbergChapterHeading returns the heading element that most often
+// follows, contains or opens Gutenberg's chapter anchors and
+// <div class="chapter"> wrappers in body, or "" if there are none.
+func gutenbergChapterHeading(body *html.Node) string {
+	counts := make(map[string]int)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		id := getAttr(n, "id")
+		if id == "" {
+			id = getAttr(n, "name")
+		}
+		if gutenbergAnchor.MatchString(id) || (n.Data == "div" && hasToken(getAttr(n, "class"), "chapter")) {
+			if h := anchorHeading(n); h != nil {
+				counts[h.Data]++
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+
+	tag := ""
+	for _, h := range []string{"h1", "h2", "h3", "h4", "h5", "h6"} {
+		if counts[h] > counts[tag] {
+			tag = h
+		}
+	}
+	return tag
+}
+
+// anchorHeading returns the heading a chapter anchor marks: the anchor
+// itself, the heading it is in, the one it opens with, or the one right
+// after it; or nil.
+func anchorHeading(n *html.Node) *html.Node {
+	for p := n; p != nil; p = p.Parent {
+		if isHeading(p) {
+			return p
+		}
+	}
+	if h := leadingHeading(n); h != nil {
+		return h
+	}
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.TextNode && strings.TrimSpace(s.Data) == "" {
+			continue
+		}
+		if isHeading(s) {
+			return s
+		}
+		if s.Type == html.ElementNode {
+			return leadingHeading(s)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGutenberg(t *testing.T) {
+	page := `<html><head><title>The Project Gutenberg eBook of Moby Dick</title></head><body>
+<div><p>The Project Gutenberg eBook of Moby Dick. This eBook is for the use of anyone anywhere.</p>
+<p>Title: Moby Dick<br>Author: Herman Melville</p><p>Release date: June 1, 2001</p></div>
+<div>*** START OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***</div>
+<h1>MOBY-DICK; or, THE WHALE.</h1>
+<p><a href="#link2HCH0001">CHAPTER 1. Loomings.</a><br><a href="#link2HCH0002">CHAPTER 2. The Carpet-Bag.</a></p>
+<div class="chapter"><h2><a id="link2HCH0001"></a>CHAPTER 1. Loomings.</h2><p>Call me Ishmael.</p></div>
+<div class="chapter"><h2><a id="link2HCH0002"></a>CHAPTER 2. The Carpet-Bag.</h2><p>I stuffed a shirt or two.</p></div>
+<div>*** END OF THE PROJECT GUTENBERG EBOOK MOBY DICK ***</div>
+<p>Updated editions will replace the previous one. The Full Project Gutenberg License follows.</p>
+</body></html>`
+
+	tests := []struct {
+		name      string
+		gutenberg bool
+		want      []string
+	}{
+		{name: "off", want: []string{"The Project Gutenberg eBook of Moby Dick"}},
+		{name: "on", gutenberg: true, want: []string{"Moby Dick", "CHAPTER 1. Loomings.", "CHAPTER 2. The Carpet-Bag."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{Gutenberg: tt.gutenberg})
+			var titles []string
+			var all strings.Builder
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+				all.WriteString(files["EPUB/xhtml/"+s.Filename])
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			if !tt.gutenberg {
+				return
+			}
+			for _, boilerplate := range []string{"Project Gutenberg", "Release date", "License"} {
+				if strings.Contains(all.String(), boilerplate) {
+					t.Errorf("sections keep %q:\n%s", boilerplate, all.String())
+				}
+			}
+			if body := sectionFile(t, result, files, "CHAPTER 1. Loomings."); !strings.Contains(body, "Call me Ishmael.") {
+				t.Errorf("first chapter lacks its text:\n%s", body)
+			}
+			opf := files[packageDocumentPath]
+			if !strings.Contains(opf, "<dc:title>Moby Dick</dc:title>") || !strings.Contains(opf, ">Herman Melville</dc:creator>") {
+				t.Errorf("package document lacks the title and author from the header:\n%s", opf)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sectionHeading is the heading element that starts a new section unless
+// SectionHeadings names others.
+const sectionHeading = "h3"
+
+// normalizeHeadings renames the headings in doc so the distinct levels it
+// uses become consecutive from h1, closing gaps that would break nesting:
+// a page using h1, h3 and h5 gets h1, h2 and h3. It returns the new name of
+// each old one.
+func normalizeHeadings(doc *html.Node) map[string]string {
+	var headings []*html.Node
+	used := make(map[string]bool)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if isHeading(n) {
+			headings = append(headings, n)
+			used[n.Data] = true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	renamed := make(map[string]string)
+	for level := 1; level <= 6; level++ {
+		if tag := fmt.Sprintf("h%d", level); used[tag] {
+			renamed[tag] = fmt.Sprintf("h%d", len(renamed)+1)
+		}
+	}
+	for _, h := range headings {
+		h.Data = renamed[h.Data]
+		h.DataAtom = atom.Lookup([]byte(h.Data))
+	}
+	return renamed
+}
+
+// ParseSectionHeadings parses a -headings value: a comma-separated list of
+// the elements that start a section, outermost first, each as tag, .class or
+// tag.class, e.g. "h2,h3" or "h1,div.chapter".
+func ParseSectionHeadings(s string) ([]SectionMarker, error) {
+	var headings []SectionMarker
+	for _, part := range strings.Split(s, ",") {
+		m, err := ParseSectionMarker(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid section headings '%s' (want a comma-separated list of tag, .class or tag.class)", s)
+		}
+		headings = append(headings, m)
+	}
+	return headings, nil
+}
+
+// sectionHeadings returns the elements that start a section, outermost
+// first: SectionHeadings if set, otherwise just sectionHeading.
+func (opts Options) sectionHeadings() []SectionMarker {
+	if len(opts.SectionHeadings) > 0 {
+		return opts.SectionHeadings
+	}
+	return []SectionMarker{{Tag: sectionHeading}}
+}
+
+// sourceHeadings returns the elements that start a section in src:
+// SectionHeadings if set, otherwise the heading its chapters were found to
+// start with, otherwise just sectionHeading.
+func (opts Options) sourceHeadings(src *source) []SectionMarker {
+	if len(opts.SectionHeadings) == 0 && src.chapterHeading != "" {
+		return []SectionMarker{{Tag: src.chapterHeading}}
+	}
+	return opts.sectionHeadings()
+}
+
+// headingLevel returns how deep in the table of contents the section that n
+// starts goes when headings start sections, 0 being a top-level chapter, or
+// -1 if n doesn't start one.
+func headingLevel(n *html.Node, headings []SectionMarker) int {
+	for level, m := range headings {
+		if m.matches(n) {
+			return level
+		}
+	}
+	return -1
+}
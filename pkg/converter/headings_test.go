@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHeadings(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantRenamed map[string]string
+		wantBody    string
+	}{
+		{
+			name:        "gaps close",
+			body:        `<h1>A</h1><h3>B</h3><h5>C</h5>`,
+			wantRenamed: map[string]string{"h1": "h1", "h3": "h2", "h5": "h3"},
+			wantBody:    `<h1>A</h1><h2>B</h2><h3>C</h3>`,
+		},
+		{
+			name:        "starts at h1",
+			body:        `<h2>A</h2><h4>B</h4><h2>C</h2>`,
+			wantRenamed: map[string]string{"h2": "h1", "h4": "h2"},
+			wantBody:    `<h1>A</h1><h2>B</h2><h1>C</h1>`,
+		},
+		{
+			name:        "consecutive levels stay",
+			body:        `<h1>A</h1><h2>B</h2>`,
+			wantRenamed: map[string]string{"h1": "h1", "h2": "h2"},
+			wantBody:    `<h1>A</h1><h2>B</h2>`,
+		},
+		{
+			name:        "no headings",
+			body:        `<p>A</p>`,
+			wantRenamed: map[string]string{},
+			wantBody:    `<p>A</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseHTML(t, "<body>"+tt.body+"</body>")
+			renamed := normalizeHeadings(doc)
+			if !reflect.DeepEqual(renamed, tt.wantRenamed) {
+				t.Errorf("renamed = %v, want %v", renamed, tt.wantRenamed)
+			}
+			if got := bodyHTML(t, doc); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestParseSectionHeadings(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SectionMarker
+		wantErr bool
+	}{
+		{"h2,h3", []SectionMarker{{Tag: "h2"}, {Tag: "h3"}}, false},
+		{"h1,div.chapter", []SectionMarker{{Tag: "h1"}, {Tag: "div", Class: "chapter"}}, false},
+		{".part", []SectionMarker{{Class: "part"}}, false},
+		{"h2,", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSectionHeadings(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSectionHeadings(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSectionHeadings(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSectionSplitting(t *testing.T) {
+	page := `<h1>Part</h1><p>a</p><h3>Chapter</h3><p>b</p><h5>Scene</h5><p>c</p>`
+	headings := []SectionMarker{{Tag: "h1"}, {Tag: "h2"}, {Tag: "h3"}}
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "default splits at h3",
+			opts: Options{},
+			want: []string{"0:Page", "0:Chapter"},
+		},
+		{
+			name: "h1,h2,h3 without normalizing misses h5",
+			opts: Options{SectionHeadings: headings},
+			want: []string{"0:Part", "2:Chapter"},
+		},
+		{
+			name: "h1,h2,h3 with normalizing nests every level",
+			opts: Options{SectionHeadings: headings, NormalizeHeadings: true},
+			want: []string{"0:Part", "1:Chapter", "2:Scene"},
+		},
+		{
+			name: "default h3 names the renumbered level",
+			opts: Options{NormalizeHeadings: true},
+			want: []string{"0:Page", "0:Scene"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sectionOutline(testSections(t, page, tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitles(t *testing.T) {
+	page := `<h1>The Voyage</h1><!-- subtitle --> <h2>A Sea Story</h2><p>Text.</p><h1>Return</h1><p>More.</p>`
+	h1 := []SectionMarker{{Tag: "h1"}}
+	tests := []struct {
+		name     string
+		opts     Options
+		want     []string
+		wantBody string // In the first section
+	}{
+		{
+			name:     "none",
+			opts:     Options{SectionHeadings: h1},
+			want:     []string{"The Voyage", "Return"},
+			wantBody: "<p>A Sea Story </p>",
+		},
+		{
+			name:     "label",
+			opts:     Options{SectionHeadings: h1, Subtitles: SubtitleLabel},
+			want:     []string{"The Voyage: A Sea Story", "Return"},
+			wantBody: "<p>A Sea Story </p>",
+		},
+		{
+			name:     "styled",
+			opts:     Options{SectionHeadings: h1, Subtitles: SubtitleStyled},
+			want:     []string{"The Voyage", "Return"},
+			wantBody: `<p class="subtitle">A Sea Story</p>`,
+		},
+		{
+			name:     "section heading",
+			opts:     Options{SectionHeadings: []SectionMarker{{Tag: "h1"}, {Tag: "h2"}}, Subtitles: SubtitleLabel},
+			want:     []string{"The Voyage", "A Sea Story", "Return"},
+			wantBody: "<p>The Voyage </p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := testSections(t, page, tt.opts)
+			var titles []string
+			for _, s := range sections {
+				titles = append(titles, s.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			if !strings.Contains(sections[0].Body, tt.wantBody) {
+				t.Errorf("first section lacks %s:\n%s", tt.wantBody, sections[0].Body)
+			}
+		})
+	}
+}
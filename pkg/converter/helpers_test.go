@@ -0,0 +1,184 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// parseHTML parses doc, failing the test if it can't.
+func parseHTML(t *testing.T, doc string) *html.Node {
+	t.Helper()
+	n, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parsing HTML: %v", err)
+	}
+	return n
+}
+
+// testSource returns a source of the page body, as loadPage would.
+func testSource(t *testing.T, body string) *source {
+	t.Helper()
+	base, _ := url.Parse("https://example.com/book/page.html")
+	return &source{doc: parseHTML(t, "<html><head><title>Page</title></head><body>"+body+"</body></html>"), baseURL: base, name: base.String()}
+}
+
+// bodyHTML renders the children of doc's <body>.
+func bodyHTML(t *testing.T, doc *html.Node) string {
+	t.Helper()
+	body := findElement(doc, "body")
+	if body == nil {
+		t.Fatal("no <body>")
+	}
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String()
+}
+
+// testSections extracts the sections of the page body with opts, as
+// Sections does, failing the test on an error.
+func testSections(t *testing.T, body string, opts Options) []Section {
+	t.Helper()
+	opts.SourceURL = "https://example.com/book/page.html"
+	opts.SourceHTML = []byte("<html><head><title>Page</title></head><body>" + body + "</body></html>")
+	opts.ImageDir = t.TempDir()
+	var sections []Section
+	for s, err := range Sections(context.Background(), opts) {
+		if err != nil {
+			t.Fatalf("Sections: %v", err)
+		}
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+// sectionOutline returns the title and level of each section.
+func sectionOutline(sections []Section) []string {
+	var outline []string
+	for _, s := range sections {
+		outline = append(outline, fmt.Sprintf("%d:%s", s.Level, s.Title))
+	}
+	return outline
+}
+
+// testBuild builds an EPUB of page, a whole HTML document, with opts into a
+// temporary directory, and returns the build's result and the EPUB's files
+// by name, failing the test on an error. The page is taken as
+// https://example.com/book/page.html unless opts names another input.
+func testBuild(t *testing.T, page string, opts Options) (*Result, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	if opts.SourceURL == "" {
+		opts.SourceURL = "https://example.com/book/page.html"
+	}
+	if page != "" {
+		opts.SourceHTML = []byte(page)
+	}
+	if opts.ImageDir == "" {
+		opts.ImageDir = filepath.Join(dir, "images")
+	}
+	if opts.OutputPath == "" {
+		opts.OutputPath = filepath.Join(dir, "book.epub")
+	}
+	result, err := build(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	return result, epubFiles(t, opts.OutputPath)
+}
+
+// epubFiles returns the files in the EPUB at path by name.
+func epubFiles(t *testing.T, path string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("opening EPUB: %v", err)
+	}
+	defer r.Close()
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+// sectionFile returns the content of the EPUB section file titled title,
+// failing the test if there is none.
+func sectionFile(t *testing.T, result *Result, files map[string]string, title string) string {
+	t.Helper()
+	for _, s := range result.Summary().Sections {
+		if s.Title == title {
+			if body, ok := files["EPUB/xhtml/"+s.Filename]; ok {
+				return body
+			}
+			t.Fatalf("no file for section %q (%s)", title, s.Filename)
+		}
+	}
+	t.Fatalf("no section %q", title)
+	return ""
+}
+
+// testPNG returns a w by h PNG filled with c.
+func testPNG(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// fileServer serves files by path, each as its content type, and 404s for
+// anything else.
+func fileServer(t *testing.T, files map[string]servedFile) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", f.contentType)
+		w.Write(f.body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// servedFile is a file fileServer serves.
+type servedFile struct {
+	contentType string
+	body        []byte
+}
@@ -0,0 +1,157 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// httpCache is a directory of downloaded pages and stylesheets, each kept
+// under a hash of its URL with the response headers needed to revalidate
+// it. The empty httpCache caches nothing.
+type httpCache string
+
+// cacheEntry is what an httpCache keeps next to a cached body.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+}
+
+// httpCache returns the cache the build described by opts downloads pages
+// and stylesheets through.
+func (opts Options) httpCache() httpCache {
+	if opts.InMemory {
+		return ""
+	}
+	return httpCache(opts.CacheDir)
+}
+
+// paths returns where c keeps the body and the entry of urlStr.
+func (c httpCache) paths(urlStr string) (body, entry string) {
+	sum := sha256.Sum256([]byte(urlStr))
+	base := filepath.Join(string(c), hex.EncodeToString(sum[:16]))
+	return base + ".body", base + ".json"
+}
+
+// get returns the body and content type of urlStr. A cached copy is
+// revalidated with the server, sending its ETag and Last-Modified date, and
+// only downloaded again if it changed; if the server can't be reached or
+// fails with a 5xx status, the cached copy is used as it is.
+func (c httpCache) get(ctx context.Context, urlStr string) ([]byte, string, error) {
+	if c == "" {
+		return fetchBytes(ctx, urlStr)
+	}
+	bodyPath, entryPath := c.paths(urlStr)
+	cached, entry, err := c.load(urlStr)
+	if err != nil {
+		fetcherLog.Warn("Ignoring cached copy", "url", urlStr, "err", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request for URL '%s': %w", urlStr, err)
+	}
+	if cached != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		if cached != nil && ctx.Err() == nil {
+			fetcherLog.Warn("Could not revalidate, using cached copy", "url", urlStr, "err", err)
+			return cached, entry.ContentType, nil
+		}
+		return nil, "", fmt.Errorf("failed to get URL '%s': %w", urlStr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		fetcherLog.Debug("Cached copy is current", "url", urlStr)
+		return cached, entry.ContentType, nil
+	}
+	if resp.StatusCode >= 500 && cached != nil {
+		fetcherLog.Warn("Server failed to revalidate, using cached copy", "url", urlStr, "status", resp.Status)
+		return cached, entry.ContentType, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("bad status for URL '%s': %s", urlStr, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response body from '%s': %w", urlStr, err)
+	}
+	if err := checkContentLength(resp, int64(len(body))); err != nil {
+		return nil, "", fmt.Errorf("failed to read response body from '%s': %w", urlStr, err)
+	}
+
+	// Keep the new copy; like the single-file cache, never an empty one
+	entry = cacheEntry{
+		URL:          urlStr,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+	}
+	if len(body) > 0 {
+		if err := c.store(bodyPath, entryPath, body, entry); err != nil {
+			fetcherLog.Warn("Failed to cache page", "url", urlStr, "err", err)
+		}
+	}
+	return body, entry.ContentType, nil
+}
+
+// load returns the cached body and entry of urlStr, or a nil body if there
+// is none.
+func (c httpCache) load(urlStr string) ([]byte, cacheEntry, error) {
+	bodyPath, entryPath := c.paths(urlStr)
+	var entry cacheEntry
+	data, err := os.ReadFile(entryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, entry, nil
+	}
+	if err != nil {
+		return nil, entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, entry, fmt.Errorf("invalid cache entry '%s': %w", entryPath, err)
+	}
+	if entry.URL != urlStr {
+		return nil, entry, nil // A hash collision; treat it as missing
+	}
+	body, err := os.ReadFile(bodyPath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(body) == 0) {
+		return nil, entry, nil
+	}
+	if err != nil {
+		return nil, entry, err
+	}
+	return body, entry, nil
+}
+
+// store writes body and its entry to the cache. Should the entry fail to
+// be written, the old one at worst has the next run download the body again.
+func (c httpCache) store(bodyPath, entryPath string, body []byte, entry cacheEntry) error {
+	if err := os.MkdirAll(string(c), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory '%s': %w", c, err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(bodyPath, body); err != nil {
+		return err
+	}
+	return writeFileAtomic(entryPath, data)
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkedIDsKept(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "paragraph",
+			body:     `<h3>One</h3><p id="target">Target.</p><p id="unlinked">Other.</p><p>See <a href="#target">above</a>.</p>`,
+			want:     []string{`<p id="target">Target. </p>`, `href="#target"`},
+			unwanted: []string{`id="unlinked"`},
+		},
+		{
+			name: "div",
+			body: `<h3>One</h3><div id="box"><p>Boxed.</p></div><p><a href="#box">back</a></p>`,
+			want: []string{`<p id="box">Boxed. </p>`},
+		},
+		{
+			name: "two ids on one paragraph",
+			body: `<h3>One</h3><div id="outer"><p id="inner">Both.</p></div><p><a href="#outer">a</a> <a href="#inner">b</a></p>`,
+			want: []string{`<p id="outer"><span id="inner"></span>Both. </p>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := testSections(t, tt.body, Options{})
+			if len(sections) != 1 {
+				t.Fatalf("got sections %v, want 1", sectionOutline(sections))
+			}
+			body := sections[0].Body
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body lacks %s:\n%s", want, body)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body has %s:\n%s", unwanted, body)
+				}
+			}
+		})
+	}
+}
+
+func TestCollidingIDsStable(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body>` +
+		`<h3>Notes</h3><p id="note">First.</p><p id="note">Second.</p><p id="note-2">Taken.</p><p id="note">Third.</p>` +
+		`<h3>Notes</h3><p><a href="#note">1</a> <a href="#note-2">2</a></p>` +
+		`<h3>Notes</h3><p>Again.</p></body></html>`
+	var first []string
+	for run := range 5 {
+		result, files := testBuild(t, page, Options{})
+		var got []string
+		for _, s := range result.Summary().Sections {
+			got = append(got, s.Filename, files["EPUB/xhtml/"+s.Filename])
+		}
+		if run == 0 {
+			first = got
+			body := got[1]
+			for _, want := range []string{`<p id="note">First. </p>`, `<p id="note-3">Second. </p>`, `<p id="note-2">Taken. </p>`, `<p id="note-4">Third. </p>`} {
+				if !strings.Contains(body, want) {
+					t.Errorf("first section lacks %s:\n%s", want, body)
+				}
+			}
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(first, "\n") {
+			t.Fatalf("run %d gave different filenames or ids:\n%q\nwant\n%q", run+1, got, first)
+		}
+	}
+	if first[0] == first[2] || first[2] == first[4] || first[0] == first[4] {
+		t.Errorf("sections with the same title share a filename: %s, %s, %s", first[0], first[2], first[4])
+	}
+}
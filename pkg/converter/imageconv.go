@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/gen2brain/avif" // Registers the AVIF decoder
+	_ "golang.org/x/image/webp"   // Registers the WebP decoder
+)
+
+// ImageFormat is what images readers may not display, such as WebP or AVIF,
+// are converted to.
+type ImageFormat string
+
+const (
+	ImageFormatKeep ImageFormat = "keep" // Embed them as they are
+	ImageFormatJPEG ImageFormat = "jpeg" // Convert them to JPEG, on white where transparent
+	ImageFormatPNG  ImageFormat = "png"  // Convert them to PNG
+)
+
+// DefaultImageQuality is the JPEG quality images are encoded with if
+// ImageQuality is zero.
+const DefaultImageQuality = 85
+
+// readerImageFormats are the raster formats every EPUB reader displays, as
+// named by the image package.
+var readerImageFormats = map[string]bool{"jpeg": true, "png": true, "gif": true}
+
+// ParseImageFormat parses a -convert-images flag value. The empty string
+// means the default, keep.
+func ParseImageFormat(s string) (ImageFormat, error) {
+	switch f := ImageFormat(strings.ToLower(s)); f {
+	case "", ImageFormatKeep:
+		return ImageFormatKeep, nil
+	case ImageFormatJPEG, ImageFormatPNG:
+		return f, nil
+	case "jpg":
+		return ImageFormatJPEG, nil
+	}
+	return "", fmt.Errorf("invalid image format '%s' (want keep, jpeg or png)", s)
+}
+
+// imageQuality returns the JPEG quality images are encoded with.
+func (opts Options) imageQuality() int {
+	if opts.ImageQuality > 0 {
+		return min(opts.ImageQuality, 100)
+	}
+	return DefaultImageQuality
+}
+
+// processesImages reports whether content images go through processImage.
+func (opts Options) processesImages() bool {
+	return opts.MaxImageSize != (image.Point{}) || opts.ImageQuality > 0 ||
+		(opts.ConvertImages != "" && opts.ConvertImages != ImageFormatKeep)
+}
+
+// sniffImageFormat returns the format of the image in data as the image
+// package names it, recognising WebP and AVIF even where their decoder
+// can't read the file, or "" if it can't tell.
+func sniffImageFormat(data []byte) string {
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return format
+	}
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && (string(data[8:12]) == "avif" || string(data[8:12]) == "avis"):
+		return "avif"
+	}
+	return ""
+}
+
+// processImage prepares the content image at imgPath for embedding as opts
+// ask: it converts formats readers may not display to ConvertImages,
+// downscales it to fit MaxImageSize, and re-encodes JPEGs at ImageQuality
+// where that makes them smaller. The result is put in store and its location
+// returned. Images needing none of this, animated GIFs, and images no
+// registered decoder can read are returned unchanged. WebP and AVIF
+// decoders are registered alongside the standard library's.
+func processImage(store MediaStore, imgPath string, opts Options) (string, error) {
+	data, err := store.Get(imgPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image '%s': %w", imgPath, err)
+	}
+	if isAnimatedGIF(data) {
+		return imgPath, nil
+	}
+	format := sniffImageFormat(data)
+	convert := format != "" && !readerImageFormats[format] && opts.ConvertImages != "" && opts.ConvertImages != ImageFormatKeep
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if convert {
+			imagesLog.Warn("Can't decode image to convert it; embedding as is", "image", mediaName(imgPath), "format", format)
+		}
+		return imgPath, nil // Not a format we can process; embed as-is
+	}
+	size := fitWithin(image.Pt(cfg.Width, cfg.Height), opts.MaxImageSize)
+	resized := size.X != cfg.Width || size.Y != cfg.Height
+	reencode := format == "jpeg" && opts.ImageQuality > 0
+	if !convert && !resized && !reencode {
+		return imgPath, nil
+	}
+
+	outFormat := format
+	if convert {
+		outFormat = string(opts.ConvertImages)
+	}
+	if !readerImageFormats[outFormat] {
+		return imgPath, nil // Resizing a format we can't encode again
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image '%s': %w", imgPath, err)
+	}
+	if resized {
+		img = downscale(img, size)
+	}
+	out, err := encodeImage(img, outFormat, opts.imageQuality())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode image '%s': %w", imgPath, err)
+	}
+	if !convert && !resized && len(out) >= len(data) {
+		return imgPath, nil // Already smaller than re-encoding makes it
+	}
+
+	name := mediaName(imgPath)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if convert || ext == "" {
+		ext = imageFormatExt(outFormat)
+	}
+	if resized {
+		base = fmt.Sprintf("%s-%dx%d", base, size.X, size.Y)
+	}
+	outPath, err := store.Put(base+ext, out)
+	if err != nil {
+		return "", fmt.Errorf("failed to save processed image: %w", err)
+	}
+	return outPath, nil
+}
+
+// encodeImage encodes img as format, one of jpeg, png or gif, with JPEG
+// quality quality. JPEG has no transparency, so transparent areas become
+// white rather than black.
+func encodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("can't encode %s images", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imageFormatExt returns the file extension of images in format.
+func imageFormatExt(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessImageConvertsWebPAndAVIF(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		convert    ImageFormat
+		wantFormat string
+		wantExt    string
+	}{
+		{"photo.webp", ImageFormatJPEG, "jpeg", ".jpg"},
+		{"photo.webp", ImageFormatPNG, "png", ".png"},
+		{"photo.avif", ImageFormatJPEG, "jpeg", ".jpg"},
+		{"photo.avif", ImageFormatPNG, "png", ".png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+"->"+string(tt.convert), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := dirStore(t.TempDir())
+			in, err := store.Put(tt.fixture, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("no decoder registered for %s: %v", tt.fixture, err)
+			}
+
+			out, err := processImage(store, in, Options{ConvertImages: tt.convert})
+			if err != nil {
+				t.Fatalf("processImage: %v", err)
+			}
+			if out == in {
+				t.Fatalf("processImage returned the original %s unconverted", tt.fixture)
+			}
+			if !strings.HasSuffix(out, tt.wantExt) {
+				t.Errorf("converted image %q, want extension %s", out, tt.wantExt)
+			}
+			converted, err := store.Get(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(converted))
+			if err != nil {
+				t.Fatalf("converted image doesn't decode: %v", err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("converted to %s, want %s", format, tt.wantFormat)
+			}
+			if cfg.Width != want.Width || cfg.Height != want.Height {
+				t.Errorf("converted to %dx%d, want %dx%d", cfg.Width, cfg.Height, want.Width, want.Height)
+			}
+		})
+	}
+}
+
+func TestProcessImageKeep(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "photo.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := dirStore(t.TempDir())
+	in, err := store.Put("photo.webp", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, convert := range []ImageFormat{"", ImageFormatKeep} {
+		out, err := processImage(store, in, Options{ConvertImages: convert})
+		if err != nil {
+			t.Fatalf("processImage(%q): %v", convert, err)
+		}
+		if out != in {
+			t.Errorf("processImage(%q) = %q, want the original kept", convert, out)
+		}
+	}
+}
+
+func TestSniffImageFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"webp header", []byte("RIFF\x00\x00\x00\x00WEBPVP8X"), "webp"},
+		{"avif header", []byte("\x00\x00\x00\x1cftypavif\x00\x00"), "avif"},
+		{"text", []byte("not an image"), ""},
+	}
+	for _, tt := range tests {
+		if got := sniffImageFormat(tt.data); got != tt.want {
+			t.Errorf("%s: sniffImageFormat = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
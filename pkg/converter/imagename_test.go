@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageName(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/a/photo.png": {"image/png", testPNG(t, 2, 2, color.Black)},
+		"/b/photo.png": {"image/png", testPNG(t, 2, 2, color.White)},
+		"/c/chart.png": {"image/png", testPNG(t, 3, 3, color.Black)},
+	})
+	page := `<html><body><h3>One</h3><p>Text.</p>` +
+		`<img src="` + srv.URL + `/a/photo.png" alt="A">` +
+		`<img src="` + srv.URL + `/b/photo.png" alt="B">` +
+		`<img src="` + srv.URL + `/c/chart.png" alt="C"></body></html>`
+
+	tests := []struct {
+		name   string
+		naming func(sourceURL string, index int) string
+		want   []string
+	}{
+		{
+			name:   "numbered",
+			naming: func(sourceURL string, index int) string { return fmt.Sprintf("img/%03d%s", index, path.Ext(sourceURL)) },
+			want:   []string{"img-001.png", "img-002.png", "img-003.png"},
+		},
+		{
+			name:   "colliding",
+			naming: func(sourceURL string, index int) string { return "figure.png" },
+			want:   []string{"figure.png", "figure-2.png", "figure-3.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			naming := func(sourceURL string, index int) string {
+				seen = append(seen, sourceURL)
+				return tt.naming(sourceURL, index)
+			}
+			result, files := testBuild(t, page, Options{ImageName: naming})
+			if want := []string{srv.URL + "/a/photo.png", srv.URL + "/b/photo.png", srv.URL + "/c/chart.png"}; fmt.Sprint(seen) != fmt.Sprint(want) {
+				t.Errorf("ImageName called with %q, want %q", seen, want)
+			}
+			one := sectionFile(t, result, files, "One")
+			for _, name := range tt.want {
+				if _, ok := files["EPUB/images/"+name]; !ok {
+					t.Errorf("EPUB lacks images/%s", name)
+				}
+				if !strings.Contains(one, `src="../images/`+name+`"`) {
+					t.Errorf("section doesn't point at images/%s:\n%s", name, one)
+				}
+			}
+		})
+	}
+}
+
+func TestSameNameImages(t *testing.T) {
+	mapA, mapB := testPNG(t, 2, 2, color.Black), testPNG(t, 3, 3, color.White)
+	srv := fileServer(t, map[string]servedFile{
+		"/a/map.png": {"image/png", mapA},
+		"/b/map.png": {"image/png", mapB},
+	})
+	page := `<html><body><h3>One</h3><p>Text.</p>` +
+		`<img src="` + srv.URL + `/a/map.png" alt="A"><img src="` + srv.URL + `/b/map.png" alt="B"></body></html>`
+	for _, inMemory := range []bool{false, true} {
+		t.Run(fmt.Sprint("in memory ", inMemory), func(t *testing.T) {
+			imageDir := filepath.Join(t.TempDir(), "images")
+			result, files := testBuild(t, page, Options{ImageDir: imageDir, InMemory: inMemory})
+			if files["EPUB/images/map.png"] != string(mapA) || files["EPUB/images/map-2.png"] != string(mapB) {
+				t.Error("EPUB doesn't hold both maps as map.png and map-2.png")
+			}
+			one := sectionFile(t, result, files, "One")
+			if !strings.Contains(one, `src="../images/map.png" alt="A"`) || !strings.Contains(one, `src="../images/map-2.png" alt="B"`) {
+				t.Errorf("section doesn't point at each map:\n%s", one)
+			}
+			if inMemory {
+				return
+			}
+			cached := make(map[string]string)
+			filepath.WalkDir(imageDir, func(p string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					data, _ := os.ReadFile(p)
+					cached[p] = string(data)
+				}
+				return nil
+			})
+			var found []string
+			for p, data := range cached {
+				if filepath.Base(p) == "map.png" && (data == string(mapA) || data == string(mapB)) {
+					found = append(found, p)
+				}
+			}
+			if len(found) != 2 {
+				t.Errorf("image directory holds %q, want both maps apart", found)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestImageOnlySections(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/plate.png": {"image/png", testPNG(t, 4, 4, color.White)},
+	})
+	img := func(alt string) string { return `<img src="` + srv.URL + `/plate.png" alt="` + alt + `">` }
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"titled by its heading", `<h3>One</h3><p>Text.</p><h3>Plate I</h3>` + img("A map") + `<h3>Two</h3><p>More.</p>`, []string{"One", "Plate I", "Two"}},
+		{"titled by its alt text", `<h3>One</h3><p>Text.</p><h3></h3>` + img("A map"), []string{"One", "A map"}},
+		{"no alt text", `<h3>One</h3><p>Text.</p><h3></h3>` + img(""), []string{"One", "Illustration"}},
+		{"before the first heading", img("Frontispiece"), []string{"Page"}}, // Named after the page, as leading content is
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := testSections(t, tt.body, Options{})
+			var titles []string
+			for _, s := range sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			for _, s := range sections {
+				if s.Title != "One" && s.Title != "Two" && !strings.Contains(s.Body, "<img ") {
+					t.Errorf("section %q lost its image: %s", s.Title, s.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestImageOnlyHeadings(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/banner.png": {"image/png", testPNG(t, 6, 2, color.Black)},
+	})
+	tests := []struct {
+		name string
+		alt  string
+		want []string
+	}{
+		{"titled by its alt text", "Part One", []string{"Part One", "Two"}},
+		{"no alt text", "", []string{"Unnamed Section", "Two"}}, // As any untitled section with text
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := `<html><head><title>Page</title></head><body>` +
+				`<h3><img src="` + srv.URL + `/banner.png" alt="` + tt.alt + `"></h3><p>Text of part one.</p>` +
+				`<h3>Two</h3><p>More.</p></body></html>`
+			result, files := testBuild(t, page, Options{})
+			var titles []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			body := sectionFile(t, result, files, tt.want[0])
+			if !strings.Contains(body, `src="../images/banner.png"`) || !strings.Contains(body, "Text of part one.") {
+				t.Errorf("section lacks its heading image or text:\n%s", body)
+			}
+			if _, ok := files["EPUB/images/banner.png"]; !ok {
+				t.Error("heading image not embedded")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// screenPresets map reader screen classes to the largest image dimensions
+// worth embedding for them. Anything bigger is downscaled before embedding so
+// readers don't have to do it at page-turn time.
+var screenPresets = map[string]image.Point{
+	"kindle": {X: 1072, Y: 1448},
+	"tablet": {X: 1536, Y: 2048},
+	"phone":  {X: 720, Y: 1280},
+}
+
+// ParseScreenPreset returns the maximum image dimensions for a -screen flag
+// value. The empty string means no limit.
+func ParseScreenPreset(name string) (image.Point, error) {
+	if name == "" {
+		return image.Point{}, nil
+	}
+	if p, ok := screenPresets[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	var names []string
+	for n := range screenPresets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return image.Point{}, fmt.Errorf("unknown screen preset '%s' (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// isAnimatedGIF reports whether data is a GIF with more than one frame.
+// Any step that decodes and re-encodes images must pass these through
+// unchanged: the image package only round-trips the first frame, which would
+// silently flatten the animation.
+func isAnimatedGIF(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("GIF8")) {
+		return false
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return len(g.Image) > 1
+}
+
+// fitImage downscales the image at imgPath so it fits within max, keeping its
+// aspect ratio. The result is put in store and its location is returned;
+// images that already fit, animated GIFs and formats the standard library
+// cannot encode are returned unchanged.
+func fitImage(store MediaStore, imgPath string, max image.Point) (string, error) {
+	data, err := store.Get(imgPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image '%s': %w", imgPath, err)
+	}
+	if isAnimatedGIF(data) {
+		return imgPath, nil
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return imgPath, nil // Not a format we can process; embed as-is
+	}
+	size := fitWithin(image.Pt(cfg.Width, cfg.Height), max)
+	if size.X == cfg.Width && size.Y == cfg.Height {
+		return imgPath, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image '%s': %w", imgPath, err)
+	}
+	dst := downscale(src, size)
+
+	name := mediaName(imgPath)
+	ext := filepath.Ext(name)
+	outName := fmt.Sprintf("%s-%dx%d%s", strings.TrimSuffix(name, ext), size.X, size.Y, ext)
+	if !readerImageFormats[format] {
+		return imgPath, nil
+	}
+	out, err := encodeImage(dst, format, DefaultImageQuality)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resized image '%s': %w", outName, err)
+	}
+	outPath, err := store.Put(outName, out)
+	if err != nil {
+		return "", fmt.Errorf("failed to save resized image: %w", err)
+	}
+	return outPath, nil
+}
+
+// fitWithin scales size down to fit inside max, keeping the aspect ratio.
+// A zero max dimension is unbounded.
+func fitWithin(size, max image.Point) image.Point {
+	scale := 1.0
+	if max.X > 0 && size.X > max.X {
+		scale = float64(max.X) / float64(size.X)
+	}
+	if max.Y > 0 && float64(size.Y)*scale > float64(max.Y) {
+		scale = float64(max.Y) / float64(size.Y)
+	}
+	if scale == 1.0 {
+		return size
+	}
+	w := int(float64(size.X)*scale + 0.5)
+	h := int(float64(size.Y)*scale + 0.5)
+	return image.Pt(clampMin(w, 1), clampMin(h, 1))
+}
+
+func clampMin(v, min int) int {
+	if v < min {
+		return min
+	}
+	return v
+}
+
+// downscale resizes src to size by averaging the source pixels that fall
+// into each destination pixel (a box filter).
+func downscale(src image.Image, size image.Point) *image.NRGBA {
+	b := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		y0 := y * b.Dy() / size.Y
+		y1 := clampMin((y+1)*b.Dy()/size.Y, y0+1)
+		for x := 0; x < size.X; x++ {
+			x0 := x * b.Dx() / size.X
+			x1 := clampMin((x+1)*b.Dx()/size.X, x0+1)
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					bl += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			o := out.Pix[y*out.Stride+x*4:]
+			o[0], o[1], o[2], o[3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return out
+}
+
+// imageSize returns the pixel dimensions of the image at imgPath in store.
+func imageSize(store MediaStore, imgPath string) (image.Point, error) {
+	data, err := store.Get(imgPath)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("failed to open image '%s': %w", mediaName(imgPath), err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Point{}, fmt.Errorf("failed to read image size of '%s': %w", mediaName(imgPath), err)
+	}
+	return image.Pt(cfg.Width, cfg.Height), nil
+}
+
+// imageExt returns the lower-cased file extension of imgPath.
+func imageExt(imgPath string) string {
+	return strings.ToLower(filepath.Ext(mediaName(imgPath)))
+}
+
+// LoadAltText reads a JSON object mapping image URLs to replacement alt text.
+func LoadAltText(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alt text file '%s': %w", filePath, err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse alt text file '%s': %w", filePath, err)
+	}
+	return m, nil
+}
+
+// lookupAltText returns the override for an image, trying each of the URLs
+// it is known by in turn.
+func lookupAltText(overrides map[string]string, urls ...string) (string, bool) {
+	for _, u := range urls {
+		if alt, ok := overrides[u]; ok {
+			return strings.TrimSpace(alt), true
+		}
+	}
+	return "", false
+}
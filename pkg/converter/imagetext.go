@@ -0,0 +1,50 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ImageTextMode selects whether image alt or caption text is also written
+// out as text next to the image, where readers can search it.
+type ImageTextMode string
+
+const (
+	ImageTextNone    ImageTextMode = "none"    // Only the alt attribute
+	ImageTextVisible ImageTextMode = "visible" // A <figcaption> under the image
+	ImageTextHidden  ImageTextMode = "hidden"  // A hidden description linked with aria-describedby
+)
+
+// ParseImageTextMode validates an -image-text flag value.
+func ParseImageTextMode(s string) (ImageTextMode, error) {
+	switch m := ImageTextMode(strings.ToLower(s)); m {
+	case ImageTextNone, ImageTextVisible, ImageTextHidden:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid image text mode '%s' (want none, visible or hidden)", s)
+}
+
+// imageCaption returns the caption of img: the <figcaption> of the figure it
+// is in, if any, otherwise alt.
+func imageCaption(img *html.Node, alt string) string {
+	for p := img.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "figure" {
+			if c := findElement(p, "figcaption"); c != nil {
+				if text := getTextContent(c); text != "" {
+					return text
+				}
+			}
+			break
+		}
+	}
+	return alt
+}
+
+// isImageFigcaption reports whether n is the caption of a figure holding an
+// image, which becomes the image's caption text rather than a paragraph.
+func isImageFigcaption(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Data == "figcaption" &&
+		n.Parent != nil && n.Parent.Data == "figure" && findElement(n.Parent, "img") != nil
+}
@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// indexThumbnailSize is the square, in pixels, index thumbnails fit in.
+const indexThumbnailSize = 128
+
+// bookIndex is the JSON index of a built EPUB written for reader apps.
+type bookIndex struct {
+	Title    string       `json:"title"`
+	Cover    string       `json:"cover,omitempty"` // Data URL of a cover thumbnail
+	Sections []indexEntry `json:"sections"`
+}
+
+// indexEntry describes one section in a bookIndex.
+type indexEntry struct {
+	Title     string `json:"title"`
+	Filename  string `json:"filename"`
+	Words     int    `json:"words"`
+	Thumbnail string `json:"thumbnail,omitempty"` // Data URL of a thumbnail of the section's first image
+}
+
+// tagPattern matches markup, to leave the text of a section body.
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// newIndexEntry describes section s, added to the EPUB as filename, with
+// the thumbnail made by sectionThumbnail.
+func newIndexEntry(s Section, filename, thumbnail string) indexEntry {
+	return indexEntry{
+		Title:     s.Title,
+		Filename:  filename,
+		Words:     len(strings.Fields(html.UnescapeString(tagPattern.ReplaceAllString(s.Body, " ")))),
+		Thumbnail: thumbnail,
+	}
+}
+
+// sectionThumbnail returns the index thumbnail of the first image of s, or
+// "" if it has none or it can't be made.
+func sectionThumbnail(store MediaStore, s Section) string {
+	if s.firstImage == "" {
+		return ""
+	}
+	thumb, err := thumbnailDataURL(store, s.firstImage)
+	if err != nil {
+		imagesLog.Warn("Could not make index thumbnail", "section", s.Title, "err", err)
+	}
+	return thumb
+}
+
+// thumbnailDataURL scales the image at imgPath in store down to an index
+// thumbnail, staged in the store too, and returns it as a base64 data URL.
+func thumbnailDataURL(store MediaStore, imgPath string) (string, error) {
+	thumbPath, err := fitImage(store, imgPath, image.Pt(indexThumbnailSize, indexThumbnailSize))
+	if err != nil {
+		return "", err
+	}
+	data, err := store.Get(thumbPath)
+	if err != nil {
+		return "", err
+	}
+	mediaType := mime.TypeByExtension(imageExt(thumbPath))
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// writeIndex writes index as JSON to filePath.
+func writeIndex(filePath string, index bookIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := writeFileAtomic(filePath, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write index '%s': %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{"/photo.png": {"image/png", testPNG(t, 512, 256, color.Black)}})
+	page := `<html><head><title>Page</title></head><body>` +
+		`<h3>One</h3><p>Three short words.</p><img src="` + srv.URL + `/photo.png" alt="Photo">` +
+		`<h3>Two</h3><p>No pictures here, just text.</p></body></html>`
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	result, _ := testBuild(t, page, Options{IndexPath: indexPath, Metadata: Metadata{Title: "Indexed"}})
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index bookIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("index isn't JSON: %v\n%s", err, data)
+	}
+	if index.Title != "Indexed" || len(index.Sections) != 2 {
+		t.Fatalf("index = %+v, want the book title and two sections", index)
+	}
+	for i, s := range result.Summary().Sections {
+		if e := index.Sections[i]; e.Title != s.Title || e.Filename != s.Filename {
+			t.Errorf("index section %d = %q in %s, want %q in %s", i, e.Title, e.Filename, s.Title, s.Filename)
+		}
+	}
+	one, two := index.Sections[0], index.Sections[1]
+	if one.Words != 4 || two.Words != 6 { // Headings count
+		t.Errorf("word counts = %d and %d, want 4 and 6", one.Words, two.Words)
+	}
+	if two.Thumbnail != "" {
+		t.Errorf("section without an image has thumbnail %.40s...", two.Thumbnail)
+	}
+	payload, ok := strings.CutPrefix(one.Thumbnail, "data:image/png;base64,")
+	if !ok {
+		t.Fatalf("section with an image has thumbnail %.40q, want a PNG data URL", one.Thumbnail)
+	}
+	png, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(png))
+	if err != nil {
+		t.Fatalf("thumbnail isn't an image: %v", err)
+	}
+	if cfg.Width != indexThumbnailSize || cfg.Height != indexThumbnailSize/2 {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", cfg.Width, cfg.Height, indexThumbnailSize, indexThumbnailSize/2)
+	}
+}
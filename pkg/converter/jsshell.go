@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// defaultMinText is how many characters of text a build may extract before
+// it's suspected of converting a JavaScript shell, if MinText isn't set.
+const defaultMinText = 200
+
+// errTooLittleText is returned when a build extracts less text than
+// Options.MinText.
+var errTooLittleText = errors.New("too little text extracted")
+
+// extractedText returns the number of characters of text in sections.
+func extractedText(sections []Section) int {
+	n := 0
+	for _, s := range sections {
+		text := html.UnescapeString(tagPattern.ReplaceAllString(s.Body, " "))
+		n += len([]rune(strings.Join(strings.Fields(text), " ")))
+	}
+	return n
+}
+
+// hasScripts reports whether any of the documents runs scripts.
+func hasScripts(sources []*source) bool {
+	for _, src := range sources {
+		if findElement(src.doc, "script") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExtractedText catches pages that render their content with
+// JavaScript, which arrive as a near-empty shell. If opts.MinText is set,
+// extracting fewer characters than that is an error; otherwise a page with
+// scripts and under defaultMinText characters gets a warning.
+func checkExtractedText(sections []Section, sources []*source, opts Options) error {
+	n := extractedText(sections)
+	hint := "the page may render its content with JavaScript, which isn't run; try a server-rendered or print version of it"
+	if opts.MinText > 0 {
+		if n < opts.MinText {
+			return fmt.Errorf("%w: %d characters, want at least %d; %s", errTooLittleText, n, opts.MinText, hint)
+		}
+		return nil
+	}
+	if n < defaultMinText && hasScripts(sources) {
+		parserLog.Warn("Little text was extracted; "+hint, "chars", n)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJavaScriptShell(t *testing.T) {
+	shell := `<html><head><title>App</title><script src="app.js"></script></head>` +
+		`<body><div id="root"><p>Loading...</p></div></body></html>`
+	article := `<html><head><title>Article</title><script src="analytics.js"></script></head><body><h3>One</h3><p>` +
+		strings.Repeat("Plenty of server-rendered text. ", 10) + `</p></body></html>`
+	tests := []struct {
+		name     string
+		page     string
+		minText  int
+		wantWarn bool
+		wantErr  bool
+	}{
+		{name: "shell", page: shell, wantWarn: true},
+		{name: "shell with a minimum", page: shell, minText: 50, wantErr: true},
+		{name: "article", page: article},
+		{name: "article with a minimum", page: article, minText: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			defer slog.SetDefault(slog.Default())
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+
+			dir := t.TempDir()
+			_, err := build(context.Background(), Options{
+				SourceHTML: []byte(tt.page),
+				SourceURL:  "https://example.com/app/",
+				OutputPath: filepath.Join(dir, "book.epub"),
+				ImageDir:   filepath.Join(dir, "images"),
+				MinText:    tt.minText,
+			})
+			if got := errors.Is(err, errTooLittleText); got != tt.wantErr {
+				t.Fatalf("build error = %v, want too little text %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "JavaScript") {
+				t.Errorf("error %q doesn't suggest the page needs JavaScript", err)
+			}
+			if got := strings.Contains(logged.String(), "Little text was extracted"); got != tt.wantWarn {
+				t.Errorf("warned = %v, want %v; logged:\n%s", got, tt.wantWarn, logged.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LeadingMode selects what happens to the content of the first page before
+// its first heading.
+type LeadingMode string
+
+const (
+	LeadingKeep        LeadingMode = "keep"        // A section of its own
+	LeadingFrontMatter LeadingMode = "frontmatter" // A section of its own, marked as front matter
+	LeadingDiscard     LeadingMode = "discard"     // Left out
+	LeadingMerge       LeadingMode = "merge"       // The start of the first section with a heading
+)
+
+// ParseLeadingMode validates a -leading flag value.
+func ParseLeadingMode(s string) (LeadingMode, error) {
+	switch m := LeadingMode(strings.ToLower(s)); m {
+	case LeadingKeep, LeadingFrontMatter, LeadingDiscard, LeadingMerge:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid leading content mode '%s' (want keep, frontmatter, discard or merge)", s)
+}
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeadingTitle(t *testing.T) {
+	body := `<body><p>Before any heading.</p><h3>One</h3><p>Text.</p></body>`
+	tests := []struct {
+		name  string
+		head  string
+		opts  Options
+		want  string
+		front bool
+	}{
+		{name: "configured", head: "<title>My Book</title>", opts: Options{LeadingTitle: "Foreword"}, want: "Foreword"},
+		{name: "page title", head: "<title>My Book</title>", want: "My Book"},
+		{name: "no title", want: "Introduction"},
+		{name: "front matter", opts: Options{LeadingTitle: "Foreword", Leading: LeadingFrontMatter}, want: "Foreword", front: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, "<html><head>"+tt.head+"</head>"+body+"</html>", tt.opts)
+			var titles []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != tt.want+"|One" {
+				t.Fatalf("sections = %q, want %q then One", titles, tt.want)
+			}
+			leading := sectionFile(t, result, files, tt.want)
+			if !strings.Contains(leading, "Before any heading.") {
+				t.Errorf("leading section lacks the content before the heading:\n%s", leading)
+			}
+			if got := strings.Contains(leading, `epub:type="frontmatter"`); got != tt.front {
+				t.Errorf("leading section marked as front matter = %v, want %v:\n%s", got, tt.front, leading)
+			}
+		})
+	}
+}
+
+func TestLeadingMode(t *testing.T) {
+	body := `<p>Before any heading.</p><h3>One</h3><p>Text.</p><h3>Two</h3><p>More.</p>`
+	tests := []struct {
+		mode      LeadingMode
+		want      []string
+		leadingIn string // Title of the section holding the leading content, if any
+	}{
+		{mode: "", want: []string{"Page", "One", "Two"}, leadingIn: "Page"},
+		{mode: LeadingKeep, want: []string{"Page", "One", "Two"}, leadingIn: "Page"},
+		{mode: LeadingFrontMatter, want: []string{"Page", "One", "Two"}, leadingIn: "Page"},
+		{mode: LeadingDiscard, want: []string{"One", "Two"}},
+		{mode: LeadingMerge, want: []string{"One", "Two"}, leadingIn: "One"},
+	}
+	for _, tt := range tests {
+		name := string(tt.mode)
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			sections := testSections(t, body, Options{Leading: tt.mode})
+			var titles []string
+			for _, s := range sections {
+				titles = append(titles, s.Title)
+				has := strings.Contains(s.Body, "Before any heading.")
+				if has != (s.Title == tt.leadingIn) {
+					t.Errorf("section %q holds the leading content = %v:\n%s", s.Title, has, s.Body)
+				}
+				if front := strings.Contains(s.Body, `epub:type="frontmatter"`); front != (has && tt.mode == LeadingFrontMatter) {
+					t.Errorf("section %q marked as front matter = %v:\n%s", s.Title, front, s.Body)
+				}
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			if tt.mode == LeadingMerge && !strings.HasPrefix(sections[0].Body, "<p>Before any heading. </p>") {
+				t.Errorf("merged content doesn't start the first section:\n%s", sections[0].Body)
+			}
+		})
+	}
+}
+
+func TestParseLeadingMode(t *testing.T) {
+	for _, s := range []string{"keep", "FrontMatter", "discard", "merge"} {
+		if _, err := ParseLeadingMode(s); err != nil {
+			t.Errorf("ParseLeadingMode(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseLeadingMode("drop"); err == nil {
+		t.Error("ParseLeadingMode accepted drop")
+	}
+}
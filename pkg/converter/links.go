@@ -0,0 +1,270 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// internalLink is where a link between parts of the book points: an
+// element of a source, by its original id, or the source's start if
+// Fragment is empty.
+type internalLink struct {
+	Source   string
+	Fragment string
+}
+
+// internalLinkPattern matches the placeholder links the extractor writes
+// for internal links, which resolveLinks replaces once the sections have
+// their filenames. Group 1 is the index into extractor.links, group 2 any
+// further attributes and group 3 the link text. sectionLinkPattern matches
+// a resolved link into another section file, with the same groups.
+var (
+	internalLinkPattern = regexp.MustCompile(`<a href="epub-link:([0-9]+)"([^>]*)>([^<]*)</a>`)
+	sectionLinkPattern  = regexp.MustCompile(`<a href="(section[0-9]{4}\.xhtml)(?:#[^"]*)?"([^>]*)>([^<]*)</a>`)
+)
+
+// collectLinks records which sources of the book links may point at, and
+// the ids in each that links in any source point at, either as bare
+// fragments ("#note1") or as fragments of a source's URL. Only these ids
+// are carried into the extracted sections.
+func (x *extractor) collectLinks(sources []*source) {
+	x.sourceNames = make(map[string]string)
+	for _, src := range sources {
+		if src.baseURL != nil {
+			x.sourceNames[withoutFragment(src.baseURL)] = src.name
+		}
+		if u, err := url.Parse(src.name); err == nil && u.IsAbs() {
+			x.sourceNames[withoutFragment(u)] = src.name
+		}
+	}
+	x.bookTargets = make(map[string]map[string]bool)
+	for _, src := range sources {
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.ElementNode && n.Data == "a" {
+				if link, ok := x.resolveInternalLink(src, getAttr(n, "href")); ok && link.Fragment != "" {
+					if x.bookTargets[link.Source] == nil {
+						x.bookTargets[link.Source] = make(map[string]bool)
+					}
+					x.bookTargets[link.Source][link.Fragment] = true
+				}
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(src.doc)
+	}
+}
+
+// resolveInternalLink returns where href, a link in src, points if that's
+// within the book: into src itself or into another of its sources.
+func (x *extractor) resolveInternalLink(src *source, href string) (internalLink, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return internalLink{}, false
+	}
+	if id, ok := localFragment(href, src.baseURL); ok {
+		return internalLink{Source: src.name, Fragment: id}, true
+	}
+	if src.baseURL == nil {
+		return internalLink{}, false
+	}
+	target, err := src.baseURL.Parse(href)
+	if err != nil {
+		return internalLink{}, false
+	}
+	name, ok := x.sourceNames[withoutFragment(target)]
+	if !ok {
+		return internalLink{}, false
+	}
+	return internalLink{Source: name, Fragment: target.Fragment}, true
+}
+
+// withoutFragment returns u as a string without its fragment.
+func withoutFragment(u *url.URL) string {
+	v := *u
+	v.Fragment, v.RawFragment = "", ""
+	return v.String()
+}
+
+// addInternalLink writes n, a link to another part of the book, as a
+// placeholder link resolveLinks points at the right section file once
+// sections have filenames. It reports false, leaving n to be walked as
+// usual, if n isn't such a link or holds more than text, e.g. an image.
+// Note references are kept inline, with the text they annotate.
+func (x *extractor) addInternalLink(n *html.Node) bool {
+	if n.Data != "a" || findElement(n, "img") != nil {
+		return false
+	}
+	link, ok := x.resolveInternalLink(x.src, getAttr(n, "href"))
+	if !ok {
+		return false
+	}
+	text := getTextContent(n)
+	if text == "" {
+		return false
+	}
+	x.links = append(x.links, link)
+	markup := fmt.Sprintf(`<a href="epub-link:%d">%s</a>`, len(x.links)-1, html.EscapeString(text))
+	if isNoteref(n) {
+		x.appendInline(n, markup) // A note reference left in place still belongs with its text
+	} else {
+		x.currentSection.WriteString(x.openParagraph() + markup + " </p>")
+	}
+	return true
+}
+
+// nameSections gives every section without a filename the one go-epub
+// would generate for it, so links can be resolved before the sections are
+// added.
+func nameSections(sections []Section) {
+	used := make(map[string]bool)
+	for _, s := range sections {
+		used[s.filename] = true
+	}
+	index := 1
+	for i := range sections {
+		if sections[i].filename != "" {
+			continue
+		}
+		for used[fmt.Sprintf("section%04d.xhtml", index)] {
+			index++
+		}
+		sections[i].filename = fmt.Sprintf("section%04d.xhtml", index)
+		used[sections[i].filename] = true
+	}
+}
+
+// resolveLinks points the placeholder links in sections at the section
+// file and id their targets ended up in. A link to an id that wasn't kept
+// points at the start of its source instead; one whose target isn't in
+// sections at all, e.g. because it was left out, is unwrapped to its text.
+// Sections without a filename only resolve links within themselves.
+func (x *extractor) resolveLinks(sections []Section) {
+	files := make(map[string]string)   // Filenames by source name and id
+	anyFile := make(map[string]string) // Filenames by id alone, for merged sections
+	starts := make(map[string]string)  // Filenames of each source's first section
+	for _, s := range sections {
+		if _, ok := starts[s.Source]; !ok {
+			starts[s.Source] = s.filename
+		}
+		for _, m := range idAttrPattern.FindAllStringSubmatch(s.Body, -1) {
+			if _, ok := files[s.Source+"#"+m[1]]; !ok {
+				files[s.Source+"#"+m[1]] = s.filename
+			}
+			if _, ok := anyFile[m[1]]; !ok {
+				anyFile[m[1]] = s.filename
+			}
+		}
+	}
+	for i := range sections {
+		s := &sections[i]
+		s.Body = internalLinkPattern.ReplaceAllStringFunc(s.Body, func(m string) string {
+			sub := internalLinkPattern.FindStringSubmatch(m)
+			attrs, text := sub[2], sub[3]
+			n, err := strconv.Atoi(sub[1])
+			if err != nil || n >= len(x.links) {
+				return unlinked(attrs, text)
+			}
+			link := x.links[n]
+			var file, id string
+			found := false
+			if link.Fragment != "" {
+				if id, found = x.anchors[link.Source+"#"+link.Fragment]; found {
+					if file, found = files[link.Source+"#"+html.EscapeString(id)]; !found {
+						file, found = anyFile[html.EscapeString(id)]
+					}
+				}
+			}
+			if !found {
+				id = ""
+				if file, found = starts[link.Source]; !found && len(sections) == 1 {
+					file, found = s.filename, true // Everything was merged into one file
+				}
+			}
+			switch {
+			case !found || file != s.filename && s.filename == "":
+				return unlinked(attrs, text)
+			case file == s.filename && id != "":
+				return `<a href="#` + html.EscapeString(id) + `"` + attrs + `>` + text + `</a>`
+			case id != "":
+				return `<a href="` + file + `#` + html.EscapeString(id) + `"` + attrs + `>` + text + `</a>`
+			case file == "":
+				return unlinked(attrs, text)
+			default:
+				return `<a href="` + file + `"` + attrs + `>` + text + `</a>`
+			}
+		})
+	}
+}
+
+// localFragment returns the fragment of href if it points into the document
+// at baseURL.
+func localFragment(href string, baseURL *url.URL) (string, bool) {
+	if strings.HasPrefix(href, "#") {
+		return href[1:], len(href) > 1
+	}
+	if baseURL == nil {
+		return "", false
+	}
+	target, err := baseURL.Parse(href)
+	if err != nil || target.Fragment == "" {
+		return "", false
+	}
+	if target.Scheme != baseURL.Scheme || target.Host != baseURL.Host || target.Path != baseURL.Path || target.RawQuery != baseURL.RawQuery {
+		return "", false
+	}
+	return target.Fragment, true
+}
+
+// collectIDs returns the set of element ids in doc.
+func collectIDs(doc *html.Node) map[string]bool {
+	ids := make(map[string]bool)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := getAttr(n, "id"); id != "" {
+				ids[id] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return ids
+}
+
+// uniqueID reserves and returns id, or, if it's already been used in the
+// current source, id with the lowest "-N" suffix that's free and isn't an
+// id elsewhere in the source. Suffixes are handed out in document order,
+// so repeated runs give the same ids.
+func (x *extractor) uniqueID(id string) string {
+	if !x.usedIDs[id] {
+		x.usedIDs[id] = true
+		return id
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", id, i)
+		if !x.usedIDs[candidate] && !x.docIDs[candidate] {
+			x.usedIDs[candidate] = true
+			return candidate
+		}
+	}
+}
+
+// unlinked returns what's left of a link with attributes attrs and text
+// text that can't point anywhere: its text, or nothing for a backlink,
+// which only makes sense as a link.
+func unlinked(attrs, text string) string {
+	if strings.Contains(attrs, `role="doc-backlink"`) {
+		return ""
+	}
+	return text
+}
@@ -0,0 +1,191 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// dirManifestName is the file that, in a directory given as input, lists
+// the HTML files to convert in order, instead of all of them by path.
+const dirManifestName = "manifest.txt"
+
+// localFiles expands local input paths into the HTML files they name, in
+// order: a file is itself, a directory its HTML files, as its manifest.txt
+// lists them or else sorted by path, and a glob pattern each of its matches
+// in turn.
+func localFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		matches := []string{p}
+		if strings.ContainsAny(p, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(p); err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", p, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match '%s'", p)
+			}
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				files = append(files, m)
+				continue
+			}
+			dirFiles, err := htmlFilesIn(m)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no HTML files to convert")
+	}
+	return files, nil
+}
+
+// htmlFilesIn returns the HTML files of dir as its manifest.txt lists them,
+// or if it has none, all of them, in its subdirectories too, sorted by path.
+func htmlFilesIn(dir string) ([]string, error) {
+	manifest := filepath.Join(dir, dirManifestName)
+	if _, err := os.Stat(manifest); err == nil {
+		names, err := LoadReadingOrder(manifest) // The same one-per-line format
+		if err != nil {
+			return nil, err
+		}
+		files := make([]string, len(names))
+		for i, name := range names {
+			files[i] = filepath.Join(dir, filepath.FromSlash(name))
+		}
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isHTMLFile(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("directory '%s' contains no HTML files", dir)
+	}
+	return files, nil
+}
+
+// isHTMLFile reports whether name has an HTML file extension.
+func isHTMLFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	}
+	return false
+}
+
+// loadLocal reads the local HTML files paths name, each becoming a source.
+// Relative images and stylesheets are read from beside them; absolute ones
+// still come from the network. Images are put in store.
+func loadLocal(paths []string, store MediaStore) ([]*source, error) {
+	files, err := localFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]*source, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", file, err)
+		}
+		src, err := localSource(file, data, store)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// loadMarkdown reads the local Markdown file as a source, rendered as HTML,
+// whose chapters start at its outermost headings.
+func loadMarkdown(file string, store MediaStore) (*source, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", file, err)
+	}
+	page := "<html><body>\n" + renderMarkdown(string(data)) + "</body></html>"
+	src, err := localSource(file, []byte(page), store)
+	if err != nil {
+		return nil, err
+	}
+	src.chapterHeading = outermostHeading(src.doc)
+	return src, nil
+}
+
+// localSource parses the HTML data of the local file into a source that
+// loads relative images and stylesheets from beside it.
+func localSource(file string, data []byte, store MediaStore) (*source, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // A Windows drive letter
+	}
+	src, err := fileSource(file, data, &url.URL{Scheme: "file", Path: p})
+	if err != nil {
+		return nil, err
+	}
+	src.fetch = func(ctx context.Context, u *url.URL) ([]byte, error) {
+		if u.Scheme != "file" {
+			return fetchHTML(ctx, u.String(), "")
+		}
+		return os.ReadFile(filepath.FromSlash(u.Path))
+	}
+	src.loadImage = func(ctx context.Context, u *url.URL) (string, error) {
+		if u.Scheme != "file" {
+			return fetchImage(ctx, u, store)
+		}
+		data, err := src.fetch(ctx, u)
+		if err != nil {
+			return "", err
+		}
+		return store.Put(localImageFilename(u), data)
+	}
+	return src, nil
+}
+
+// outermostHeading returns the highest level of heading in doc, e.g. h1, or
+// "" if it has none.
+func outermostHeading(doc *html.Node) string {
+	tag := ""
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if isHeading(n) && (tag == "" || n.Data < tag) {
+			tag = n.Data
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return tag
+}
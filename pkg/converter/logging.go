@@ -0,0 +1,28 @@
+package converter
+
+import (
+	"context"
+	"log/slog"
+)
+
+// The parts of the pipeline that log, each naming itself in the component
+// attribute of its records.
+var (
+	fetcherLog = componentLogger("fetcher") // Fetching pages and stylesheets, caching and retrying requests
+	parserLog  = componentLogger("parser")  // Parsing pages and splitting them into sections
+	imagesLog  = componentLogger("images")  // Downloading, converting and embedding images
+	writerLog  = componentLogger("writer")  // Assembling and writing the EPUB and the files beside it
+)
+
+// componentLogger logs through slog's default logger at the time of each
+// call, so the records of a build follow whatever the program set up.
+type componentLogger string
+
+func (c componentLogger) log(level slog.Level, msg string, args ...any) {
+	slog.Default().With("component", string(c)).Log(context.Background(), level, msg, args...)
+}
+
+func (c componentLogger) Debug(msg string, args ...any) { c.log(slog.LevelDebug, msg, args...) }
+func (c componentLogger) Info(msg string, args ...any)  { c.log(slog.LevelInfo, msg, args...) }
+func (c componentLogger) Warn(msg string, args ...any)  { c.log(slog.LevelWarn, msg, args...) }
+func (c componentLogger) Error(msg string, args ...any) { c.log(slog.LevelError, msg, args...) }
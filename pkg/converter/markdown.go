@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// The Markdown syntax renderMarkdown understands: the common block and
+// inline elements, without nested lists, tables or reference links.
+var (
+	mdHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	mdFence     = regexp.MustCompile("^ {0,3}(```|~~~)")
+	mdListItem  = regexp.MustCompile(`^ {0,3}([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	mdQuote     = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	mdSetext    = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	mdEscape    = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!>])")
+	mdImage     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+&#34;.*?&#34;)?\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+&#34;.*?&#34;)?\)`)
+	mdAutolink  = regexp.MustCompile(`&lt;(https?://[^\s&]+)&gt;`)
+	mdStrong    = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdEmphasis  = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|\b_(\S(?:.*?\S)?)_\b`)
+	mdHardBreak = regexp.MustCompile(`(?:  +|\\)\n`)
+	mdCodeSpan  = regexp.MustCompile("(`+)(.+?)`+")
+)
+
+// mdEscapeBase is where backslash-escaped characters are hidden, in the
+// private use area, while inline markup is matched.
+const mdEscapeBase = '\uE000'
+
+// renderMarkdown renders the Markdown document md as HTML body content.
+func renderMarkdown(md string) string {
+	var b strings.Builder
+	renderMarkdownBlocks(&b, strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n"))
+	return b.String()
+}
+
+// renderMarkdownBlocks renders lines of Markdown as block elements to b.
+func renderMarkdownBlocks(b *strings.Builder, lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + renderMarkdownInline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case len(para) > 0 && mdSetext.MatchString(line):
+			tag := "h2"
+			if strings.Contains(line, "=") {
+				tag = "h1"
+			}
+			b.WriteString("<" + tag + ">" + renderMarkdownInline(strings.Join(para, "\n")) + "</" + tag + ">\n")
+			para = nil
+		case mdFence.MatchString(line):
+			flush()
+			fence := mdFence.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case mdHeading.MatchString(line):
+			flush()
+			m := mdHeading.FindStringSubmatch(line)
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">" + renderMarkdownInline(m[2]) + "</" + tag + ">\n")
+		case isMarkdownRule(line):
+			flush()
+			b.WriteString("<hr/>\n")
+		case mdQuote.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && mdQuote.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuote.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			b.WriteString("<blockquote>\n")
+			renderMarkdownBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case mdListItem.MatchString(line):
+			flush()
+			tag := "ul"
+			if m := mdListItem.FindStringSubmatch(line); m[1][0] >= '0' && m[1][0] <= '9' {
+				tag = "ol"
+			}
+			var items []string
+			for ; i < len(lines); i++ {
+				if m := mdListItem.FindStringSubmatch(lines[i]); m != nil {
+					items = append(items, m[2])
+				} else if strings.TrimSpace(lines[i]) != "" && !mdHeading.MatchString(lines[i]) && !isMarkdownRule(lines[i]) {
+					items[len(items)-1] += "\n" + strings.TrimSpace(lines[i]) // A continuation line
+				} else {
+					break
+				}
+			}
+			i--
+			b.WriteString("<" + tag + ">\n")
+			for _, item := range items {
+				b.WriteString("<li>" + renderMarkdownInline(item) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+		case len(para) == 0 && strings.HasPrefix(line, "    "):
+			var code []string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			i--
+			b.WriteString("<pre><code>" + html.EscapeString(strings.TrimRight(strings.Join(code, "\n"), "\n")) + "</code></pre>\n")
+		default:
+			para = append(para, strings.TrimLeft(line, " \t")) // Trailing spaces may be a line break
+		}
+	}
+	flush()
+}
+
+// isMarkdownRule reports whether line is a thematic break: three or more
+// of the same -, * or _, optionally spaced out.
+func isMarkdownRule(line string) bool {
+	s := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	return len(s) >= 3 && strings.Trim(s, s[:1]) == "" && strings.Contains("-*_", s[:1])
+}
+
+// renderMarkdownInline renders the inline Markdown of a paragraph, heading
+// or list item as HTML.
+func renderMarkdownInline(s string) string {
+	s = mdEscape.ReplaceAllStringFunc(s, func(m string) string {
+		return string(mdEscapeBase + rune(m[1]))
+	})
+	var b strings.Builder
+	last := 0
+	for _, loc := range mdCodeSpan.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(renderMarkdownText(s[last:loc[0]]))
+		b.WriteString("<code>" + html.EscapeString(strings.TrimSpace(s[loc[4]:loc[5]])) + "</code>")
+		last = loc[1]
+	}
+	b.WriteString(renderMarkdownText(s[last:]))
+	return strings.Map(func(r rune) rune {
+		if r >= mdEscapeBase && r < mdEscapeBase+128 {
+			return r - mdEscapeBase
+		}
+		return r
+	}, b.String())
+}
+
+// renderMarkdownText renders inline Markdown with no code spans in it.
+func renderMarkdownText(s string) string {
+	s = html.EscapeString(s)
+	s = mdImage.ReplaceAllString(s, `<img src="$2" alt="$1"/>`)
+	s = mdLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = mdAutolink.ReplaceAllString(s, `<a href="$1">$1</a>`)
+	s = mdStrong.ReplaceAllString(s, `<strong>$1$2</strong>`)
+	s = mdEmphasis.ReplaceAllString(s, `<em>$1$2</em>`)
+	return mdHardBreak.ReplaceAllString(s, "<br/>\n")
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SectionMarker selects elements that start a new section, for sources that
+// wrap chapters in e.g. <div class="chapter"> rather than using headings.
+type SectionMarker struct {
+	Tag   string // Element name; any element if empty
+	Class string // Class the element must have; any if empty
+}
+
+// ParseSectionMarker parses a -section-marker value of the form tag, .class
+// or tag.class.
+func ParseSectionMarker(s string) (SectionMarker, error) {
+	tag, class, _ := strings.Cut(strings.TrimSpace(s), ".")
+	m := SectionMarker{Tag: strings.ToLower(tag), Class: class}
+	if m == (SectionMarker{}) || strings.ContainsAny(s, " #[>") {
+		return SectionMarker{}, fmt.Errorf("invalid section marker '%s' (want tag, .class or tag.class)", s)
+	}
+	return m, nil
+}
+
+// matches reports whether n is a section marker element.
+func (m SectionMarker) matches(n *html.Node) bool {
+	if m == (SectionMarker{}) || n.Type != html.ElementNode {
+		return false
+	}
+	if m.Tag != "" && n.Data != m.Tag {
+		return false
+	}
+	return m.Class == "" || hasToken(getAttr(n, "class"), m.Class)
+}
+
+// markerTitle returns the label of the section started by marker element n:
+// the text of a heading it opens with, its title attribute, or else its
+// class made readable, so <div class="chapter-intro"> is "Chapter Intro".
+func (x *extractor) markerTitle(n *html.Node) string {
+	if h := leadingHeading(n); h != nil {
+		if title := x.headingTitle(h); title != "" {
+			return title
+		}
+	}
+	if title := strings.TrimSpace(getAttr(n, "title")); title != "" {
+		return title
+	}
+	class := x.opts.SectionMarker.Class
+	if class == "" {
+		class, _, _ = strings.Cut(strings.TrimSpace(getAttr(n, "class")), " ")
+	}
+	return classTitle(class)
+}
+
+// classTitle turns a class name such as "chapter-intro" or "back_matter"
+// into a title, "Chapter Intro" or "Back Matter".
+func classTitle(class string) string {
+	words := strings.FieldsFunc(class, func(r rune) bool { return r == '-' || r == '_' })
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// leadingHeading returns the first heading inside n if no text comes before
+// it, or nil.
+func leadingHeading(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			if strings.TrimSpace(c.Data) != "" {
+				return nil
+			}
+		case html.ElementNode:
+			switch c.Data {
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				return c
+			}
+			if h := leadingHeading(c); h != nil {
+				return h
+			}
+			if strings.TrimSpace(getText(c)) != "" {
+				return nil
+			}
+		}
+	}
+	return nil
+}
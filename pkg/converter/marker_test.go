@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSectionMarker(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SectionMarker
+		wantErr bool
+	}{
+		{"div.chapter", SectionMarker{Tag: "div", Class: "chapter"}, false},
+		{"SECTION", SectionMarker{Tag: "section"}, false},
+		{".chapter-intro", SectionMarker{Class: "chapter-intro"}, false},
+		{"", SectionMarker{}, true},
+		{"div > p", SectionMarker{}, true},
+		{"div#main", SectionMarker{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSectionMarker(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSectionMarker(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSectionMarkerTitles(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker string
+		page   string
+		want   []string
+	}{
+		{
+			name:   "chapter divs split by their headings",
+			marker: "div.chapter",
+			page:   `<div class="chapter"><h4>The Start</h4><p>One.</p></div><div class="chapter"><h4>The End</h4><p>Two.</p></div>`,
+			want:   []string{"0:The Start", "0:The End"},
+		},
+		{
+			name:   "title attribute",
+			marker: "div.chapter",
+			page:   `<div class="chapter" title="Prologue"><p>One.</p></div>`,
+			want:   []string{"0:Prologue"},
+		},
+		{
+			name:   "marker class",
+			marker: ".chapter-intro",
+			page:   `<div class="wide chapter-intro"><p>One.</p></div>`,
+			want:   []string{"0:Chapter Intro"},
+		},
+		{
+			name:   "element class",
+			marker: "section",
+			page:   `<section class="back_matter extra"><p>One.</p></section>`,
+			want:   []string{"0:Back Matter"},
+		},
+		{
+			name:   "no class",
+			marker: "section",
+			page:   `<section><p>One.</p></section>`,
+			want:   []string{"0:Unnamed Section"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marker, err := ParseSectionMarker(tt.marker)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := sectionOutline(testSections(t, tt.page, Options{SectionMarker: marker}))
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("sections = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassTitle(t *testing.T) {
+	tests := map[string]string{
+		"chapter-intro": "Chapter Intro",
+		"back_matter":   "Back Matter",
+		"CHAPTER":       "Chapter",
+		"":              "",
+	}
+	for in, want := range tests {
+		if got := classTitle(in); got != want {
+			t.Errorf("classTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Images, stylesheets and covers are passed around as media locations,
+// returned by the build's MediaStore. The built-in stores use locations
+// go-epub can read directly: the path of a local file or, in memory mode, a
+// data URL that also carries the file's name.
+
+// MediaStore keeps the images, stylesheets and covers fetched or made during
+// a build until they are embedded in the EPUB, e.g. in object storage. Its
+// locations are opaque to the build, except that the base name of a
+// location should be the name it was stored under. Sections are prepared
+// concurrently, so a store must be safe for concurrent use.
+type MediaStore interface {
+	// Put stores data, which belongs in a file called name, and returns
+	// its location.
+	Put(name string, data []byte) (loc string, err error)
+	// Get returns the contents of the media at loc.
+	Get(loc string) ([]byte, error)
+}
+
+// dirStore keeps media as files in a directory; its locations are paths.
+// Each file goes in a subdirectory named after a hash of its contents, so
+// files of the same name, such as two map.png images from different
+// directories of a site, don't overwrite each other.
+type dirStore string
+
+func (d dirStore) Put(name string, data []byte) (string, error) {
+	filePath := filepath.Join(string(d), shortHash(data), name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory '%s': %w", filepath.Dir(filePath), err)
+	}
+	if err := writeFileAtomic(filePath, data); err != nil { // Readers never see it half-written
+		return "", fmt.Errorf("failed to save '%s': %w", filePath, err)
+	}
+	return filePath, nil
+}
+
+func (d dirStore) Get(loc string) ([]byte, error) {
+	return readMedia(loc)
+}
+
+// shortHash returns a short hex hash of data, for telling apart files with
+// the same name.
+func shortHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// memoryStore keeps media as data URLs, which are their own locations.
+type memoryStore struct{}
+
+func (memoryStore) Put(name string, data []byte) (string, error) {
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return "data:" + mediaType + ";name=" + url.PathEscape(name) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (memoryStore) Get(loc string) ([]byte, error) {
+	return readMedia(loc)
+}
+
+// mediaStore returns the store the build described by opts keeps media in.
+func (opts Options) mediaStore() MediaStore {
+	switch {
+	case opts.MediaStore != nil:
+		return opts.MediaStore
+	case opts.InMemory:
+		return memoryStore{}
+	}
+	return dirStore(opts.ImageDir)
+}
+
+// isBuiltinStore reports whether store's locations are local paths or data
+// URLs, which go-epub and readMedia can read without it.
+func isBuiltinStore(store MediaStore) bool {
+	switch store.(type) {
+	case dirStore, memoryStore:
+		return true
+	}
+	return false
+}
+
+// epubSource returns a source go-epub can embed the media at loc from: the
+// location itself for the built-in stores, otherwise a data URL of its
+// contents.
+func epubSource(store MediaStore, loc string) (string, error) {
+	if isBuiltinStore(store) {
+		return loc, nil
+	}
+	data, err := store.Get(loc)
+	if err != nil {
+		return "", fmt.Errorf("failed to read '%s': %w", loc, err)
+	}
+	return memoryStore{}.Put(mediaName(loc), data)
+}
+
+// isDataURL reports whether loc is an in-memory media location.
+func isDataURL(loc string) bool {
+	return strings.HasPrefix(loc, "data:")
+}
+
+// putDataImage puts the image the data URL u holds into store, named after
+// a hash of its contents, and returns its location.
+func putDataImage(u *url.URL, store MediaStore) (string, error) {
+	data, err := readMedia(u.String())
+	if err != nil {
+		return "", fmt.Errorf("bad data URL image: %w", err)
+	}
+	ext := ".img"
+	if format := sniffImageFormat(data); format != "" {
+		ext = imageFormatExt(format)
+	} else if strings.HasPrefix(u.Opaque, "image/svg+xml") {
+		ext = ".svg"
+	}
+	return store.Put("image-"+shortHash(data)+ext, data)
+}
+
+// readMedia returns the contents of a local file or data URL.
+func readMedia(loc string) ([]byte, error) {
+	if !isDataURL(loc) {
+		return os.ReadFile(loc)
+	}
+	header, payload, ok := strings.Cut(loc, ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed data URL for '%s'", mediaName(loc))
+	}
+	if strings.HasSuffix(header, ";base64") {
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	s, err := url.PathUnescape(payload)
+	return []byte(s), err
+}
+
+// mediaName returns the file name of the media at loc.
+func mediaName(loc string) string {
+	if !isDataURL(loc) {
+		return filepath.Base(loc)
+	}
+	header, _, _ := strings.Cut(loc, ",")
+	for _, param := range strings.Split(header, ";")[1:] {
+		if v, ok := strings.CutPrefix(param, "name="); ok {
+			if name, err := url.PathUnescape(v); err == nil {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// epubMediaName returns the name to embed the media at loc under, or "" to
+// let go-epub name it after the file.
+func epubMediaName(loc string) string {
+	if isDataURL(loc) {
+		return mediaName(loc) // go-epub would take a name from the encoded data
+	}
+	return ""
+}
+
+// fetchImage downloads the image at u into store and returns its location.
+// A directory store reuses an earlier download of the same image. Images
+// inlined as data URLs are decoded instead.
+func fetchImage(ctx context.Context, u *url.URL, store MediaStore) (string, error) {
+	if u.Scheme == "data" {
+		return putDataImage(u, store)
+	}
+	if dir, ok := store.(dirStore); ok {
+		return fetchOrLoadImage(ctx, u.String(), string(dir))
+	}
+	data, contentType, err := fetchBytes(ctx, u.String())
+	if err != nil {
+		return "", err
+	}
+	if err := checkImageType(contentType); err != nil {
+		return "", fmt.Errorf("bad image '%s': %w", u, err)
+	}
+	return store.Put(localImageFilename(u), data)
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mapStore is a MediaStore that keeps media in a map, as an object store
+// would, recording what passes through it.
+type mapStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	gets  map[string]int
+}
+
+func (s *mapStore) Put(name string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc := fmt.Sprintf("store://%d/%s", len(s.files), name)
+	s.files[loc] = data
+	return loc, nil
+}
+
+func (s *mapStore) Get(loc string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[loc]
+	if !ok {
+		return nil, fmt.Errorf("no media at %s", loc)
+	}
+	s.gets[loc]++
+	return data, nil
+}
+
+func TestMediaStore(t *testing.T) {
+	photo, bg := testPNG(t, 4, 4, color.Black), testPNG(t, 2, 2, color.White)
+	srv := fileServer(t, map[string]servedFile{
+		"/photo.png": {"image/png", photo},
+		"/bg.png":    {"image/png", bg},
+		"/style.css": {"text/css", []byte(`body { background: url(bg.png) }`)},
+	})
+	page := `<html><head><title>Page</title><link rel="stylesheet" href="style.css"></head>` +
+		`<body><h3>One</h3><p>Text.</p><img src="photo.png" alt="Photo"></body></html>`
+	store := &mapStore{files: make(map[string][]byte), gets: make(map[string]int)}
+	imageDir := filepath.Join(t.TempDir(), "images")
+	result, files := testBuild(t, page, Options{SourceURL: srv.URL + "/page.html", EmbedCSS: true, MediaStore: store, ImageDir: imageDir})
+
+	for name, data := range map[string][]byte{"photo.png": photo, "bg.png": bg} {
+		var loc string
+		for l, d := range store.files {
+			if strings.HasSuffix(l, "/"+name) && string(d) == string(data) {
+				loc = l
+			}
+		}
+		if loc == "" {
+			t.Errorf("%s not put in the store", name)
+			continue
+		}
+		if store.gets[loc] == 0 {
+			t.Errorf("%s never read back from the store", name)
+		}
+		if files["EPUB/images/"+name] != string(data) {
+			t.Errorf("EPUB lacks images/%s from the store", name)
+		}
+	}
+	if !strings.Contains(sectionFile(t, result, files, "One"), `src="../images/photo.png"`) {
+		t.Error("section doesn't point at the embedded photo")
+	}
+	if _, err := os.Stat(imageDir); err == nil {
+		t.Errorf("media written to %s despite the store", imageDir)
+	}
+}
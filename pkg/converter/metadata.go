@@ -0,0 +1,261 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-epub"
+	"golang.org/x/net/html"
+)
+
+// Metadata describes the book-level metadata written to the EPUB.
+// It doubles as the schema of the -meta sidecar JSON file.
+type Metadata struct {
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Language    string    `json:"language"`
+	Identifiers []string  `json:"identifiers"`
+	Series      string    `json:"series"`
+	SeriesIndex int       `json:"series_index"` // Position in the series, if set
+	Description string    `json:"description"`
+	Subjects    []string  `json:"subjects"`
+	Publisher   string    `json:"publisher"`
+	Date        string    `json:"date"`     // Publication date, YYYY, YYYY-MM or YYYY-MM-DD
+	Creators    []Creator `json:"creators"` // Creators besides Author, such as a translator
+}
+
+// Creator is a person who contributed to the book in some role.
+type Creator struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // MARC relator code, e.g. "trl"; "aut" if empty
+}
+
+// creatorRoles maps the common roles' names to their MARC relator codes.
+var creatorRoles = map[string]string{
+	"author":      "aut",
+	"translator":  "trl",
+	"illustrator": "ill",
+	"editor":      "edt",
+}
+
+// ParseCreatorRole returns the MARC relator code for role, which may be a
+// code or one of the names in creatorRoles. An empty role means author.
+func ParseCreatorRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return "aut", nil
+	}
+	if code, ok := creatorRoles[role]; ok {
+		return code, nil
+	}
+	if len(role) == 3 && !strings.ContainsFunc(role, func(r rune) bool { return r < 'a' || r > 'z' }) {
+		return role, nil
+	}
+	return "", fmt.Errorf("invalid creator role '%s' (want a MARC relator code such as aut or trl, or author, translator, illustrator or editor)", role)
+}
+
+// ParseCreator parses a -creator value, "Name" or "Name:role".
+func ParseCreator(s string) (Creator, error) {
+	name, role := s, ""
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		name, role = s[:i], s[i+1:]
+	}
+	c := Creator{Name: strings.TrimSpace(name)}
+	if c.Name == "" {
+		return Creator{}, fmt.Errorf("invalid creator '%s' (want Name or Name:role)", s)
+	}
+	var err error
+	if c.Role, err = ParseCreatorRole(role); err != nil {
+		return Creator{}, err
+	}
+	return c, nil
+}
+
+// LoadMetadata reads a sidecar metadata JSON file.
+func LoadMetadata(filePath string) (Metadata, error) {
+	var m Metadata
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return m, fmt.Errorf("failed to read metadata file '%s': %w", filePath, err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse metadata file '%s': %w", filePath, err)
+	}
+	if err := m.normalize(); err != nil {
+		return m, fmt.Errorf("failed to parse metadata file '%s': %w", filePath, err)
+	}
+	return m, nil
+}
+
+// normalize checks the creator roles, identifiers and date of m as read
+// from a file, putting them in the form the package document wants.
+func (m *Metadata) normalize() error {
+	var err error
+	for i, c := range m.Creators {
+		if m.Creators[i].Role, err = ParseCreatorRole(c.Role); err != nil {
+			return err
+		}
+	}
+	for i, id := range m.Identifiers {
+		if m.Identifiers[i], err = ParseIdentifier(id); err != nil {
+			return err
+		}
+	}
+	if m.Date != "" {
+		if m.Date, err = ParseDate(m.Date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge returns m with every field that is set in override replacing its
+// counterpart. Layering detected values, the sidecar and explicit flags in that
+// order gives flags the final say.
+func (m Metadata) Merge(override Metadata) Metadata {
+	if override.Title != "" {
+		m.Title = override.Title
+	}
+	if override.Author != "" {
+		m.Author = override.Author
+	}
+	if override.Language != "" {
+		m.Language = override.Language
+	}
+	if len(override.Identifiers) > 0 {
+		m.Identifiers = override.Identifiers
+	}
+	if override.Series != "" {
+		m.Series = override.Series
+	}
+	if override.SeriesIndex != 0 {
+		m.SeriesIndex = override.SeriesIndex
+	}
+	if override.Description != "" {
+		m.Description = override.Description
+	}
+	if len(override.Subjects) > 0 {
+		m.Subjects = override.Subjects
+	}
+	if len(override.Creators) > 0 {
+		m.Creators = override.Creators
+	}
+	if override.Publisher != "" {
+		m.Publisher = override.Publisher
+	}
+	if override.Date != "" {
+		m.Date = override.Date
+	}
+	return m
+}
+
+// apply sets the metadata go-epub has setters for. The first identifier
+// becomes the unique publication identifier.
+func (m Metadata) apply(e *epub.Epub) {
+	if m.Title != "" {
+		e.SetTitle(m.Title)
+	}
+	if m.Author != "" {
+		e.SetAuthor(m.Author)
+	}
+	if m.Language != "" {
+		e.SetLang(m.Language)
+	}
+	if len(m.Identifiers) > 0 {
+		e.SetIdentifier(m.Identifiers[0])
+	}
+	if m.Description != "" {
+		e.SetDescription(m.Description)
+	}
+}
+
+// opfElements returns package document metadata elements for the fields
+// go-epub cannot set itself: extra identifiers, series, subjects, publisher,
+// date and creators besides the author. Creators' roles are written as role
+// properties refining them, the EPUB 3 form of opf:role.
+func (m Metadata) opfElements() []string {
+	var elements []string
+	for i, id := range m.Identifiers {
+		if i == 0 {
+			continue // Written by go-epub as the unique identifier
+		}
+		elements = append(elements, fmt.Sprintf(`<dc:identifier id="id-%d">%s</dc:identifier>`, i+1, html.EscapeString(id)))
+	}
+	if m.Series != "" {
+		elements = append(elements,
+			fmt.Sprintf(`<meta property="belongs-to-collection" id="series">%s</meta>`, html.EscapeString(m.Series)),
+			`<meta refines="#series" property="collection-type">series</meta>`)
+		if m.SeriesIndex > 0 {
+			elements = append(elements, fmt.Sprintf(`<meta refines="#series" property="group-position">%d</meta>`, m.SeriesIndex))
+		}
+	}
+	for _, subject := range m.Subjects {
+		elements = append(elements, fmt.Sprintf(`<dc:subject>%s</dc:subject>`, html.EscapeString(subject)))
+	}
+	if m.Publisher != "" {
+		elements = append(elements, fmt.Sprintf(`<dc:publisher>%s</dc:publisher>`, html.EscapeString(m.Publisher)))
+	}
+	if m.Date != "" {
+		elements = append(elements, fmt.Sprintf(`<dc:date>%s</dc:date>`, html.EscapeString(m.Date)))
+	}
+	for i, c := range m.Creators {
+		role := c.Role
+		if role == "" {
+			role = "aut"
+		}
+		if c.Name == m.Author && role == "aut" {
+			continue // Written by go-epub
+		}
+		id := fmt.Sprintf("creator-%d", i+2)
+		elements = append(elements,
+			fmt.Sprintf(`<dc:creator id="%s">%s</dc:creator>`, id, html.EscapeString(c.Name)),
+			fmt.Sprintf(`<meta refines="#%s" property="role" scheme="marc:relators">%s</meta>`, id, html.EscapeString(role)))
+	}
+	return elements
+}
+
+// isbnPattern matches an ISBN-10 or ISBN-13, with or without hyphens or
+// spaces.
+var isbnPattern = regexp.MustCompile(`^(?:97[89][- ]?)?(?:[0-9][- ]?){9}[0-9Xx]$`)
+
+// uuidPattern matches a UUID in its canonical form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseIdentifier parses a book identifier. A bare ISBN or UUID becomes the
+// urn:isbn: or urn:uuid: URN readers recognise; anything else, such as a URL
+// or a URN already, is kept as it is.
+func ParseIdentifier(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return "", fmt.Errorf("invalid identifier '' (want an ISBN, UUID or URN)")
+	case isbnPattern.MatchString(s):
+		digits := strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(s))
+		if len(digits) != 10 && len(digits) != 13 {
+			return "", fmt.Errorf("invalid ISBN '%s' (want 10 or 13 digits)", s)
+		}
+		return "urn:isbn:" + digits, nil
+	case uuidPattern.MatchString(s):
+		return "urn:uuid:" + strings.ToLower(s), nil
+	}
+	return s, nil
+}
+
+// dateLayouts are the forms of publication date accepted, from the W3C
+// date formats EPUB uses.
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006", time.RFC3339}
+
+// ParseDate checks that s is a publication date in one of dateLayouts.
+func ParseDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("invalid date '%s' (want YYYY, YYYY-MM or YYYY-MM-DD)", s)
+}
@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCreators(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>Text.</p></body></html>`
+	translator, err := ParseCreator("Bea Translator:translator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	illustrator, err := ParseCreator("Cy Artist:ill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "book.epub")
+	_, files := testBuild(t, page, Options{OutputPath: out, Metadata: Metadata{
+		Title:    "Translated",
+		Author:   "Ann Writer",
+		Creators: []Creator{{Name: "Ann Writer"}, translator, illustrator},
+	}})
+	opf := files[packageDocumentPath]
+
+	roles := make(map[string]string) // Role by creator name
+	creators := regexp.MustCompile(`<dc:creator id="([^"]+)"[^>]*>([^<]+)</dc:creator>`).FindAllStringSubmatch(opf, -1)
+	for _, c := range creators {
+		role := regexp.MustCompile(`<meta refines="#` + c[1] + `" property="role" scheme="marc:relators"[^>]*>([^<]+)</meta>`).FindStringSubmatch(opf)
+		if role == nil {
+			t.Errorf("creator %s has no role:\n%s", c[2], opf)
+			continue
+		}
+		if _, dup := roles[c[2]]; dup {
+			t.Errorf("%s is a creator twice:\n%s", c[2], opf)
+		}
+		roles[c[2]] = role[1]
+	}
+	want := map[string]string{"Ann Writer": "aut", "Bea Translator": "trl", "Cy Artist": "ill"}
+	if len(roles) != len(want) {
+		t.Errorf("creators = %v, want %v:\n%s", roles, want, opf)
+	}
+	for name, role := range want {
+		if roles[name] != role {
+			t.Errorf("%s has role %q, want %q", name, roles[name], role)
+		}
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := ValidateEPUB(data); err != nil || len(problems) > 0 {
+		t.Errorf("ValidateEPUB = %v, %v", problems, err)
+	}
+}
+
+func TestParseCreator(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Creator
+		wantErr bool
+	}{
+		{in: "Ann Writer", want: Creator{Name: "Ann Writer", Role: "aut"}},
+		{in: "Bea Translator:Translator", want: Creator{Name: "Bea Translator", Role: "trl"}},
+		{in: " Ed Itor : edt ", want: Creator{Name: "Ed Itor", Role: "edt"}},
+		{in: "Dr. No: A Life:editor", want: Creator{Name: "Dr. No: A Life", Role: "edt"}},
+		{in: ":trl", wantErr: true},
+		{in: "Ann Writer:ghostwriter", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCreator(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCreator(%q) = %+v, %v, want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("ParseCreator(%q) error %q doesn't say what's invalid", tt.in, err)
+		}
+	}
+}
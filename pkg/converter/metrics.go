@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Metrics records how long each phase of a build took, for profiling.
+type Metrics struct {
+	Fetch   time.Duration // Fetching or reading the sources, following meta refreshes
+	Parse   time.Duration // Parsing the sources as HTML
+	Extract time.Duration // Splitting the sources into sections and adding them, not counting images
+	Images  time.Duration // Downloading, resizing and embedding images, the cover included
+	Write   time.Duration // Writing the EPUB and any other requested files
+	Total   time.Duration
+}
+
+// MarshalJSON encodes m with each phase in milliseconds, as in
+// {"fetch_ms": 120.5, ...}.
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(struct {
+		Fetch   float64 `json:"fetch_ms"`
+		Parse   float64 `json:"parse_ms"`
+		Extract float64 `json:"extract_ms"`
+		Images  float64 `json:"images_ms"`
+		Write   float64 `json:"write_ms"`
+		Total   float64 `json:"total_ms"`
+	}{ms(m.Fetch), ms(m.Parse), ms(m.Extract), ms(m.Images), ms(m.Write), ms(m.Total)})
+}
+
+// timeImages adds the time since start to the build's image time; call it
+// deferred with time.Now() as start.
+func (x *extractor) timeImages(start time.Time) {
+	x.result.summary.Metrics.Images += time.Since(start)
+}
+
+// WriteMetrics writes m as JSON to filePath, or to stderr if filePath is "-".
+func WriteMetrics(filePath string, m Metrics) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build metrics: %w", err)
+	}
+	data = append(data, '\n')
+	if filePath == "-" {
+		_, err = os.Stderr.Write(data)
+	} else {
+		err = os.WriteFile(filePath, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write build metrics to '%s': %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMetrics(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/book.html": {"text/html", []byte(`<h3>One</h3><p>Text.</p><img src="pic.png" alt="Picture"><h3>Two</h3><p>More.</p>`)},
+		"/pic.png":   {"image/png", testPNG(t, 4, 4, color.White)},
+	})
+	result, _ := testBuild(t, "", Options{SourceURL: srv.URL + "/book.html"})
+	m := result.Summary().Metrics
+
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"fetch", m.Fetch}, {"parse", m.Parse}, {"extract", m.Extract}, {"images", m.Images}, {"write", m.Write},
+	}
+	var sum time.Duration
+	for _, p := range phases {
+		if p.d <= 0 {
+			t.Errorf("%s took %s, want it recorded", p.name, p.d)
+		}
+		sum += p.d
+	}
+	if m.Total < sum {
+		t.Errorf("total %s is less than the phases' %s", m.Total, sum)
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err := WriteMetrics(path, m); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]float64
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("metrics file isn't JSON: %v", err)
+	}
+	var keys []string
+	for k, v := range written {
+		keys = append(keys, k)
+		if v < 0 {
+			t.Errorf("%s = %v, want non-negative", k, v)
+		}
+	}
+	sort.Strings(keys)
+	if got, want := strings.Join(keys, ","), "extract_ms,fetch_ms,images_ms,parse_ms,total_ms,write_ms"; got != want {
+		t.Errorf("metrics keys = %s, want %s", got, want)
+	}
+}
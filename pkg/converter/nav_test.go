@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNavTitle(t *testing.T) {
+	const page = `<html><head><title>Page</title></head><body><h3>One</h3><p>Text.</p></body></html>`
+	tests := []struct {
+		name     string
+		navTitle string
+		want     string
+	}{
+		{"default", "", "<h1>Table of Contents</h1>"},
+		{"set", "Inhalt", "<h1>Inhalt</h1>"},
+		{"escaped", "Contents & Index", "<h1>Contents &amp; Index</h1>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{NavTitle: tt.navTitle, ContentsPage: true})
+			nav := files[navDocumentPath]
+			if !strings.Contains(nav, tt.want) {
+				t.Errorf("navigation document lacks %s:\n%s", tt.want, nav)
+			}
+			if strings.Count(nav, "<h1>") != 1 {
+				t.Errorf("navigation document has %d headings, want 1", strings.Count(nav, "<h1>"))
+			}
+			if tt.navTitle == "" {
+				return
+			}
+			contents := sectionFile(t, result, files, tt.navTitle)
+			if !strings.Contains(contents, tt.want) {
+				t.Errorf("contents page isn't headed %s:\n%s", tt.want, contents)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadReadingOrder reads a reading order file: one section title or source
+// name per line. Blank lines and lines starting with # are ignored.
+func LoadReadingOrder(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reading order file '%s': %w", file, err)
+	}
+	var order []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		order = append(order, line)
+	}
+	return order, nil
+}
+
+// reorderSections puts sections in the given reading order. Each key selects
+// the sections whose title matches it (ignoring case) or that came from the
+// source it names, in their original order. Sections no key selects follow
+// the listed ones, also in their original order.
+func reorderSections(sections []Section, order []string) []Section {
+	if len(order) == 0 {
+		return sections
+	}
+	placed := make([]bool, len(sections))
+	reordered := make([]Section, 0, len(sections))
+	for _, key := range order {
+		found := false
+		for i, s := range sections {
+			if placed[i] || !(strings.EqualFold(s.Title, key) || s.Source == key) {
+				continue
+			}
+			placed[i] = true
+			reordered = append(reordered, s)
+			found = true
+		}
+		if !found {
+			writerLog.Warn("Could not find section from the reading order", "section", key)
+		}
+	}
+	for i, s := range sections {
+		if !placed[i] {
+			reordered = append(reordered, s)
+		}
+	}
+	return reordered
+}
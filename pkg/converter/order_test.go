@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadingOrder(t *testing.T) {
+	const page = `<html><head><title>Page</title></head><body>` +
+		`<h3>Alpha</h3><p>A.</p><h3>Beta</h3><p>B.</p><h3>Gamma</h3><p>C.</p><h3>Delta</h3><p>D.</p></body></html>`
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{"none", nil, []string{"Alpha", "Beta", "Gamma", "Delta"}},
+		{"all listed", []string{"Delta", "gamma", "Beta", "Alpha"}, []string{"Delta", "Gamma", "Beta", "Alpha"}},
+		{"unlisted follow", []string{"Gamma", "Missing"}, []string{"Gamma", "Alpha", "Beta", "Delta"}},
+		{"by source", []string{"Delta", "https://example.com/book/page.html"}, []string{"Delta", "Alpha", "Beta", "Gamma"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{ReadingOrder: tt.order})
+			var titles []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			// The spine lists the section files in the same order
+			opf, last := files[packageDocumentPath], -1
+			for _, s := range result.Summary().Sections {
+				at := strings.Index(opf, `<itemref idref="`+s.Filename+`"`)
+				if at < last {
+					t.Errorf("spine has %q out of order:\n%s", s.Title, opf)
+				}
+				last = at
+			}
+		})
+	}
+}
+
+func TestLoadReadingOrder(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "order.txt")
+	if err := os.WriteFile(file, []byte("# Anthology order\nSecond Story\n\n  First Story  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	order, err := LoadReadingOrder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, "|") != "Second Story|First Story" {
+		t.Errorf("order = %q", order)
+	}
+}
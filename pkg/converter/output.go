@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// writeOutput writes a finished EPUB to filePath through a temporary file
+// renamed into place, so a write that fails, runs out of space or is killed
+// never leaves a truncated EPUB behind for a resumed batch to take as built.
+func writeOutput(filePath string, data []byte) error {
+	if err := writeFileAtomic(filePath, data); err != nil {
+		return writeError(filePath, err)
+	}
+	return nil
+}
+
+// writeError explains a failure to write filePath, with what to do about
+// it for the causes a user can fix.
+func writeError(filePath string, err error) error {
+	dir := filepath.Dir(filePath)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("cannot write '%s': permission denied; check that '%s' is writable by you, or write somewhere else: %w", filePath, dir, err)
+	case errors.Is(err, syscall.EROFS):
+		return fmt.Errorf("cannot write '%s': the file system is read-only; write somewhere else: %w", filePath, err)
+	case errors.Is(err, syscall.ENOSPC):
+		return fmt.Errorf("cannot write '%s': no space left on the device; free some space or write somewhere else: %w", filePath, err)
+	case errors.Is(err, fs.ErrNotExist) && !dirExists(dir):
+		return fmt.Errorf("cannot write '%s': directory '%s' does not exist; create it first: %w", filePath, dir, err)
+	}
+	return fmt.Errorf("error writing EPUB file '%s': %w", filePath, err)
+}
+
+// dirExists reports whether dir is an existing directory.
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
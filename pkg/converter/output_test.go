@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOutputFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, dir string) string // Returns the output path
+		wantErr string
+		root    bool // Whether the case still fails when run as root
+	}{
+		{
+			name:    "missing directory",
+			setup:   func(t *testing.T, dir string) string { return filepath.Join(dir, "missing", "book.epub") },
+			wantErr: "does not exist; create it first",
+			root:    true,
+		},
+		{
+			name: "unwritable directory",
+			setup: func(t *testing.T, dir string) string {
+				out := filepath.Join(dir, "book.epub")
+				if err := os.WriteFile(out, []byte("old book"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(dir, 0555); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(dir, 0755) })
+				return out
+			},
+			wantErr: "permission denied; check that",
+		},
+		{
+			name: "directory in the way",
+			setup: func(t *testing.T, dir string) string {
+				out := filepath.Join(dir, "book.epub")
+				if err := os.MkdirAll(filepath.Join(out, "chapter"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				return out
+			},
+			wantErr: "book.epub",
+			root:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.root && os.Geteuid() == 0 {
+				t.Skip("root can write anywhere")
+			}
+			dir := t.TempDir()
+			out := tt.setup(t, dir)
+			before, _ := os.ReadFile(out)
+
+			err := writeOutput(out, []byte("PK\x03\x04 the new book"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("writeOutput error = %v, want one saying %q", err, tt.wantErr)
+			}
+			if after, _ := os.ReadFile(out); string(after) != string(before) {
+				t.Errorf("destination changed by a failed write: %q, was %q", after, before)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(out))
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), ".") {
+					t.Errorf("failed write left %s behind", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestBuildReportsUnwritableOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "missing", "book.epub")
+	opts := Options{
+		SourceHTML: []byte("<h3>One</h3><p>Text.</p>"),
+		OutputPath: out,
+		ImageDir:   filepath.Join(t.TempDir(), "images"),
+	}
+	_, err := build(context.Background(), opts)
+	if err == nil || !strings.Contains(err.Error(), out) || !strings.Contains(err.Error(), "create it first") {
+		t.Fatalf("build error = %v, want one naming %s and what to do", err, out)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("EPUB written despite the error")
+	}
+}
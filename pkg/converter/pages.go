@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultPageWorkers is how many pages are fetched at once if PageWorkers
+// is zero.
+const DefaultPageWorkers = 4
+
+// pageWorkers returns how many pages the build fetches at once.
+func (opts Options) pageWorkers() int {
+	if opts.PageWorkers > 0 {
+		return opts.PageWorkers
+	}
+	return DefaultPageWorkers
+}
+
+// loadPages fetches and parses the pages at urls on up to PageWorkers
+// goroutines, returning them in the order of urls however the fetches
+// finish. The per-host rate limits still apply, so only pages on different
+// hosts, or hosts without a delay, are fetched side by side. The first page
+// that fails to load stops the rest.
+func loadPages(ctx context.Context, opts Options, urls []string) ([]*source, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sources := make([]*source, len(urls))
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(opts.pageWorkers(), len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// A single cache file can't hold several pages
+				page := opts
+				page.SourceURL, page.SourceHTML, page.HTMLCache = urls[i], nil, ""
+				src, err := loadPage(ctx, page)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("error loading '%s': %w", urls[i], err)
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+				sources[i] = src
+				progressFrom(ctx).step(ProgressPages, len(urls), urls[i])
+			}
+		}()
+	}
+	for i := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
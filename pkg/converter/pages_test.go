@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadPagesKeepsOrder(t *testing.T) {
+	const pages = 6
+	// Each page is only sent once the page after it has been, so the pages
+	// finish last to first, and one at a time they'd never finish at all
+	sent := make([]chan struct{}, pages+2)
+	for i := range sent {
+		sent[i] = make(chan struct{})
+	}
+	close(sent[pages+1])
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/chapter-%d.html", &n); err != nil || n < 1 || n > pages {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case <-sent[n+1]:
+		case <-time.After(5 * time.Second):
+			http.Error(w, "next page never fetched", http.StatusGatewayTimeout)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Chapter %d</title></head><body><p>Text of chapter %d.</p></body></html>`, n, n)
+		close(sent[n])
+	}))
+	defer srv.Close()
+
+	var urls, want []string
+	for i := 1; i <= pages; i++ {
+		urls = append(urls, fmt.Sprintf("%s/chapter-%d.html", srv.URL, i))
+		want = append(want, fmt.Sprintf("Chapter %d", i))
+	}
+	result, files := testBuild(t, "", Options{SourceURLs: urls, PageWorkers: pages})
+	var got []string
+	for _, s := range result.Summary().Sections {
+		got = append(got, s.Title)
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("sections = %q, want %q", got, want)
+	}
+	for i := 1; i <= pages; i++ {
+		if body := sectionFile(t, result, files, fmt.Sprintf("Chapter %d", i)); !strings.Contains(body, fmt.Sprintf("Text of chapter %d.", i)) {
+			t.Errorf("chapter %d lacks its page's text:\n%s", i, body)
+		}
+	}
+}
+
+func TestLoadPagesLimitsWorkers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		most     int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		most = max(most, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>%s</title></head><body><p>Text.</p></body></html>`, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for i := range 8 {
+		urls = append(urls, fmt.Sprintf("%s/page-%d.html", srv.URL, i))
+	}
+	sources, err := loadPages(context.Background(), Options{PageWorkers: 2}, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != len(urls) {
+		t.Fatalf("got %d pages, want %d", len(sources), len(urls))
+	}
+	if most > 2 {
+		t.Errorf("%d pages fetched at once, want at most 2", most)
+	}
+}
+
+func TestLoadPagesError(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/one.html": {"text/html", []byte(`<html><head><title>One</title></head><body><p>Text.</p></body></html>`)},
+	})
+	urls := []string{srv.URL + "/one.html", srv.URL + "/missing.html", srv.URL + "/one.html"}
+	_, err := loadPages(context.Background(), Options{}, urls)
+	if err == nil || !strings.Contains(err.Error(), "/missing.html") {
+		t.Errorf("loadPages error = %v, want one naming the missing page", err)
+	}
+}
@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultImageWorkers is how many images are downloaded at once if
+// ImageWorkers is zero.
+const DefaultImageWorkers = 4
+
+// loadedImage is the outcome of loading an image ahead of the walk.
+type loadedImage struct {
+	loc string
+	err error
+}
+
+// imageWorkers returns how many images the build downloads at once.
+func (opts Options) imageWorkers() int {
+	if opts.ImageWorkers > 0 {
+		return opts.ImageWorkers
+	}
+	return DefaultImageWorkers
+}
+
+// imageURLs returns the distinct http and https images the <img> elements
+// of src refer to, in document order, leaving out those inside skipped
+// elements and those already loaded.
+func imageURLs(src *source, skip map[string]bool, loaded map[string]loadedImage) []*url.URL {
+	var urls []*url.URL
+	seen := make(map[string]bool)
+	for u := range loaded {
+		seen[u] = true
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skip[n.Data] {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "img" {
+			if u, err := src.baseURL.Parse(getAttr(n, "src")); err == nil && (u.Scheme == "http" || u.Scheme == "https") && !seen[u.String()] {
+				seen[u.String()] = true
+				urls = append(urls, u)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(src.doc)
+	return urls
+}
+
+// countImages returns how many images prefetchImages will download for
+// sources, as far as can be told before they are extracted.
+func (x *extractor) countImages(sources []*source) int {
+	seen := make(map[string]loadedImage)
+	for _, src := range sources {
+		for _, u := range imageURLs(src, x.skip, seen) {
+			seen[u.String()] = loadedImage{}
+		}
+	}
+	return len(seen)
+}
+
+// prefetchImages downloads the images of src on up to ImageWorkers
+// goroutines before it is walked, so the walk finds them ready instead of
+// waiting for each download in turn.
+func (x *extractor) prefetchImages(src *source) {
+	defer x.timeImages(time.Now())
+	x.prefetched = nil
+	urls := imageURLs(src, x.skip, x.loaded)
+	if len(urls) == 0 {
+		return
+	}
+
+	loaded := make([]loadedImage, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(x.opts.imageWorkers(), len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				loc, err := src.loadImage(x.ctx, urls[i])
+				loaded[i] = loadedImage{loc: loc, err: err}
+				if err == nil {
+					imagesLog.Debug("Loaded image", "url", urls[i].String(), "file", loc)
+				}
+				progressFrom(x.ctx).step(ProgressImages, x.imageTotal, urls[i].String())
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	x.prefetched = make(map[string]loadedImage, len(urls))
+	for i, u := range urls {
+		x.prefetched[u.String()] = loaded[i]
+		x.loaded[u.String()] = loaded[i]
+	}
+}
+
+// loadImage returns the media location of the image at u in the current
+// source, downloaded ahead of the walk or for an earlier source if it was.
+func (x *extractor) loadImage(ctx context.Context, u *url.URL) (string, error) {
+	if img, ok := x.prefetched[u.String()]; ok {
+		return img.loc, img.err
+	}
+	if img, ok := x.loaded[u.String()]; ok {
+		return img.loc, img.err
+	}
+	loc, err := x.src.loadImage(ctx, u)
+	if ctx.Err() == nil {
+		x.loaded[u.String()] = loadedImage{loc: loc, err: err}
+	}
+	return loc, err
+}
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// preparedSection is a section made ready to add to the EPUB.
+type preparedSection struct {
+	order     int // Position in the spine; preparation finishes in any order
+	section   Section
+	err       error  // If set, the section is left out for this reason
+	thumbnail string // Index thumbnail of its first image, if wanted
+}
+
+// prepareSections does the per-section work that doesn't touch the EPUB,
+// sanitizing sections and making index thumbnails, on opts.Workers
+// goroutines. The results come back in the order of sections, whatever order
+// they were finished in, so they can be added to the spine as they are.
+func prepareSections(sections []Section, opts Options) []preparedSection {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	jobs := make(chan int)
+	results := make(chan preparedSection, len(sections))
+	var wg sync.WaitGroup
+	for range min(workers, len(sections)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- prepareSection(i, sections[i], opts)
+			}
+		}()
+	}
+	for i := range sections {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	prepared := make([]preparedSection, 0, len(sections))
+	for p := range results {
+		prepared = append(prepared, p)
+	}
+	sort.Slice(prepared, func(i, j int) bool { return prepared[i].order < prepared[j].order })
+	return prepared
+}
+
+// prepareSection prepares s, the order'th section.
+func prepareSection(order int, s Section, opts Options) preparedSection {
+	p := preparedSection{order: order, section: s}
+	body, err := sanitizeSection(s.Body)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.section.Body = body
+	if opts.IndexPath != "" && s.firstImage != "" {
+		p.thumbnail = sectionThumbnail(opts.mediaStore(), s)
+	}
+	return p
+}
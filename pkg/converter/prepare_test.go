@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedStore is a MediaStore whose first image can't be read until the
+// others have been read for their thumbnails, so the first section is
+// prepared last.
+type gatedStore struct {
+	images map[string][]byte
+	first  string
+	others sync.WaitGroup // Done as each other image is read for the last time
+	mu     sync.Mutex
+	reads  map[string]int
+}
+
+func (s *gatedStore) Put(name string, data []byte) (string, error) {
+	return "", fmt.Errorf("unexpected Put of %s", name)
+}
+
+func (s *gatedStore) Get(loc string) ([]byte, error) {
+	if loc == s.first {
+		done := make(chan struct{})
+		go func() { s.others.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			return nil, fmt.Errorf("other sections never prepared")
+		}
+	} else {
+		s.mu.Lock()
+		s.reads[loc]++
+		if s.reads[loc] == 2 { // Once to size the thumbnail, once to encode it
+			s.others.Done()
+		}
+		s.mu.Unlock()
+	}
+	return s.images[loc], nil
+}
+
+func TestPrepareSectionsKeepsOrder(t *testing.T) {
+	store := &gatedStore{images: make(map[string][]byte), first: "one.png", reads: make(map[string]int)}
+	colors := []color.Color{color.Black, color.White, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	var sections []Section
+	for i, name := range []string{"one", "two", "three", "four"} {
+		store.images[name+".png"] = testPNG(t, 2, 2, colors[i])
+		sections = append(sections, Section{Title: name, Body: "<p>" + name + "</p>", firstImage: name + ".png"})
+	}
+	store.others.Add(len(sections) - 1)
+
+	prepared := prepareSections(sections, Options{Workers: len(sections), IndexPath: "index.json", MediaStore: store})
+	if len(prepared) != len(sections) {
+		t.Fatalf("got %d prepared sections, want %d", len(prepared), len(sections))
+	}
+	for i, p := range prepared {
+		s := sections[i]
+		if p.err != nil {
+			t.Errorf("section %q: %v", s.Title, p.err)
+		}
+		if p.order != i || p.section.Title != s.Title {
+			t.Errorf("prepared[%d] = section %q at %d, want %q", i, p.section.Title, p.order, s.Title)
+		}
+		if want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(store.images[s.firstImage]); p.thumbnail != want {
+			t.Errorf("section %q has another section's thumbnail", s.Title)
+		}
+	}
+}
+
+func TestSpineFollowsDocumentOrder(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body>`
+	var want []string
+	for i := 1; i <= 20; i++ {
+		title := fmt.Sprintf("Chapter %d", i)
+		want = append(want, title)
+		page += "<h3>" + title + "</h3><p>Text of " + title + ".</p>"
+	}
+	page += `</body></html>`
+	result, files := testBuild(t, page, Options{Workers: 8})
+	var got []string
+	for _, s := range result.Summary().Sections {
+		got = append(got, s.Title)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("sections = %v, want %v", got, want)
+	}
+	opf := files["EPUB/package.opf"]
+	last := -1
+	for _, s := range result.Summary().Sections {
+		at := strings.Index(opf, `<itemref idref="`+s.Filename+`"`)
+		if at < 0 {
+			t.Fatalf("spine lacks %s:\n%s", s.Filename, opf)
+		}
+		if at < last {
+			t.Errorf("%s (%s) comes earlier in the spine than the section before it", s.Filename, s.Title)
+		}
+		last = at
+	}
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PresentationMode selects what happens to deprecated presentational
+// attributes such as align and bgcolor, which aren't valid in XHTML.
+type PresentationMode string
+
+const (
+	PresentationStrip PresentationMode = "strip" // Dropped with the rest of the source markup
+	PresentationCSS   PresentationMode = "css"   // Converted to inline CSS on the extracted paragraphs
+)
+
+// ParsePresentationMode validates a -presentational flag value.
+func ParsePresentationMode(s string) (PresentationMode, error) {
+	switch m := PresentationMode(strings.ToLower(s)); m {
+	case PresentationStrip, PresentationCSS:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid presentational attribute mode '%s' (want strip or css)", s)
+}
+
+// alignValues are the align values with a text-align equivalent.
+var alignValues = map[string]bool{"left": true, "right": true, "center": true, "justify": true}
+
+// colorPattern matches a bgcolor value that is safe to use as a CSS color:
+// a color name or a hex color, with or without the #.
+var colorPattern = regexp.MustCompile(`^(?:[a-zA-Z]+|#?[0-9a-fA-F]{3}|#?[0-9a-fA-F]{6})$`)
+
+// presentationalStyle returns the inline CSS equivalent of the alignment
+// and background color n inherits from its nearest ancestors that set them,
+// or "". The page's own background (bgcolor on <body>) is left to readers.
+func presentationalStyle(n *html.Node) string {
+	var align, bgcolor string
+	for p := n; p != nil && p.Type == html.ElementNode && p.Data != "body"; p = p.Parent {
+		if align == "" {
+			if p.Data == "center" {
+				align = "center"
+			} else if a := strings.ToLower(strings.TrimSpace(getAttr(p, "align"))); alignValues[a] {
+				align = a
+			}
+		}
+		if c := strings.TrimSpace(getAttr(p, "bgcolor")); bgcolor == "" && colorPattern.MatchString(c) {
+			bgcolor = strings.ToLower(c)
+			if !strings.HasPrefix(bgcolor, "#") && (len(bgcolor) == 3 || len(bgcolor) == 6) && strings.Trim(bgcolor, "0123456789abcdef") == "" {
+				bgcolor = "#" + bgcolor // Browsers accept hex colors without the #; CSS doesn't
+			}
+		}
+	}
+	var rules []string
+	if align != "" {
+		rules = append(rules, "text-align: "+align)
+	}
+	if bgcolor != "" {
+		rules = append(rules, "background-color: "+bgcolor)
+	}
+	return strings.Join(rules, "; ")
+}
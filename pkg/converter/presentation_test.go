@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPresentationalAttributes(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body bgcolor="white"><h3>One</h3>` +
+		`<table border="1" cellpadding="4" bgcolor="ffcc00"><tr><td align="center">Centered on yellow.</td>` +
+		`<td bgcolor="#336699">On blue.</td></tr></table><p align="bogus">Plain.</p></body></html>`
+	tests := []struct {
+		mode PresentationMode
+		want []string
+	}{
+		{PresentationStrip, []string{"<p>Centered on yellow. </p>", "<p>On blue. </p>", "<p>Plain. </p>"}},
+		{PresentationCSS, []string{
+			`<p style="text-align: center; background-color: #ffcc00">Centered on yellow. </p>`,
+			`<p style="background-color: #336699">On blue. </p>`,
+			"<p>Plain. </p>",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "book.epub")
+			result, files := testBuild(t, page, Options{OutputPath: out, Presentational: tt.mode})
+			body := sectionFile(t, result, files, "One")
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("section lacks %s:\n%s", want, body)
+				}
+			}
+			for _, attr := range []string{"bgcolor=", "align=", "border=", "cellpadding="} {
+				if strings.Contains(body, attr) {
+					t.Errorf("section kept %s:\n%s", attr, body)
+				}
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if problems, err := ValidateEPUB(data); err != nil || len(problems) > 0 {
+				t.Errorf("ValidateEPUB = %v, %v", problems, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ProgressStage is the part of a build a ProgressEvent reports on.
+type ProgressStage string
+
+const (
+	ProgressPages   ProgressStage = "pages"   // Fetching the input pages
+	ProgressImages  ProgressStage = "images"  // Downloading the images of the pages
+	ProgressWriting ProgressStage = "writing" // Writing the EPUB
+)
+
+// ProgressEvent reports that Done of the Total items of a stage are done.
+// Total is zero when it isn't known ahead, as for the pages of a crawl, and
+// for the writing stage, which has no items.
+type ProgressEvent struct {
+	Stage ProgressStage
+	Done  int
+	Total int
+	Item  string // URL of the page or image just done
+}
+
+// String describes e, e.g. "fetched page 3/40" or "downloaded image 12/200".
+func (e ProgressEvent) String() string {
+	var s string
+	switch e.Stage {
+	case ProgressPages:
+		s = fmt.Sprintf("fetched page %d", e.Done)
+	case ProgressImages:
+		s = fmt.Sprintf("downloaded image %d", e.Done)
+	case ProgressWriting:
+		return "writing EPUB"
+	default:
+		s = fmt.Sprintf("%s %d", e.Stage, e.Done)
+	}
+	if e.Total > 0 {
+		s += fmt.Sprintf("/%d", e.Total)
+	}
+	return s
+}
+
+// progress reports how far a build is along to the Progress callback of its
+// options. It is safe for concurrent use, so downloads on several goroutines
+// can report as they finish; a nil progress reports nothing.
+type progress struct {
+	report func(ProgressEvent)
+	mu     sync.Mutex
+	done   map[ProgressStage]int
+}
+
+// progressKey carries a build's progress in its context, like its retry
+// policy.
+type progressKey struct{}
+
+// withProgress returns ctx carrying a progress reporting to opts.Progress,
+// if it is set.
+func (opts Options) withProgress(ctx context.Context) context.Context {
+	if opts.Progress == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progress{report: opts.Progress, done: make(map[ProgressStage]int)})
+}
+
+// progressFrom returns the progress ctx carries, or nil.
+func progressFrom(ctx context.Context) *progress {
+	p, _ := ctx.Value(progressKey{}).(*progress)
+	return p
+}
+
+// step reports one more item of stage done, out of total, or zero if that
+// isn't known.
+func (p *progress) step(stage ProgressStage, total int, item string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[stage]++
+	if total > 0 {
+		total = max(total, p.done[stage])
+	}
+	p.report(ProgressEvent{Stage: stage, Done: p.done[stage], Total: total, Item: item})
+}
+
+// start reports that stage, one without items, has begun.
+func (p *progress) start(stage ProgressStage) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report(ProgressEvent{Stage: stage})
+}
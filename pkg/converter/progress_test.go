@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	files := make(map[string]servedFile)
+	for i := 1; i <= 3; i++ {
+		files[fmt.Sprintf("/img-%d.png", i)] = servedFile{"image/png", testPNG(t, i+1, i+1, color.Black)}
+	}
+	files["/1.html"] = servedFile{"text/html", []byte(`<html><body><h3>One</h3><p>Text.</p><img src="img-1.png" alt="1"><img src="img-2.png" alt="2"></body></html>`)}
+	files["/2.html"] = servedFile{"text/html", []byte(`<html><body><h3>Two</h3><p>Text.</p><img src="img-3.png" alt="3"><img src="img-1.png" alt="1 again"></body></html>`)}
+	srv := fileServer(t, files)
+
+	var mu sync.Mutex
+	var events []ProgressEvent
+	report := func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	testBuild(t, "", Options{SourceURLs: []string{srv.URL + "/1.html", srv.URL + "/2.html"}, Progress: report})
+
+	done := make(map[ProgressStage]int)
+	for i, e := range events {
+		if e.Stage == ProgressWriting {
+			if i != len(events)-1 {
+				t.Errorf("event %d = %v, before the last", i, e)
+			}
+			continue
+		}
+		done[e.Stage]++
+		if e.Done != done[e.Stage] || e.Total < e.Done || e.Item == "" {
+			t.Errorf("event %d = %+v, want %d done of a total at least that, naming its item", i, e, done[e.Stage])
+		}
+	}
+	if done[ProgressPages] != 2 || done[ProgressImages] != 3 {
+		t.Errorf("reported %d pages and %d images, want 2 and 3: %v", done[ProgressPages], done[ProgressImages], events)
+	}
+	if len(events) == 0 || events[len(events)-1].Stage != ProgressWriting {
+		t.Errorf("last event isn't writing the EPUB: %v", events)
+	}
+}
+
+func TestProgressEventString(t *testing.T) {
+	tests := []struct {
+		e    ProgressEvent
+		want string
+	}{
+		{ProgressEvent{Stage: ProgressPages, Done: 3, Total: 40}, "fetched page 3/40"},
+		{ProgressEvent{Stage: ProgressPages, Done: 3}, "fetched page 3"},
+		{ProgressEvent{Stage: ProgressImages, Done: 12, Total: 200}, "downloaded image 12/200"},
+		{ProgressEvent{Stage: ProgressWriting}, "writing EPUB"},
+	}
+	for _, tt := range tests {
+		if got := tt.e.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.e, got, tt.want)
+		}
+	}
+}
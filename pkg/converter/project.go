@@ -0,0 +1,189 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Input is one source of a book mixing kinds of source: exactly one of its
+// fields is set.
+type Input struct {
+	URL      string `json:"url,omitempty"`      // Page to fetch
+	File     string `json:"file,omitempty"`     // Local HTML file, directory or glob pattern, each file a chapter
+	Markdown string `json:"markdown,omitempty"` // Local Markdown file, its outermost headings starting chapters
+}
+
+// Project is a book manifest: a YAML, TOML or JSON file describing a book
+// and the sources it is made from, in order, so it can be built again the
+// same way. Its keys are the same in each format.
+type Project struct {
+	Output   string   `json:"output"`   // Where the EPUB is written
+	Metadata Metadata `json:"metadata"` // Book-level metadata, as in a -meta sidecar
+	Cover    string   `json:"cover"`    // Local path or URL of the cover image
+	CSS      []string `json:"css"`      // Local stylesheets added to every section
+	Sources  []Input  `json:"sources"`
+}
+
+// LoadProject reads a book manifest, as YAML if its name ends in .yaml or
+// .yml, as TOML if it ends in .toml, and otherwise as JSON. Local paths in
+// it are relative to the manifest's directory.
+func LoadProject(filePath string) (*Project, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read book manifest '%s': %w", filePath, err)
+	}
+	var p Project
+	if err := decodeProject(data, filepath.Ext(filePath), &p); err != nil {
+		return nil, fmt.Errorf("failed to parse book manifest '%s': %w", filePath, err)
+	}
+	if err := p.Metadata.normalize(); err != nil {
+		return nil, fmt.Errorf("failed to parse book manifest '%s': %w", filePath, err)
+	}
+	if len(p.Sources) == 0 {
+		return nil, fmt.Errorf("book manifest '%s' has no sources", filePath)
+	}
+
+	dir := filepath.Dir(filePath)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.Contains(p, "://") {
+			return p
+		}
+		return filepath.Join(dir, filepath.FromSlash(p))
+	}
+	for i, in := range p.Sources {
+		set := 0
+		for _, field := range []string{in.URL, in.File, in.Markdown} {
+			if field != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("source %d of book manifest '%s' must have exactly one of url, file or markdown", i+1, filePath)
+		}
+		if in.URL != "" {
+			if _, err := parseBaseURL(in.URL); err != nil {
+				return nil, fmt.Errorf("source %d of book manifest '%s': %w", i+1, filePath, err)
+			}
+		}
+		p.Sources[i].File, p.Sources[i].Markdown = resolve(in.File), resolve(in.Markdown)
+	}
+	p.Output, p.Cover = resolve(p.Output), resolve(p.Cover)
+	for i, css := range p.CSS {
+		p.CSS[i] = resolve(css)
+	}
+	return &p, nil
+}
+
+// decodeProject decodes the manifest data, in the format its file extension
+// ext says, into p. YAML and TOML are decoded into plain values and read
+// from there as JSON, so every format shares the JSON field names.
+func decodeProject(data []byte, ext string, p *Project) error {
+	var doc any
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+	case ".toml":
+		var table map[string]any
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			return err
+		}
+		doc = table
+	default:
+		return json.Unmarshal(data, p)
+	}
+	asJSON, err := json.Marshal(plainDates(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(asJSON, p)
+}
+
+// plainDates returns v with the dates YAML and TOML read unquoted, such as
+// date: 2024-01-02, turned back into the strings they were written as.
+func plainDates(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = plainDates(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = plainDates(e)
+		}
+	case []map[string]any: // TOML's arrays of tables
+		for _, e := range v {
+			plainDates(e)
+		}
+	}
+	return v
+}
+
+// Options returns base set up to build p: its sources replace the input of
+// base, and its output, cover, metadata and stylesheets fill in what base
+// leaves unset, with base's metadata taking precedence field by field.
+func (p *Project) Options(base Options) Options {
+	opts := base
+	opts.Inputs = p.Sources
+	opts.SourceURL, opts.SourceHTML, opts.Archive = "", nil, ""
+	opts.SourceURLs, opts.Paths = nil, nil
+	if opts.OutputPath == "" {
+		opts.OutputPath = p.Output
+	}
+	if opts.CoverImage == "" {
+		opts.CoverImage = p.Cover
+	}
+	opts.Metadata = p.Metadata.Merge(base.Metadata)
+	opts.Stylesheets = append(append([]string{}, p.CSS...), base.Stylesheets...)
+	return opts
+}
+
+// loadInputs fetches or reads the Inputs of opts, in order, fetching the
+// pages among them side by side first.
+func loadInputs(ctx context.Context, opts Options) ([]*source, error) {
+	var urls []string
+	for _, in := range opts.Inputs {
+		if in.URL != "" {
+			urls = append(urls, in.URL)
+		}
+	}
+	pages, err := loadPages(ctx, opts, urls)
+	if err != nil {
+		return nil, err
+	}
+	var sources []*source
+	for _, in := range opts.Inputs {
+		switch {
+		case in.URL != "":
+			sources = append(sources, pages[0])
+			pages = pages[1:]
+		case in.File != "":
+			srcs, err := loadLocal([]string{in.File}, opts.mediaStore())
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, srcs...)
+		case in.Markdown != "":
+			src, err := loadMarkdown(in.Markdown, opts.mediaStore())
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, src)
+		}
+	}
+	return sources, nil
+}
@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProjectFormats(t *testing.T) {
+	tests := []struct {
+		file     string
+		manifest string
+	}{
+		{"book.yaml", `
+output: out/book.epub
+cover: cover.png
+css: [style.css]
+metadata:
+  title: A Book
+  author: Ann Author
+  language: en
+  date: 2024-01-02
+  creators:
+    - name: Tom Translator
+      role: trl
+sources:
+  - url: https://example.com/one
+  - file: chapters/two.html
+  - markdown: three.md
+`},
+		{"book.yml", `
+output: out/book.epub
+cover: cover.png
+css: [style.css]
+metadata: {title: A Book, author: Ann Author, language: en, date: "2024-01-02", creators: [{name: Tom Translator, role: trl}]}
+sources: [{url: "https://example.com/one"}, {file: chapters/two.html}, {markdown: three.md}]
+`},
+		{"book.toml", `
+output = "out/book.epub"
+cover = "cover.png"
+css = ["style.css"]
+
+[metadata]
+title = "A Book"
+author = "Ann Author"
+language = "en"
+date = 2024-01-02
+
+[[metadata.creators]]
+name = "Tom Translator"
+role = "trl"
+
+[[sources]]
+url = "https://example.com/one"
+
+[[sources]]
+file = "chapters/two.html"
+
+[[sources]]
+markdown = "three.md"
+`},
+		{"book.json", `{
+  "output": "out/book.epub",
+  "cover": "cover.png",
+  "css": ["style.css"],
+  "metadata": {"title": "A Book", "author": "Ann Author", "language": "en", "date": "2024-01-02",
+    "creators": [{"name": "Tom Translator", "role": "trl"}]},
+  "sources": [{"url": "https://example.com/one"}, {"file": "chapters/two.html"}, {"markdown": "three.md"}]
+}`},
+	}
+	dir := t.TempDir()
+	want := Project{
+		Output: filepath.Join(dir, "out", "book.epub"),
+		Cover:  filepath.Join(dir, "cover.png"),
+		CSS:    []string{filepath.Join(dir, "style.css")},
+		Metadata: Metadata{
+			Title: "A Book", Author: "Ann Author", Language: "en", Date: "2024-01-02",
+			Creators: []Creator{{Name: "Tom Translator", Role: "trl"}},
+		},
+		Sources: []Input{
+			{URL: "https://example.com/one"},
+			{File: filepath.Join(dir, "chapters", "two.html")},
+			{Markdown: filepath.Join(dir, "three.md")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := LoadProject(path)
+			if err != nil {
+				t.Fatalf("LoadProject: %v", err)
+			}
+			if !reflect.DeepEqual(*p, want) {
+				t.Errorf("got  %+v\nwant %+v", *p, want)
+			}
+		})
+	}
+}
+
+func TestLoadProjectErrors(t *testing.T) {
+	tests := []struct {
+		file     string
+		manifest string
+		wantErr  string
+	}{
+		{"bad.yaml", "sources: [url: \n", "failed to parse"},
+		{"bad.toml", "sources = [\n", "failed to parse"},
+		{"empty.yaml", "output: book.epub\n", "has no sources"},
+		{"blank.yaml", "sources:\n  - url: https://example.com\n  - {}\n", "source 2 of book manifest"},
+		{"blank.json", `{"sources": [{"url": ""}]}`, "exactly one of url, file or markdown"},
+		{"two.toml", "[[sources]]\nurl = \"https://example.com\"\nfile = \"a.html\"\n", "exactly one of url, file or markdown"},
+		{"three.yaml", "sources:\n  - {url: https://example.com, file: a.html, markdown: b.md}\n", "exactly one of url, file or markdown"},
+		{"badurl.json", `{"sources": [{"url": "not a url"}]}`, "source 1 of book manifest"},
+		{"badrole.yaml", "metadata: {creators: [{name: Tom, role: somebody}]}\nsources: [{file: a.html}]\n", "invalid creator role"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadProject(path)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), path) {
+			t.Errorf("LoadProject(%s) error = %v, want one naming the manifest and saying %q", tt.file, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadProjectPaths(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(t.TempDir(), "elsewhere.html")
+	manifest := "output: ../book.epub\ncover: https://example.com/cover.png\nsources:\n  - file: " + abs + "\n  - markdown: notes/ch1.md\n"
+	path := filepath.Join(dir, "project", "book.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if want := filepath.Join(dir, "book.epub"); p.Output != want {
+		t.Errorf("output = %s, want %s", p.Output, want)
+	}
+	if p.Cover != "https://example.com/cover.png" {
+		t.Errorf("cover URL resolved as a path: %s", p.Cover)
+	}
+	if p.Sources[0].File != abs {
+		t.Errorf("absolute file = %s, want it kept as %s", p.Sources[0].File, abs)
+	}
+	if want := filepath.Join(dir, "project", "notes", "ch1.md"); p.Sources[1].Markdown != want {
+		t.Errorf("markdown = %s, want %s", p.Sources[1].Markdown, want)
+	}
+}
+
+func TestPlainDates(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	moment := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	got := plainDates(map[string]any{
+		"date":     day,
+		"modified": moment,
+		"list":     []any{day, "kept", 3},
+		"tables":   []map[string]any{{"date": day}},
+	})
+	want := map[string]any{
+		"date":     "2024-01-02",
+		"modified": "2024-01-02T15:04:05Z",
+		"list":     []any{"2024-01-02", "kept", 3},
+		"tables":   []map[string]any{{"date": "2024-01-02"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("plainDates = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestPublicOnlyThroughProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		w.Write([]byte("<p>Proxied.</p>"))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr error
+	}{
+		{name: "loopback target refused", target: "http://127.0.0.1:8080/admin", wantErr: errNotPublic},
+		{name: "metadata service refused", target: "http://169.254.169.254/latest/meta-data/", wantErr: errNotPublic},
+		{name: "public target proxied", target: "http://93.184.216.34/book.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			proxied = nil
+			mu.Unlock()
+			ctx := Options{Proxy: proxyURL, PublicOnly: true, Retries: -1}.withRetryPolicy(context.Background())
+			_, err := fetchPage(ctx, tt.target, "")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("fetchPage error = %v, want %v", err, tt.wantErr)
+				}
+				if len(proxied) != 0 {
+					t.Errorf("proxy was sent %v", proxied)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetchPage: %v", err)
+			}
+			if len(proxied) != 1 || proxied[0] != tt.target {
+				t.Errorf("proxy was sent %v, want [%s]", proxied, tt.target)
+			}
+		})
+	}
+}
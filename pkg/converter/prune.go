@@ -0,0 +1,153 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Directories go-epub puts embedded resources in, relative to the EPUB root.
+var resourceDirs = []string{"EPUB/images/", "EPUB/css/", "EPUB/fonts/", "EPUB/audio/", "EPUB/videos/"}
+
+// refAttrPattern matches the attributes content documents reference other
+// files with.
+var refAttrPattern = regexp.MustCompile(`(?:src|href|xlink:href)\s*=\s*["']([^"']*)["']`)
+
+// pruneEPUB removes the embedded resources in the EPUB in data that no
+// content document or stylesheet refers to, along with their manifest items.
+// Resources named in keep (paths from the archive root) are always kept. It
+// returns the new EPUB and the archive paths of the removed resources.
+func pruneEPUB(data []byte, keep map[string]bool) ([]byte, []string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open EPUB archive: %w", err)
+	}
+	contents := make(map[string][]byte)
+	resources := make(map[string]bool)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open '%s' in EPUB: %w", f.Name, err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read '%s' in EPUB: %w", f.Name, err)
+		}
+		contents[f.Name] = b
+		if isResourcePath(f.Name) {
+			resources[f.Name] = true
+		}
+	}
+
+	// Follow references out from the content documents; stylesheets are only
+	// scanned once something uses them
+	used := make(map[string]bool)
+	var queue []string
+	for name := range contents {
+		if strings.HasPrefix(name, "EPUB/") && !resources[name] && name != packageDocumentPath {
+			queue = append(queue, name)
+		}
+	}
+	for name := range keep {
+		if resources[name] && !used[name] {
+			used[name] = true
+			queue = append(queue, name)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, ref := range fileReferences(name, contents[name]) {
+			if resources[ref] && !used[ref] {
+				used[ref] = true
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	var pruned []string
+	for name := range resources {
+		if !used[name] {
+			pruned = append(pruned, name)
+		}
+	}
+	if len(pruned) == 0 {
+		return data, nil, nil
+	}
+	sort.Strings(pruned)
+	out, err := removeResources(data, pruned)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, pruned, nil
+}
+
+// removeResources removes the files at the given archive paths from the
+// EPUB in data, along with their manifest items.
+func removeResources(data []byte, names []string) ([]byte, error) {
+	drop := make(map[string]bool, len(names))
+	for _, name := range names {
+		drop[name] = true
+	}
+	return rewriteEPUB(data, func(name string, b []byte) ([]byte, error) {
+		if drop[name] {
+			return nil, errDropEntry
+		}
+		if name == packageDocumentPath {
+			return removeManifestItems(b, names), nil
+		}
+		return b, nil
+	})
+}
+
+// isResourcePath reports whether name is in one of go-epub's resource
+// directories.
+func isResourcePath(name string) bool {
+	for _, dir := range resourceDirs {
+		if strings.HasPrefix(name, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileReferences returns the archive paths the file name refers to through
+// src/href attributes or CSS url(...) values.
+func fileReferences(name string, data []byte) []string {
+	var refs []string
+	for _, m := range refAttrPattern.FindAllSubmatch(data, -1) {
+		refs = append(refs, string(m[1]))
+	}
+	for _, m := range cssURLPattern.FindAllSubmatch(data, -1) {
+		refs = append(refs, strings.TrimSpace(string(m[2])))
+	}
+
+	var paths []string
+	dir := path.Dir(name)
+	for _, ref := range refs {
+		u, err := url.Parse(ref)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			continue // External, fragment-only or data: references
+		}
+		paths = append(paths, path.Join(dir, u.Path))
+	}
+	return paths
+}
+
+// removeManifestItems drops the package document's manifest items for the
+// given archive paths.
+func removeManifestItems(opf []byte, names []string) []byte {
+	for _, name := range names {
+		href := strings.TrimPrefix(name, "EPUB/")
+		item := regexp.MustCompile(`\s*<item\b[^>]*\bhref="` + regexp.QuoteMeta(href) + `"[^>]*>(?:</item>)?`)
+		opf = item.ReplaceAll(opf, nil)
+	}
+	return opf
+}
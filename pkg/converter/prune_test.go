@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestPruneResources(t *testing.T) {
+	kept, dropped := testPNG(t, 4, 4, color.Black), testPNG(t, 4, 4, color.White)
+	srv := fileServer(t, map[string]servedFile{
+		"/kept.png":    {"image/png", kept},
+		"/dropped.png": {"image/png", dropped},
+	})
+	// The leading content is extracted, embedding its image, then discarded
+	page := `<html><head><title>Page</title></head><body><p>Preface.</p><img src="dropped.png" alt="Gone">` +
+		`<h3>One</h3><p>Text.</p><img src="kept.png" alt="Kept"></body></html>`
+	for _, prune := range []bool{false, true} {
+		name := "kept without pruning"
+		if prune {
+			name = "pruned"
+		}
+		t.Run(name, func(t *testing.T) {
+			opts := Options{SourceURL: srv.URL + "/page.html", Leading: LeadingDiscard, PruneResources: prune}
+			result, files := testBuild(t, page, opts)
+			paths := make(map[string]string)
+			for name, data := range files {
+				if strings.HasPrefix(name, "EPUB/images/") {
+					paths[data] = name
+				}
+			}
+			if _, ok := paths[string(kept)]; !ok {
+				t.Error("referenced image missing")
+			}
+			_, ok := paths[string(dropped)]
+			if ok == prune {
+				t.Errorf("unreferenced image in the EPUB = %v, want %v", ok, !prune)
+			}
+			if !prune {
+				if len(result.Summary().Pruned) != 0 {
+					t.Errorf("pruned %v without PruneResources", result.Summary().Pruned)
+				}
+				return
+			}
+
+			pruned := result.Summary().Pruned
+			if len(pruned) != 1 || pruned[0].Kind != "image" {
+				t.Fatalf("Pruned = %+v, want the one unreferenced image", pruned)
+			}
+			opf := files[packageDocumentPath]
+			if strings.Contains(opf, strings.TrimPrefix(pruned[0].Path, "../")) {
+				t.Errorf("manifest still lists %s:\n%s", pruned[0].Path, opf)
+			}
+			for _, r := range result.Summary().Resources {
+				if r.Path == pruned[0].Path {
+					t.Errorf("resources still list %s", r.Path)
+				}
+			}
+		})
+	}
+}
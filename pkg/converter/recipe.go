@@ -0,0 +1,217 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Recipe is the extraction rules for a site, applied to pages from its
+// domains. Options that configure the same thing win over a recipe.
+type Recipe struct {
+	Name           string   `json:"name"`
+	Domains        []string `json:"domains"`                   // Hosts it applies to, with their subdomains
+	Content        string   `json:"content,omitempty"`         // CSS selector of the content to keep, as Options.Select
+	Title          string   `json:"title,omitempty"`           // CSS selector of the element whose text titles the page
+	NextPage       string   `json:"next_page,omitempty"`       // The "next" link when crawling, as Options.NextLink
+	Strip          string   `json:"strip,omitempty"`           // CSS selector of elements to drop, as Options.Remove
+	ChapterHeading string   `json:"chapter_heading,omitempty"` // Heading element, h1 to h6, that starts chapters
+
+	content, title, strip Selector
+	nextPage              SectionMarker
+}
+
+// BuiltinRecipes are the recipes shipped for common sites. Options.Recipes
+// doesn't include them unless the caller adds them.
+var BuiltinRecipes = mustCompileRecipes([]Recipe{
+	{
+		Name:           "Project Gutenberg",
+		Domains:        []string{"gutenberg.org"},
+		Strip:          "#pg-header, #pg-footer, #pg-machine-header, .pg-boilerplate",
+		ChapterHeading: "h2",
+	},
+	{
+		Name:           "Wikipedia",
+		Domains:        []string{"wikipedia.org"},
+		Content:        "#mw-content-text > .mw-parser-output",
+		Title:          "#firstHeading",
+		Strip:          ".mw-editsection, .navbox, .vertical-navbox, .metadata, .ambox, .hatnote, .sistersitebox, .noprint, .mw-empty-elt, #toc, .toc",
+		ChapterHeading: "h2",
+	},
+	{
+		Name:     "WordPress.com",
+		Domains:  []string{"wordpress.com"},
+		Content:  ".entry-content",
+		Title:    ".entry-title",
+		NextPage: ".nav-next",
+		Strip:    ".sharedaddy, .jp-relatedposts, .wpcnt",
+	},
+	{
+		Name:     "Blogger",
+		Domains:  []string{"blogspot.com", "blogger.com"},
+		Content:  ".post-body",
+		Title:    ".post-title",
+		NextPage: ".blog-pager-older-link",
+		Strip:    ".post-share-buttons",
+	},
+	{
+		Name:    "Substack",
+		Domains: []string{"substack.com"},
+		Content: ".available-content",
+		Title:   "h1.post-title",
+		Strip:   ".subscription-widget-wrap, .subscribe-widget, .share-dialog, .button-wrapper",
+	},
+})
+
+// LoadRecipes reads the recipes in the .json files in dir, in order of
+// their names. A file holds one recipe or an array of them.
+func LoadRecipes(dir string) ([]Recipe, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes in '%s': %w", dir, err)
+	}
+	if files == nil {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("failed to read recipes in '%s': %w", dir, err)
+		}
+	}
+	sort.Strings(files)
+	var recipes []Recipe
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipe '%s': %w", file, err)
+		}
+		var batch []Recipe
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = decodeRecipe(data, &batch)
+		} else {
+			var r Recipe
+			err = decodeRecipe(data, &r)
+			batch = []Recipe{r}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipe '%s': %w", file, err)
+		}
+		for _, r := range batch {
+			if err := r.compile(); err != nil {
+				return nil, fmt.Errorf("invalid recipe in '%s': %w", file, err)
+			}
+			recipes = append(recipes, r)
+		}
+	}
+	return recipes, nil
+}
+
+// decodeRecipe decodes JSON data into v, rejecting unknown fields so typos
+// in a recipe don't go unnoticed.
+func decodeRecipe(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the recipe")
+	}
+	return nil
+}
+
+// compile checks r and parses its selectors.
+func (r *Recipe) compile() error {
+	if len(r.Domains) == 0 {
+		return fmt.Errorf("recipe '%s' has no domains", r.Name)
+	}
+	for i, d := range r.Domains {
+		r.Domains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+	}
+	var err error
+	for _, s := range []struct {
+		text string
+		sel  *Selector
+	}{{r.Content, &r.content}, {r.Title, &r.title}, {r.Strip, &r.strip}} {
+		if s.text == "" {
+			continue
+		}
+		if *s.sel, err = ParseSelector(s.text); err != nil {
+			return fmt.Errorf("recipe '%s': %w", r.Name, err)
+		}
+	}
+	if r.NextPage != "" {
+		if r.nextPage, err = ParseNextLink(r.NextPage); err != nil {
+			return fmt.Errorf("recipe '%s': %w", r.Name, err)
+		}
+	}
+	if r.ChapterHeading != "" {
+		r.ChapterHeading = strings.ToLower(r.ChapterHeading)
+		if !isHeadingTag(r.ChapterHeading) {
+			return fmt.Errorf("recipe '%s': invalid chapter heading '%s' (want h1 to h6)", r.Name, r.ChapterHeading)
+		}
+	}
+	return nil
+}
+
+// mustCompileRecipes compiles the built-in recipes, which are known good.
+func mustCompileRecipes(recipes []Recipe) []Recipe {
+	for i := range recipes {
+		if err := recipes[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return recipes
+}
+
+// recipeFor returns the first of the configured recipes for the host of u,
+// or nil if there is none.
+func (opts Options) recipeFor(u *url.URL) *Recipe {
+	if u == nil || u.Host == "" {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for i, r := range opts.Recipes {
+		for _, d := range r.Domains {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return &opts.Recipes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// applyRecipe narrows src to its content, as selectContent does, with the
+// rules of the recipe for its site filling in what opts doesn't configure,
+// and takes its title and chapter heading from the recipe.
+func applyRecipe(src *source, opts Options) {
+	sel, remove := opts.Select, opts.Remove
+	if r := opts.recipeFor(src.baseURL); r != nil {
+		parserLog.Debug("Using recipe", "recipe", r.Name, "source", src.name)
+		if !r.title.empty() {
+			if n := findNode(src.doc, r.title.matches); n != nil {
+				if title := getTextContent(n); title != "" {
+					if src.meta.Title == "" {
+						src.meta.Title = title
+					}
+					if opts.LeadingTitle == "" {
+						src.title = title
+					}
+				}
+			}
+		}
+		if r.ChapterHeading != "" {
+			src.chapterHeading = r.ChapterHeading
+		}
+		if sel.empty() {
+			sel = r.content
+		}
+		if remove.empty() {
+			remove = r.strip
+		}
+	}
+	selectContent(src, sel, remove)
+}
@@ -0,0 +1,134 @@
+package converter
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecipe(t *testing.T) {
+	page := `<html><head><title>Site name | Real Title</title></head><body>` +
+		`<h1 class="headline">Real Title</h1>` +
+		`<div class="sidebar"><h2>Sidebar</h2><p>Ads.</p></div>` +
+		`<div class="article"><p>Intro.</p><h2>Part A</h2><p>A text.</p><div class="share">Share!</div><h2>Part B</h2><p>B text.</p></div>` +
+		`</body></html>`
+	srv := fileServer(t, map[string]servedFile{"/post.html": {"text/html", []byte(page)}})
+	host := strings.Split(strings.TrimPrefix(srv.URL, "http://"), ":")[0]
+
+	dir := t.TempDir()
+	recipe := `{"name": "Test site", "domains": ["` + host + `"], "content": ".article", "title": ".headline", "strip": ".share", "chapter_heading": "H2"}`
+	if err := os.WriteFile(filepath.Join(dir, "site.json"), []byte(recipe), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := `[{"name": "Elsewhere", "domains": ["example.com"], "content": ".nothing"}]`
+	if err := os.WriteFile(filepath.Join(dir, "other.json"), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recipes, err := LoadRecipes(dir)
+	if err != nil {
+		t.Fatalf("LoadRecipes: %v", err)
+	}
+	if len(recipes) != 2 || recipes[0].Name != "Elsewhere" || recipes[1].Name != "Test site" {
+		t.Fatalf("recipes = %+v, want both files' in name order", recipes)
+	}
+	if r := (Options{Recipes: recipes}).recipeFor(&url.URL{Host: "blog.example.com"}); r == nil || r.Name != "Elsewhere" {
+		t.Errorf("recipe for a subdomain = %+v, want Elsewhere", r)
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		want     []string
+		wantText []string
+		unwanted []string
+	}{
+		{
+			name:     "recipe",
+			opts:     Options{Recipes: recipes},
+			want:     []string{"Real Title", "Part A", "Part B"},
+			wantText: []string{"Intro.", "A text.", "B text."},
+			unwanted: []string{"Ads.", "Share!", "Sidebar"},
+		},
+		{
+			name:     "options win",
+			opts:     Options{Recipes: recipes, Select: mustParseSelector(t, ".sidebar")},
+			want:     []string{"Sidebar"},
+			wantText: []string{"Ads."},
+			unwanted: []string{"A text."},
+		},
+		{
+			name:     "no recipe",
+			opts:     Options{},
+			want:     []string{"Site name | Real Title"},
+			wantText: []string{"Ads.", "Share!"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.SourceURL = srv.URL + "/post.html"
+			result, files := testBuild(t, "", tt.opts)
+			var titles []string
+			var all strings.Builder
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+				all.WriteString(files["EPUB/xhtml/"+s.Filename])
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			for _, text := range tt.wantText {
+				if !strings.Contains(all.String(), text) {
+					t.Errorf("sections lack %q", text)
+				}
+			}
+			for _, text := range tt.unwanted {
+				if strings.Contains(all.String(), text) {
+					t.Errorf("sections have %q", text)
+				}
+			}
+			if tt.name == "recipe" && !strings.Contains(files[packageDocumentPath], "<dc:title>Real Title</dc:title>") {
+				t.Errorf("book isn't titled from the recipe's title:\n%s", files[packageDocumentPath])
+			}
+		})
+	}
+}
+
+// mustParseSelector parses s, failing the test if it's invalid.
+func mustParseSelector(t *testing.T, s string) Selector {
+	t.Helper()
+	sel, err := ParseSelector(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sel
+}
+
+func TestLoadRecipesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  string
+		wantErr string
+	}{
+		{"unknown field", `{"name": "Typo", "domains": ["example.com"], "contnet": ".post"}`, "unknown field"},
+		{"no domains", `{"name": "Nowhere", "content": ".post"}`, "has no domains"},
+		{"bad selector", `{"name": "Bad", "domains": ["example.com"], "content": "div[["}`, "recipe 'Bad'"},
+		{"bad heading", `{"name": "Deep", "domains": ["example.com"], "chapter_heading": "h7"}`, "invalid chapter heading"},
+		{"trailing data", `{"name": "Two", "domains": ["example.com"]} {}`, "unexpected data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "recipe.json"), []byte(tt.recipe), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadRecipes(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadRecipes error = %v, want one saying %q", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := LoadRecipes(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadRecipes of a missing directory succeeded")
+	}
+}
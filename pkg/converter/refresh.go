@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxRefreshHops bounds how many meta-refresh redirects are followed.
+const maxRefreshHops = 5
+
+// metaRefreshTarget returns the URL a <meta http-equiv="refresh"> tag in doc
+// redirects to, resolved against baseURL, or nil if there is none.
+func metaRefreshTarget(doc *html.Node, baseURL *url.URL) *url.URL {
+	var content string
+	var find func(*html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(getAttr(n, "http-equiv"), "refresh") {
+			content = getAttr(n, "content")
+			return true
+		}
+		if n.Type == html.ElementNode && n.Data == "body" {
+			return false // Only the head can redirect
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if !find(doc) {
+		return nil
+	}
+
+	ref := parseRefreshContent(content)
+	if ref == "" {
+		return nil
+	}
+	target, err := baseURL.Parse(ref)
+	if err != nil {
+		return nil
+	}
+	return target
+}
+
+// parseRefreshContent extracts the URL from a refresh content value such as
+// `0; url=https://example.com/` or `5;URL='page.html'`.
+func parseRefreshContent(content string) string {
+	_, rest, ok := strings.Cut(content, ";")
+	if !ok {
+		rest, ok = strings.CutPrefix(strings.TrimSpace(content), ",")
+		if !ok {
+			return "" // Just a delay: the page reloads itself
+		}
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 4 && strings.EqualFold(rest[:3], "url") {
+		if after, ok := strings.CutPrefix(strings.TrimSpace(rest[3:]), "="); ok {
+			rest = strings.TrimSpace(after)
+		}
+	}
+	return strings.Trim(rest, `'"`)
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseRefreshContent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"0; url=https://example.com/", "https://example.com/"},
+		{"5;URL='page.html'", "page.html"},
+		{`0; URL = "other.html"`, "other.html"},
+		{"3; next.html", "next.html"},
+		{"30", ""},
+	}
+	for _, tt := range tests {
+		if got := parseRefreshContent(tt.in); got != tt.want {
+			t.Errorf("parseRefreshContent(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMetaRefreshTarget(t *testing.T) {
+	base, _ := url.Parse("https://example.com/books/index.html")
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"relative target", `<head><meta http-equiv="Refresh" content="0; url=book.html"></head>`, "https://example.com/books/book.html"},
+		{"absolute target", `<head><meta http-equiv="refresh" content="0;url=https://other.org/"></head>`, "https://other.org/"},
+		{"delay only", `<head><meta http-equiv="refresh" content="60"></head>`, ""},
+		{"in the body", `<body><p>Text</p><meta http-equiv="refresh" content="0; url=x.html"></body>`, ""},
+		{"none", `<head><title>T</title></head>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := metaRefreshTarget(parseHTML(t, tt.doc), base)
+			got := ""
+			if target != nil {
+				got = target.String()
+			}
+			if got != tt.want {
+				t.Errorf("metaRefreshTarget = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenditionSpread is the rendition:spread property of the package document:
+// when a reader shows two pages side by side.
+type RenditionSpread string
+
+const (
+	SpreadNone      RenditionSpread = "none"
+	SpreadLandscape RenditionSpread = "landscape"
+	SpreadBoth      RenditionSpread = "both"
+	SpreadAuto      RenditionSpread = "auto"
+)
+
+// ParseRenditionSpread validates a -spread flag value; empty leaves the
+// property unset.
+func ParseRenditionSpread(s string) (RenditionSpread, error) {
+	switch v := RenditionSpread(strings.ToLower(s)); v {
+	case "", SpreadNone, SpreadLandscape, SpreadBoth, SpreadAuto:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid rendition spread '%s' (want none, landscape, both or auto)", s)
+}
+
+// RenditionFlow is the rendition:flow property of the package document: how
+// a reader presents overflowing content.
+type RenditionFlow string
+
+const (
+	FlowPaginated          RenditionFlow = "paginated"
+	FlowScrolledContinuous RenditionFlow = "scrolled-continuous"
+	FlowScrolledDoc        RenditionFlow = "scrolled-doc"
+	FlowAuto               RenditionFlow = "auto"
+)
+
+// ParseRenditionFlow validates a -flow flag value; empty leaves the property
+// unset.
+func ParseRenditionFlow(s string) (RenditionFlow, error) {
+	switch v := RenditionFlow(strings.ToLower(s)); v {
+	case "", FlowPaginated, FlowScrolledContinuous, FlowScrolledDoc, FlowAuto:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid rendition flow '%s' (want paginated, scrolled-continuous, scrolled-doc or auto)", s)
+}
+
+// renditionElements returns the package document metadata elements for the
+// rendition properties opts sets. The rendition prefix is reserved in EPUB 3,
+// so it needn't be declared.
+func (opts Options) renditionElements() []string {
+	var elements []string
+	if opts.Spread != "" {
+		elements = append(elements, fmt.Sprintf(`<meta property="rendition:spread">%s</meta>`, opts.Spread))
+	}
+	if opts.Flow != "" {
+		elements = append(elements, fmt.Sprintf(`<meta property="rendition:flow">%s</meta>`, opts.Flow))
+	}
+	return elements
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenditionProperties(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>Text.</p></body></html>`
+	tests := []struct {
+		name   string
+		spread RenditionSpread
+		flow   RenditionFlow
+	}{
+		{name: "unset"},
+		{name: "spread", spread: SpreadLandscape},
+		{name: "flow", flow: FlowScrolledDoc},
+		{name: "both", spread: SpreadNone, flow: FlowPaginated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "book.epub")
+			_, files := testBuild(t, page, Options{OutputPath: out, Spread: tt.spread, Flow: tt.flow})
+			opf := files[packageDocumentPath]
+			for property, value := range map[string]string{"rendition:spread": string(tt.spread), "rendition:flow": string(tt.flow)} {
+				element := `<meta property="` + property + `">` + value + `</meta>`
+				if got := strings.Contains(opf, `property="`+property+`"`); got != (value != "") {
+					t.Errorf("package document has %s = %v, want %v:\n%s", property, got, value != "", opf)
+				} else if value != "" && !strings.Contains(opf, element) {
+					t.Errorf("package document lacks %s:\n%s", element, opf)
+				}
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if problems, err := ValidateEPUB(data); err != nil || len(problems) > 0 {
+				t.Errorf("ValidateEPUB = %v, %v", problems, err)
+			}
+		})
+	}
+}
+
+func TestParseRendition(t *testing.T) {
+	if v, err := ParseRenditionSpread("Landscape"); err != nil || v != SpreadLandscape {
+		t.Errorf("ParseRenditionSpread(Landscape) = %q, %v", v, err)
+	}
+	if _, err := ParseRenditionSpread("portrait-only"); err == nil {
+		t.Error("ParseRenditionSpread accepted portrait-only")
+	}
+	if v, err := ParseRenditionFlow("scrolled-continuous"); err != nil || v != FlowScrolledContinuous {
+		t.Errorf("ParseRenditionFlow(scrolled-continuous) = %q, %v", v, err)
+	}
+	if _, err := ParseRenditionFlow("scrolled"); err == nil {
+		t.Error("ParseRenditionFlow accepted scrolled")
+	}
+}
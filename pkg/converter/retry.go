@@ -0,0 +1,250 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults of the retry policy of HTTP requests.
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultRetries        = 3
+	DefaultRetryBackoff   = time.Second
+)
+
+// retryPolicy is how the requests of a build are sent: with a client whose
+// timeout bounds each attempt, body included, and how many times and after
+// what pause a failed attempt is repeated; as what user agent, with what
+// credentials; and how far apart requests to one host are spaced.
+type retryPolicy struct {
+	client        *http.Client // With a cookie jar holding the configured cookies
+	retries       int
+	backoff       time.Duration // Pause before the first retry; doubled before each later one
+	userAgent     string
+	interval      time.Duration   // Least time between the starts of requests to one host
+	headers       http.Header     // Sent to authHosts only
+	authorization string          // Sent to authHosts only
+	authHosts     map[string]bool // Hosts of the pages the build names
+}
+
+// retryPolicyKey carries a build's retryPolicy in its context, so every
+// request of the build follows it without threading Options through.
+type retryPolicyKey struct{}
+
+// withRetryPolicy returns ctx carrying the retry policy opts describe.
+// Without Options.Proxy, requests go through the proxies the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables name, if any.
+func (opts Options) withRetryPolicy(ctx context.Context) context.Context {
+	jar, _ := cookiejar.New(nil) // Never fails
+	for _, c := range opts.Cookies {
+		u, c := cookieURL(c), *c
+		if !strings.HasPrefix(c.Domain, ".") {
+			c.Domain = "" // Host-only
+		}
+		jar.SetCookies(u, []*http.Cookie{&c})
+	}
+	p := retryPolicy{
+		client:        &http.Client{Timeout: DefaultRequestTimeout, Jar: jar},
+		retries:       DefaultRetries,
+		backoff:       DefaultRetryBackoff,
+		userAgent:     DefaultUserAgent(),
+		interval:      max(opts.RequestDelay, 0),
+		headers:       opts.Headers,
+		authorization: opts.Authorization,
+		authHosts:     opts.sourceHosts(),
+	}
+	p.client.CheckRedirect = p.checkRedirect
+	if opts.Proxy != nil || opts.PublicOnly {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		switch {
+		case opts.Proxy != nil && opts.PublicOnly:
+			// Every connection is to the configured proxy, so it's the
+			// hosts requests are for that are checked
+			t.Proxy = func(req *http.Request) (*url.URL, error) {
+				if err := checkPublicHost(req.Context(), req.URL.Hostname()); err != nil {
+					return nil, err
+				}
+				return opts.Proxy, nil
+			}
+		case opts.Proxy != nil:
+			t.Proxy = http.ProxyURL(opts.Proxy)
+		default:
+			d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublicOnly}
+			t.DialContext = d.DialContext
+		}
+		p.client.Transport = t
+	}
+	if opts.UserAgent != "" {
+		p.userAgent = opts.UserAgent
+	}
+	if opts.RequestTimeout > 0 {
+		p.client.Timeout = opts.RequestTimeout
+	}
+	if opts.Retries != 0 {
+		p.retries = max(opts.Retries, 0)
+	}
+	if opts.RetryBackoff > 0 {
+		p.backoff = opts.RetryBackoff
+	}
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// retryPolicyFrom returns the retry policy ctx carries, or the default one.
+func retryPolicyFrom(ctx context.Context) retryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(retryPolicy); ok {
+		return p
+	}
+	return retryPolicyFrom(Options{}.withRetryPolicy(ctx))
+}
+
+// withCrawlDelay returns ctx with its retry policy spacing requests to a
+// host at least d apart, as a robots.txt Crawl-delay asks of a crawl.
+func withCrawlDelay(ctx context.Context, d time.Duration) context.Context {
+	p := retryPolicyFrom(ctx)
+	if d <= p.interval {
+		return ctx
+	}
+	p.interval = d
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// authorize adds the configured headers and credentials to req if it goes
+// to the host of a page the build names.
+func (p retryPolicy) authorize(req *http.Request) {
+	if !p.authHosts[strings.ToLower(req.URL.Host)] {
+		return
+	}
+	for name, values := range p.headers {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if p.authorization != "" {
+		req.Header.Set("Authorization", p.authorization)
+	}
+}
+
+// maxRedirects is how many redirects a request follows, as http.Client
+// does by default.
+const maxRedirects = 10
+
+// checkRedirect is the client's CheckRedirect. The client copies a request's
+// headers to where it's redirected, dropping only Authorization and cookies,
+// and only for another domain, so the configured headers and credentials
+// are taken off a redirect that leaves the hosts authorize sends them to.
+func (p retryPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !p.authHosts[strings.ToLower(req.URL.Host)] {
+		for name := range p.headers {
+			req.Header.Del(name)
+		}
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// errNotPublic is the error of a connection PublicOnly refuses.
+var errNotPublic = errors.New("address is not public")
+
+// dialPublicOnly refuses to connect to address, as resolved for a dial, if
+// it's not a public one.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("failed to parse address '%s': %w", host, err)
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("refusing to connect to '%s': %w", ip, errNotPublic)
+	}
+	return nil
+}
+
+// checkPublicHost resolves host, a request's host name or IP address, and
+// refuses it if any of its addresses isn't a public one. It's how requests
+// sent through a proxy are checked, since only the proxy is dialled.
+func checkPublicHost(ctx context.Context, host string) error {
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve '%s': %w", host, err)
+	}
+	for _, ip := range ips {
+		if !isPublicAddr(ip) {
+			return fmt.Errorf("refusing to request '%s' at '%s': %w", host, ip, errNotPublic)
+		}
+	}
+	return nil
+}
+
+// isPublicAddr reports whether ip may be reached with PublicOnly set: not
+// loopback, private (RFC 1918 or IPv6 unique local), link-local, multicast,
+// unspecified or in one of the other nonPublicRanges.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, r := range nonPublicRanges {
+		if r.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicRanges are ranges netip.Addr doesn't tell apart that aren't
+// public either: "this network", which Linux dials as the local host, and
+// RFC 6598's carrier-grade NAT space.
+var nonPublicRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// ParseProxy parses a -proxy value: the URL of an HTTP, HTTPS or SOCKS5
+// proxy, e.g. socks5://127.0.0.1:9050 for Tor.
+func ParseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy '%s' (want e.g. http://proxy:3128 or socks5://127.0.0.1:9050)", s)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme '%s' (want http, https, socks5 or socks5h)", u.Scheme)
+}
+
+// delay returns the pause before retry number attempt, counting from 0: the
+// backoff doubled for each earlier retry, give or take half of it at random
+// so clients that failed together don't all come back at once.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff << min(attempt, 16)
+	return d/2 + rand.N(d+1)
+}
+
+// retryable reports whether an attempt that ended with resp or err is worth
+// repeating: the connection failed or timed out, or the server had a
+// temporary problem. Cancelling the build isn't retried.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, errNotPublic)
+	}
+	return resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestPublicOnlyRefusesLocalServer(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("<p>Secret.</p>"))
+	}))
+	defer srv.Close()
+
+	ctx := Options{PublicOnly: true}.withRetryPolicy(context.Background())
+	_, err := fetchPage(ctx, srv.URL, "")
+	if !errors.Is(err, errNotPublic) {
+		t.Fatalf("fetchPage error = %v, want the address refused", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("server got %d requests", n)
+	}
+
+	ctx = Options{}.withRetryPolicy(context.Background())
+	if _, err := fetchPage(ctx, srv.URL, ""); err != nil {
+		t.Errorf("fetchPage without PublicOnly: %v", err)
+	}
+}
+
+// scriptedServer answers with statuses in turn, the last one repeating,
+// and counts the requests it gets.
+func scriptedServer(t *testing.T, retryAfter string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	hits := new(atomic.Int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(hits.Add(1)) - 1
+		status := statuses[min(n, len(statuses)-1)]
+		if status == http.StatusTooManyRequests && retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("body " + strconv.Itoa(n)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+func TestDoRequestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retries    int
+		wantStatus int
+		wantHits   int32
+	}{
+		{"success", []int{200}, 3, 200, 1},
+		{"server errors then success", []int{503, 500, 200}, 3, 200, 3},
+		{"server errors past the retries", []int{502}, 2, 502, 3},
+		{"no retries", []int{503, 200}, -1, 503, 1},
+		{"not found not retried", []int{404, 200}, 3, 404, 1},
+		{"not implemented not retried", []int{501, 200}, 3, 501, 1},
+		{"rate limited then success", []int{429, 200}, 3, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := scriptedServer(t, "0", tt.statuses...)
+			ctx := Options{Retries: tt.retries, RetryBackoff: time.Millisecond}.withRetryPolicy(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := doRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if n := hits.Load(); n != tt.wantHits {
+				t.Errorf("server got %d requests, want %d", n, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestRateLimitPausesHost(t *testing.T) {
+	srv, hits := scriptedServer(t, "1", 429, 200)
+	ctx := Options{RetryBackoff: time.Millisecond}.withRetryPolicy(context.Background())
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := doRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, before the 1s Retry-After", elapsed)
+	}
+	if resp.StatusCode != http.StatusOK || hits.Load() != 2 {
+		t.Errorf("status %d after %d requests, want 200 after 2", resp.StatusCode, hits.Load())
+	}
+
+	// Other requests to the host wait out the pause too
+	u, _ := url.Parse(srv.URL)
+	rateLimits.pause(u.Host, 300*time.Millisecond)
+	start = time.Now()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if resp, err = doRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("request sent %s into the host's pause", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"3", 3 * time.Second},
+		{"", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+		{"-5", 0},
+		{"86400", maxRetryAfter},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
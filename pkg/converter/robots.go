@@ -0,0 +1,206 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRobotsSize is the most of a robots.txt file read, as crawlers cap it.
+const maxRobotsSize = 512 << 10
+
+// maxCrawlDelay is the longest Crawl-delay in robots.txt honoured.
+const maxCrawlDelay = time.Minute
+
+// DefaultUserAgent returns the User-Agent sent when Options.UserAgent is
+// empty: this program, its version and where to find out about it.
+func DefaultUserAgent() string {
+	return "epub-creator-go/" + strings.TrimPrefix(toolVersion(), "v") + " (+https://github.com/ritikprajapat21/epub-creator-go)"
+}
+
+// robotsRules are the rules of a robots.txt group: paths disallowed and
+// allowed, most specific match winning, and the pause asked for between
+// requests.
+type robotsRules struct {
+	allow, disallow []string
+	crawlDelay      time.Duration
+}
+
+// robotsTTL is how long a host's robots.txt is kept before it's fetched
+// again, the most RFC 9309 allows.
+const robotsTTL = 24 * time.Hour
+
+// robotsEntry is a cached robots.txt: its rules and when they go stale.
+type robotsEntry struct {
+	rules   *robotsRules
+	expires time.Time
+}
+
+// robotsCache holds the rules of each host's robots.txt for the user agent
+// they were read for, so each is fetched once per robotsTTL, however many
+// builds a process runs.
+var robotsCache = struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry // By scheme, host and user agent
+}{entries: make(map[string]robotsEntry)}
+
+// robotsAllowed reports whether the robots.txt of u's host lets the user
+// agent of the retry policy in ctx fetch u, and the Crawl-delay it asks for
+// between requests, if any. A robots.txt that's missing or can't be fetched
+// allows everything; only a missing one is remembered, so one that failed
+// with a server error or timeout is asked for again next time.
+func robotsAllowed(ctx context.Context, u *url.URL) (bool, time.Duration) {
+	agent := retryPolicyFrom(ctx).userAgent
+	key := u.Scheme + "://" + u.Host + " " + agent
+	robotsCache.mu.Lock()
+	entry, ok := robotsCache.entries[key]
+	robotsCache.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rules.allows(u), entry.rules.crawlDelay
+	}
+	rules, cache := fetchRobots(ctx, u, agent)
+	if cache {
+		robotsCache.mu.Lock()
+		robotsCache.entries[key] = robotsEntry{rules: rules, expires: time.Now().Add(robotsTTL)}
+		robotsCache.mu.Unlock()
+	}
+	return rules.allows(u), rules.crawlDelay
+}
+
+// fetchRobots fetches and parses the robots.txt of u's host for agent. It
+// reports whether the rules may be cached: they may if robots.txt was read,
+// or is missing or forbidden with a 4xx status, allowing everything, but
+// not if it couldn't be fetched or the server failed.
+func fetchRobots(ctx context.Context, u *url.URL, agent string) (*robotsRules, bool) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return &robotsRules{}, false
+	}
+	resp, err := doRequest(req)
+	if err != nil {
+		fetcherLog.Debug("Could not fetch robots.txt; assuming everything is allowed", "url", robotsURL.String(), "err", err)
+		return &robotsRules{}, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fetcherLog.Debug("No robots.txt; assuming everything is allowed", "url", robotsURL.String(), "status", resp.Status)
+		return &robotsRules{}, resp.StatusCode >= 400 && resp.StatusCode < 500
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return &robotsRules{}, false
+	}
+	return parseRobots(data, agent), true
+}
+
+// parseRobots returns the rules of the robots.txt data for agent: those of
+// the groups naming its product token, e.g. "epub-creator-go", or else
+// those of the "*" groups.
+func parseRobots(data []byte, agent string) *robotsRules {
+	token := strings.ToLower(agent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	var named, wildcard robotsRules
+	foundNamed := false
+	var current []*robotsRules // Groups the lines being read belong to
+	inAgents := false          // Reading a group's User-agent lines
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+		if field == "user-agent" {
+			if !inAgents {
+				current, inAgents = nil, true
+			}
+			switch v := strings.ToLower(value); {
+			case v == "*":
+				current = append(current, &wildcard)
+			case v == token:
+				current = append(current, &named)
+				foundNamed = true
+			}
+			continue
+		}
+		inAgents = false
+		for _, g := range current {
+			switch field {
+			case "allow":
+				if value != "" {
+					g.allow = append(g.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					g.disallow = append(g.disallow, value)
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					g.crawlDelay = min(time.Duration(secs*float64(time.Second)), maxCrawlDelay)
+				}
+			}
+		}
+	}
+	if foundNamed {
+		return &named
+	}
+	return &wildcard
+}
+
+// allows reports whether the rules let u be fetched: the longest matching
+// rule decides, Allow winning a tie, and nothing matching allows it.
+func (r *robotsRules) allows(u *url.URL) bool {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	longest, allowed := -1, true
+	for _, rule := range r.disallow {
+		if robotsMatch(rule, p) && len(rule) > longest {
+			longest, allowed = len(rule), false
+		}
+	}
+	for _, rule := range r.allow {
+		if robotsMatch(rule, p) && len(rule) >= longest {
+			longest, allowed = len(rule), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether the robots.txt path pattern matches p. "*"
+// matches any characters and a trailing "$" anchors the pattern at the end.
+func robotsMatch(pattern, p string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(p, parts[0]) {
+		return false
+	}
+	rest := p[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == ""
+}
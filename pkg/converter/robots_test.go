@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	data := []byte(`# Comment
+User-agent: *
+Disallow: /private
+Crawl-delay: 2
+
+User-agent: epub-creator-go
+User-agent: other
+Disallow: /books/
+Allow: /books/public
+Disallow: /*.pdf$
+`)
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"epub-creator-go/1.0 (+https://example.com)", "/books/secret", false},
+		{"epub-creator-go/1.0", "/books/public/one", true},
+		{"epub-creator-go/1.0", "/private", true}, // The named group replaces "*"
+		{"epub-creator-go/1.0", "/files/book.pdf", false},
+		{"epub-creator-go/1.0", "/files/book.pdf?x=1", true},
+		{"SomeBot/2.0", "/private/page", false},
+		{"SomeBot/2.0", "/books/secret", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse("https://example.com" + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseRobots(data, tt.agent).allows(u); got != tt.want {
+			t.Errorf("%s fetching %s: allowed = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+	if d := parseRobots(data, "SomeBot").crawlDelay; d != 2*time.Second {
+		t.Errorf("crawl delay = %s, want 2s", d)
+	}
+}
+
+func TestRobotsCaching(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int // Of robots.txt, one per fetch; the last repeats
+		wantFirst  bool  // Whether /private is allowed on the first check
+		wantSecond bool
+		wantHits   int32
+	}{
+		{"read once", []int{200}, false, false, 1},
+		{"missing cached", []int{404, 200}, true, true, 1},
+		{"forbidden cached", []int{403, 200}, true, true, 1},
+		{"server error not cached", []int{503, 200}, true, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(hits.Add(1)) - 1
+				status := tt.statuses[min(n, len(tt.statuses)-1)]
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+				}
+			}))
+			defer srv.Close()
+
+			ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+			u, _ := url.Parse(srv.URL + "/private")
+			if got, _ := robotsAllowed(ctx, u); got != tt.wantFirst {
+				t.Errorf("first check allowed = %v, want %v", got, tt.wantFirst)
+			}
+			if got, _ := robotsAllowed(ctx, u); got != tt.wantSecond {
+				t.Errorf("second check allowed = %v, want %v", got, tt.wantSecond)
+			}
+			if n := hits.Load(); n != tt.wantHits {
+				t.Errorf("robots.txt fetched %d times, want %d", n, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestRobotsCacheExpires(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer srv.Close()
+
+	ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+	u, _ := url.Parse(srv.URL + "/page")
+	robotsAllowed(ctx, u)
+	robotsCache.mu.Lock()
+	for key, entry := range robotsCache.entries {
+		entry.expires = time.Now().Add(-time.Second)
+		robotsCache.entries[key] = entry
+	}
+	robotsCache.mu.Unlock()
+	robotsAllowed(ctx, u)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("robots.txt fetched %d times, want it fetched again once stale", n)
+	}
+}
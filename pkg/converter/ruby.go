@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// rubyElements are the elements kept inside a <ruby> annotation; anything
+// else in it is reduced to its text.
+var rubyElements = map[string]bool{"rb": true, "rp": true, "rt": true, "rtc": true}
+
+// rubyMarkup renders a <ruby> element as XHTML, keeping its base text and
+// annotations apart so readers can show the annotations above the text.
+func rubyMarkup(n *html.Node) string {
+	var b strings.Builder
+	var render func(*html.Node)
+	render = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.Type == html.TextNode:
+				b.WriteString(html.EscapeString(strings.Join(strings.Fields(c.Data), " ")))
+			case c.Type == html.ElementNode && rubyElements[c.Data]:
+				b.WriteString("<" + c.Data + ">")
+				render(c)
+				b.WriteString("</" + c.Data + ">")
+			case c.Type == html.ElementNode:
+				render(c)
+			}
+		}
+	}
+	b.WriteString("<ruby>")
+	render(n)
+	b.WriteString("</ruby>")
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRubyStaysInItsBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "after text in the same paragraph",
+			body: `<h3>CHAPTER II</h3><p>Read as <ruby>漢<rt>kan</rt></ruby></p>`,
+			want: `<p>CHAPTER II </p><p>Read as<ruby>漢<rt>kan</rt></ruby> </p>`,
+		},
+		{
+			name: "opening a paragraph after a heading",
+			body: `<h3>CHAPTER II</h3><p><ruby>漢<rt>kan</rt></ruby> text</p>`,
+			want: `<p>CHAPTER II </p><p><ruby>漢<rt>kan</rt></ruby> </p><p>text </p>`,
+		},
+		{
+			name: "opening a paragraph after another",
+			body: `<h3>CHAPTER II</h3><p>First.</p><p><ruby>漢<rt>kan</rt></ruby></p>`,
+			want: `<p>CHAPTER II </p><p>First. </p><p><ruby>漢<rt>kan</rt></ruby> </p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := testSections(t, tt.body, Options{})
+			if len(sections) != 1 {
+				t.Fatalf("got sections %v, want 1", sectionOutline(sections))
+			}
+			if got := strings.ReplaceAll(sections[0].Body, "\n", ""); got != tt.want {
+				t.Errorf("body = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
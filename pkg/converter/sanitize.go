@@ -0,0 +1,186 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Elements sanitizeSection treats specially: those readers reject, dropped
+// with their content, and those EPUB content documents allow, kept. Others
+// are renamed to an allowed equivalent if they have one, or else unwrapped,
+// keeping their content.
+var (
+	droppedElements = map[string]bool{
+		"applet": true, "base": true, "button": true, "canvas": true, "dialog": true, "embed": true,
+		"frame": true, "frameset": true, "iframe": true, "input": true, "link": true, "meta": true,
+		"noscript": true, "object": true, "script": true, "select": true, "style": true,
+		"template": true, "textarea": true, "title": true,
+	}
+	allowedElements = map[string]bool{
+		"a": true, "abbr": true, "address": true, "article": true, "aside": true, "audio": true,
+		"b": true, "bdi": true, "bdo": true, "blockquote": true, "br": true, "caption": true,
+		"cite": true, "code": true, "col": true, "colgroup": true, "data": true, "dd": true,
+		"del": true, "details": true, "dfn": true, "div": true, "dl": true, "dt": true, "em": true,
+		"figcaption": true, "figure": true, "footer": true, "h1": true, "h2": true, "h3": true,
+		"h4": true, "h5": true, "h6": true, "header": true, "hgroup": true, "hr": true, "i": true,
+		"img": true, "ins": true, "kbd": true, "li": true, "main": true, "mark": true, "nav": true,
+		"ol": true, "p": true, "pre": true, "q": true, "rb": true, "rp": true, "rt": true,
+		"rtc": true, "ruby": true, "s": true, "samp": true, "section": true, "small": true,
+		"source": true, "span": true, "strong": true, "sub": true, "summary": true, "sup": true,
+		"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+		"time": true, "tr": true, "track": true, "u": true, "ul": true, "var": true, "video": true,
+		"wbr": true,
+	}
+	// Obsolete elements with an allowed equivalent they become instead
+	renamedElements = map[string]string{"acronym": "abbr", "center": "div", "strike": "s", "tt": "code"}
+)
+
+// Attributes sanitizeSection keeps: those allowed on any element, besides
+// aria-* and data-* ones, and those allowed on particular elements.
+var (
+	globalAttributes = map[string]bool{
+		"class": true, "dir": true, "epub:type": true, "hidden": true, "id": true, "lang": true,
+		"role": true, "style": true, "title": true, "xml:lang": true,
+	}
+	elementAttributes = map[string]map[string]bool{
+		"a":          {"href": true, "hreflang": true, "rel": true, "type": true},
+		"audio":      {"controls": true, "src": true},
+		"blockquote": {"cite": true},
+		"col":        {"span": true},
+		"colgroup":   {"span": true},
+		"data":       {"value": true},
+		"del":        {"cite": true, "datetime": true},
+		"details":    {"open": true},
+		"img":        {"alt": true, "height": true, "src": true, "width": true},
+		"ins":        {"cite": true, "datetime": true},
+		"li":         {"value": true},
+		"ol":         {"reversed": true, "start": true, "type": true},
+		"q":          {"cite": true},
+		"source":     {"src": true, "type": true},
+		"td":         {"colspan": true, "headers": true, "rowspan": true},
+		"th":         {"abbr": true, "colspan": true, "headers": true, "rowspan": true, "scope": true},
+		"time":       {"datetime": true},
+		"track":      {"kind": true, "label": true, "src": true, "srclang": true},
+		"video":      {"controls": true, "height": true, "poster": true, "src": true, "width": true},
+	}
+	urlAttributes = map[string]bool{"cite": true, "href": true, "poster": true, "src": true}
+	xmlAttrName   = regexp.MustCompile(`^[a-z][a-z0-9._-]*$`)
+)
+
+// sanitizeSection makes a section body valid EPUB content. It joins links
+// nested in links into one, then parses body as HTML, which closes unclosed
+// tags and fixes mis-nesting the way browsers do; drops scripts, frames, form
+// controls and the like along with their content; unwraps other elements
+// EPUB doesn't allow; keeps only allowed attributes; renders the result as
+// XHTML; and unwraps elements nested where XHTML doesn't allow them. It
+// returns an error if the result still isn't well-formed.
+func sanitizeSection(body string) (string, error) {
+	bodyNode := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(bytes.NewReader(unnestLinks([]byte(body))), bodyNode)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse section: %w", err)
+	}
+	for _, n := range nodes {
+		bodyNode.AppendChild(n)
+	}
+	sanitizeChildren(bodyNode)
+
+	var b strings.Builder
+	for c := bodyNode.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", fmt.Errorf("failed to render section: %w", err)
+		}
+	}
+	sanitized, err := normalizeNesting(b.String())
+	if err != nil {
+		return "", fmt.Errorf("malformed XHTML after sanitizing: %w", err)
+	}
+	if err := checkXHTML(sanitized); err != nil {
+		return "", fmt.Errorf("malformed XHTML after sanitizing: %w", err)
+	}
+	return sanitized, nil
+}
+
+// sanitizeChildren sanitizes the children of n in place.
+func sanitizeChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.ElementNode:
+			name := strings.ToLower(c.Data)
+			if renamed, ok := renamedElements[name]; ok {
+				name, c.Data, c.DataAtom = renamed, renamed, atom.Lookup([]byte(renamed))
+			}
+			switch {
+			case droppedElements[name]:
+				n.RemoveChild(c)
+			case c.Namespace != "":
+				sanitizeForeign(c) // Inline SVG or MathML
+			case allowedElements[name]:
+				c.Attr = allowedAttributes(name, c.Attr)
+				sanitizeChildren(c)
+			default:
+				sanitizeChildren(c)
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+			}
+		case html.DoctypeNode:
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// sanitizeForeign removes the scripts, event handlers and script links from
+// an inline SVG or MathML subtree, whose own vocabularies are kept.
+func sanitizeForeign(n *html.Node) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if !strings.HasPrefix(strings.ToLower(a.Key), "on") && !isScriptURL(a.Val) {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && (strings.EqualFold(c.Data, "script") || strings.EqualFold(c.Data, "foreignObject")) {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			sanitizeForeign(c)
+		}
+		c = next
+	}
+}
+
+// allowedAttributes returns the attributes of an element named name that
+// EPUB allows on it, leaving out any URL that would run a script.
+func allowedAttributes(name string, attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + key
+		}
+		allowed := globalAttributes[key] || elementAttributes[name][key] ||
+			(strings.HasPrefix(key, "aria-") || strings.HasPrefix(key, "data-")) && xmlAttrName.MatchString(key)
+		if !allowed || (urlAttributes[key] && isScriptURL(a.Val)) {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// isScriptURL reports whether rawURL is a javascript: or vbscript: URL.
+func isScriptURL(rawURL string) bool {
+	u := strings.ToLower(strings.TrimSpace(rawURL))
+	return strings.HasPrefix(u, "javascript:") || strings.HasPrefix(u, "vbscript:")
+}
@@ -0,0 +1,72 @@
+package converter
+
+import "testing"
+
+func TestSanitizeSection(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script dropped with content", `<p>Text<script>alert(1)</script></p>`, `<p>Text</p>`},
+		{"form controls dropped", `<p>A<input type="text"/><button>Go</button>B</p>`, `<p>AB</p>`},
+		{"unknown element unwrapped", `<p><font color="red">Red</font> text</p>`, `<p>Red text</p>`},
+		{"obsolete element renamed", `<center>Middle</center><p><tt>x</tt><strike>y</strike></p>`, `<div>Middle</div><p><code>x</code><s>y</s></p>`},
+		{"event handlers dropped", `<p onclick="evil()" class="c">Text</p>`, `<p class="c">Text</p>`},
+		{"script links dropped", `<a href="javascript:evil()">Link</a>`, `<a>Link</a>`},
+		{"data and aria kept", `<span data-x="1" aria-label="l" bogus="2">T</span>`, `<span data-x="1" aria-label="l">T</span>`},
+		{"unclosed tags closed", `<p>One<p>Two`, `<p>One</p><p>Two</p>`},
+		{"void elements self-closed", `<p>A<br>B</p><hr>`, `<p>A<br/>B</p><hr/>`},
+		{"named entities become characters", `<p>A&nbsp;B &copy;</p>`, "<p>A B ©</p>"},
+		{"markup characters escaped", `<p>1 &lt; 2 &amp; 3</p>`, `<p>1 &lt; 2 &amp; 3</p>`},
+		{"svg scripts dropped", `<svg onload="evil()"><script>x</script><circle r="1"></circle></svg>`, `<svg><circle r="1"></circle></svg>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeSection(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("sanitizeSection(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+			}
+			if err := checkXHTML(got); err != nil {
+				t.Errorf("result isn't well-formed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckXHTML(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{`<p>Text</p>`, false},
+		{`<p>A<br/>B</p>`, false},
+		{`<p>Unclosed`, true},
+		{`<p>A<br>B</p>`, true},
+		{`<p>A&nbsp;B</p>`, true},
+		{`<p><b>Mis</p></b>`, true},
+	}
+	for _, tt := range tests {
+		if err := checkXHTML(tt.body); (err != nil) != tt.wantErr {
+			t.Errorf("checkXHTML(%q) = %v, want error %v", tt.body, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrepareSectionsSanitizes(t *testing.T) {
+	prepared := prepareSections([]Section{
+		{Title: "Fine", Body: `<p>Text</p>`},
+		{Title: "Fixed", Body: `<p>Unclosed<div>block</div>`},
+	}, Options{})
+	for _, p := range prepared {
+		if p.err != nil {
+			t.Errorf("section %q: %v", p.section.Title, p.err)
+		}
+		if err := checkXHTML(p.section.Body); err != nil {
+			t.Errorf("section %q isn't well-formed: %v", p.section.Title, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveHTML(t *testing.T) {
+	page := "<html><body><h3>Saved</h3><p>Exactly these bytes.</p></body></html>"
+	srv := fileServer(t, map[string]servedFile{
+		"/page.html": {"text/html; charset=utf-8", []byte(page)},
+	})
+	dir := t.TempDir()
+
+	t.Run("written", func(t *testing.T) {
+		file := filepath.Join(dir, "page.html")
+		if err := SaveHTML(context.Background(), srv.URL+"/page.html", file); err != nil {
+			t.Fatalf("SaveHTML: %v", err)
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != page {
+			t.Errorf("saved %q, want %q", got, page)
+		}
+	})
+
+	t.Run("failed fetch leaves the file", func(t *testing.T) {
+		file := filepath.Join(dir, "kept.html")
+		if err := os.WriteFile(file, []byte("earlier copy"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ctx := Options{Retries: -1}.withRetryPolicy(context.Background())
+		if err := SaveHTML(ctx, srv.URL+"/missing.html", file); err == nil {
+			t.Fatal("SaveHTML of a missing page succeeded")
+		}
+		got, err := os.ReadFile(file)
+		if err != nil || string(got) != "earlier copy" {
+			t.Errorf("file after a failed fetch = %q, %v; want it untouched", got, err)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 2 {
+			t.Errorf("directory holds %d files, want no temporary ones left", len(entries))
+		}
+	})
+}
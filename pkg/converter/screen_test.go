@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseScreenPreset(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    image.Point
+		wantErr bool
+	}{
+		{"", image.Point{}, false},
+		{"phone", image.Pt(720, 1280), false},
+		{"Kindle", image.Pt(1072, 1448), false},
+		{"watch", image.Point{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScreenPreset(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseScreenPreset(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPhonePresetCapsImages(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/wide.png":  {"image/png", testPNG(t, 1600, 400, color.White)},
+		"/tall.png":  {"image/png", testPNG(t, 300, 2000, color.Black)},
+		"/small.png": {"image/png", testPNG(t, 100, 50, color.White)},
+	})
+	page := `<html><body><h3>One</h3><p>Text.</p><img src="` + srv.URL + `/wide.png" alt="Wide">` +
+		`<img src="` + srv.URL + `/tall.png" alt="Tall"><img src="` + srv.URL + `/small.png" alt="Small"></body></html>`
+	phone, err := ParseScreenPreset("phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, files := testBuild(t, page, Options{MaxImageSize: phone})
+
+	var sizes []image.Point
+	for name, data := range files {
+		if !strings.HasPrefix(name, "EPUB/images/") {
+			continue
+		}
+		cfg, _, err := image.DecodeConfig(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sizes = append(sizes, image.Pt(cfg.Width, cfg.Height))
+		if cfg.Width > phone.X || cfg.Height > phone.Y {
+			t.Errorf("%s is %dx%d, bigger than the phone's %dx%d", name, cfg.Width, cfg.Height, phone.X, phone.Y)
+		}
+	}
+	want := map[image.Point]bool{image.Pt(720, 180): true, image.Pt(192, 1280): true, image.Pt(100, 50): true}
+	if len(sizes) != len(want) {
+		t.Fatalf("embedded images %v, want %d", sizes, len(want))
+	}
+	for _, s := range sizes {
+		if !want[s] {
+			t.Errorf("image is %v, want one of %v scaled to fit, keeping its aspect ratio", s, want)
+		}
+	}
+}
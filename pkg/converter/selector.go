@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// Selector is a group of CSS selectors, as in "article.main" or ".ads,
+// .share-buttons, nav", matched with the cascadia engine. The zero Selector
+// matches nothing.
+type Selector struct {
+	text  string
+	group cascadia.SelectorGroup
+}
+
+// ParseSelector parses a -select or -remove value: a comma-separated group
+// of CSS selectors.
+func ParseSelector(s string) (Selector, error) {
+	group, err := cascadia.ParseGroup(s)
+	if err != nil {
+		return Selector{}, fmt.Errorf("invalid selector '%s': %w", s, err)
+	}
+	return Selector{text: strings.TrimSpace(s), group: group}, nil
+}
+
+func (s Selector) String() string { return s.text }
+
+// empty reports whether s is the zero Selector.
+func (s Selector) empty() bool { return len(s.group) == 0 }
+
+// matches reports whether element n matches any selector in s.
+func (s Selector) matches(n *html.Node) bool {
+	return n.Type == html.ElementNode && s.group.Match(n)
+}
+
+// matchAll returns the elements in the tree under root that match s, in
+// document order, leaving out those inside another match.
+func (s Selector) matchAll(root *html.Node) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if s.matches(n) {
+			found = append(found, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
+
+// selectContent drops the elements of src's body that remove matches, then
+// narrows the body to the elements that sel matches, in document order. A
+// page sel matches nothing in is kept whole, with a warning.
+func selectContent(src *source, sel, remove Selector) {
+	if sel.empty() && remove.empty() {
+		return
+	}
+	body := findElement(src.doc, "body")
+	if body == nil {
+		body = src.doc
+	}
+	for _, n := range remove.matchAll(body) {
+		n.Parent.RemoveChild(n)
+	}
+	if sel.empty() {
+		return
+	}
+	kept := sel.matchAll(body)
+	if len(kept) == 0 {
+		parserLog.Warn("Nothing on the page matches the content selector; keeping it whole", "source", src.name, "selector", sel.String())
+		return
+	}
+	for _, n := range kept {
+		n.Parent.RemoveChild(n)
+	}
+	for c := body.FirstChild; c != nil; c = body.FirstChild {
+		body.RemoveChild(c)
+	}
+	for _, n := range kept {
+		body.AppendChild(n)
+	}
+}
@@ -0,0 +1,88 @@
+package converter
+
+import "testing"
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		sel     string
+		wantErr bool
+	}{
+		{"article.main", false},
+		{".ads, .share-buttons, nav", false},
+		{"div > p:first-child", false},
+		{"p:nth-child(2)", false},
+		{"li:not(.keep) + li", false},
+		{`a[href^="https:"]`, false},
+		{"div >", true},
+		{"[unclosed", true},
+		{"p:nope", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseSelector(tt.sel)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSelector(%q) error = %v, want error %v", tt.sel, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		selectExpr string
+		removeExpr string
+		want       string
+	}{
+		{
+			name:       "select keeps matches in order",
+			body:       `<nav>menu</nav><article class="main"><p>One</p></article><aside>ad</aside><article class="main"><p>Two</p></article>`,
+			selectExpr: "article.main",
+			want:       `<article class="main"><p>One</p></article><article class="main"><p>Two</p></article>`,
+		},
+		{
+			name:       "remove drops matches first",
+			body:       `<div><p>Keep</p><div class="ads">Buy</div><p class="share">Share</p></div>`,
+			removeExpr: ".ads, .share",
+			want:       `<div><p>Keep</p></div>`,
+		},
+		{
+			name:       "nth-child",
+			body:       `<ul><li>a</li><li>b</li><li>c</li></ul>`,
+			selectExpr: "li:nth-child(2)",
+			want:       `<li>b</li>`,
+		},
+		{
+			name:       "nested matches are kept once",
+			body:       `<div class="c"><div class="c">x</div></div>`,
+			selectExpr: ".c",
+			want:       `<div class="c"><div class="c">x</div></div>`,
+		},
+		{
+			name:       "no match keeps the page whole",
+			body:       `<p>All</p>`,
+			selectExpr: "article",
+			want:       `<p>All</p>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sel, remove Selector
+			var err error
+			if tt.selectExpr != "" {
+				if sel, err = ParseSelector(tt.selectExpr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.removeExpr != "" {
+				if remove, err = ParseSelector(tt.removeExpr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			src := testSource(t, tt.body)
+			selectContent(src, sel, remove)
+			if got := bodyHTML(t, src.doc); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
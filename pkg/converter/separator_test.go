@@ -0,0 +1,53 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSourceSeparator(t *testing.T) {
+	srv := fileServer(t, map[string]servedFile{
+		"/one.html": {"text/html", []byte(`<html><head><title>Morning</title></head><body><h3>Dawn</h3><p>First.</p></body></html>`)},
+		"/two.html": {"text/html", []byte(`<html><head><title>Evening</title></head><body><h3>Dusk</h3><p>Second.</p></body></html>`)},
+	})
+	tests := []struct {
+		name      string
+		separator string
+		want      []string
+	}{
+		{"none", "", []string{"Dawn", "Dusk"}},
+		{"labelled", "Part {{.Index}}: {{.Title}}", []string{"Dawn", "Part 2: Evening", "Dusk"}},
+		{"empty label", "{{if false}}x{{end}}", []string{"Dawn", "Dusk"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{SourceURLs: []string{srv.URL + "/one.html", srv.URL + "/two.html"}, SourceSeparator: tt.separator}
+			result, files := testBuild(t, "", opts)
+			var titles []string
+			for _, s := range result.Summary().Sections {
+				titles = append(titles, s.Title)
+			}
+			if strings.Join(titles, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("sections = %q, want %q", titles, tt.want)
+			}
+			if tt.separator == "" || len(tt.want) == 2 {
+				return
+			}
+			body := sectionFile(t, result, files, "Part 2: Evening")
+			if !strings.Contains(body, `<div class="source-separator"><p>Part 2: Evening</p></div>`) {
+				t.Errorf("separator section lacks its label:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestSourceSeparatorInvalidTemplate(t *testing.T) {
+	opts := Options{SourceHTML: []byte("<p>Text.</p>"), SourceSeparator: "{{.Index", ImageDir: t.TempDir()}
+	for _, err := range Sections(t.Context(), opts) {
+		if err == nil || !strings.Contains(err.Error(), "separator") {
+			t.Errorf("error = %v, want an invalid separator template", err)
+		}
+		return
+	}
+	t.Error("no error for an invalid separator template")
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSidecarMetadataPrecedence(t *testing.T) {
+	sidecarPath := filepath.Join(t.TempDir(), "book.json")
+	sidecar := `{
+  "title": "Sidecar Title",
+  "author": "Sidecar Author",
+  "language": "fr",
+  "identifiers": ["isbn:9780306406157"],
+  "series": "Sidecar Series",
+  "description": "From the sidecar.",
+  "subjects": ["Fiction", "Sea stories"]
+}`
+	if err := os.WriteFile(sidecarPath, []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := LoadMetadata(sidecarPath)
+	if err != nil {
+		t.Fatalf("LoadMetadata: %v", err)
+	}
+	flags := Metadata{Title: "Flag Title"}
+
+	page := `<html lang="en"><head><title>Page Title</title>
+<meta name="author" content="Page Author"><meta name="description" content="From the page.">
+</head><body><h3>One</h3><p>Text.</p></body></html>`
+	_, files := testBuild(t, page, Options{Metadata: meta.Merge(flags)})
+	opf := files[packageDocumentPath]
+
+	for _, want := range []string{
+		">Flag Title</dc:title>",
+		">Sidecar Author</dc:creator>",
+		">fr</dc:language>",
+		">isbn:9780306406157</dc:identifier>",
+		">Sidecar Series</meta>",
+		">From the sidecar.</dc:description>",
+		">Fiction</dc:subject>",
+		">Sea stories</dc:subject>",
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document lacks %s", want)
+		}
+	}
+	for _, unwanted := range []string{"Sidecar Title", "Page Title", "Page Author", "From the page."} {
+		if strings.Contains(opf, unwanted) {
+			t.Errorf("package document has overridden %q", unwanted)
+		}
+	}
+	if t.Failed() {
+		t.Log(opf)
+	}
+}
@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// anchoredSection is a section merged into a single-file book, with the id
+// of the element it starts at.
+type anchoredSection struct {
+	Title string
+	ID    string
+	Level int
+}
+
+var (
+	idAttrPattern         = regexp.MustCompile(`\bid="([^"]*)"`)
+	leadingHeadingPattern = regexp.MustCompile(`^\s*(?:<section\b[^>]*>\s*)?<h[1-6][\s>]`)
+	leadingParaPattern    = regexp.MustCompile(`^\s*<p>([^<]*)</p>`)
+)
+
+// mergeSections joins sections into a single one titled title, each wrapped
+// in a <section> whose id the returned anchors list in reading order. A
+// section whose body doesn't already start with a heading gets its title as
+// one, in place of the paragraph the extractor wrote the heading's text to.
+// Links to the endnotes section point into the merged file instead.
+func mergeSections(sections []Section, title string) (Section, []anchoredSection) {
+	used := make(map[string]bool)
+	for _, s := range sections {
+		for _, m := range idAttrPattern.FindAllStringSubmatch(s.Body, -1) {
+			used[m[1]] = true
+		}
+	}
+
+	merged := Section{Title: title}
+	var b strings.Builder
+	var anchors []anchoredSection
+	for i, s := range sections {
+		id := fmt.Sprintf("part-%d", i+1)
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("part-%d-%d", i+1, n)
+		}
+		used[id] = true
+		anchors = append(anchors, anchoredSection{Title: s.Title, ID: id, Level: s.Level})
+
+		b.WriteString(fmt.Sprintf(`<section id="%s">`, id))
+		body := s.Body
+		if !leadingHeadingPattern.MatchString(body) {
+			b.WriteString("<h2>" + html.EscapeString(s.Title) + "</h2>")
+			body = withoutTitleParagraph(body, s.Title)
+		}
+		b.WriteString(strings.ReplaceAll(body, `href="`+endnotesFilename+`#`, `href="#`))
+		b.WriteString("</section>")
+
+		if merged.CSS == "" {
+			merged.CSS = s.CSS
+		} else if s.CSS != "" && s.CSS != merged.CSS {
+			writerLog.Warn("Section has its own stylesheet, but a single file can only link one; using the first", "section", s.Title)
+		}
+		if merged.firstImage == "" {
+			merged.firstImage = s.firstImage
+		}
+		if merged.Source == "" {
+			merged.Source = s.Source
+		}
+	}
+	merged.Body = b.String()
+	return merged, anchors
+}
+
+// withoutTitleParagraph returns body without its first paragraph if that
+// just repeats title, as the text of the heading the section starts at does.
+// Title casing may have changed the title's case.
+func withoutTitleParagraph(body, title string) string {
+	m := leadingParaPattern.FindStringSubmatchIndex(body)
+	if m == nil {
+		return body
+	}
+	text := strings.Join(strings.Fields(html.UnescapeString(body[m[2]:m[3]])), " ")
+	if text == "" || !strings.EqualFold(text, strings.Join(strings.Fields(title), " ")) {
+		return body
+	}
+	return body[m[1]:]
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeSections(t *testing.T) {
+	tests := []struct {
+		name        string
+		sections    []Section
+		wantBody    string
+		wantAnchors []anchoredSection
+	}{
+		{
+			name: "heading paragraph becomes the section heading once",
+			sections: []Section{
+				{Title: "One", Level: 1, Body: `<p>One </p><p>First. </p>`},
+				{Title: "Two", Level: 1, Body: `<p>Two </p><p>Second. </p>`},
+			},
+			wantBody:    `<section id="part-1"><h2>One</h2><p>First. </p></section><section id="part-2"><h2>Two</h2><p>Second. </p></section>`,
+			wantAnchors: []anchoredSection{{"One", "part-1", 1}, {"Two", "part-2", 1}},
+		},
+		{
+			name:     "title casing changed the title",
+			sections: []Section{{Title: "The Long Road", Body: `<p>THE LONG ROAD </p><p>Text. </p>`}},
+			wantBody: `<section id="part-1"><h2>The Long Road</h2><p>Text. </p></section>`,
+		},
+		{
+			name:     "body not starting with the title keeps its first paragraph",
+			sections: []Section{{Title: "Introduction", Body: `<p>Before any heading. </p>`}},
+			wantBody: `<section id="part-1"><h2>Introduction</h2><p>Before any heading. </p></section>`,
+		},
+		{
+			name:     "body with its own heading gets none added",
+			sections: []Section{{Title: "Notes", Body: `<h2>Notes</h2><p>A note. </p>`}},
+			wantBody: `<section id="part-1"><h2>Notes</h2><p>A note. </p></section>`,
+		},
+		{
+			name:     "escaped title",
+			sections: []Section{{Title: "Q&A", Body: `<p>Q&amp;A </p><p>Yes. </p>`}},
+			wantBody: `<section id="part-1"><h2>Q&amp;A</h2><p>Yes. </p></section>`,
+		},
+		{
+			name:     "ids already used are avoided",
+			sections: []Section{{Title: "One", Body: `<p id="part-1">One </p>`}},
+			wantBody: `<section id="part-1-2"><h2>One</h2><p id="part-1">One </p></section>`,
+		},
+		{
+			name:     "endnote links point into the file",
+			sections: []Section{{Title: "One", Body: `<p>See <a href="` + endnotesFilename + `#note-1">1</a></p>`}},
+			wantBody: `<section id="part-1"><h2>One</h2><p>See <a href="#note-1">1</a></p></section>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, anchors := mergeSections(tt.sections, "Book")
+			if merged.Title != "Book" {
+				t.Errorf("title = %q, want Book", merged.Title)
+			}
+			if merged.Body != tt.wantBody {
+				t.Errorf("body\ngot  %s\nwant %s", merged.Body, tt.wantBody)
+			}
+			if tt.wantAnchors != nil && !reflect.DeepEqual(anchors, tt.wantAnchors) {
+				t.Errorf("anchors = %v, want %v", anchors, tt.wantAnchors)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSkipElements are the elements left out of extraction, contents and
+// all, unless Options.SkipElements says otherwise: site navigation, headers
+// and footers, and sidebars.
+var DefaultSkipElements = []string{"nav", "footer", "aside", "header"}
+
+// ParseSkipElements parses a -skip value: a comma-separated list of element
+// names. An empty value skips nothing.
+func ParseSkipElements(s string) ([]string, error) {
+	elements := []string{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if strings.ContainsFunc(name, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-')
+		}) {
+			return nil, fmt.Errorf("invalid element name '%s' (want e.g. nav,footer)", name)
+		}
+		elements = append(elements, name)
+	}
+	return elements, nil
+}
+
+// skippedElements returns the set of elements the build described by opts
+// doesn't extract.
+func (opts Options) skippedElements() map[string]bool {
+	elements := opts.SkipElements
+	if elements == nil {
+		elements = DefaultSkipElements
+	}
+	skip := make(map[string]bool, len(elements))
+	for _, name := range elements {
+		skip[strings.ToLower(name)] = true
+	}
+	return skip
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSkipElements(t *testing.T) {
+	body := `<header><p>Site header.</p></header><nav><ul><li>Home</li><li>About</li></ul></nav>` +
+		`<h3>One</h3><p>Content.</p><aside><p>Related posts.</p></aside><figure><p>Diagram notes.</p></figure>` +
+		`<footer><p>Copyright.</p></footer>`
+	tests := []struct {
+		name     string
+		skip     []string
+		want     []string
+		unwanted []string
+	}{
+		{
+			name:     "default",
+			want:     []string{"Content.", "Diagram notes."},
+			unwanted: []string{"Site header.", "Home", "About", "Related posts.", "Copyright."},
+		},
+		{
+			name: "none",
+			skip: []string{},
+			want: []string{"Content.", "Site header.", "Home", "Related posts.", "Copyright."},
+		},
+		{
+			name:     "configured",
+			skip:     []string{"FIGURE", "aside"},
+			want:     []string{"Content.", "Site header.", "Home", "Copyright."},
+			unwanted: []string{"Related posts.", "Diagram notes."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var all strings.Builder
+			for _, s := range testSections(t, body, Options{SkipElements: tt.skip}) {
+				all.WriteString(s.Body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(all.String(), want) {
+					t.Errorf("sections lack %q:\n%s", want, all.String())
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(all.String(), unwanted) {
+					t.Errorf("sections have %q:\n%s", unwanted, all.String())
+				}
+			}
+		})
+	}
+}
+
+func TestParseSkipElements(t *testing.T) {
+	got, err := ParseSkipElements(" Nav, footer,,my-widget ")
+	if err != nil || strings.Join(got, ",") != "nav,footer,my-widget" {
+		t.Errorf("ParseSkipElements = %q, %v, want nav, footer and my-widget", got, err)
+	}
+	if got, err := ParseSkipElements(""); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("ParseSkipElements of nothing = %#v, %v, want an empty list", got, err)
+	}
+	if _, err := ParseSkipElements("div.ad"); err == nil {
+		t.Error("ParseSkipElements accepted a selector")
+	}
+}
@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// source is one parsed input document.
+type source struct {
+	doc     *html.Node
+	baseURL *url.URL // For resolving relative links and images
+	name    string   // Archive entry name or URL, for messages and separator labels
+	title   string   // Title of any content before the first heading
+
+	meta           Metadata // Metadata read from the page's text, e.g. a Project Gutenberg header
+	chapterHeading string   // If set, the heading element its chapters start with, unless SectionHeadings says
+
+	parseTime time.Duration // How long parsing it took, for the build metrics
+
+	// loadImage returns the media location of the image at u.
+	loadImage func(ctx context.Context, u *url.URL) (string, error)
+	// fetch returns the contents of the resource at u, e.g. a stylesheet.
+	fetch func(ctx context.Context, u *url.URL) ([]byte, error)
+}
+
+// leadingTitle returns the title of the content before the first heading of
+// a page: configured if set, otherwise the page's <title>, otherwise
+// "Introduction".
+func leadingTitle(doc *html.Node, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if t := findElement(doc, "title"); t != nil {
+		if title := strings.TrimSpace(getText(t)); title != "" {
+			return title
+		}
+	}
+	return "Introduction"
+}
+
+// LoadURLs reads a list of pages to convert, one URL per line. Blank lines
+// and lines starting with '#' are ignored.
+func LoadURLs(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read URL list '%s': %w", file, err)
+	}
+	var urls []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := parseBaseURL(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, i+1, err)
+		}
+		urls = append(urls, line)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("URL list '%s' has no URLs", file)
+	}
+	return urls, nil
+}
+
+// sourceURL returns the page the book is made from, or its first page when
+// it is made from several; "" if that isn't a web page. It takes the inputs
+// in the order readSources does.
+func (opts Options) sourceURL() string {
+	switch {
+	case opts.Archive != "":
+		return ""
+	case len(opts.Inputs) > 0:
+		return opts.Inputs[0].URL
+	case len(opts.Paths) > 0:
+		return ""
+	case len(opts.SourceURLs) > 0:
+		return opts.SourceURLs[0]
+	}
+	return opts.SourceURL
+}
+
+// loadSources fetches or reads the documents opts describes, stripped of
+// Project Gutenberg's boilerplate in Gutenberg mode.
+func loadSources(ctx context.Context, opts Options) ([]*source, error) {
+	ctx, err := opts.withJobState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := readSources(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range sources {
+		if opts.Gutenberg {
+			stripGutenberg(src)
+			if opts.LeadingTitle == "" && src.meta.Title != "" {
+				src.title = src.meta.Title // The <title> names Project Gutenberg too
+			}
+		}
+		applyRecipe(src, opts)
+	}
+	return sources, nil
+}
+
+// readSources fetches, reads and parses the input documents, as they are.
+func readSources(ctx context.Context, opts Options) ([]*source, error) {
+	if opts.Archive != "" {
+		return loadArchive(opts.Archive, opts.mediaStore())
+	}
+	if len(opts.Inputs) > 0 {
+		return loadInputs(ctx, opts)
+	}
+	if len(opts.Paths) > 0 {
+		return loadLocal(opts.Paths, opts.mediaStore())
+	}
+	if len(opts.SourceURLs) > 0 {
+		return loadPages(ctx, opts, opts.SourceURLs)
+	}
+	if opts.Crawl > 0 {
+		return crawlSources(ctx, opts)
+	}
+	src, err := loadPage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SourceHTML == nil {
+		progressFrom(ctx).step(ProgressPages, 1, opts.SourceURL)
+	}
+	return []*source{src}, nil
+}
+
+// loadPage fetches, or reads from the cache or SourceHTML, the single page
+// SourceURL names, following any meta refresh as opts allow.
+func loadPage(ctx context.Context, opts Options) (*source, error) {
+	// Fetch or load the HTML content, unless it was handed over
+	var body []byte
+	var baseURL *url.URL
+	var err error
+	saved := false // Fetched by an earlier run of a resumed build
+	if opts.SourceHTML != nil {
+		body = opts.SourceHTML
+		baseURL, err = url.Parse(opts.SourceURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse base URL '%s': %w", opts.SourceURL, err)
+		}
+	} else if b, u, ok := jobStateFrom(ctx).page(opts.SourceURL); ok {
+		body, baseURL, saved = b, u, true
+		fetcherLog.Debug("Using page saved by an earlier run", "url", opts.SourceURL)
+	} else {
+		cache := opts.HTMLCache
+		if opts.InMemory || opts.CacheDir != "" {
+			cache = "" // The cache directory revalidates what the single file wouldn't
+		}
+		body, baseURL, err = fetchOrLoadHTML(ctx, opts.SourceURL, cache, opts.httpCache())
+		if err != nil {
+			return nil, fmt.Errorf("error fetching or loading HTML: %w", err)
+		}
+		fetcherLog.Info("Fetched page", "url", opts.SourceURL, "bytes", len(body))
+	}
+
+	// Parse the HTML
+	parseStart := time.Now()
+	doc, err := html.Parse(bytes.NewReader(unnestLinks(body)))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %w", err)
+	}
+	parseTime := time.Since(parseStart)
+
+	// Landing pages that redirect with a meta refresh have no content of their own
+	for hops := 0; ; hops++ {
+		target := metaRefreshTarget(doc, baseURL)
+		if target == nil || target.String() == baseURL.String() {
+			break
+		}
+		if !opts.FollowRefresh {
+			fetcherLog.Warn("Page redirects with a meta refresh; use -follow-refresh to convert the target instead", "url", target.String())
+			break
+		}
+		if hops == maxRefreshHops {
+			return nil, fmt.Errorf("error following meta refresh: more than %d redirects", maxRefreshHops)
+		}
+		body, err = fetchPage(ctx, target.String(), opts.httpCache())
+		if err != nil {
+			return nil, fmt.Errorf("error following meta refresh: %w", err)
+		}
+		parseStart = time.Now()
+		doc, err = html.Parse(bytes.NewReader(unnestLinks(body)))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing HTML from '%s': %w", target, err)
+		}
+		parseTime += time.Since(parseStart)
+		baseURL = target
+	}
+
+	if opts.SourceHTML == nil && !saved {
+		if err := jobStateFrom(ctx).record(opts.SourceURL, baseURL, body); err != nil {
+			fetcherLog.Warn("Could not save progress", "url", opts.SourceURL, "err", err)
+		}
+	}
+
+	// The page's own URL names it; a <base> element only affects what its
+	// references resolve against
+	name := baseURL.String()
+	baseURL = documentBase(doc, baseURL)
+
+	return &source{
+		doc:     doc,
+		baseURL: baseURL,
+		name:    name,
+		title:   leadingTitle(doc, opts.LeadingTitle),
+
+		parseTime: parseTime,
+		loadImage: func(ctx context.Context, u *url.URL) (string, error) {
+			return fetchImage(ctx, u, opts.mediaStore())
+		},
+		fetch: func(ctx context.Context, u *url.URL) ([]byte, error) {
+			return fetchHTML(ctx, u.String(), opts.httpCache())
+		},
+	}, nil
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceURL(t *testing.T) {
+	const page = "https://example.com/book.html"
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "single page", opts: Options{SourceURL: page}, want: page},
+		{name: "pages", opts: Options{SourceURL: "https://example.com/default", SourceURLs: []string{page, "https://example.com/2.html"}}, want: page},
+		{name: "URL input", opts: Options{SourceURL: "https://example.com/default", Inputs: []Input{{URL: page}}}, want: page},
+		{name: "file input", opts: Options{SourceURL: "https://example.com/default", Inputs: []Input{{File: "book.html"}}}},
+		{name: "local files", opts: Options{SourceURL: "https://example.com/default", Paths: []string{"book.html"}}},
+		{name: "local files win over pages", opts: Options{SourceURLs: []string{page}, Paths: []string{"book.html"}}},
+		{name: "archive", opts: Options{SourceURL: "https://example.com/default", Archive: "book.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.sourceURL(); got != tt.want {
+				t.Errorf("sourceURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalFilesHaveNoSourceLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.html")
+	page := `<html><head><title>Local Book</title></head><body><h3>One</h3><p>Text.</p></body></html>`
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, files := testBuild(t, "", Options{
+		SourceURL:   "https://www.gutenberg.org/cache/epub/1/pg1-images.html",
+		Paths:       []string{path},
+		Attribution: true,
+		Colophon:    true,
+	})
+
+	attribution := sectionFile(t, result, files, "Attribution")
+	if !strings.Contains(attribution, "<em>Local Book</em>.</p>") {
+		t.Errorf("attribution doesn't credit the book alone:\n%s", attribution)
+	}
+	colophon := sectionFile(t, result, files, "Colophon")
+	for name, body := range map[string]string{"attribution": attribution, "colophon": colophon} {
+		if strings.Contains(body, "gutenberg") || strings.Contains(body, "converted from") || strings.Contains(body, "Source:") {
+			t.Errorf("%s names a source for local files:\n%s", name, body)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jobState is the progress of a build kept in Options.StatePath: the pages
+// fetched so far, so a build that was interrupted can pick up where it left
+// off. Their bodies are kept in a directory next to the state file, and
+// images already downloaded to ImageDir are reused as they always are.
+type jobState struct {
+	path string
+	mu   sync.Mutex
+
+	Start string               `json:"start"` // The page the build starts from, or the first of its list
+	Pages map[string]statePage `json:"pages"` // By the URL asked for
+}
+
+// statePage is a fetched page in a jobState.
+type statePage struct {
+	URL  string `json:"url"`  // Where the page was found, after any meta refresh
+	File string `json:"file"` // Its body, in the state's page directory
+}
+
+// jobStateKey carries a build's jobState in its context.
+type jobStateKey struct{}
+
+// startURL returns the page the build described by opts starts from, which
+// a state file must have been written for to be resumed.
+func (opts Options) startURL() string {
+	switch {
+	case len(opts.SourceURLs) > 0:
+		return opts.SourceURLs[0]
+	case len(opts.Inputs) > 0:
+		return opts.Inputs[0].URL + opts.Inputs[0].File + opts.Inputs[0].Markdown
+	}
+	return opts.SourceURL
+}
+
+// withJobState returns ctx carrying the job state in opts.StatePath, read
+// from the file if an earlier build of the same pages left one, or started
+// afresh otherwise.
+func (opts Options) withJobState(ctx context.Context) (context.Context, error) {
+	if opts.StatePath == "" {
+		return ctx, nil
+	}
+	st := &jobState{path: opts.StatePath, Start: opts.startURL(), Pages: make(map[string]statePage)}
+	data, err := os.ReadFile(opts.StatePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read state file '%s': %w", opts.StatePath, err)
+	default:
+		var saved jobState
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("failed to parse state file '%s': %w", opts.StatePath, err)
+		}
+		if saved.Start != st.Start {
+			fetcherLog.Warn("State file is for another book; starting over", "state", opts.StatePath, "start", saved.Start)
+		} else if len(saved.Pages) > 0 {
+			fetcherLog.Info("Resuming from state file", "state", opts.StatePath, "pages", len(saved.Pages))
+			st.Pages = saved.Pages
+		}
+	}
+	return context.WithValue(ctx, jobStateKey{}, st), nil
+}
+
+// jobStateFrom returns the job state ctx carries, or nil.
+func jobStateFrom(ctx context.Context) *jobState {
+	st, _ := ctx.Value(jobStateKey{}).(*jobState)
+	return st
+}
+
+// pageDir is the directory the bodies of the state's pages are kept in.
+func (st *jobState) pageDir() string {
+	return st.path + ".pages"
+}
+
+// page returns the body of the page fetched for urlStr by an earlier run and
+// where it was found, if the state has it.
+func (st *jobState) page(urlStr string) ([]byte, *url.URL, bool) {
+	if st == nil {
+		return nil, nil, false
+	}
+	st.mu.Lock()
+	p, ok := st.Pages[urlStr]
+	st.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, nil, false
+	}
+	body, err := os.ReadFile(filepath.Join(st.pageDir(), p.File))
+	if err != nil {
+		fetcherLog.Warn("Fetching page again; its saved copy is unreadable", "url", urlStr, "err", err)
+		return nil, nil, false
+	}
+	return body, u, true
+}
+
+// record saves body, fetched for urlStr from u, and the state that now
+// includes it, so a later run doesn't fetch it again.
+func (st *jobState) record(urlStr string, u *url.URL, body []byte) error {
+	if st == nil {
+		return nil
+	}
+	file := shortHash([]byte(urlStr)) + ".html"
+	if err := os.MkdirAll(st.pageDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", st.pageDir(), err)
+	}
+	if err := writeFileAtomic(filepath.Join(st.pageDir(), file), body); err != nil {
+		return fmt.Errorf("failed to save page '%s': %w", urlStr, err)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.Pages[urlStr] = statePage{URL: u.String(), File: file}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(st.path, data); err != nil { // An interrupted write leaves the last state
+		return fmt.Errorf("failed to save state file '%s': %w", st.path, err)
+	}
+	return nil
+}
+
+// removeJobState deletes the state file at path and its pages once the book
+// is written.
+func removeJobState(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.RemoveAll(path + ".pages"); err != nil {
+		fetcherLog.Warn("Could not remove saved pages", "dir", path+".pages", "err", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fetcherLog.Warn("Could not remove state file", "state", path, "err", err)
+	}
+}
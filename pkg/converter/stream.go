@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+
+	"github.com/go-shiori/go-epub"
+)
+
+// Sections extracts the documents described by opts and yields their
+// sections one at a time, as soon as each is complete, without building an
+// EPUB or holding the whole book in memory. Images are still downloaded to
+// opts.ImageDir, or the configured media store; the src of each <img> is the path it would have inside an
+// EPUB built with the same options. Stopping the iteration early stops the
+// extraction. An error is yielded once, as the last pair.
+func Sections(ctx context.Context, opts Options) iter.Seq2[Section, error] {
+	return func(yield func(Section, error) bool) {
+		ctx := opts.withProgress(opts.withRetryPolicy(ctx))
+		sources, err := loadSources(ctx, opts)
+		if err != nil {
+			yield(Section{}, err)
+			return
+		}
+		if _, ok := opts.mediaStore().(dirStore); ok {
+			if err := os.MkdirAll(opts.ImageDir, 0755); err != nil {
+				yield(Section{}, fmt.Errorf("error creating temp image directory: %w", err))
+				return
+			}
+		}
+
+		// The EPUB is only used to assign internal image paths
+		e, err := epub.NewEpub(opts.Metadata.Title)
+		if err != nil {
+			yield(Section{}, fmt.Errorf("error creating EPUB: %w", err))
+			return
+		}
+		x, err := newExtractor(ctx, opts, e, &Result{})
+		if err != nil {
+			yield(Section{}, err)
+			return
+		}
+		x.emit = func(s Section) bool {
+			sections := []Section{s}
+			x.resolveLinks(sections) // Links out of a section have no file to point at yet
+			return yield(sections[0], nil)
+		}
+		x.imageTotal = x.countImages(sources)
+		x.collectLinks(sources)
+		for _, src := range sources {
+			x.extract(src)
+		}
+		if x.stopped {
+			return
+		}
+		if ctx.Err() != nil {
+			yield(Section{}, ctx.Err())
+			return
+		}
+		x.finish()
+	}
+}
@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSectionsIterator(t *testing.T) {
+	pic := testPNG(t, 2, 2, color.Black)
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/page-1.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head><body><h3>One</h3><p>First.</p><h3>Two</h3><p>Second.</p></body></html>`))
+		case "/page-2.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title></head><body><h3>Three</h3><p>Third.</p><img src="pic.png" alt="Picture"></body></html>`))
+		case "/pic.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pic)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	opts := func() Options {
+		return Options{SourceURLs: []string{srv.URL + "/page-1.html", srv.URL + "/page-2.html"}, ImageDir: t.TempDir(), Retries: -1}
+	}
+
+	t.Run("titles", func(t *testing.T) {
+		var titles []string
+		for s, err := range Sections(context.Background(), opts()) {
+			if err != nil {
+				t.Fatalf("Sections: %v", err)
+			}
+			titles = append(titles, s.Title)
+			if s.Title == "Three" && !strings.Contains(s.Body, `src="../images/pic.png"`) {
+				t.Errorf("section Three lacks its image: %s", s.Body)
+			}
+		}
+		if strings.Join(titles, "|") != "One|Two|Three" {
+			t.Errorf("sections = %q, want One, Two and Three", titles)
+		}
+	})
+
+	t.Run("stopping early", func(t *testing.T) {
+		mu.Lock()
+		clear(requested)
+		mu.Unlock()
+		var titles []string
+		for s, err := range Sections(context.Background(), opts()) {
+			if err != nil {
+				t.Fatalf("Sections: %v", err)
+			}
+			titles = append(titles, s.Title)
+			break
+		}
+		if strings.Join(titles, "|") != "One" {
+			t.Errorf("sections = %q, want only One", titles)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if n := requested["/pic.png"]; n != 0 {
+			t.Errorf("the second page's image was requested %d times after the iteration stopped", n)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		o := opts()
+		o.SourceURLs = append(o.SourceURLs, srv.URL+"/missing.html")
+		var pairs int
+		var last error
+		for s, err := range Sections(context.Background(), o) {
+			pairs++
+			if err != nil && s.Title != "" {
+				t.Errorf("error yielded with section %q", s.Title)
+			}
+			last = err
+		}
+		if pairs != 1 || last == nil || !strings.Contains(last.Error(), "/missing.html") {
+			t.Errorf("got %d pairs ending with error %v, want just an error naming the missing page", pairs, last)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var titles []string
+		var last error
+		for s, err := range Sections(ctx, opts()) {
+			if last != nil {
+				t.Fatalf("yielded %q, %v after the error %v", s.Title, err, last)
+			}
+			if err != nil {
+				last = err
+				continue
+			}
+			titles = append(titles, s.Title)
+			cancel()
+		}
+		if !errors.Is(last, context.Canceled) {
+			t.Errorf("last error = %v, want cancelled", last)
+		}
+		if len(titles) == 0 || len(titles) == 3 {
+			t.Errorf("sections = %q, want the iteration cut short", titles)
+		}
+	})
+}
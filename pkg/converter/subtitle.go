@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SubtitleMode selects what happens to a lower heading that immediately
+// follows a section heading, as in <h3>Title</h3><h4>Subtitle</h4>.
+type SubtitleMode string
+
+const (
+	SubtitleNone   SubtitleMode = "none"   // It's ordinary content
+	SubtitleLabel  SubtitleMode = "label"  // It's added to the section title, "Title: Subtitle"
+	SubtitleStyled SubtitleMode = "styled" // It's written as a <p class="subtitle"> under the title
+)
+
+// ParseSubtitleMode validates a -subtitles flag value.
+func ParseSubtitleMode(s string) (SubtitleMode, error) {
+	switch m := SubtitleMode(strings.ToLower(s)); m {
+	case SubtitleNone, SubtitleLabel, SubtitleStyled:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid subtitle mode '%s' (want none, label or styled)", s)
+}
+
+// subtitleHeading returns the heading right after heading n if it's of a
+// lower level, skipping whitespace and comments, or nil.
+func subtitleHeading(n *html.Node) *html.Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		switch {
+		case s.Type == html.CommentNode:
+			continue
+		case s.Type == html.TextNode && strings.TrimSpace(s.Data) == "":
+			continue
+		case isHeading(s) && s.Data > n.Data:
+			return s
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"path"
+)
+
+// Summary records what went into a built EPUB.
+type Summary struct {
+	Output    string         // Path the EPUB was written to
+	Sections  []SectionInfo  // Sections in spine order
+	Resources []ResourceInfo // Embedded images, stylesheets and fonts
+	Cover     *CoverInfo     // Nil if the book has no cover
+	Skipped   []SkippedSection
+	Pruned    []ResourceInfo // Resources removed because nothing referenced them
+	Chapters  []string       // Paths of the per-section EPUBs, if requested
+
+	Accessibility []A11yFinding       // Findings of the accessibility report, if requested
+	Validation    []ValidationProblem // Problems validation found, if requested
+
+	Metrics Metrics // How long each phase of the build took
+}
+
+// SkippedSection is a section left out of the EPUB because it could not be
+// made into well-formed XHTML.
+type SkippedSection struct {
+	Title  string
+	Body   string
+	Reason string
+}
+
+// CoverInfo describes the cover image and its optional thumbnail.
+type CoverInfo struct {
+	Path      string // Internal path of the cover image
+	Width     int
+	Height    int
+	Thumbnail *ThumbnailInfo // Nil unless a thumbnail was requested
+
+	source string // Media location of the cover image
+}
+
+// ThumbnailInfo describes a generated cover thumbnail.
+type ThumbnailInfo struct {
+	File   string // Local path of the thumbnail file
+	Path   string // Internal path, if the thumbnail was embedded
+	Width  int
+	Height int
+
+	source string // Media location of the thumbnail
+}
+
+// SectionInfo describes one section of the EPUB.
+type SectionInfo struct {
+	Title    string
+	Filename string // Internal filename, e.g. "section0001.xhtml"
+	Size     int    // Size of the section body in bytes
+}
+
+// ResourceInfo describes one embedded resource.
+type ResourceInfo struct {
+	Kind string // "image", "css" or "font"
+	Path string // Internal path as referenced from sections
+	Size int64  // Size in bytes, or -1 if unknown
+}
+
+// Result is returned by a successful build.
+type Result struct {
+	summary Summary
+	index   *bookIndex // Written next to the EPUB if requested
+
+	duplicates []string // Archive paths of images replaced by a larger copy, to remove from the EPUB
+
+	media    map[string]string // Media locations of embedded resources by internal path
+	chapters []Section         // Sections as added, when they also become EPUBs of their own
+	nav      []navEntry        // Table of contents of a single-file book, pointing into its one section
+	contents []navEntry        // Entries of the table of contents page, if there is one
+}
+
+// Summary returns the build summary.
+func (r *Result) Summary() Summary {
+	return r.summary
+}
+
+// Contents lists the sections and resources that went into the EPUB.
+func (r *Result) Contents() ([]SectionInfo, []ResourceInfo) {
+	return r.summary.Sections, r.summary.Resources
+}
+
+// prune moves the resources at the given archive paths from Resources to
+// Pruned.
+func (r *Result) prune(names []string) {
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+	}
+	kept := r.summary.Resources[:0]
+	for _, res := range r.summary.Resources {
+		if removed[internalArchivePath(res.Path)] {
+			r.summary.Pruned = append(r.summary.Pruned, res)
+		} else {
+			kept = append(kept, res)
+		}
+	}
+	r.summary.Resources = kept
+}
+
+// internalArchivePath turns an internal path as returned by go-epub, which
+// is relative to the section directory, into a path from the archive root.
+func internalArchivePath(internalPath string) string {
+	return path.Join("EPUB/xhtml", internalPath)
+}
+
+// addResource records an embedded resource of size bytes, or -1 if unknown,
+// whose media location is loc.
+func (r *Result) addResource(kind, internalPath, loc string, size int64) {
+	if r.media == nil {
+		r.media = make(map[string]string)
+	}
+	r.media[internalPath] = loc
+	r.summary.Resources = append(r.summary.Resources, ResourceInfo{Kind: kind, Path: internalPath, Size: size})
+}
+
+// addCover records the embedded images of cover, read from store.
+func (r *Result) addCover(cover *CoverInfo, store MediaStore) {
+	r.summary.Cover = cover
+	r.addResource("image", cover.Path, cover.source, storedSize(store, cover.source))
+	if t := cover.Thumbnail; t != nil && t.Path != "" {
+		r.addResource("image", t.Path, t.source, storedSize(store, t.source))
+	}
+}
+
+// storedSize returns the size of the media at loc in store, or -1 if it
+// can't be read.
+func storedSize(store MediaStore, loc string) int64 {
+	data, err := store.Get(loc)
+	if err != nil {
+		return -1
+	}
+	return int64(len(data))
+}
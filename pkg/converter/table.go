@@ -0,0 +1,202 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultTableImageColumns is how many columns a table may have before
+// Options.TableImages embeds it as an image.
+const DefaultTableImageColumns = 8
+
+// Layout of tables rendered as SVG images.
+const (
+	tableCellWrap    = 40 // Runes per line before cell text wraps
+	tableCharWidth   = 8  // Approximate width of a character at tableFontSize
+	tableLineHeight  = 18
+	tableCellPadding = 6
+	tableFontSize    = 14
+)
+
+// tableImageColumns returns how many columns a table may have before it's
+// embedded as an image.
+func (opts Options) tableImageColumns() int {
+	if opts.TableImageColumns > 0 {
+		return opts.TableImageColumns
+	}
+	return DefaultTableImageColumns
+}
+
+// tableRows returns the cells of each row of table n, leaving out the rows
+// of any tables nested in it.
+func tableRows(n *html.Node) [][]*html.Node {
+	var rows [][]*html.Node
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "tr":
+				var cells []*html.Node
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						cells = append(cells, cell)
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return rows
+}
+
+// cellSpan returns the number of columns a cell spans.
+func cellSpan(cell *html.Node) int {
+	span, err := strconv.Atoi(strings.TrimSpace(getAttr(cell, "colspan")))
+	if err != nil || span < 1 {
+		return 1
+	}
+	return span
+}
+
+// tableColumns returns the number of columns in rows.
+func tableColumns(rows [][]*html.Node) int {
+	columns := 0
+	for _, row := range rows {
+		n := 0
+		for _, cell := range row {
+			n += cellSpan(cell)
+		}
+		columns = max(columns, n)
+	}
+	return columns
+}
+
+// isComplexTable reports whether table n has more than maxColumns columns
+// or has another table nested in it.
+func isComplexTable(n *html.Node, maxColumns int) bool {
+	if tableColumns(tableRows(n)) > maxColumns {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if findElement(c, "table") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tableText returns the text of rows as one line, for alt text: cells
+// separated by commas and rows by semicolons.
+func tableText(rows [][]*html.Node) string {
+	var lines []string
+	for _, row := range rows {
+		var cells []string
+		for _, cell := range row {
+			if text := strings.Join(strings.Fields(getTextContent(cell)), " "); text != "" {
+				cells = append(cells, text)
+			}
+		}
+		if len(cells) > 0 {
+			lines = append(lines, strings.Join(cells, ", "))
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// tableSVG draws rows as a grid of cells in an SVG image. Columns are as wide
+// as their longest line of text; header cells are bold. Cells spanning rows
+// aren't accounted for, so they push later cells of the rows below along.
+func tableSVG(rows [][]*html.Node) []byte {
+	columns := tableColumns(rows)
+	widths := make([]int, columns)
+	lines := make([][][]string, len(rows))
+	heights := make([]int, len(rows))
+	for i, row := range rows {
+		lines[i] = make([][]string, len(row))
+		heights[i] = 1
+		col := 0
+		for j, cell := range row {
+			lines[i][j] = wrapWords(getTextContent(cell), tableCellWrap)
+			heights[i] = max(heights[i], len(lines[i][j]))
+			if span := cellSpan(cell); span == 1 {
+				for _, line := range lines[i][j] {
+					widths[col] = max(widths[col], len([]rune(line)))
+				}
+			}
+			col += cellSpan(cell)
+		}
+	}
+	x := make([]int, columns+1)
+	for col, w := range widths {
+		x[col+1] = x[col] + max(w, 1)*tableCharWidth + 2*tableCellPadding
+	}
+	y := make([]int, len(rows)+1)
+	for i, h := range heights {
+		y[i+1] = y[i] + h*tableLineHeight + 2*tableCellPadding
+	}
+
+	var b strings.Builder
+	width, height := x[columns]+1, y[len(rows)]+1
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	b.WriteString(`  <rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+	fmt.Fprintf(&b, `  <g font-family="sans-serif" font-size="%d" fill="#000000">`+"\n", tableFontSize)
+	for i, row := range rows {
+		col := 0
+		for j, cell := range row {
+			end := min(col+cellSpan(cell), columns)
+			fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#000000"/>`+"\n",
+				x[col], y[i], x[end]-x[col], y[i+1]-y[i])
+			weight := ""
+			if cell.Data == "th" {
+				weight = ` font-weight="bold"`
+			}
+			for k, line := range lines[i][j] {
+				fmt.Fprintf(&b, `    <text x="%d" y="%d"%s>%s</text>`+"\n",
+					x[col]+tableCellPadding, y[i]+tableCellPadding+(k+1)*tableLineHeight-4, weight, html.EscapeString(line))
+			}
+			col = end
+		}
+	}
+	b.WriteString("  </g>\n</svg>\n")
+	return []byte(b.String())
+}
+
+// addTableImage embeds table n as an SVG image with its text as the alt
+// text, in place of extracting its cells. It reports whether it did; if not,
+// the table is extracted as usual.
+func (x *extractor) addTableImage(n *html.Node) bool {
+	defer x.timeImages(time.Now())
+	rows := tableRows(n)
+	x.tables++
+	imgPath, err := x.store.Put(fmt.Sprintf("table-%d.svg", x.tables), tableSVG(rows))
+	if err != nil {
+		imagesLog.Warn("Could not save image of table, extracting its text", "table", x.tables, "err", err)
+		return false
+	}
+	epubImgPath, err := x.embedImage(imgPath, x.src.name)
+	if err != nil {
+		imagesLog.Warn("Could not add image of table to EPUB, extracting its text", "table", x.tables, "err", err)
+		return false
+	}
+	alt := tableText(rows)
+	if alt == "" {
+		alt = "Table"
+	}
+	x.currentSection.WriteString(fmt.Sprintf(`%s<img src="%s" alt="%s"/></p>`, x.openBlock(`p class="table-image"`), epubImgPath, html.EscapeString(alt)))
+	if x.sectionImages == 0 {
+		x.firstImageAlt, x.firstImage = "Table", imgPath
+	}
+	x.sectionImages++
+	return true
+}
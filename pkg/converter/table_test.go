@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTableImages(t *testing.T) {
+	var wide strings.Builder
+	wide.WriteString(`<table><tr>`)
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&wide, "<th>Col %d</th>", i)
+	}
+	wide.WriteString(`</tr><tr>`)
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&wide, "<td>v%d</td>", i)
+	}
+	wide.WriteString(`</tr></table>`)
+	narrow := `<table><tr><td>Small</td><td>table</td></tr></table>`
+	nested := `<table><tr><td><table><tr><td>Inner</td></tr></table></td></tr></table>`
+	page := `<html><head><title>Page</title></head><body><h3>One</h3>` + wide.String() + narrow + nested + `</body></html>`
+	wideAlt := `alt="Col 1, Col 2, Col 3, Col 4, Col 5, Col 6, Col 7, Col 8, Col 9, Col 10; v1, v2, v3, v4, v5, v6, v7, v8, v9, v10"`
+
+	tests := []struct {
+		name   string
+		opts   Options
+		images int
+		want   []string
+	}{
+		{name: "off", opts: Options{}, want: []string{"<p>Col 1 </p>", "<p>Small </p>", "<p>Inner </p>"}},
+		{name: "on", opts: Options{TableImages: true}, images: 2, want: []string{wideAlt, "<p>Small </p>", `alt="Inner"`}},
+		{name: "more columns allowed", opts: Options{TableImages: true, TableImageColumns: 10}, images: 1, want: []string{"<p>Col 1 </p>", `alt="Inner"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, tt.opts)
+			body := sectionFile(t, result, files, "One")
+			if n := strings.Count(body, `<p class="table-image"><img src="../images/table-`); n != tt.images {
+				t.Errorf("section has %d table images, want %d:\n%s", n, tt.images, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("section lacks %s:\n%s", want, body)
+				}
+			}
+			svgs := 0
+			for name, data := range files {
+				if strings.HasPrefix(name, "EPUB/images/table-") && strings.HasSuffix(name, ".svg") {
+					svgs++
+					if !strings.HasPrefix(data, "<svg ") {
+						t.Errorf("%s isn't an SVG:\n%s", name, data)
+					}
+				}
+			}
+			if svgs != tt.images {
+				t.Errorf("EPUB holds %d table images, want %d", svgs, tt.images)
+			}
+		})
+	}
+}
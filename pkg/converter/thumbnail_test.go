@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCoverThumbnail(t *testing.T) {
+	cover := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(cover, testPNG(t, 600, 900, color.Gray{100}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	page := `<html><body><h3>One</h3><p>Text.</p></body></html>`
+	tests := []struct {
+		name  string
+		embed bool
+	}{
+		{name: "file only"},
+		{name: "embedded", embed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, files := testBuild(t, page, Options{CoverImage: cover, ThumbnailSize: 120, EmbedThumbnail: tt.embed})
+			c := result.Summary().Cover
+			if c == nil || c.Thumbnail == nil {
+				t.Fatalf("cover = %+v, want a thumbnail", c)
+			}
+			if c.Width != 600 || c.Height != 900 {
+				t.Errorf("cover is %dx%d, want 600x900", c.Width, c.Height)
+			}
+			thumb := c.Thumbnail
+			if thumb.Width != 80 || thumb.Height != 120 {
+				t.Errorf("thumbnail is %dx%d, want 80x120", thumb.Width, thumb.Height)
+			}
+
+			f, err := os.Open(thumb.File)
+			if err != nil {
+				t.Fatalf("thumbnail file: %v", err)
+			}
+			defer f.Close()
+			cfg, _, err := image.DecodeConfig(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != thumb.Width || cfg.Height != thumb.Height {
+				t.Errorf("thumbnail file is %dx%d, summary says %dx%d", cfg.Width, cfg.Height, thumb.Width, thumb.Height)
+			}
+
+			opf := files[packageDocumentPath]
+			if embedded := thumb.Path != "" && strings.Contains(opf, strings.TrimPrefix(thumb.Path, "../")); embedded != tt.embed {
+				t.Errorf("thumbnail in the manifest = %v, want %v (path %q)", embedded, tt.embed, thumb.Path)
+			}
+		})
+	}
+}
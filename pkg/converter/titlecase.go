@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleCaseMode selects how extracted section titles are re-cased.
+type TitleCaseMode string
+
+const (
+	TitleCaseNone     TitleCaseMode = "none"
+	TitleCaseTitle    TitleCaseMode = "title"
+	TitleCaseSentence TitleCaseMode = "sentence"
+)
+
+// ParseTitleCaseMode validates a -title-case flag value.
+func ParseTitleCaseMode(s string) (TitleCaseMode, error) {
+	switch m := TitleCaseMode(strings.ToLower(s)); m {
+	case TitleCaseNone, TitleCaseTitle, TitleCaseSentence:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid title case mode '%s' (want none, title or sentence)", s)
+}
+
+// smallWords stay lowercase in title case unless they start or end the title.
+var smallWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true, "by": true,
+	"for": true, "from": true, "in": true, "nor": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "vs": true, "with": true,
+}
+
+var romanNumeral = regexp.MustCompile(`^M{0,4}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+
+// numberedWords are followed by a section's number, which may be a roman
+// numeral, as in "Chapter XIV".
+var numberedWords = map[string]bool{"book": true, "chapter": true, "part": true, "volume": true}
+
+// isRomanNumeral reports whether word, in any case, is a roman numeral such
+// as "XIV".
+func isRomanNumeral(word string) bool {
+	return word != "" && romanNumeral.MatchString(strings.ToUpper(word))
+}
+
+// numberedAt reports whether the i'th of words is where a section number
+// goes: after "Chapter", "Book", "Part" or "Volume", as the whole title, or
+// as a label starting it, as in "XIV. The Return". Words such as "MIX", "DIV"
+// or "LI" elsewhere are words, not numerals.
+func numberedAt(words []string, i int) bool {
+	switch {
+	case len(words) == 1:
+		return true
+	case i == 0:
+		return strings.ContainsAny(words[0][len(words[0])-1:], ".:")
+	}
+	prev := strings.TrimFunc(words[i-1], func(r rune) bool { return !isWordRune(r) })
+	return numberedWords[strings.ToLower(prev)]
+}
+
+// applyTitleCase re-cases title according to mode. Roman numerals where a
+// section number goes (see numberedAt) are upper-cased in both title and
+// sentence case.
+func applyTitleCase(title string, mode TitleCaseMode) string {
+	if mode == TitleCaseNone || mode == "" {
+		return title
+	}
+	words := strings.Fields(title)
+	for i, word := range words {
+		// Separate surrounding punctuation, e.g. "(CONTINUED)." or "I."
+		start := strings.IndexFunc(word, isWordRune)
+		if start < 0 {
+			continue
+		}
+		end := strings.LastIndexFunc(word, isWordRune)
+		_, size := utf8.DecodeRuneInString(word[end:])
+		prefix, core, suffix := word[:start], word[start:end+size], word[end+size:]
+		// A word following "XIV." or "Part One:" starts a new clause
+		clauseStart := i == 0 || strings.ContainsAny(words[i-1][len(words[i-1])-1:], ".:;!?")
+
+		switch {
+		case isRomanNumeral(core) && numberedAt(words, i):
+			core = strings.ToUpper(core)
+		case mode == TitleCaseTitle && !clauseStart && i < len(words)-1 && smallWords[strings.ToLower(core)]:
+			core = strings.ToLower(core)
+		case mode == TitleCaseTitle || clauseStart:
+			core = capitalize(core)
+		default:
+			core = strings.ToLower(core)
+		}
+		words[i] = prefix + core + suffix
+	}
+	return strings.Join(words, " ")
+}
+
+// capitalize lower-cases word and upper-cases its first letter.
+func capitalize(word string) string {
+	lower := strings.ToLower(word)
+	r, size := utf8.DecodeRuneInString(lower)
+	return string(unicode.ToUpper(r)) + lower[size:]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,51 @@
+package converter
+
+import "testing"
+
+func TestApplyTitleCase(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode TitleCaseMode
+		want string
+	}{
+		{"THE MIX OF DIV", TitleCaseTitle, "The Mix of Div"},
+		{"A LI DC CIV", TitleCaseTitle, "A Li Dc Civ"},
+		{"CHAPTER XIV", TitleCaseTitle, "Chapter XIV"},
+		{"chapter iv: the return", TitleCaseTitle, "Chapter IV: The Return"},
+		{"BOOK II. THE FALL", TitleCaseSentence, "Book II. The fall"},
+		{"PART I", TitleCaseSentence, "Part I"},
+		{"Volume III (continued)", TitleCaseTitle, "Volume III (Continued)"},
+		{"XIV. THE RETURN OF THE KING", TitleCaseTitle, "XIV. The Return of the King"},
+		{"XIV", TitleCaseTitle, "XIV"},
+		{"THE CIVIL WAR", TitleCaseSentence, "The civil war"},
+		{"DIV AND MIX", TitleCaseSentence, "Div and mix"},
+		{"a tale of two cities", TitleCaseTitle, "A Tale of Two Cities"},
+		{"WHAT IT IS FOR", TitleCaseTitle, "What It Is For"},
+		{"Left As Is", TitleCaseNone, "Left As Is"},
+		{"Left As Is", "", "Left As Is"},
+	}
+	for _, tt := range tests {
+		if got := applyTitleCase(tt.in, tt.mode); got != tt.want {
+			t.Errorf("applyTitleCase(%q, %q) = %q, want %q", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseTitleCaseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TitleCaseMode
+		wantErr bool
+	}{
+		{"none", TitleCaseNone, false},
+		{"Title", TitleCaseTitle, false},
+		{"SENTENCE", TitleCaseSentence, false},
+		{"upper", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTitleCaseMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTitleCaseMode(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// The in-book table of contents page: its file, its title unless NavTitle
+// names one, and the empty list in its body that finishEPUB fills in once
+// every section has a file to point to.
+const (
+	contentsFilename    = "contents.xhtml"
+	contentsPagePath    = "EPUB/xhtml/" + contentsFilename
+	contentsTitle       = "Table of Contents" // As go-epub heads the navigation document
+	contentsPlaceholder = `<ol class="contents"></ol>`
+)
+
+// tocBuilder collects the table of contents of a book as its sections are
+// added, nesting each under the closest earlier one less deep.
+type tocBuilder struct {
+	entries []navEntry
+}
+
+// add adds the section s, added to the EPUB as filename, or if anchors are
+// set, the extracted sections merged into it.
+func (t *tocBuilder) add(s Section, filename string, anchors []anchoredSection) {
+	if len(anchors) == 0 {
+		t.entries = append(t.entries, navEntry{Title: s.Title, Href: "xhtml/" + filename, Level: s.Level})
+		return
+	}
+	for _, a := range anchors {
+		t.entries = append(t.entries, navEntry{Title: a.Title, Href: "xhtml/" + filename + "#" + a.ID, Level: a.Level})
+	}
+}
+
+// contentsSection returns the in-book table of contents page, headed
+// NavTitle, with its list still to be filled in by setContentsEntries.
+func contentsSection(opts Options) Section {
+	title := opts.NavTitle
+	if title == "" {
+		title = contentsTitle
+	}
+	return Section{
+		Title:    title,
+		Body:     "<h1>" + html.EscapeString(title) + "</h1>\n" + contentsPlaceholder,
+		filename: contentsFilename,
+	}
+}
+
+// setContentsEntries fills in the list of the table of contents page with
+// entries, nested like the navigation document's.
+func setContentsEntries(page []byte, entries []navEntry) []byte {
+	rel := make([]navEntry, len(entries))
+	for i, entry := range entries {
+		rel[i] = entry
+		rel[i].Href = strings.TrimPrefix(entry.Href, "xhtml/") // The page is beside the sections
+	}
+	var b strings.Builder
+	b.WriteString(`<ol class="contents">`)
+	writeNavItems(&b, rel, 0, "\n  ")
+	b.WriteString("\n</ol>")
+	return bytes.Replace(page, []byte(contentsPlaceholder), []byte(b.String()), 1)
+}
@@ -0,0 +1,290 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ValidationProblem is something wrong with a built EPUB that EPUBCheck
+// would report and readers may choke on.
+type ValidationProblem struct {
+	File    string `json:"file"` // Path inside the EPUB, or "" for the archive itself
+	Message string `json:"message"`
+}
+
+func (p ValidationProblem) String() string {
+	if p.File == "" {
+		return p.Message
+	}
+	return p.File + ": " + p.Message
+}
+
+// opfPackage is the part of a package document ValidateEPUB checks.
+type opfPackage struct {
+	UniqueID string `xml:"unique-identifier,attr"`
+	Metadata struct {
+		Identifiers []struct {
+			ID    string `xml:"id,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"identifier"`
+		Titles    []string `xml:"title"`
+		Languages []string `xml:"language"`
+	} `xml:"metadata"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// ValidateEPUB runs structural checks like EPUBCheck's on the EPUB in data:
+// the mimetype file comes first and uncompressed; the container names a
+// package document; the package document has an identifier, title and
+// language, a navigation document, and a manifest listing every file, each
+// of which exists, with a spine of manifest items; every content document is
+// well-formed XHTML; and every internal link, image and stylesheet reference
+// points at a file that exists, and at an id in it for a fragment. It
+// returns the problems it finds, sorted by file, or an error if data isn't a
+// zip archive at all.
+func ValidateEPUB(data []byte) ([]ValidationProblem, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB archive: %w", err)
+	}
+	problems := []ValidationProblem{}
+	report := func(file, format string, args ...any) {
+		problems = append(problems, ValidationProblem{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			report(f.Name, "can't be opened: %v", err)
+			continue
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			report(f.Name, "can't be read: %v", err)
+			continue
+		}
+		files[f.Name] = b
+	}
+
+	// The mimetype file identifies the archive as an EPUB
+	switch {
+	case len(zr.File) == 0 || zr.File[0].Name != "mimetype":
+		report("mimetype", "must be the first file in the archive")
+	case zr.File[0].Method != zip.Store:
+		report("mimetype", "must be stored uncompressed")
+	case string(files["mimetype"]) != "application/epub+zip":
+		report("mimetype", "must contain exactly application/epub+zip")
+	}
+
+	// The container points at the package document
+	const containerPath = "META-INF/container.xml"
+	container, ok := files[containerPath]
+	if !ok {
+		report(containerPath, "is missing")
+		return sortProblems(problems), nil
+	}
+	var c struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &c); err != nil {
+		report(containerPath, "is malformed: %v", err)
+		return sortProblems(problems), nil
+	}
+	if len(c.Rootfiles) == 0 {
+		report(containerPath, "names no package document")
+		return sortProblems(problems), nil
+	}
+	opfPath := c.Rootfiles[0].FullPath
+	opfData, ok := files[opfPath]
+	if !ok {
+		report(containerPath, "names package document '%s', which is missing", opfPath)
+		return sortProblems(problems), nil
+	}
+	var pkg opfPackage
+	if err := xml.Unmarshal(opfData, &pkg); err != nil {
+		report(opfPath, "is malformed: %v", err)
+		return sortProblems(problems), nil
+	}
+
+	// Metadata every package document needs
+	hasUniqueID := false
+	for _, id := range pkg.Metadata.Identifiers {
+		if id.ID == pkg.UniqueID && strings.TrimSpace(id.Value) != "" {
+			hasUniqueID = true
+		}
+	}
+	if !hasUniqueID {
+		report(opfPath, "has no dc:identifier matching its unique-identifier '%s'", pkg.UniqueID)
+	}
+	if len(pkg.Metadata.Titles) == 0 || strings.TrimSpace(pkg.Metadata.Titles[0]) == "" {
+		report(opfPath, "has no dc:title")
+	}
+	if len(pkg.Metadata.Languages) == 0 || strings.TrimSpace(pkg.Metadata.Languages[0]) == "" {
+		report(opfPath, "has no dc:language")
+	}
+
+	// The manifest lists every file, and only files that exist
+	opfDir := path.Dir(opfPath)
+	manifest := make(map[string]string) // Media types by archive path
+	itemIDs := make(map[string]bool)
+	navs := 0
+	for _, item := range pkg.Items {
+		itemIDs[item.ID] = true
+		p, err := resolveArchivePath(opfDir, item.Href)
+		if err != nil {
+			report(opfPath, "manifest item '%s' has an invalid href '%s'", item.ID, item.Href)
+			continue
+		}
+		if _, ok := files[p]; !ok {
+			report(opfPath, "manifest item '%s' refers to '%s', which is missing", item.ID, p)
+		}
+		manifest[p] = item.MediaType
+		if hasToken(item.Properties, "nav") {
+			navs++
+		}
+	}
+	if navs != 1 {
+		report(opfPath, "has %d navigation documents (want exactly 1)", navs)
+	}
+	for name := range files {
+		if _, ok := manifest[name]; !ok && name != "mimetype" && name != opfPath && !strings.HasPrefix(name, "META-INF/") && !strings.HasSuffix(name, "/") {
+			report(name, "is not listed in the manifest")
+		}
+	}
+	if len(pkg.Spine.ItemRefs) == 0 {
+		report(opfPath, "has an empty spine")
+	}
+	for _, ref := range pkg.Spine.ItemRefs {
+		if !itemIDs[ref.IDRef] {
+			report(opfPath, "spine refers to '%s', which isn't a manifest item", ref.IDRef)
+		}
+	}
+	if pkg.Spine.Toc != "" && !itemIDs[pkg.Spine.Toc] {
+		report(opfPath, "spine toc refers to '%s', which isn't a manifest item", pkg.Spine.Toc)
+	}
+
+	// Content documents must be well-formed, and what they refer to must exist
+	ids := make(map[string]map[string]bool)
+	refs := make(map[string][]string)
+	var names []string
+	for name, mediaType := range manifest {
+		if _, ok := files[name]; !ok {
+			continue
+		}
+		switch mediaType {
+		case "application/xhtml+xml", "application/x-dtbncx+xml", "image/svg+xml":
+			docIDs, docRefs, err := scanXMLDocument(files[name])
+			if err != nil {
+				report(name, "is not well-formed XML: %v", err)
+				continue
+			}
+			ids[name], refs[name] = docIDs, docRefs
+			names = append(names, name)
+		case "text/css":
+			for _, m := range cssURLPattern.FindAllSubmatch(files[name], -1) {
+				refs[name] = append(refs[name], strings.TrimSpace(string(m[2])))
+			}
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		for _, ref := range refs[name] {
+			u, err := url.Parse(ref)
+			if err != nil {
+				report(name, "has an invalid reference '%s'", ref)
+				continue
+			}
+			if u.Scheme != "" || u.Host != "" {
+				continue // External or data: references
+			}
+			target := name
+			if u.Path != "" {
+				if target, err = resolveArchivePath(path.Dir(name), u.EscapedPath()); err != nil {
+					report(name, "has an invalid reference '%s'", ref)
+					continue
+				}
+				if _, ok := files[target]; !ok {
+					report(name, "refers to '%s', which is missing", target)
+					continue
+				}
+			}
+			if u.Fragment != "" && ids[target] != nil && !ids[target][u.Fragment] {
+				report(name, "links to '#%s' in '%s', which has no such id", u.Fragment, target)
+			}
+		}
+	}
+	return sortProblems(problems), nil
+}
+
+// resolveArchivePath resolves the URL path ref, relative to the archive
+// directory dir, to an archive path.
+func resolveArchivePath(dir, ref string) (string, error) {
+	p, err := url.PathUnescape(ref)
+	if err != nil {
+		return "", err
+	}
+	p = path.Join(dir, p)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.New("outside the archive")
+	}
+	return p, nil
+}
+
+// scanXMLDocument parses an XHTML, NCX or SVG document strictly, as readers
+// do, and returns the ids it defines and the src, href and xlink:href
+// references it makes.
+func scanXMLDocument(data []byte) (map[string]bool, []string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+	ids := make(map[string]bool)
+	var refs []string
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return ids, refs, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, a := range start.Attr {
+			switch a.Name.Local {
+			case "id":
+				ids[a.Value] = true
+			case "src", "href":
+				refs = append(refs, a.Value)
+			}
+		}
+	}
+}
+
+// sortProblems sorts problems by file, keeping the order found within one.
+func sortProblems(problems []ValidationProblem) []ValidationProblem {
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].File < problems[j].File })
+	return problems
+}
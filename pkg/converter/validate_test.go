@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateEPUB(t *testing.T) {
+	page := `<html><head><title>Page</title></head><body><h3>One</h3><p>Text.</p><h3>Two</h3><p>More.</p></body></html>`
+	out := filepath.Join(t.TempDir(), "book.epub")
+	result, _ := testBuild(t, page, Options{OutputPath: out})
+	built, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	section := result.Summary().Sections[0].Filename
+
+	tests := []struct {
+		name string
+		edit func(name string, data []byte) ([]byte, error)
+		want []string // Problems, as File: Message
+	}{
+		{
+			name: "as built",
+			edit: func(name string, data []byte) ([]byte, error) { return data, nil },
+		},
+		{
+			name: "no mimetype",
+			edit: func(name string, data []byte) ([]byte, error) {
+				if name == "mimetype" {
+					return nil, errDropEntry
+				}
+				return data, nil
+			},
+			want: []string{"mimetype: must be the first file in the archive"},
+		},
+		{
+			name: "broken package document reference",
+			edit: func(name string, data []byte) ([]byte, error) {
+				if name == packageDocumentPath {
+					data = bytes.Replace(data, []byte(`href="xhtml/`+section+`"`), []byte(`href="xhtml/missing.xhtml"`), 1)
+				}
+				return data, nil
+			},
+			want: []string{
+				"EPUB/package.opf: manifest item '" + section + "' refers to 'EPUB/xhtml/missing.xhtml', which is missing",
+				"EPUB/xhtml/" + section + ": is not listed in the manifest",
+			},
+		},
+		{
+			name: "broken link",
+			edit: func(name string, data []byte) ([]byte, error) {
+				if name == "EPUB/xhtml/"+section {
+					data = bytes.Replace(data, []byte(`Text.`), []byte(`<a href="#nowhere">Text.</a>`), 1)
+				}
+				return data, nil
+			},
+			want: []string{"EPUB/xhtml/" + section + ": links to '#nowhere' in 'EPUB/xhtml/" + section + "', which has no such id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := rewriteEPUB(built, tt.edit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			problems, err := ValidateEPUB(data)
+			if err != nil {
+				t.Fatalf("ValidateEPUB: %v", err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	if _, err := ValidateEPUB([]byte("not a zip")); err == nil {
+		t.Error("ValidateEPUB of something that isn't a zip succeeded")
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// checkXHTML reports whether body is well-formed XML, as a section body must
+// be for the EPUB to be valid. EPUB content documents have no DTD, so HTML
+// named entities such as &nbsp; count as errors.
+func checkXHTML(body string) error {
+	d := xml.NewDecoder(strings.NewReader("<div>" + body + "</div>"))
+	d.Strict = true
+	for {
+		_, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Elements normalizeNesting treats as inline and block content. Links are
+// neither: they take the content model of what they're in, so a link may
+// hold a paragraph where the paragraph itself would be allowed.
+var (
+	inlineElements = map[string]bool{
+		"abbr": true, "b": true, "cite": true, "code": true, "em": true, "i": true,
+		"q": true, "small": true, "span": true, "strong": true, "sub": true, "sup": true, "u": true,
+	}
+	blockElements = map[string]bool{
+		"article": true, "aside": true, "blockquote": true, "div": true, "figure": true, "footer": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+		"li": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+	}
+)
+
+// normalizeNesting unwraps block elements nested in inline ones, where
+// XHTML doesn't allow them. The tags are dropped and their content kept.
+// Links are looked through, so a block in a link is only unwrapped if the
+// link is itself in an inline element. body must be well-formed.
+func normalizeNesting(body string) (string, error) {
+	type openElement struct {
+		name    string
+		dropped bool
+	}
+	var stack []openElement
+	parentIsInline := func() bool {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if !stack[i].dropped && stack[i].name != "a" {
+				return inlineElements[stack[i].name]
+			}
+		}
+		return false
+	}
+
+	d := xml.NewDecoder(strings.NewReader(body))
+	d.Strict = true
+	var b strings.Builder
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		raw := body[start:d.InputOffset()]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			drop := blockElements[name] && parentIsInline()
+			stack = append(stack, openElement{name: name, dropped: drop})
+			if drop {
+				continue
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if top.dropped {
+					continue
+				}
+			}
+		}
+		b.WriteString(raw)
+	}
+	return b.String(), nil
+}
+
+// unnestLinks unwraps links inside links in the HTML markup body, keeping
+// their text in the outer link, so they make one link to the outer href.
+// It works on the markup, as the HTML parser would otherwise split them
+// into several links, the outer one's text cut off where the inner began.
+// A block element ending closes any link left open, as browsers render it.
+func unnestLinks(body []byte) []byte {
+	if !bytes.Contains(body, []byte("<a")) && !bytes.Contains(body, []byte("<A")) {
+		return body
+	}
+	var out bytes.Buffer
+	var open []bool // Whether each open link was dropped
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		name, _ := z.TagName()
+		tag := string(name)
+		switch {
+		case tt == html.StartTagToken && tag == "a":
+			drop := len(open) > 0
+			open = append(open, drop)
+			if drop {
+				continue
+			}
+		case tt == html.EndTagToken && tag == "a" && len(open) > 0:
+			dropped := open[len(open)-1]
+			open = open[:len(open)-1]
+			if dropped {
+				continue
+			}
+		case tt == html.EndTagToken && blockElements[tag]:
+			open = open[:0]
+		}
+		out.Write(raw)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnnestLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"nested link joined", `<a href="a">outer <a href="b">inner</a> rest</a>`, `<a href="a">outer inner rest</a>`},
+		{"deeper nesting", `<a href="a">1<a href="b">2<a href="c">3</a></a></a>`, `<a href="a">123</a>`},
+		{"siblings kept", `<a href="a">one</a> <a href="b">two</a>`, `<a href="a">one</a> <a href="b">two</a>`},
+		{"block end closes", `<p><a href="a">open</p><p><a href="b">two</a></p>`, `<p><a href="a">open</p><p><a href="b">two</a></p>`},
+		{"no links", `<p>Text &amp; more</p>`, `<p>Text &amp; more</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(unnestLinks([]byte(tt.in))); got != tt.want {
+				t.Errorf("unnestLinks(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNesting(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"block in inline unwrapped", `<span>a<p>b</p></span>`, `<span>ab</span>`},
+		{"block in link kept", `<a href="x"><p>b</p></a>`, `<a href="x"><p>b</p></a>`},
+		{"block in link in inline unwrapped", `<em><a href="x"><div>b</div></a></em>`, `<em><a href="x">b</a></em>`},
+		{"valid nesting untouched", `<div><p><em>a</em></p></div>`, `<div><p><em>a</em></p></div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeNesting(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeNesting(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeSectionNestedLinks(t *testing.T) {
+	got, err := sanitizeSection(`<p><a href="https://example.com/a">outer <a href="https://example.com/b">inner</a></a></p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p><a href="https://example.com/a">outer inner</a></p>`
+	if got != want {
+		t.Errorf("sanitizeSection\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestNestedLinksInSource(t *testing.T) {
+	page := `<h3>One</h3><p><a href="#t">outer <a href="#u">inner</a></a></p><p id="t">Target.</p><p id="u">Other.</p>`
+	sections := testSections(t, page, Options{})
+	if len(sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(sections))
+	}
+	body := sections[0].Body
+	if n := strings.Count(body, "<a "); n != 1 {
+		t.Fatalf("got %d links, want 1: %s", n, body)
+	}
+	if !strings.Contains(body, `<a href="#t">outer inner</a>`) {
+		t.Errorf("want one link to the outer target: %s", body)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"epub/pkg/converter"
+)
+
+// progressBarWidth is how many characters the bar itself takes up.
+const progressBarWidth = 30
+
+// progressBar draws a build's progress on a terminal as one line it keeps
+// rewriting, e.g. "[=========>           ] downloaded image 12/40". Log
+// messages written through it are printed above the bar.
+type progressBar struct {
+	mu   sync.Mutex
+	w    io.Writer
+	line string // What the bar shows, "" while it shows nothing
+}
+
+// update redraws the bar for e.
+func (b *progressBar) update(e converter.ProgressEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	line := e.String()
+	if e.Total > 0 {
+		n := progressBarWidth * e.Done / e.Total
+		bar := strings.Repeat("=", n)
+		if n < progressBarWidth {
+			bar += ">" + strings.Repeat(" ", progressBarWidth-n-1)
+		}
+		line = "[" + bar + "] " + line
+	}
+	b.line = line
+	fmt.Fprint(b.w, "\r\033[K"+line)
+}
+
+// Write prints p, a log message, above the bar.
+func (b *progressBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line == "" {
+		return b.w.Write(p)
+	}
+	fmt.Fprint(b.w, "\r\033[K")
+	n, err := b.w.Write(p)
+	fmt.Fprint(b.w, b.line)
+	return n, err
+}
+
+// clear erases the bar, if there is one, leaving the cursor at the start of
+// its line.
+func (b *progressBar) clear() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line != "" {
+		fmt.Fprint(b.w, "\r\033[K")
+		b.line = ""
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
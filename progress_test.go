@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"epub/pkg/converter"
+)
+
+func TestProgressBar(t *testing.T) {
+	var out strings.Builder
+	b := &progressBar{w: &out}
+
+	b.Write([]byte("before the bar\n"))
+	b.update(converter.ProgressEvent{Stage: converter.ProgressImages, Done: 15, Total: 30})
+	b.Write([]byte("a log message\n"))
+	b.update(converter.ProgressEvent{Stage: converter.ProgressWriting})
+	b.clear()
+
+	half := "[" + strings.Repeat("=", 15) + ">" + strings.Repeat(" ", 14) + "] downloaded image 15/30"
+	want := "before the bar\n" +
+		"\r\033[K" + half +
+		"\r\033[K" + "a log message\n" + half +
+		"\r\033[K" + "writing EPUB" +
+		"\r\033[K"
+	if out.String() != want {
+		t.Errorf("drew %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"epub/pkg/converter"
+)
+
+// Limits on what one convert request may ask of the server.
+const (
+	maxConvertRequest = 1 << 20 // Bytes of JSON body
+	maxServeCrawl     = 50      // Further pages followed with crawl
+)
+
+// defaultMaxBuilds is how many conversions the server runs at once if
+// -max-builds isn't given.
+const defaultMaxBuilds = 4
+
+// serveConfig is how the server is run: where it listens, the bearer token
+// requests to /convert must carry, if any, and how many conversions it
+// runs at once.
+type serveConfig struct {
+	addr      string
+	token     string
+	maxBuilds int
+}
+
+// convertRequest is the JSON body of POST /convert.
+type convertRequest struct {
+	URL     string         `json:"url"`
+	Title   string         `json:"title"`
+	Author  string         `json:"author"`
+	Options convertOptions `json:"options"`
+}
+
+// convertOptions are the build options a convert request may set; the rest
+// come from the serve command's flags.
+type convertOptions struct {
+	Headings      string `json:"headings"`       // As -headings
+	SingleFile    *bool  `json:"single_file"`    // As -single-file
+	ContentsPage  *bool  `json:"toc_page"`       // As -toc-page
+	Gutenberg     *bool  `json:"gutenberg"`      // As -gutenberg
+	MaxImage      int    `json:"max_image"`      // As -max-image
+	ConvertImages string `json:"convert_images"` // As -convert-images
+	ImageQuality  int    `json:"image_quality"`  // As -image-quality
+	Crawl         int    `json:"crawl"`          // As -crawl, at most maxServeCrawl
+}
+
+// apply returns base with the options of the request applied.
+func (r *convertRequest) apply(base converter.Options) (converter.Options, error) {
+	opts := base
+	opts.Metadata = converter.Metadata{Title: r.Title, Author: r.Author}.Merge(base.Metadata)
+	o := r.Options
+	if o.Headings != "" {
+		headings, err := converter.ParseSectionHeadings(o.Headings)
+		if err != nil {
+			return opts, err
+		}
+		opts.SectionHeadings = headings
+	}
+	if o.SingleFile != nil {
+		opts.SingleFile = *o.SingleFile
+	}
+	if o.ContentsPage != nil {
+		opts.ContentsPage = *o.ContentsPage
+	}
+	if o.Gutenberg != nil {
+		opts.Gutenberg = *o.Gutenberg
+	}
+	if o.MaxImage < 0 {
+		return opts, fmt.Errorf("invalid max_image %d", o.MaxImage)
+	}
+	if o.MaxImage > 0 {
+		opts.MaxImageSize = image.Pt(o.MaxImage, o.MaxImage)
+	}
+	if o.ConvertImages != "" {
+		format, err := converter.ParseImageFormat(o.ConvertImages)
+		if err != nil {
+			return opts, err
+		}
+		opts.ConvertImages = format
+	}
+	if o.ImageQuality < 0 || o.ImageQuality > 100 {
+		return opts, fmt.Errorf("invalid image_quality %d (want 1 to 100)", o.ImageQuality)
+	}
+	if o.ImageQuality > 0 {
+		opts.ImageQuality = o.ImageQuality
+	}
+	if o.Crawl < 0 || o.Crawl > maxServeCrawl {
+		return opts, fmt.Errorf("invalid crawl %d (want 0 to %d)", o.Crawl, maxServeCrawl)
+	}
+	if o.Crawl > 0 {
+		opts.Crawl = o.Crawl
+	}
+	return opts, nil
+}
+
+// serve runs an HTTP server on cfg.addr converting the pages POSTed to
+// /convert into EPUBs, built with base but for what each request sets, and
+// answering GET /healthz while it is up, until ctx is cancelled. A
+// conversion stops when its client goes away or the server does. Only the
+// EPUB is built: the files base asks for besides it are not written.
+// Requests without cfg.token, if set, are refused, as are those beyond
+// cfg.maxBuilds conversions at once.
+func serve(ctx context.Context, cfg serveConfig, base converter.Options) error {
+	srv := &http.Server{
+		Addr:              cfg.addr,
+		Handler:           serveMux(cfg, base),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("Listening", "addr", cfg.addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// serveMux returns the handler of the server serve runs.
+func serveMux(cfg serveConfig, base converter.Options) *http.ServeMux {
+	base.OutputPath, base.IndexPath, base.AccessibilityReport = "", "", ""
+	base.ChapterDir, base.DebugHTMLDir, base.ImageDir = "", "", ""
+	base.StatePath = "" // Builds for different clients mustn't resume one another
+	builds := make(chan struct{}, max(cfg.maxBuilds, 1))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("POST /convert", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, cfg.token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		select {
+		case builds <- struct{}{}:
+			defer func() { <-builds }()
+		default:
+			w.Header().Set("Retry-After", "10")
+			writeError(w, http.StatusServiceUnavailable, errors.New("too many conversions in progress"))
+			return
+		}
+		handleConvert(w, r, base)
+	})
+	return mux
+}
+
+// authorized reports whether r carries token as its bearer token, or token
+// is empty.
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// handleConvert builds the EPUB of the page the JSON body of r names and
+// writes it as the response.
+func handleConvert(w http.ResponseWriter, r *http.Request, base converter.Options) {
+	var req convertRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConvertRequest))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	opts, err := req.apply(base)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	c := converter.New(opts)
+	if err := c.FromURL(req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.BuildTo(r.Context(), &buf); err != nil {
+		if r.Context().Err() != nil {
+			return // The client went away
+		}
+		slog.Warn("Could not convert page", "url", req.URL, "err", err)
+		writeError(w, http.StatusBadGateway, fmt.Errorf("failed to convert '%s': %w", req.URL, err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": epubFilename(req.Title)}))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+	slog.Info("Converted page", "url", req.URL, "bytes", buf.Len())
+}
+
+// writeError answers a request with status and err as a JSON error.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// epubFilename returns the name to download the EPUB titled title as: its
+// letters and digits with dashes between words, or book.epub.
+func epubFilename(title string) string {
+	name := strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if name == "" {
+		name = "book"
+	}
+	return name + ".epub"
+}
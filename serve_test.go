@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"epub/pkg/converter"
+)
+
+func TestServeToken(t *testing.T) {
+	mux := serveMux(serveConfig{token: "s3cret", maxBuilds: 1}, converter.Options{})
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", "Basic s3cret", http.StatusUnauthorized},
+		{"right token", "Bearer s3cret", http.StatusBadRequest}, // Let through to the empty body
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/convert", strings.NewReader(""))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServeConvert(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<h3>One</h3><p>First.</p><h3>Two</h3><p>Second.</p>"))
+	}))
+	defer page.Close()
+
+	srv := httptest.NewServer(serveMux(serveConfig{maxBuilds: 1}, converter.Options{}))
+	defer srv.Close()
+	body := `{"url": "` + page.URL + `/book.html", "title": "My Book", "options": {"toc_page": true}}`
+	resp, err := http.Post(srv.URL+"/convert", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, data)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/epub+zip" {
+		t.Errorf("Content-Type = %q, want application/epub+zip", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, `filename=my-book.epub`) {
+		t.Errorf("Content-Disposition = %q, want my-book.epub", cd)
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("response isn't a zip: %v", err)
+	}
+	if len(r.File) == 0 || r.File[0].Name != "mimetype" {
+		t.Fatal("response doesn't start with the EPUB mimetype")
+	}
+	if problems, err := converter.ValidateEPUB(data); err != nil || len(problems) > 0 {
+		t.Errorf("served EPUB isn't valid: %v, %v", problems, err)
+	}
+	var sections int
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "EPUB/xhtml/") && f.Name != "EPUB/xhtml/cover.xhtml" {
+			sections++
+		}
+	}
+	if sections != 3 {
+		t.Errorf("EPUB has %d section files, want the contents page and two sections", sections)
+	}
+}
+
+func TestServeMaxBuilds(t *testing.T) {
+	fetching, release := make(chan struct{}, 1), make(chan struct{})
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case fetching <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.Write([]byte("<h1>Title</h1><p>Text.</p>"))
+	}))
+	defer page.Close()
+
+	srv := httptest.NewServer(serveMux(serveConfig{maxBuilds: 1}, converter.Options{}))
+	defer srv.Close()
+	body := `{"url": "` + page.URL + `/book.html"}`
+	first := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(srv.URL+"/convert", "application/json", strings.NewReader(body))
+		if err != nil {
+			first <- 0
+			return
+		}
+		resp.Body.Close()
+		first <- resp.StatusCode
+	}()
+	select {
+	case <-fetching: // The first conversion holds the only slot
+	case <-time.After(5 * time.Second):
+		t.Fatal("first conversion never fetched its page")
+	}
+
+	resp, err := http.Post(srv.URL+"/convert", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("second conversion got %d, want 503 while the first runs", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("refused conversion has no Retry-After")
+	}
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("first conversion got %d, want 200", code)
+	}
+}
+
+func TestServeRefusesPrivateAddresses(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<h1>Internal</h1><p>Secret.</p>"))
+	}))
+	defer page.Close()
+
+	mux := serveMux(serveConfig{maxBuilds: 1}, converter.Options{PublicOnly: true})
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader(`{"url": "`+page.URL+`/"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "not public") {
+		t.Errorf("status = %d, want 502 refusing the address: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServeIgnoresStatePath(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<h3>One</h3><p>Text.</p>"))
+	}))
+	defer page.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	mux := serveMux(serveConfig{maxBuilds: 1}, converter.Options{StatePath: statePath})
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader(`{"url": "`+page.URL+`/"}`))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(statePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("state file written by a served build: %v", err)
+	}
+}